@@ -1,15 +1,92 @@
 package go_webshop_course
 
-import "errors"
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"time"
+)
 
-var ErrBasketNotFound = errors.New("basket not found")
+var (
+	ErrBasketNotFound  = errors.New("basket not found")
+	ErrItemNotInBasket = errors.New("item not in basket")
+	ErrInvalidQuantity = errors.New("invalid quantity")
+)
+
+// MaxLineQuantity is the most items of one product a basket line can hold,
+// it keeps the totals far away from overflowing
+const MaxLineQuantity = 100
+
+// GuestBasketTTL is how long a guest keeps their basket, the guest cookie expires after it
+// and the baskets of guests that are older than this are deleted
+const GuestBasketTTL = 30 * 24 * time.Hour
 
 type Basket struct {
 	UserID int
 	Items  []BasketItem
+	// Coupon is the code of the coupon the customer entered, if any
+	Coupon string
 }
 
+// BasketItem is a product in the basket, VariantID is 0 for products without variants
 type BasketItem struct {
 	ProductID int
+	VariantID int
 	Quantity  int
 }
+
+// BasketView is a basket joined with its products and priced,
+// so clients don't have to look up every product themselves
+type BasketView struct {
+	UserID    int          `json:"user_id"`
+	Lines     []BasketLine `json:"lines"`
+	ItemCount int          `json:"item_count"`
+	Subtotal  Money        `json:"subtotal"`
+	Shipping  Money        `json:"shipping"`
+	// Coupon is the entered coupon code, CouponError tells why it doesn't give a discount (anymore)
+	Coupon      string            `json:"coupon,omitempty"`
+	CouponError string            `json:"coupon_error,omitempty"`
+	Discounts   []AppliedDiscount `json:"discounts"`
+	Discount    Money             `json:"discount"`
+	// Country decides the tax rates
+	Country string `json:"country"`
+	// PricesIncludeTax tells whether the prices and subtotal already contain the tax,
+	// if not, the tax is added on top to get the total
+	PricesIncludeTax bool      `json:"prices_include_tax"`
+	Taxes            []TaxLine `json:"taxes"`
+	Tax              Money     `json:"tax"`
+	Total            Money     `json:"total"`
+}
+
+type BasketLine struct {
+	ProductID int    `json:"product_id"`
+	VariantID int    `json:"variant_id,omitempty"`
+	SKU       string `json:"sku,omitempty"`
+	Name      string `json:"name"`
+	// Variant describes the chosen variant, like "Blue"
+	Variant     string   `json:"variant,omitempty"`
+	Image       string   `json:"img"`
+	UnitPrice   Money    `json:"unit_price"`
+	CategoryIDs []int    `json:"category_ids,omitempty"`
+	TaxClass    TaxClass `json:"tax_class"`
+	Quantity    int      `json:"quantity"`
+	LineTotal   Money    `json:"line_total"`
+	// Available is the number of items we can still sell, a quantity above it can't be checked out
+	Available   int         `json:"available"`
+	StockStatus StockStatus `json:"stock_status"`
+	// Discount is the part of the promotions that went to this line
+	Discount Money `json:"discount"`
+}
+
+// NewGuestID creates a basket owner ID for a shopper that's not logged in.
+// Guest IDs are always negative, so they never clash with user IDs.
+func NewGuestID() int {
+	var b [8]byte
+	_, _ = rand.Read(b[:])
+	return -int(binary.BigEndian.Uint64(b[:])>>2) - 1
+}
+
+// IsGuest reports whether the basket owner ID belongs to a guest
+func IsGuest(userID int) bool {
+	return userID < 0
+}
@@ -0,0 +1,198 @@
+package go_webshop_course
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+var (
+	ErrCategoryNotFound    = errors.New("category not found")
+	ErrCategoryHasChildren = errors.New("category still has subcategories")
+	ErrCollectionNotFound  = errors.New("collection not found")
+	ErrSlugExists          = errors.New("slug already in use")
+)
+
+// Category is a node in the category tree, top-level categories have no ParentID
+type Category struct {
+	ID          int    `json:"id"`
+	ParentID    int    `json:"parent_id,omitempty"`
+	Slug        string `json:"slug"`
+	Name        string `json:"name"`
+	Description string `json:"desc"`
+	// Position orders the categories that share a parent
+	Position int `json:"position"`
+}
+
+// CategoryNode is a category with its subcategories
+type CategoryNode struct {
+	Category
+	Children []CategoryNode `json:"children"`
+}
+
+// Collection is a hand-picked list of products, like "Staff picks"
+type Collection struct {
+	ID          int    `json:"id"`
+	Slug        string `json:"slug"`
+	Name        string `json:"name"`
+	Description string `json:"desc"`
+	// ProductIDs are the products of the collection in the order they're shown
+	ProductIDs []int `json:"product_ids"`
+}
+
+// CategoryPage is a category with its place in the tree and its products,
+// the products of its subcategories included
+type CategoryPage struct {
+	Category    Category   `json:"category"`
+	Breadcrumbs []Category `json:"breadcrumbs"`
+	Children    []Category `json:"children"`
+	Products    []Product  `json:"products"`
+}
+
+// CollectionPage is a collection with its products in the curated order
+type CollectionPage struct {
+	Collection Collection `json:"collection"`
+	Products   []Product  `json:"products"`
+}
+
+// Categories are all our categories, the tree is walked through their ParentIDs
+type Categories []Category
+
+func (cs Categories) Find(categoryID int) (Category, bool) {
+	i := slices.IndexFunc(cs, func(c Category) bool { return c.ID == categoryID })
+	if i < 0 {
+		return Category{}, false
+	}
+	return cs[i], true
+}
+
+// Children returns the direct subcategories, ordered by position and name.
+// A parentID of 0 gives the top-level categories.
+func (cs Categories) Children(parentID int) []Category {
+	var children []Category
+	for _, c := range cs {
+		if c.ParentID == parentID {
+			children = append(children, c)
+		}
+	}
+	slices.SortFunc(children, func(a, b Category) int {
+		return cmp.Or(cmp.Compare(a.Position, b.Position), cmp.Compare(a.Name, b.Name), cmp.Compare(a.ID, b.ID))
+	})
+	return children
+}
+
+// Tree arranges the categories from the top-level ones down
+func (cs Categories) Tree() []CategoryNode {
+	return cs.nodes(0, map[int]bool{})
+}
+
+func (cs Categories) nodes(parentID int, seen map[int]bool) []CategoryNode {
+	nodes := []CategoryNode{}
+	for _, c := range cs.Children(parentID) {
+		// a broken tree shouldn't send us round in circles
+		if seen[c.ID] {
+			continue
+		}
+		seen[c.ID] = true
+		nodes = append(nodes, CategoryNode{Category: c, Children: cs.nodes(c.ID, seen)})
+	}
+	return nodes
+}
+
+// Breadcrumbs is the path from the top of the tree down to the category, the category included
+func (cs Categories) Breadcrumbs(categoryID int) []Category {
+	var path []Category
+	seen := map[int]bool{}
+	for c, ok := cs.Find(categoryID); ok && !seen[c.ID]; c, ok = cs.Find(c.ParentID) {
+		seen[c.ID] = true
+		path = append(path, c)
+	}
+	slices.Reverse(path)
+	return path
+}
+
+// Descendants returns the IDs of the category and everything below it
+func (cs Categories) Descendants(categoryID int) []int {
+	ids := []int{categoryID}
+	for i := 0; i < len(ids); i++ {
+		for _, c := range cs {
+			if c.ParentID == ids[i] && !slices.Contains(ids, c.ID) {
+				ids = append(ids, c.ID)
+			}
+		}
+	}
+	return ids
+}
+
+// Validate checks a category on its own, whether its parent exists is up to the caller
+func (c Category) Validate() error {
+	fe := FieldErrors{}
+	switch {
+	case strings.TrimSpace(c.Name) == "":
+		fe.Add("name", "is required")
+	case utf8.RuneCountInString(c.Name) > maxNameLength:
+		fe.Add("name", "can't be longer than %d characters", maxNameLength)
+	}
+	validateSlug(fe, c.Slug)
+	switch {
+	case c.ParentID < 0:
+		fe.Add("parent_id", "can't be negative")
+	case c.ParentID != 0 && c.ParentID == c.ID:
+		fe.Add("parent_id", "a category can't be its own parent")
+	}
+	return fe.Err()
+}
+
+// Validate checks a collection on its own, whether its products exist is up to the caller
+func (c Collection) Validate() error {
+	fe := FieldErrors{}
+	switch {
+	case strings.TrimSpace(c.Name) == "":
+		fe.Add("name", "is required")
+	case utf8.RuneCountInString(c.Name) > maxNameLength:
+		fe.Add("name", "can't be longer than %d characters", maxNameLength)
+	}
+	validateSlug(fe, c.Slug)
+	for i, id := range c.ProductIDs {
+		field := fmt.Sprintf("product_ids[%d]", i)
+		switch {
+		case id <= 0:
+			fe.Add(field, "needs to be a product ID")
+		case slices.Index(c.ProductIDs, id) < i:
+			fe.Add(field, "product %d is listed twice", id)
+		}
+	}
+	return fe.Err()
+}
+
+func validateSlug(fe FieldErrors, slug string) {
+	switch {
+	case slug == "":
+		fe.Add("slug", "is required")
+	case slug != Slugify(slug):
+		fe.Add("slug", "can only contain lowercase letters, digits and dashes, like %q", Slugify(slug))
+	}
+}
+
+// Slugify turns a name into something to put in a URL, "Rust & Go plushies" becomes "rust-go-plushies"
+func Slugify(name string) string {
+	var b strings.Builder
+	dash := false
+	for _, r := range strings.ToLower(name) {
+		switch {
+		case r < unicode.MaxASCII && (unicode.IsLetter(r) || unicode.IsDigit(r)):
+			if dash && b.Len() > 0 {
+				b.WriteByte('-')
+			}
+			dash = false
+			b.WriteRune(r)
+		default:
+			dash = true
+		}
+	}
+	return b.String()
+}
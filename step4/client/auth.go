@@ -0,0 +1,111 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/gerbenjacobs/go-webshop-course/handler/apiv2"
+)
+
+// refreshMargin is how long before they expire the tokens are refreshed,
+// so a request doesn't arrive with a token that just expired
+const refreshMargin = 30 * time.Second
+
+// Login logs in as the user, the requests after it are on their behalf.
+// A basket the client had as a guest is added to the basket of the user.
+func (c *Client) Login(ctx context.Context, email, password string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.requestTokens(ctx, url.Values{
+		"grant_type": {"password"},
+		"email":      {email},
+		"password":   {password},
+	})
+}
+
+// Logout forgets the tokens, the requests after it are made as a guest
+func (c *Client) Logout() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.tokens = apiv2.TokenPair{}
+	c.expiresAt = time.Time{}
+}
+
+// Tokens are the tokens of the logged in user, they can be stored and handed to SetTokens later.
+// They change when the client refreshes them.
+func (c *Client) Tokens() apiv2.TokenPair {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.tokens
+}
+
+// SetTokens logs in with tokens from an earlier Login, they're refreshed when they run out
+func (c *Client) SetTokens(tokens apiv2.TokenPair) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.setTokens(tokens)
+}
+
+// accessToken is the token to send, it's refreshed when it's about to expire.
+// It's empty when the client isn't logged in.
+func (c *Client) accessToken(ctx context.Context) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.tokens.AccessToken == "" || time.Until(c.expiresAt) > refreshMargin {
+		return c.tokens.AccessToken, nil
+	}
+	if err := c.refresh(ctx); err != nil {
+		return "", err
+	}
+	return c.tokens.AccessToken, nil
+}
+
+// refreshAfter refreshes the tokens after the API turned down the access token. Another
+// request might have refreshed them in the meantime, then the new token is used as it is.
+func (c *Client) refreshAfter(ctx context.Context, rejected string) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.tokens.AccessToken != rejected {
+		return c.tokens.AccessToken, nil
+	}
+	if err := c.refresh(ctx); err != nil {
+		return "", err
+	}
+	return c.tokens.AccessToken, nil
+}
+
+// refresh swaps the refresh token for new tokens, the caller needs to hold the lock
+func (c *Client) refresh(ctx context.Context) error {
+	return c.requestTokens(ctx, url.Values{
+		"grant_type":    {"refresh_token"},
+		"refresh_token": {c.tokens.RefreshToken},
+	})
+}
+
+// requestTokens gets new tokens from the token endpoint, the caller needs to hold the lock
+func (c *Client) requestTokens(ctx context.Context, form url.Values) error {
+	req := formRequest(http.MethodPost, "/auth/token", form)
+	res, err := c.send(ctx, req, "")
+	if err != nil {
+		return err
+	}
+	var tokens apiv2.TokenPair
+	if _, err := decode(req, res, &tokens); err != nil {
+		return err
+	}
+	c.setTokens(tokens)
+	return nil
+}
+
+func (c *Client) setTokens(tokens apiv2.TokenPair) {
+	c.tokens = tokens
+	c.expiresAt = time.Now().Add(time.Duration(tokens.ExpiresIn) * time.Second)
+}
@@ -0,0 +1,90 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/gerbenjacobs/go-webshop-course/handler/apiv2"
+)
+
+// BasketItem is a product to add to the basket, VariantID is
+// left out for products without variants and Quantity defaults to 1
+type BasketItem struct {
+	ProductID int `json:"product_id"`
+	VariantID int `json:"variant_id,omitempty"`
+	Quantity  int `json:"quantity,omitempty"`
+}
+
+// Basket shows the basket with the taxes of the country, the shop's own country when it's empty.
+// Until Login the basket is that of a guest, kept in a cookie.
+func (c *Client) Basket(ctx context.Context, country string) (apiv2.BasketView, error) {
+	req := newRequest(http.MethodGet, "/basket")
+	if country != "" {
+		req.query = url.Values{"country": {country}}
+	}
+	var basket apiv2.BasketView
+	_, err := c.do(ctx, req, &basket)
+	return basket, err
+}
+
+func (c *Client) AddToBasket(ctx context.Context, item BasketItem) (apiv2.BasketView, error) {
+	req, err := jsonRequest(http.MethodPost, "/basket/items", item)
+	if err != nil {
+		return apiv2.BasketView{}, err
+	}
+	var basket apiv2.BasketView
+	_, err = c.do(ctx, req, &basket)
+	return basket, err
+}
+
+// SetBasketQuantity changes the quantity of an item in the basket, 0 removes it
+func (c *Client) SetBasketQuantity(ctx context.Context, productID, variantID, quantity int) (apiv2.BasketView, error) {
+	req, err := jsonRequest(http.MethodPatch, basketItemPath(productID), map[string]int{"quantity": quantity})
+	if err != nil {
+		return apiv2.BasketView{}, err
+	}
+	req.query = variantQuery(variantID)
+
+	var basket apiv2.BasketView
+	_, err = c.do(ctx, req, &basket)
+	return basket, err
+}
+
+func (c *Client) RemoveFromBasket(ctx context.Context, productID, variantID int) error {
+	req := newRequest(http.MethodDelete, basketItemPath(productID))
+	req.query = variantQuery(variantID)
+	_, err := c.do(ctx, req, nil)
+	return err
+}
+
+// ClearBasket removes all items and the coupon
+func (c *Client) ClearBasket(ctx context.Context) error {
+	_, err := c.do(ctx, newRequest(http.MethodDelete, "/basket"), nil)
+	return err
+}
+
+func (c *Client) ApplyCoupon(ctx context.Context, code string) (apiv2.BasketView, error) {
+	var basket apiv2.BasketView
+	_, err := c.do(ctx, formRequest(http.MethodPost, "/basket/coupon", url.Values{"code": {code}}), &basket)
+	return basket, err
+}
+
+func (c *Client) RemoveCoupon(ctx context.Context) error {
+	_, err := c.do(ctx, newRequest(http.MethodDelete, "/basket/coupon"), nil)
+	return err
+}
+
+func basketItemPath(productID int) string {
+	return fmt.Sprintf("/basket/items/%d", productID)
+}
+
+// variantQuery picks the variant of a basket item, products without variants don't have one
+func variantQuery(variantID int) url.Values {
+	if variantID == 0 {
+		return nil
+	}
+	return url.Values{"variant_id": {strconv.Itoa(variantID)}}
+}
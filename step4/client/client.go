@@ -0,0 +1,245 @@
+// Package client is a typed Go client for the API of the webshop, for our other
+// services that need to talk to the shop. It speaks v2 of the API, so it uses the
+// types of the apiv2 package, and the errors it returns mirror those of our domain.
+//
+//	c := client.New("https://shop.example.com", client.Options{})
+//	if err := c.Login(ctx, email, password); err != nil {
+//		return err
+//	}
+//	product, err := c.Product(ctx, 1)
+//	if errors.Is(err, app.ErrProductNotFound) {
+//		...
+//	}
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gerbenjacobs/go-webshop-course/handler/apiv2"
+)
+
+const (
+	apiPrefix = "/api/v2"
+	mediaType = "application/vnd.webshop.v2+json"
+)
+
+type Options struct {
+	// HTTPClient sends the requests, the default has a timeout of 10 seconds.
+	// The guest basket lives in a cookie, a client without a jar gets one.
+	HTTPClient *http.Client
+	// MaxRetries is how often a request is tried again after a network error or
+	// a 429, 502, 503 or 504. Only requests that are safe to repeat are retried.
+	// It defaults to 3, a negative number turns retries off.
+	MaxRetries int
+	// MinBackoff is the wait before the first retry, it doubles every retry up
+	// to MaxBackoff. A Retry-After header is honoured up to MaxBackoff as well.
+	// They default to 100ms and 5s.
+	MinBackoff time.Duration
+	MaxBackoff time.Duration
+}
+
+// Client is safe for concurrent use, a logged in client shares its tokens between requests
+type Client struct {
+	baseURL string
+	opts    Options
+	client  *http.Client
+
+	// mu guards the tokens, it's held during a refresh so only one request refreshes them
+	mu        sync.Mutex
+	tokens    apiv2.TokenPair
+	expiresAt time.Time
+}
+
+// New creates a client for the shop at baseURL, like https://shop.example.com
+func New(baseURL string, opts Options) *Client {
+	if opts.HTTPClient == nil {
+		opts.HTTPClient = &http.Client{Timeout: 10 * time.Second}
+	}
+	if opts.MaxRetries == 0 {
+		opts.MaxRetries = 3
+	}
+	if opts.MinBackoff == 0 {
+		opts.MinBackoff = 100 * time.Millisecond
+	}
+	if opts.MaxBackoff == 0 {
+		opts.MaxBackoff = 5 * time.Second
+	}
+
+	httpClient := opts.HTTPClient
+	if httpClient.Jar == nil {
+		// a copy, so we don't change the caller's client
+		withJar := *httpClient
+		withJar.Jar, _ = cookiejar.New(nil) // it never fails without options
+		httpClient = &withJar
+	}
+	return &Client{
+		baseURL: strings.TrimSuffix(baseURL, "/"),
+		opts:    opts,
+		client:  httpClient,
+	}
+}
+
+// request is a call to the API, its body is encoded up front so it can be sent again on a retry
+type request struct {
+	method      string
+	path        string
+	query       url.Values
+	body        []byte
+	contentType string
+}
+
+func newRequest(method, path string) request {
+	return request{method: method, path: path}
+}
+
+func jsonRequest(method, path string, v any) (request, error) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return request{}, fmt.Errorf("failed to encode %s %s body: %w", method, path, err)
+	}
+	return request{method: method, path: path, body: b, contentType: "application/json"}, nil
+}
+
+func formRequest(method, path string, form url.Values) request {
+	return request{method: method, path: path, body: []byte(form.Encode()), contentType: "application/x-www-form-urlencoded"}
+}
+
+// idempotent requests can be sent again without doing their work twice
+func (r request) idempotent() bool {
+	switch r.method {
+	case http.MethodGet, http.MethodHead, http.MethodPut, http.MethodDelete:
+		return true
+	}
+	return false
+}
+
+// do sends the request as the logged in user, or as a guest, and decodes the response into out.
+// When the access token is turned down, the tokens are refreshed and the request is sent once more.
+func (c *Client) do(ctx context.Context, req request, out any) (http.Header, error) {
+	token, err := c.accessToken(ctx)
+	if err != nil {
+		return nil, err
+	}
+	res, err := c.send(ctx, req, token)
+	if err != nil {
+		return nil, err
+	}
+	if res.StatusCode == http.StatusUnauthorized && token != "" {
+		discard(res)
+		if token, err = c.refreshAfter(ctx, token); err != nil {
+			return nil, err
+		}
+		if res, err = c.send(ctx, req, token); err != nil {
+			return nil, err
+		}
+	}
+	return decode(req, res, out)
+}
+
+// send sends the request, it retries when that's safe and might help
+func (c *Client) send(ctx context.Context, req request, token string) (*http.Response, error) {
+	for attempt := 0; ; attempt++ {
+		res, err := c.sendOnce(ctx, req, token)
+		if attempt >= c.opts.MaxRetries || !req.idempotent() || !retryable(res, err) || ctx.Err() != nil {
+			return res, err
+		}
+
+		wait := c.backoff(attempt, res)
+		if res != nil {
+			discard(res)
+		}
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+func (c *Client) sendOnce(ctx context.Context, req request, token string) (*http.Response, error) {
+	u := c.baseURL + apiPrefix + req.path
+	if len(req.query) > 0 {
+		u += "?" + req.query.Encode()
+	}
+	var body io.Reader
+	if req.body != nil {
+		body = bytes.NewReader(req.body)
+	}
+	r, err := http.NewRequestWithContext(ctx, req.method, u, body)
+	if err != nil {
+		return nil, err
+	}
+	r.Header.Set("Accept", mediaType)
+	if req.contentType != "" {
+		r.Header.Set("Content-Type", req.contentType)
+	}
+	if token != "" {
+		r.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	res, err := c.client.Do(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to reach the webshop: %w", err)
+	}
+	return res, nil
+}
+
+// retryable tells whether the same request could succeed later
+func retryable(res *http.Response, err error) bool {
+	if err != nil {
+		return true
+	}
+	switch res.StatusCode {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// backoff is the wait before the next attempt, with jitter so our clients don't all come back at once
+func (c *Client) backoff(attempt int, res *http.Response) time.Duration {
+	wait := min(c.opts.MinBackoff<<attempt, c.opts.MaxBackoff)
+	wait = wait/2 + rand.N(wait/2+1)
+	if res == nil {
+		return wait
+	}
+	if seconds, err := strconv.Atoi(res.Header.Get("Retry-After")); err == nil {
+		wait = max(wait, min(time.Duration(seconds)*time.Second, c.opts.MaxBackoff))
+	}
+	return wait
+}
+
+// decode reads the response into out, or into an Error when the API reports a problem
+func decode(req request, res *http.Response, out any) (http.Header, error) {
+	defer res.Body.Close()
+	if res.StatusCode >= 400 {
+		return nil, newError(res)
+	}
+	if out == nil || res.StatusCode == http.StatusNoContent {
+		return res.Header, nil
+	}
+	if err := json.NewDecoder(res.Body).Decode(out); err != nil {
+		return nil, fmt.Errorf("failed to decode %s %s response: %w", req.method, req.path, err)
+	}
+	return res.Header, nil
+}
+
+// discard lets go of a response we won't read, so its connection can be reused
+func discard(res *http.Response) {
+	_, _ = io.Copy(io.Discard, res.Body)
+	res.Body.Close()
+}
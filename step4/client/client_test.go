@@ -0,0 +1,19 @@
+package client_test
+
+import (
+	"testing"
+
+	"github.com/gerbenjacobs/go-webshop-course/client/clienttest"
+	"github.com/gerbenjacobs/go-webshop-course/handler/handlertest"
+)
+
+func TestClientEndToEnd(t *testing.T) {
+	customer := clienttest.User{Email: "customer@example.com", Password: "customer-password"}
+	admin := clienttest.User{Email: "admin@example.com", Password: "admin-password"}
+
+	h := handlertest.NewHandler(t,
+		handlertest.Account{Email: customer.Email, Password: customer.Password},
+		handlertest.Account{Email: admin.Email, Password: admin.Password, Admin: true},
+	)
+	clienttest.TestClient(t, h, customer, admin)
+}
@@ -0,0 +1,414 @@
+// Package clienttest contains the end-to-end tests of the client,
+// they run it against a real handler in a TLS httptest.Server.
+package clienttest
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	app "github.com/gerbenjacobs/go-webshop-course"
+	"github.com/gerbenjacobs/go-webshop-course/client"
+	"github.com/gerbenjacobs/go-webshop-course/handler"
+	"github.com/gerbenjacobs/go-webshop-course/handler/apiv2"
+	"github.com/gerbenjacobs/go-webshop-course/payment"
+)
+
+// User is an account the suite logs in with
+type User struct {
+	Email    string
+	Password string
+}
+
+// TestClient runs the client suite against the handler. It needs the seed data
+// of our storage, a customer and an admin, and payments by the fake provider.
+// The subtests share the handler, they add orders and products to it.
+//
+//	func TestClientEndToEnd(t *testing.T) {
+//		h := handlertest.NewHandler(t,
+//			handlertest.Account{Email: customer.Email, Password: customer.Password},
+//			handlertest.Account{Email: admin.Email, Password: admin.Password, Admin: true},
+//		)
+//		clienttest.TestClient(t, h, customer, admin)
+//	}
+func TestClient(t *testing.T, h *handler.Handler, customer, admin User) {
+	ctx := context.Background()
+	srv := newServer(t, h)
+
+	t.Run("products are listed page by page", func(t *testing.T) {
+		c := newClient(srv)
+		var ids []int
+		query := app.ProductQuery{Limit: 1}
+		for {
+			page, err := c.Products(ctx, query)
+			if err != nil {
+				t.Fatalf("Products: %v", err)
+			}
+			if len(page.Products) != 1 {
+				t.Fatalf("len(Products) = %d, want 1", len(page.Products))
+			}
+			ids = append(ids, page.Products[0].ID)
+			if page.Total < len(ids) {
+				t.Fatalf("Total = %d, but we've seen %d products", page.Total, len(ids))
+			}
+			if page.NextCursor == "" {
+				break
+			}
+			query.Cursor = page.NextCursor
+		}
+
+		all, err := c.Products(ctx, app.ProductQuery{Limit: 100})
+		if err != nil {
+			t.Fatalf("Products: %v", err)
+		}
+		if len(all.Products) != len(ids) || all.Total != len(ids) {
+			t.Errorf("one page has %d of %d products, page by page there are %d", len(all.Products), all.Total, len(ids))
+		}
+
+		product, err := c.Product(ctx, ids[0])
+		if err != nil {
+			t.Fatalf("Product: %v", err)
+		}
+		if product.ID != ids[0] || product.Price.Amount == "" {
+			t.Errorf("Product = %+v, want product %d with a price", product, ids[0])
+		}
+	})
+
+	t.Run("products can be searched", func(t *testing.T) {
+		c := newClient(srv)
+		results, err := c.SearchProducts(ctx, "gopher")
+		if err != nil {
+			t.Fatalf("SearchProducts: %v", err)
+		}
+		if len(results) == 0 || results[0].Product.ID != 1 {
+			t.Errorf("SearchProducts = %+v, want the Gopher plushie first", results)
+		}
+	})
+
+	t.Run("errors mirror the domain errors", func(t *testing.T) {
+		c := newClient(srv)
+		_, err := c.Product(ctx, 999)
+		if !errors.Is(err, app.ErrProductNotFound) {
+			t.Errorf("Product error = %v, want %v", err, app.ErrProductNotFound)
+		}
+		var apiErr *client.Error
+		if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusNotFound || apiErr.Code != "product_not_found" {
+			t.Errorf("Product error = %#v, want a 404 product_not_found", err)
+		}
+
+		_, err = c.AddToBasket(ctx, client.BasketItem{ProductID: 1, Quantity: -1})
+		var fields app.FieldErrors
+		if !errors.Is(err, app.ErrInvalidInput) || !errors.As(err, &fields) || fields["quantity"] == "" {
+			t.Errorf("AddToBasket error = %v, want invalid input of the quantity", err)
+		}
+
+		if _, err := c.Orders(ctx); !errors.Is(err, client.ErrUnauthorized) {
+			t.Errorf("Orders error = %v, want %v", err, client.ErrUnauthorized)
+		}
+		if err := c.Login(ctx, customer.Email, "not-the-password"); !errors.Is(err, app.ErrInvalidCredentials) {
+			t.Errorf("Login error = %v, want %v", err, app.ErrInvalidCredentials)
+		}
+	})
+
+	t.Run("guests have a basket", func(t *testing.T) {
+		c := newClient(srv)
+		if _, err := c.AddToBasket(ctx, client.BasketItem{ProductID: 1, Quantity: 2}); err != nil {
+			t.Fatalf("AddToBasket: %v", err)
+		}
+		if _, err := c.AddToBasket(ctx, client.BasketItem{ProductID: 2}); !errors.Is(err, app.ErrVariantRequired) {
+			t.Errorf("AddToBasket error = %v, want %v", err, app.ErrVariantRequired)
+		}
+		if _, err := c.AddToBasket(ctx, client.BasketItem{ProductID: 2, VariantID: 2}); err != nil {
+			t.Fatalf("AddToBasket: %v", err)
+		}
+		basket, err := c.SetBasketQuantity(ctx, 1, 0, 3)
+		if err != nil {
+			t.Fatalf("SetBasketQuantity: %v", err)
+		}
+		assertLines(t, basket, map[[2]int]int{{1, 0}: 3, {2, 2}: 1})
+
+		if err := c.RemoveFromBasket(ctx, 2, 2); err != nil {
+			t.Fatalf("RemoveFromBasket: %v", err)
+		}
+		basket, err = c.Basket(ctx, "")
+		if err != nil {
+			t.Fatalf("Basket: %v", err)
+		}
+		assertLines(t, basket, map[[2]int]int{{1, 0}: 3})
+
+		if err := c.ClearBasket(ctx); err != nil {
+			t.Fatalf("ClearBasket: %v", err)
+		}
+		if err := c.RemoveFromBasket(ctx, 1, 0); !errors.Is(err, app.ErrItemNotInBasket) {
+			t.Errorf("RemoveFromBasket error = %v, want %v", err, app.ErrItemNotInBasket)
+		}
+	})
+
+	t.Run("customers check out and pay", func(t *testing.T) {
+		c := newClient(srv)
+		if _, err := c.AddToBasket(ctx, client.BasketItem{ProductID: 1}); err != nil {
+			t.Fatalf("AddToBasket: %v", err)
+		}
+		if err := c.Login(ctx, customer.Email, customer.Password); err != nil {
+			t.Fatalf("Login: %v", err)
+		}
+		// the basket of the guest comes along
+		basket, err := c.Basket(ctx, "")
+		if err != nil {
+			t.Fatalf("Basket: %v", err)
+		}
+		if basket.ItemCount == 0 {
+			t.Fatal("the basket is empty after logging in")
+		}
+
+		order, err := c.Checkout(ctx, "NL")
+		if err != nil {
+			t.Fatalf("Checkout: %v", err)
+		}
+		got, err := c.Order(ctx, order.ID)
+		if err != nil {
+			t.Fatalf("Order: %v", err)
+		}
+		if got.ID != order.ID || got.Total != order.Total {
+			t.Errorf("Order = %+v, want %+v", got, order)
+		}
+		orders, err := c.Orders(ctx)
+		if err != nil {
+			t.Fatalf("Orders: %v", err)
+		}
+		if !slices.ContainsFunc(orders, func(o apiv2.Order) bool { return o.ID == order.ID }) {
+			t.Errorf("Orders = %+v, want order %d in it", orders, order.ID)
+		}
+
+		auth, err := c.PayOrder(ctx, order.ID, payment.FakeMethodSuccess, "")
+		if err != nil {
+			t.Fatalf("PayOrder: %v", err)
+		}
+		if auth.Status != string(payment.StatusAuthorized) {
+			t.Errorf("Status = %q, want %q", auth.Status, payment.StatusAuthorized)
+		}
+		if _, err := c.PayOrder(ctx, order.ID, payment.FakeMethodSuccess, ""); !errors.Is(err, app.ErrOrderNotPayable) {
+			t.Errorf("PayOrder error = %v, want %v", err, app.ErrOrderNotPayable)
+		}
+
+		c.Logout()
+		if _, err := c.Order(ctx, order.ID); !errors.Is(err, client.ErrUnauthorized) {
+			t.Errorf("Order error after Logout = %v, want %v", err, client.ErrUnauthorized)
+		}
+	})
+
+	t.Run("tokens are refreshed", func(t *testing.T) {
+		c := newClient(srv)
+		if err := c.Login(ctx, customer.Email, customer.Password); err != nil {
+			t.Fatalf("Login: %v", err)
+		}
+		tokens := c.Tokens()
+
+		// a token the API turns down
+		rejected := newClient(srv)
+		rejected.SetTokens(apiv2.TokenPair{AccessToken: "not-a-token", RefreshToken: tokens.RefreshToken, ExpiresIn: 3600})
+		if _, err := rejected.Orders(ctx); err != nil {
+			t.Errorf("Orders with a rejected token: %v", err)
+		}
+		if rejected.Tokens().AccessToken == "not-a-token" {
+			t.Error("the rejected token wasn't replaced")
+		}
+
+		// a token that's about to expire
+		before := srv.tokenRequests()
+		expiring := newClient(srv)
+		expiring.SetTokens(apiv2.TokenPair{AccessToken: tokens.AccessToken, RefreshToken: tokens.RefreshToken})
+		if _, err := expiring.Orders(ctx); err != nil {
+			t.Errorf("Orders with an expiring token: %v", err)
+		}
+		if after := srv.tokenRequests(); after != before+1 {
+			t.Errorf("%d token requests for an expiring token, want 1", after-before)
+		}
+
+		invalid := newClient(srv)
+		invalid.SetTokens(apiv2.TokenPair{AccessToken: "not-a-token", RefreshToken: "not-a-token", ExpiresIn: 3600})
+		if _, err := invalid.Orders(ctx); !errors.Is(err, app.ErrInvalidToken) {
+			t.Errorf("Orders error = %v, want %v", err, app.ErrInvalidToken)
+		}
+	})
+
+	t.Run("admins manage products", func(t *testing.T) {
+		c := newClient(srv)
+		if err := c.Login(ctx, admin.Email, admin.Password); err != nil {
+			t.Fatalf("Login: %v", err)
+		}
+		product, err := c.CreateProduct(ctx, apiv2.ProductInput{
+			Name:     "Rust crab plushie",
+			Price:    apiv2.Money{Amount: "15.99", Currency: "EUR"},
+			TaxClass: string(app.TaxClassStandard),
+			Stock:    apiv2.StockInput{OnHand: 20},
+		})
+		if err != nil {
+			t.Fatalf("CreateProduct: %v", err)
+		}
+		if product.Price.Amount != "15.99" || product.Stock.OnHand != 20 {
+			t.Errorf("CreateProduct = %+v, want a price of 15.99 and 20 on hand", product)
+		}
+
+		product, err = c.PatchProduct(ctx, product.ID, apiv2.ProductPatch{Stock: &apiv2.StockInput{OnHand: 5}})
+		if err != nil {
+			t.Fatalf("PatchProduct: %v", err)
+		}
+		if product.Stock.OnHand != 5 || product.Name != "Rust crab plushie" {
+			t.Errorf("PatchProduct = %+v, want 5 on hand and the rest unchanged", product)
+		}
+
+		if err := c.DeleteProduct(ctx, product.ID); err != nil {
+			t.Fatalf("DeleteProduct: %v", err)
+		}
+		if _, err := c.Product(ctx, product.ID); !errors.Is(err, app.ErrProductNotFound) {
+			t.Errorf("Product error after DeleteProduct = %v, want %v", err, app.ErrProductNotFound)
+		}
+
+		customerClient := newClient(srv)
+		if err := customerClient.Login(ctx, customer.Email, customer.Password); err != nil {
+			t.Fatalf("Login: %v", err)
+		}
+		if err := customerClient.DeleteProduct(ctx, 1); !errors.Is(err, client.ErrForbidden) {
+			t.Errorf("DeleteProduct error as a customer = %v, want %v", err, client.ErrForbidden)
+		}
+	})
+
+	t.Run("requests that are safe to repeat are retried", func(t *testing.T) {
+		c := newClient(srv)
+		srv.fail(2, http.StatusServiceUnavailable)
+		if _, err := c.Product(ctx, 1); err != nil {
+			t.Errorf("Product after two failures: %v", err)
+		}
+
+		srv.fail(1, http.StatusTooManyRequests)
+		if _, err := c.Basket(ctx, ""); err != nil {
+			t.Errorf("Basket after a 429: %v", err)
+		}
+
+		srv.fail(1, http.StatusServiceUnavailable)
+		_, err := c.AddToBasket(ctx, client.BasketItem{ProductID: 1})
+		if !errors.Is(err, client.ErrServer) {
+			t.Errorf("AddToBasket error = %v, want %v", err, client.ErrServer)
+		}
+		if left := srv.fail(0, 0); left != 0 {
+			t.Errorf("a POST was retried")
+		}
+
+		srv.fail(10, http.StatusBadGateway)
+		if _, err := c.Product(ctx, 1); !errors.Is(err, client.ErrServer) {
+			t.Errorf("Product error = %v, want %v after the retries ran out", err, client.ErrServer)
+		}
+		srv.fail(0, 0)
+
+		noRetries := client.New(srv.URL, client.Options{HTTPClient: srv.Client(), MaxRetries: -1})
+		srv.fail(1, http.StatusServiceUnavailable)
+		if _, err := noRetries.Product(ctx, 1); !errors.Is(err, client.ErrServer) {
+			t.Errorf("Product error = %v, want %v without retries", err, client.ErrServer)
+		}
+	})
+
+	t.Run("requests stop with their context", func(t *testing.T) {
+		canceled, cancel := context.WithCancel(ctx)
+		cancel()
+		if _, err := newClient(srv).Product(canceled, 1); !errors.Is(err, context.Canceled) {
+			t.Errorf("Product error = %v, want %v", err, context.Canceled)
+		}
+
+		// the context runs out while waiting for a retry
+		c := client.New(srv.URL, client.Options{HTTPClient: srv.Client(), MinBackoff: time.Minute, MaxBackoff: time.Minute})
+		srv.fail(1, http.StatusServiceUnavailable)
+		short, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+		defer cancel()
+		start := time.Now()
+		if _, err := c.Product(short, 1); !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("Product error = %v, want %v", err, context.DeadlineExceeded)
+		}
+		if waited := time.Since(start); waited > 10*time.Second {
+			t.Errorf("Product took %s, it didn't stop with its context", waited)
+		}
+		srv.fail(0, 0)
+	})
+}
+
+func newClient(srv *server) *client.Client {
+	return client.New(srv.URL, client.Options{HTTPClient: srv.Client(), MinBackoff: time.Millisecond, MaxBackoff: 10 * time.Millisecond})
+}
+
+// server serves the handler over TLS, the session cookies are only sent over HTTPS.
+// It can fail requests on purpose and counts the requests for tokens.
+type server struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	failures int
+	status   int
+	tokens   int
+}
+
+func newServer(t *testing.T, h http.Handler) *server {
+	srv := &server{}
+	srv.Server = httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		srv.mu.Lock()
+		if r.URL.Path == "/api/v2/auth/token" {
+			srv.tokens++
+		}
+		fail := srv.failures > 0
+		if fail {
+			srv.failures--
+		}
+		status := srv.status
+		srv.mu.Unlock()
+
+		if fail {
+			// like a proxy in front of us would answer
+			w.Header().Set("Retry-After", "0")
+			http.Error(w, http.StatusText(status), status)
+			return
+		}
+		h.ServeHTTP(w, r)
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// fail makes the next requests fail with the status, it returns how many failures were left
+func (s *server) fail(requests, status int) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	left := s.failures
+	s.failures, s.status = requests, status
+	return left
+}
+
+func (s *server) tokenRequests() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.tokens
+}
+
+// assertLines checks the quantities in the basket, by product and variant ID
+func assertLines(t *testing.T, basket apiv2.BasketView, want map[[2]int]int) {
+	t.Helper()
+	got := make(map[[2]int]int)
+	for _, line := range basket.Lines {
+		got[[2]int{line.ProductID, line.VariantID}] = line.Quantity
+	}
+	if len(got) != len(want) {
+		t.Errorf("lines = %v, want %v", got, want)
+		return
+	}
+	for k, q := range want {
+		if got[k] != q {
+			t.Errorf("lines = %v, want %v", got, want)
+			return
+		}
+	}
+}
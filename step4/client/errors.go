@@ -0,0 +1,130 @@
+package client
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+
+	app "github.com/gerbenjacobs/go-webshop-course"
+	"github.com/gerbenjacobs/go-webshop-course/payment"
+	"github.com/gerbenjacobs/go-webshop-course/tax"
+)
+
+// The problems that aren't caused by our domain, they can happen on every endpoint
+var (
+	ErrInvalidRequest = errors.New("invalid request")
+	ErrUnauthorized   = errors.New("unauthorized")
+	ErrForbidden      = errors.New("forbidden")
+	ErrNotFound       = errors.New("not found")
+	ErrServer         = errors.New("server error")
+)
+
+// codeErrors turns the stable codes of the API's problems back into our errors,
+// it mirrors domainProblems of the handler
+var codeErrors = map[string]error{
+	"invalid_request":       ErrInvalidRequest,
+	"unauthorized":          ErrUnauthorized,
+	"forbidden":             ErrForbidden,
+	"not_found":             ErrNotFound,
+	"internal_error":        ErrServer,
+	"invalid_input":         app.ErrInvalidInput,
+	"invalid_money":         app.ErrInvalidMoney,
+	"invalid_quantity":      app.ErrInvalidQuantity,
+	"product_not_found":     app.ErrProductNotFound,
+	"variant_not_found":     app.ErrVariantNotFound,
+	"variant_required":      app.ErrVariantRequired,
+	"sku_exists":            app.ErrSKUExists,
+	"insufficient_stock":    app.ErrInsufficientStock,
+	"category_not_found":    app.ErrCategoryNotFound,
+	"category_has_children": app.ErrCategoryHasChildren,
+	"collection_not_found":  app.ErrCollectionNotFound,
+	"slug_exists":           app.ErrSlugExists,
+	"basket_not_found":      app.ErrBasketNotFound,
+	"item_not_in_basket":    app.ErrItemNotInBasket,
+	"empty_basket":          app.ErrEmptyBasket,
+	"basket_changed":        app.ErrBasketChanged,
+	"invalid_total":         app.ErrInvalidTotal,
+	"coupon_not_found":      app.ErrPromotionNotFound,
+	"coupon_not_active":     app.ErrPromotionNotActive,
+	"coupon_used_up":        app.ErrPromotionUsedUp,
+	"coupon_not_applicable": app.ErrPromotionNotApplicable,
+	"order_not_found":       app.ErrOrderNotFound,
+	"order_not_payable":     app.ErrOrderNotPayable,
+	"order_not_refundable":  app.ErrOrderNotRefundable,
+	"order_changed":         app.ErrOrderChanged,
+	"payment_declined":      payment.ErrDeclined,
+	"unknown_country":       tax.ErrUnknownCountry,
+	"user_not_found":        app.ErrUserNotFound,
+	"user_exists":           app.ErrUserExists,
+	"invalid_email":         app.ErrInvalidEmail,
+	"password_too_short":    app.ErrPasswordTooShort,
+	"invalid_credentials":   app.ErrInvalidCredentials,
+	"invalid_token":         app.ErrInvalidToken,
+}
+
+// Error is a problem the API reported. It unwraps to the error of its code, so
+// errors.Is(err, app.ErrProductNotFound) works, and to the field errors when
+// the input isn't valid.
+type Error struct {
+	StatusCode int             `json:"status"`
+	Code       string          `json:"code"`
+	Title      string          `json:"title"`
+	Detail     string          `json:"detail"`
+	Instance   string          `json:"instance"`
+	Fields     app.FieldErrors `json:"fields"`
+}
+
+func (e *Error) Error() string {
+	msg := e.Detail
+	if msg == "" {
+		msg = e.Title
+	}
+	if e.Code == "" {
+		return fmt.Sprintf("webshop API: %d: %s", e.StatusCode, msg)
+	}
+	return fmt.Sprintf("webshop API: %d %s: %s", e.StatusCode, e.Code, msg)
+}
+
+func (e *Error) Unwrap() []error {
+	var errs []error
+	if err, ok := codeErrors[e.Code]; ok {
+		errs = append(errs, err)
+	} else if err, ok := statusErrors[e.StatusCode]; ok {
+		errs = append(errs, err)
+	} else if e.StatusCode >= 500 {
+		errs = append(errs, ErrServer)
+	}
+	if len(e.Fields) > 0 {
+		errs = append(errs, e.Fields)
+	}
+	return errs
+}
+
+// statusErrors are for the answers without a code we know, like those of a proxy in between
+var statusErrors = map[int]error{
+	http.StatusBadRequest:   ErrInvalidRequest,
+	http.StatusUnauthorized: ErrUnauthorized,
+	http.StatusForbidden:    ErrForbidden,
+	http.StatusNotFound:     ErrNotFound,
+}
+
+// newError reads the problem details of the response, answers that
+// don't have them only get the status
+func newError(res *http.Response) error {
+	e := &Error{}
+	if contentType, _, _ := mime.ParseMediaType(res.Header.Get("Content-Type")); contentType == "application/problem+json" {
+		if err := json.NewDecoder(res.Body).Decode(e); err != nil {
+			return fmt.Errorf("failed to decode problem of %d response: %w", res.StatusCode, err)
+		}
+	} else {
+		_, _ = io.Copy(io.Discard, res.Body)
+	}
+	e.StatusCode = res.StatusCode
+	if e.Title == "" {
+		e.Title = http.StatusText(res.StatusCode)
+	}
+	return e
+}
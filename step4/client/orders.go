@@ -0,0 +1,47 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"github.com/gerbenjacobs/go-webshop-course/handler/apiv2"
+)
+
+// Checkout turns the basket into an order, with the taxes of the country.
+// The stock is held until the order is paid or its reservation runs out.
+func (c *Client) Checkout(ctx context.Context, country string) (apiv2.Order, error) {
+	form := url.Values{}
+	if country != "" {
+		form.Set("country", country)
+	}
+	var order apiv2.Order
+	_, err := c.do(ctx, formRequest(http.MethodPost, "/checkout", form), &order)
+	return order, err
+}
+
+func (c *Client) Orders(ctx context.Context) ([]apiv2.Order, error) {
+	var orders []apiv2.Order
+	_, err := c.do(ctx, newRequest(http.MethodGet, "/orders"), &orders)
+	return orders, err
+}
+
+func (c *Client) Order(ctx context.Context, orderID int) (apiv2.Order, error) {
+	var order apiv2.Order
+	_, err := c.do(ctx, newRequest(http.MethodGet, fmt.Sprintf("/orders/%d", orderID)), &order)
+	return order, err
+}
+
+// PayOrder starts the payment of an order with the payment method. When the
+// authorization requires action, the customer needs to visit its RedirectURL
+// and is sent to returnURL afterwards.
+func (c *Client) PayOrder(ctx context.Context, orderID int, method, returnURL string) (apiv2.PaymentAuthorization, error) {
+	form := url.Values{"method": {method}}
+	if returnURL != "" {
+		form.Set("return_url", returnURL)
+	}
+	var auth apiv2.PaymentAuthorization
+	_, err := c.do(ctx, formRequest(http.MethodPost, fmt.Sprintf("/orders/%d/pay", orderID), form), &auth)
+	return auth, err
+}
@@ -0,0 +1,131 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	app "github.com/gerbenjacobs/go-webshop-course"
+	"github.com/gerbenjacobs/go-webshop-course/handler/apiv2"
+)
+
+// ProductPage is one page of a product listing, pass NextCursor or PrevCursor
+// in the query to get the page after or before it
+type ProductPage struct {
+	Products []apiv2.Product
+	// Total is the number of products that match the filters, on all pages together
+	Total int
+	// NextCursor and PrevCursor are empty when there's no page after or before this one
+	NextCursor string
+	PrevCursor string
+}
+
+// Products lists the products, the API pages them even when the query has no limit
+func (c *Client) Products(ctx context.Context, query app.ProductQuery) (ProductPage, error) {
+	req := newRequest(http.MethodGet, "/products")
+	req.query = productQuery(query)
+
+	var page ProductPage
+	header, err := c.do(ctx, req, &page.Products)
+	if err != nil {
+		return ProductPage{}, err
+	}
+	page.Total, _ = strconv.Atoi(header.Get("X-Total-Count"))
+	page.NextCursor = linkCursor(header, "next")
+	page.PrevCursor = linkCursor(header, "prev")
+	return page, nil
+}
+
+// SearchProducts finds the products that match the query, the most relevant first
+func (c *Client) SearchProducts(ctx context.Context, query string) ([]apiv2.SearchResult, error) {
+	req := newRequest(http.MethodGet, "/products")
+	req.query = url.Values{"q": {query}}
+
+	var results []apiv2.SearchResult
+	_, err := c.do(ctx, req, &results)
+	return results, err
+}
+
+func (c *Client) Product(ctx context.Context, productID int) (apiv2.Product, error) {
+	var product apiv2.Product
+	_, err := c.do(ctx, newRequest(http.MethodGet, fmt.Sprintf("/products/%d", productID)), &product)
+	return product, err
+}
+
+// CreateProduct adds a product to the shop, it needs an admin
+func (c *Client) CreateProduct(ctx context.Context, product apiv2.ProductInput) (apiv2.Product, error) {
+	return c.sendProduct(ctx, http.MethodPost, "/admin/products", product)
+}
+
+// UpdateProduct replaces a product, it needs an admin
+func (c *Client) UpdateProduct(ctx context.Context, productID int, product apiv2.ProductInput) (apiv2.Product, error) {
+	return c.sendProduct(ctx, http.MethodPut, fmt.Sprintf("/admin/products/%d", productID), product)
+}
+
+// PatchProduct changes the fields of a product that are set in the patch, it needs an admin
+func (c *Client) PatchProduct(ctx context.Context, productID int, patch apiv2.ProductPatch) (apiv2.Product, error) {
+	return c.sendProduct(ctx, http.MethodPatch, fmt.Sprintf("/admin/products/%d", productID), patch)
+}
+
+// DeleteProduct removes a product from the shop, it needs an admin
+func (c *Client) DeleteProduct(ctx context.Context, productID int) error {
+	_, err := c.do(ctx, newRequest(http.MethodDelete, fmt.Sprintf("/admin/products/%d", productID)), nil)
+	return err
+}
+
+func (c *Client) sendProduct(ctx context.Context, method, path string, body any) (apiv2.Product, error) {
+	req, err := jsonRequest(method, path, body)
+	if err != nil {
+		return apiv2.Product{}, err
+	}
+	var product apiv2.Product
+	_, err = c.do(ctx, req, &product)
+	return product, err
+}
+
+// productQuery turns the query into the parameters of the listing, the zero values are left out
+func productQuery(q app.ProductQuery) url.Values {
+	values := url.Values{}
+	if q.Sort != "" {
+		values.Set("sort", string(q.Sort))
+	}
+	if q.MinPrice != nil {
+		values.Set("min_price", q.MinPrice.Decimal())
+	}
+	if q.MaxPrice != nil {
+		values.Set("max_price", q.MaxPrice.Decimal())
+	}
+	if q.Category != "" {
+		values.Set("category", q.Category)
+	}
+	if q.InStock {
+		values.Set("in_stock", "true")
+	}
+	if q.Cursor != "" {
+		values.Set("cursor", q.Cursor)
+	}
+	if q.Limit > 0 {
+		values.Set("limit", strconv.Itoa(q.Limit))
+	}
+	return values
+}
+
+// linkCursor finds the cursor of the page with the relation in the Link header,
+// like </api/v2/products?cursor=abc>; rel="next"
+func linkCursor(header http.Header, rel string) string {
+	for _, link := range strings.Split(header.Get("Link"), ",") {
+		target, params, ok := strings.Cut(strings.TrimSpace(link), ";")
+		if !ok || strings.TrimSpace(params) != fmt.Sprintf("rel=%q", rel) {
+			continue
+		}
+		u, err := url.Parse(strings.Trim(target, "<>"))
+		if err != nil {
+			return ""
+		}
+		return u.Query().Get("cursor")
+	}
+	return ""
+}
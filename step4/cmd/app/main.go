@@ -3,22 +3,50 @@ package main
 import (
 	"context"
 	"errors"
+	"flag"
 	"log/slog"
 	"net/http"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
 	"time"
 
+	app "github.com/gerbenjacobs/go-webshop-course"
 	"github.com/gerbenjacobs/go-webshop-course/handler"
+	"github.com/gerbenjacobs/go-webshop-course/payment"
+	"github.com/gerbenjacobs/go-webshop-course/search"
 	"github.com/gerbenjacobs/go-webshop-course/services"
 	"github.com/gerbenjacobs/go-webshop-course/storage"
+	"github.com/gerbenjacobs/go-webshop-course/tax"
 	"github.com/lmittmann/tint"
 )
 
-var address = "localhost:8000"
+var (
+	address     = "localhost:8000"
+	storageType = flag.String("storage", "memory", "storage backend to use: memory or sqlite")
+	dsn         = flag.String("db", "webshop.db", "path to the SQLite database, used when -storage=sqlite")
+	shipping    = flag.String("shipping", "4.95", "flat shipping fee per order")
+	taxFile     = flag.String("tax", "tax.json", "path to the tax rates config")
+	admins      = flag.String("admins", "", "comma-separated email addresses of the existing accounts that get the admin role at startup")
+	paymentsURL = flag.String("payments", "", "URL of a running cmd/fakepay, when empty the fake payment provider runs in-process under /fakepay")
+	// without SESSION_KEYS we run with the development keys, most likely on http://localhost
+	secureCookies = flag.Bool("secure-cookies", os.Getenv("SESSION_KEYS") != "", "only send cookies over HTTPS, on by default when SESSION_KEYS is set")
+)
+
+// devSigningKey is only meant for local development, set JWT_KEYS in any other environment
+const devSigningKey = "dev:this-really-should-be-a-secure-token-thats-not-stored-in-code"
+
+// devSessionKeys is only meant for local development, set SESSION_KEYS in any other environment.
+// Everyone can read it here, so with it anyone can forge a session of any user.
+const devSessionKeys = "dev-session-auth-key-that-everyone-can-read:dev-session-encryption-key-32byt"
+
+// devWebhookSecret is only meant for local development, set PAYMENT_WEBHOOK_SECRET in any other environment
+const devWebhookSecret = "dev-webhook-secret"
 
 func main() {
+	flag.Parse()
+
 	// handle shutdown signals
 	shutdown := make(chan os.Signal, 3)
 	signal.Notify(shutdown, syscall.SIGINT, syscall.SIGTERM)
@@ -30,17 +58,165 @@ func main() {
 	}
 	logger := slog.New(tint.NewHandler(output, tintOpt))
 
+	// create our storages
+	var (
+		productRepo    storage.ProductRepository
+		basketRepo     storage.BasketRepository
+		userRepo       storage.UserRepository
+		orderRepo      storage.OrderRepository
+		promoRepo      storage.PromotionRepository
+		categoryRepo   storage.CategoryRepository
+		collectionRepo storage.CollectionRepository
+	)
+	switch *storageType {
+	case "memory":
+		memoryBaskets := storage.NewBasketRepo()
+		memoryProducts := storage.NewProductRepo()
+		memoryPromotions := storage.NewPromotionRepo()
+		productRepo = memoryProducts
+		basketRepo = memoryBaskets
+		userRepo = storage.NewUserRepo()
+		orderRepo = storage.NewOrderRepo(memoryBaskets, memoryProducts, memoryPromotions)
+		promoRepo = memoryPromotions
+		categoryRepo = storage.NewCategoryRepo(memoryProducts)
+		collectionRepo = storage.NewCollectionRepo()
+	case "sqlite":
+		db, err := storage.OpenSQLite(context.Background(), *dsn)
+		if err != nil {
+			logger.Error("failed to open database", "error", err)
+			os.Exit(1)
+		}
+		defer db.Close()
+
+		applied, err := storage.Migrate(context.Background(), db)
+		if err != nil {
+			logger.Error("failed to migrate database", "error", err)
+			os.Exit(1)
+		}
+		for _, m := range applied {
+			logger.Info("Migration applied", "version", m.Version, "name", m.Name)
+		}
+		productRepo = storage.NewSQLiteProductRepo(db)
+		basketRepo = storage.NewSQLiteBasketRepo(db)
+		userRepo = storage.NewSQLiteUserRepo(db)
+		orderRepo = storage.NewSQLiteOrderRepo(db)
+		promoRepo = storage.NewSQLitePromotionRepo(db)
+		categoryRepo = storage.NewSQLiteCategoryRepo(db)
+		collectionRepo = storage.NewSQLiteCollectionRepo(db)
+	default:
+		logger.Error("unknown storage type", "storage", *storageType)
+		os.Exit(1)
+	}
+	logger.Info("Storage selected", "storage", *storageType)
+
+	// load our tax rates
+	taxConfig, err := tax.LoadConfig(*taxFile)
+	if err != nil {
+		logger.Error("failed to load tax config", "error", err)
+		os.Exit(1)
+	}
+	taxEngine, err := tax.NewEngine(taxConfig)
+	if err != nil {
+		logger.Error("failed to create tax engine", "error", err)
+		os.Exit(1)
+	}
+
 	// create our dependencies
-	productRepo := storage.NewProductRepo()
-	productSvc := services.NewProductService(productRepo)
-	basketSvc := services.NewBasketService(storage.NewBasketRepo())
+	productSvc := services.NewProductService(productRepo, categoryRepo, search.NewInvertedIndex())
+	if err := productSvc.ReindexProducts(context.Background()); err != nil {
+		logger.Error("failed to build search index", "error", err)
+		os.Exit(1)
+	}
+	catalogSvc := services.NewCatalogService(categoryRepo, collectionRepo, productSvc)
+	shippingFee, err := app.ParseMoney(*shipping, app.DefaultCurrency)
+	if err != nil {
+		logger.Error("invalid shipping fee", "error", err)
+		os.Exit(1)
+	}
+	promotionSvc := services.NewPromotionService(promoRepo, orderRepo)
+	basketSvc := services.NewBasketService(basketRepo, productSvc, promotionSvc, taxEngine, shippingFee)
+	userSvc := services.NewUserService(userRepo, strings.Split(*admins, ","))
+	withoutAccount, err := userSvc.AppointAdmins(context.Background())
+	if err != nil {
+		logger.Error("failed to appoint admins", "error", err)
+		os.Exit(1)
+	}
+	for _, email := range withoutAccount {
+		logger.Warn("Admin has no account yet, sign up and restart to get the admin role", "email", email)
+	}
+
+	webhookSecret := os.Getenv("PAYMENT_WEBHOOK_SECRET")
+	if webhookSecret == "" {
+		logger.Warn("PAYMENT_WEBHOOK_SECRET not set, using development webhook secret")
+		webhookSecret = devWebhookSecret
+	}
+	var (
+		payments    payment.PaymentProvider
+		fakePayment *payment.Fake
+	)
+	if *paymentsURL != "" {
+		payments = payment.NewFakeClient(*paymentsURL, []byte(webhookSecret))
+	} else {
+		fakePayment = payment.NewFake(payment.FakeOptions{
+			BaseURL:       "http://" + address + "/fakepay",
+			WebhookURL:    "http://" + address + "/webhooks/payment",
+			WebhookSecret: []byte(webhookSecret),
+			Logger:        logger,
+		})
+		payments = fakePayment
+	}
+	orderSvc := services.NewOrderService(orderRepo, basketSvc, payments)
+
+	jwtKeys := os.Getenv("JWT_KEYS")
+	if jwtKeys == "" {
+		logger.Warn("JWT_KEYS not set, using development signing key")
+		jwtKeys = devSigningKey
+	}
+	signingKeys, err := services.ParseSigningKeys(jwtKeys)
+	if err != nil {
+		logger.Error("failed to parse JWT_KEYS", "error", err)
+		os.Exit(1)
+	}
+	authSvc, err := services.NewAuthService(userSvc, signingKeys)
+	if err != nil {
+		logger.Error("failed to create auth service", "error", err)
+		os.Exit(1)
+	}
+	sessionKeys := os.Getenv("SESSION_KEYS")
+	if sessionKeys == "" {
+		logger.Warn("SESSION_KEYS not set, using development session keys: anyone can forge a login, never run like this in production")
+		sessionKeys = devSessionKeys
+	}
+	parsedSessionKeys, err := handler.ParseSessionKeys(sessionKeys)
+	if err != nil {
+		logger.Error("failed to parse SESSION_KEYS", "error", err)
+		os.Exit(1)
+	}
+	if !*secureCookies {
+		logger.Warn("Secure cookies are off, the session cookie is also sent over plain HTTP")
+	}
 	deps := handler.Dependencies{
-		Product: productSvc,
-		Basket:  basketSvc,
+		Product:  productSvc,
+		Catalog:  catalogSvc,
+		Basket:   basketSvc,
+		User:     userSvc,
+		Auth:     authSvc,
+		Order:    orderSvc,
+		Payments: payments,
+		Tax:      taxEngine,
+
+		SessionKeys:   parsedSessionKeys,
+		SecureCookies: *secureCookies,
 	}
 
 	// create a handler and server
-	app := handler.New(logger, deps)
+	var app http.Handler = handler.New(logger, deps)
+	if fakePayment != nil {
+		mux := http.NewServeMux()
+		mux.Handle("/fakepay/", http.StripPrefix("/fakepay", fakePayment))
+		mux.Handle("/", app)
+		app = mux
+	}
 	srv := &http.Server{
 		Addr:         address,
 		ReadTimeout:  5 * time.Second,
@@ -57,6 +233,22 @@ func main() {
 		}
 	}()
 
+	// guests that never come back leave their baskets behind, clean them up every hour
+	cleanup := time.NewTicker(time.Hour)
+	defer cleanup.Stop()
+	go func() {
+		for ; ; <-cleanup.C {
+			n, err := basketSvc.DeleteExpiredGuestBaskets(context.Background())
+			if err != nil {
+				logger.Error("failed to delete expired guest baskets", "error", err)
+				continue
+			}
+			if n > 0 {
+				logger.Info("Deleted expired guest baskets", "count", n)
+			}
+		}
+	}()
+
 	// wait for shutdown signals
 	<-shutdown
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
@@ -0,0 +1,71 @@
+// Command fakepay runs the fake payment provider as its own HTTP server,
+// start the webshop with -payments http://localhost:8001 to use it.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/gerbenjacobs/go-webshop-course/payment"
+	"github.com/lmittmann/tint"
+)
+
+var (
+	address    = flag.String("addr", "localhost:8001", "address to listen on")
+	webhookURL = flag.String("webhook", "http://localhost:8000/webhooks/payment", "URL that receives the payment webhooks")
+	asyncDelay = flag.Duration("async-delay", 2*time.Second, "how long async payments take to complete")
+)
+
+const devWebhookSecret = "dev-webhook-secret"
+
+func main() {
+	flag.Parse()
+
+	shutdown := make(chan os.Signal, 3)
+	signal.Notify(shutdown, syscall.SIGINT, syscall.SIGTERM)
+
+	logger := slog.New(tint.NewHandler(os.Stdout, &tint.Options{Level: slog.LevelDebug}))
+
+	webhookSecret := os.Getenv("PAYMENT_WEBHOOK_SECRET")
+	if webhookSecret == "" {
+		logger.Warn("PAYMENT_WEBHOOK_SECRET not set, using development webhook secret")
+		webhookSecret = devWebhookSecret
+	}
+
+	fake := payment.NewFake(payment.FakeOptions{
+		BaseURL:       "http://" + *address,
+		WebhookURL:    *webhookURL,
+		WebhookSecret: []byte(webhookSecret),
+		AsyncDelay:    *asyncDelay,
+		Logger:        logger,
+	})
+	srv := &http.Server{
+		Addr:         *address,
+		ReadTimeout:  5 * time.Second,
+		WriteTimeout: 10 * time.Second,
+		Handler:      fake,
+	}
+
+	go func() {
+		logger.Info("Fake payment provider started", "address", srv.Addr)
+		if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			logger.Error("failed to listen", "error", err)
+			os.Exit(1)
+		}
+	}()
+
+	<-shutdown
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := srv.Shutdown(ctx); err != nil {
+		logger.Error("Server shutdown failed", "error", err)
+	}
+	logger.Info("Fake payment provider stopped")
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"strconv"
+
+	"github.com/gerbenjacobs/go-webshop-course/storage"
+	"github.com/lmittmann/tint"
+)
+
+var dsn = flag.String("db", "webshop.db", "path to the SQLite database")
+
+const usage = `Usage: migrate [-db webshop.db] <command>
+
+Commands:
+  up          apply all pending migrations
+  down [n]    roll back the last n migrations (default 1)
+  status      show which migrations have been applied
+`
+
+func main() {
+	flag.Usage = func() {
+		fmt.Fprint(flag.CommandLine.Output(), usage)
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	logger := slog.New(tint.NewHandler(os.Stdout, &tint.Options{Level: slog.LevelDebug}))
+
+	if flag.NArg() < 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	ctx := context.Background()
+	db, err := storage.OpenSQLite(ctx, *dsn)
+	if err != nil {
+		logger.Error("failed to open database", "error", err)
+		os.Exit(1)
+	}
+	defer db.Close()
+
+	switch flag.Arg(0) {
+	case "up":
+		applied, err := storage.Migrate(ctx, db)
+		for _, m := range applied {
+			logger.Info("Migration applied", "version", m.Version, "name", m.Name)
+		}
+		if err != nil {
+			logger.Error("failed to migrate", "error", err)
+			os.Exit(1)
+		}
+		if len(applied) == 0 {
+			logger.Info("Database is up to date")
+		}
+	case "down":
+		steps := 1
+		if flag.NArg() > 1 {
+			steps, err = strconv.Atoi(flag.Arg(1))
+			if err != nil || steps < 1 {
+				logger.Error("invalid number of steps", "steps", flag.Arg(1))
+				os.Exit(2)
+			}
+		}
+		reverted, err := storage.Rollback(ctx, db, steps)
+		for _, m := range reverted {
+			logger.Info("Migration rolled back", "version", m.Version, "name", m.Name)
+		}
+		if err != nil {
+			logger.Error("failed to roll back", "error", err)
+			os.Exit(1)
+		}
+	case "status":
+		status, err := storage.Status(ctx, db)
+		if err != nil {
+			logger.Error("failed to fetch migration status", "error", err)
+			os.Exit(1)
+		}
+		for _, s := range status {
+			applied := "pending"
+			if s.Applied {
+				applied = "applied at " + s.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+			fmt.Printf("%04d  %-30s %s\n", s.Version, s.Name, applied)
+		}
+	default:
+		flag.Usage()
+		os.Exit(2)
+	}
+}
@@ -0,0 +1,198 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+func (h *Handler) apiCreateProduct(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	product, ok := readInput(h, w, r, apiVersionFromContext(r.Context()).productInput(), "product")
+	if !ok {
+		return
+	}
+
+	product, err := h.Product.CreateProduct(r.Context(), product)
+	if err != nil {
+		h.apiError(w, r, err, "create product")
+		return
+	}
+
+	h.logger.InfoContext(r.Context(), "Product created", "product_id", product.ID)
+	w.Header().Set("Location", apiURL(r, "/products/%d", product.ID))
+	h.writeJSON(w, r, http.StatusCreated, apiVersionFromContext(r.Context()).product(product))
+}
+
+func (h *Handler) apiUpdateProduct(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+	productID, err := strconv.Atoi(p.ByName("id"))
+	if err != nil {
+		h.apiProblem(w, r, problemInvalidRequest, "invalid product ID")
+		return
+	}
+
+	product, ok := readInput(h, w, r, apiVersionFromContext(r.Context()).productInput(), "product")
+	if !ok {
+		return
+	}
+	product.ID = productID
+
+	product, err = h.Product.UpdateProduct(r.Context(), product)
+	if err != nil {
+		h.apiError(w, r, err, "update product")
+		return
+	}
+
+	h.logger.InfoContext(r.Context(), "Product updated", "product_id", product.ID)
+	h.writeJSON(w, r, http.StatusOK, apiVersionFromContext(r.Context()).product(product))
+}
+
+func (h *Handler) apiPatchProduct(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+	productID, err := strconv.Atoi(p.ByName("id"))
+	if err != nil {
+		h.apiProblem(w, r, problemInvalidRequest, "invalid product ID")
+		return
+	}
+
+	patch, ok := readInput(h, w, r, apiVersionFromContext(r.Context()).productPatchInput(), "product")
+	if !ok {
+		return
+	}
+
+	product, err := h.Product.PatchProduct(r.Context(), productID, patch)
+	if err != nil {
+		h.apiError(w, r, err, "update product")
+		return
+	}
+
+	h.logger.InfoContext(r.Context(), "Product updated", "product_id", product.ID)
+	h.writeJSON(w, r, http.StatusOK, apiVersionFromContext(r.Context()).product(product))
+}
+
+func (h *Handler) apiDeleteProduct(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+	productID, err := strconv.Atoi(p.ByName("id"))
+	if err != nil {
+		h.apiProblem(w, r, problemInvalidRequest, "invalid product ID")
+		return
+	}
+
+	if err := h.Product.DeleteProduct(r.Context(), productID); err != nil {
+		h.apiError(w, r, err, "delete product")
+		return
+	}
+
+	h.logger.InfoContext(r.Context(), "Product deleted", "product_id", productID)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *Handler) apiCreateCategory(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	category, ok := readInput(h, w, r, apiVersionFromContext(r.Context()).categoryInput(), "category")
+	if !ok {
+		return
+	}
+
+	category, err := h.Catalog.CreateCategory(r.Context(), category)
+	if err != nil {
+		h.apiError(w, r, err, "create category")
+		return
+	}
+
+	h.logger.InfoContext(r.Context(), "Category created", "category_id", category.ID)
+	w.Header().Set("Location", apiURL(r, "/categories/%s", category.Slug))
+	h.writeJSON(w, r, http.StatusCreated, apiVersionFromContext(r.Context()).category(category))
+}
+
+func (h *Handler) apiUpdateCategory(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+	categoryID, err := strconv.Atoi(p.ByName("id"))
+	if err != nil {
+		h.apiProblem(w, r, problemInvalidRequest, "invalid category ID")
+		return
+	}
+
+	category, ok := readInput(h, w, r, apiVersionFromContext(r.Context()).categoryInput(), "category")
+	if !ok {
+		return
+	}
+	category.ID = categoryID
+
+	category, err = h.Catalog.UpdateCategory(r.Context(), category)
+	if err != nil {
+		h.apiError(w, r, err, "update category")
+		return
+	}
+
+	h.logger.InfoContext(r.Context(), "Category updated", "category_id", category.ID)
+	h.writeJSON(w, r, http.StatusOK, apiVersionFromContext(r.Context()).category(category))
+}
+
+func (h *Handler) apiDeleteCategory(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+	categoryID, err := strconv.Atoi(p.ByName("id"))
+	if err != nil {
+		h.apiProblem(w, r, problemInvalidRequest, "invalid category ID")
+		return
+	}
+
+	if err := h.Catalog.DeleteCategory(r.Context(), categoryID); err != nil {
+		h.apiError(w, r, err, "delete category")
+		return
+	}
+
+	h.logger.InfoContext(r.Context(), "Category deleted", "category_id", categoryID)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *Handler) apiCreateCollection(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	collection, ok := readInput(h, w, r, apiVersionFromContext(r.Context()).collectionInput(), "collection")
+	if !ok {
+		return
+	}
+
+	collection, err := h.Catalog.CreateCollection(r.Context(), collection)
+	if err != nil {
+		h.apiError(w, r, err, "create collection")
+		return
+	}
+
+	h.logger.InfoContext(r.Context(), "Collection created", "collection_id", collection.ID)
+	w.Header().Set("Location", apiURL(r, "/collections/%s", collection.Slug))
+	h.writeJSON(w, r, http.StatusCreated, apiVersionFromContext(r.Context()).collection(collection))
+}
+
+func (h *Handler) apiUpdateCollection(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+	collectionID, err := strconv.Atoi(p.ByName("id"))
+	if err != nil {
+		h.apiProblem(w, r, problemInvalidRequest, "invalid collection ID")
+		return
+	}
+
+	collection, ok := readInput(h, w, r, apiVersionFromContext(r.Context()).collectionInput(), "collection")
+	if !ok {
+		return
+	}
+	collection.ID = collectionID
+
+	collection, err = h.Catalog.UpdateCollection(r.Context(), collection)
+	if err != nil {
+		h.apiError(w, r, err, "update collection")
+		return
+	}
+
+	h.logger.InfoContext(r.Context(), "Collection updated", "collection_id", collection.ID)
+	h.writeJSON(w, r, http.StatusOK, apiVersionFromContext(r.Context()).collection(collection))
+}
+
+func (h *Handler) apiDeleteCollection(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+	collectionID, err := strconv.Atoi(p.ByName("id"))
+	if err != nil {
+		h.apiProblem(w, r, problemInvalidRequest, "invalid collection ID")
+		return
+	}
+
+	if err := h.Catalog.DeleteCollection(r.Context(), collectionID); err != nil {
+		h.apiError(w, r, err, "delete collection")
+		return
+	}
+
+	h.logger.InfoContext(r.Context(), "Collection deleted", "collection_id", collectionID)
+	w.WriteHeader(http.StatusNoContent)
+}
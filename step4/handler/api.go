@@ -1,103 +1,414 @@
 package handler
 
 import (
-	"encoding/json"
+	"fmt"
 	"net/http"
 	"strconv"
 
+	app "github.com/gerbenjacobs/go-webshop-course"
 	"github.com/julienschmidt/httprouter"
 )
 
+// basketFormsDeprecated is when the form based basket endpoints were deprecated,
+// as the Deprecation header wants it: @ and the Unix time
+const basketFormsDeprecated = "@1792108800"
+
 func (h *Handler) apiProducts(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
-	products, err := h.Product.ListProducts(r.Context())
+	if query := r.URL.Query().Get("q"); query != "" {
+		h.apiSearchProducts(w, r, query)
+		return
+	}
+
+	query, err := productQuery(r.URL.Query())
+	if err != nil {
+		h.apiError(w, r, err, "list products")
+		return
+	}
+	page, err := h.Product.ListProducts(r.Context(), query)
 	if err != nil {
-		h.logger.Error("failed to fetch products", "error", err)
-		http.Error(w, "failed to fetch products", http.StatusInternalServerError)
+		h.apiError(w, r, err, "fetch products")
 		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	if err := json.NewEncoder(w).Encode(products); err != nil {
-		h.logger.Error("failed to write products JSON", "error", err)
-		http.Error(w, "failed to write products JSON", http.StatusInternalServerError)
+	// the body stays a plain list, paging goes through the headers
+	w.Header().Set("X-Total-Count", strconv.Itoa(page.Total))
+	w.Header().Set("Link", pageLinks(r.URL, page))
+	h.writeJSON(w, r, http.StatusOK, apiVersionFromContext(r.Context()).products(page.Products))
+}
+
+// apiSearchProducts lists the products that match the query, the most relevant first
+func (h *Handler) apiSearchProducts(w http.ResponseWriter, r *http.Request, query string) {
+	results, err := h.Product.SearchProducts(r.Context(), query)
+	if err != nil {
+		h.apiError(w, r, err, "search products")
+		return
 	}
+
+	h.writeJSON(w, r, http.StatusOK, apiVersionFromContext(r.Context()).searchResults(results))
 }
 
 func (h *Handler) apiProductByID(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
 	// validate our product ID
 	productID, err := strconv.Atoi(p.ByName("id"))
 	if err != nil {
-		h.logger.ErrorContext(r.Context(), "couldn't convert product ID to int", "error", err)
-		http.Error(w, "invalid product ID", http.StatusBadRequest)
+		h.apiProblem(w, r, problemInvalidRequest, "invalid product ID")
 		return
 	}
 
 	product, err := h.Product.ShowProduct(r.Context(), productID)
 	if err != nil {
-		h.logger.Error("failed to fetch product", "error", err)
-		http.Error(w, "failed to fetch product", http.StatusInternalServerError)
+		h.apiError(w, r, err, "fetch product")
 		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	if err := json.NewEncoder(w).Encode(product); err != nil {
-		h.logger.Error("failed to write product JSON", "error", err)
-		http.Error(w, "failed to write product JSON", http.StatusInternalServerError)
-	}
+	h.writeJSON(w, r, http.StatusOK, apiVersionFromContext(r.Context()).product(product))
 }
 
 func (h *Handler) apiBasket(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
-	userID := 1
-	basket, err := h.Basket.GetBasket(r.Context(), userID)
+	var (
+		basket app.BasketView
+		err    error
+	)
+	// a guest without a basket sees an empty one, it's only created when they add something
+	if userID, ok := userIDFromContext(r.Context()); ok {
+		basket, err = h.Basket.GetBasketView(r.Context(), userID, r.URL.Query().Get("country"))
+	} else {
+		basket, err = h.Basket.EmptyBasketView(r.Context(), r.URL.Query().Get("country"))
+	}
 	if err != nil {
-		h.logger.Error("failed to fetch basket", "error", err)
-		http.Error(w, "failed to fetch basket", http.StatusInternalServerError)
+		h.apiError(w, r, err, "fetch basket")
+		return
+	}
+
+	h.writeJSON(w, r, http.StatusOK, apiVersionFromContext(r.Context()).basket(basket))
+}
+
+// basketItemRequest is the body to add an item to the basket, quantity defaults to 1
+type basketItemRequest struct {
+	ProductID int  `json:"product_id"`
+	VariantID int  `json:"variant_id"`
+	Quantity  *int `json:"quantity"`
+}
+
+func (b basketItemRequest) validate() error {
+	fe := app.FieldErrors{}
+	if b.ProductID <= 0 {
+		fe.Add("product_id", "is required")
+	}
+	if b.VariantID < 0 {
+		fe.Add("variant_id", "can't be negative")
+	}
+	if b.Quantity != nil && *b.Quantity < 1 {
+		fe.Add("quantity", "needs to be at least 1")
+	} else if b.Quantity != nil && *b.Quantity > app.MaxLineQuantity {
+		fe.Add("quantity", "can't be more than %d", app.MaxLineQuantity)
+	}
+	return fe.Err()
+}
+
+// basketQuantityRequest is the body to change the quantity of an item, 0 removes it
+type basketQuantityRequest struct {
+	Quantity *int `json:"quantity"`
+}
+
+func (b basketQuantityRequest) validate() error {
+	fe := app.FieldErrors{}
+	if b.Quantity == nil {
+		fe.Add("quantity", "is required")
+	} else if *b.Quantity < 0 {
+		fe.Add("quantity", "can't be negative")
+	} else if *b.Quantity > app.MaxLineQuantity {
+		fe.Add("quantity", "can't be more than %d", app.MaxLineQuantity)
+	}
+	return fe.Err()
+}
+
+func (h *Handler) apiAddBasketItem(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	var req basketItemRequest
+	if !h.decodeJSON(w, r, &req) {
+		return
+	}
+	if err := req.validate(); err != nil {
+		h.apiError(w, r, err, "add to basket")
+		return
+	}
+
+	quantity := 1
+	if req.Quantity != nil {
+		quantity = *req.Quantity
+	}
+
+	userID, _ := userIDFromContext(r.Context())
+	if err := h.Basket.AddToBasket(r.Context(), userID, req.ProductID, req.VariantID, quantity); err != nil {
+		h.apiError(w, r, err, "add to basket")
 		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	if err := json.NewEncoder(w).Encode(basket); err != nil {
-		h.logger.Error("failed to write basket JSON", "error", err)
-		http.Error(w, "failed to write basket JSON", http.StatusInternalServerError)
+	location := apiURL(r, "/basket/items/%d", req.ProductID)
+	if req.VariantID != 0 {
+		location += fmt.Sprintf("?variant_id=%d", req.VariantID)
+	}
+	w.Header().Set("Location", location)
+	h.writeBasketView(w, r, http.StatusCreated)
+}
+
+func (h *Handler) apiUpdateBasketItem(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+	productID, variantID, ok := h.pathBasketLine(w, r, p)
+	if !ok {
+		return
+	}
+	var req basketQuantityRequest
+	if !h.decodeJSON(w, r, &req) {
+		return
+	}
+	if err := req.validate(); err != nil {
+		h.apiError(w, r, err, "update basket item")
+		return
+	}
+
+	userID, _ := userIDFromContext(r.Context())
+	if err := h.Basket.UpdateItem(r.Context(), userID, productID, variantID, *req.Quantity); err != nil {
+		h.apiError(w, r, err, "update basket item")
+		return
+	}
+	h.writeBasketView(w, r, http.StatusOK)
+}
+
+func (h *Handler) apiDeleteBasketItem(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+	productID, variantID, ok := h.pathBasketLine(w, r, p)
+	if !ok {
+		return
+	}
+
+	userID, _ := userIDFromContext(r.Context())
+	if err := h.Basket.RemoveItem(r.Context(), userID, productID, variantID); err != nil {
+		h.apiError(w, r, err, "remove basket item")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *Handler) apiClearBasket(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	userID, _ := userIDFromContext(r.Context())
+	if err := h.Basket.ClearBasket(r.Context(), userID); err != nil {
+		h.apiError(w, r, err, "clear basket")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// writeBasketView replies with the basket as it is now, priced for the optional `country` query value
+func (h *Handler) writeBasketView(w http.ResponseWriter, r *http.Request, status int) {
+	userID, _ := userIDFromContext(r.Context())
+	basket, err := h.Basket.GetBasketView(r.Context(), userID, r.URL.Query().Get("country"))
+	if err != nil {
+		h.apiError(w, r, err, "fetch basket")
+		return
+	}
+
+	h.writeJSON(w, r, status, apiVersionFromContext(r.Context()).basket(basket))
+}
+
+// pathBasketLine reads the product ID from the path and the optional variant_id from the query,
+// it replies with the problem when they can't be read
+func (h *Handler) pathBasketLine(w http.ResponseWriter, r *http.Request, p httprouter.Params) (productID, variantID int, ok bool) {
+	productID, err := strconv.Atoi(p.ByName("id"))
+	if err != nil {
+		h.apiProblem(w, r, problemInvalidRequest, "invalid product ID")
+		return 0, 0, false
+	}
+	if v := r.URL.Query().Get("variant_id"); v != "" {
+		if variantID, err = strconv.Atoi(v); err != nil {
+			h.apiProblem(w, r, problemInvalidRequest, "invalid variant ID")
+			return 0, 0, false
+		}
+	}
+	return productID, variantID, true
+}
+
+// deprecated marks a route that has been replaced, clients find the successor in the Link header.
+// The successor is a path in the API, like /basket/items.
+func deprecated(successor string, next httprouter.Handle) httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+		w.Header().Set("Deprecation", basketFormsDeprecated)
+		w.Header().Add("Link", fmt.Sprintf("<%s>; rel=\"successor-version\"", apiURL(r, "%s", successor)))
+		next(w, r, p)
 	}
 }
 
 func (h *Handler) apiAddToBasket(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
 	r.ParseForm()
-	productIDParam := r.Form.Get("product_id")
-
-	productID, err := strconv.Atoi(productIDParam)
+	productID, variantID, ok := h.formBasketLine(w, r)
+	if !ok {
+		return
+	}
+	quantity, err := formQuantity(r, 1)
 	if err != nil {
-		h.logger.ErrorContext(r.Context(), "couldn't convert product ID to int", "error", err)
-		http.Error(w, "invalid product ID", http.StatusBadRequest)
+		h.apiProblem(w, r, problemInvalidRequest, "invalid quantity")
 		return
 	}
 
-	userID := 1
-	quantity := 1
-	if err := h.Basket.AddToBasket(r.Context(), userID, productID, quantity); err != nil {
-		h.logger.Error("failed to add to basket", "error", err)
-		http.Error(w, "failed to add to basket", http.StatusInternalServerError)
+	userID, _ := userIDFromContext(r.Context())
+	if err := h.Basket.AddToBasket(r.Context(), userID, productID, variantID, quantity); err != nil {
+		h.apiError(w, r, err, "add to basket")
 		return
 	}
 }
 
 func (h *Handler) apiRemoveFromBasket(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
 	r.ParseForm()
-	productIDParam := r.Form.Get("product_id")
+	productID, variantID, ok := h.formBasketLine(w, r)
+	if !ok {
+		return
+	}
+	quantity, err := formQuantity(r, 1)
+	if err != nil {
+		h.apiProblem(w, r, problemInvalidRequest, "invalid quantity")
+		return
+	}
 
-	productID, err := strconv.Atoi(productIDParam)
+	userID, _ := userIDFromContext(r.Context())
+	if err := h.Basket.RemoveFromBasket(r.Context(), userID, productID, variantID, quantity); err != nil {
+		h.apiError(w, r, err, "remove from basket")
+		return
+	}
+}
+
+func (h *Handler) apiSetBasketQuantity(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	r.ParseForm()
+	productID, variantID, ok := h.formBasketLine(w, r)
+	if !ok {
+		return
+	}
+	quantity, err := strconv.Atoi(r.Form.Get("quantity"))
 	if err != nil {
-		h.logger.ErrorContext(r.Context(), "couldn't convert product ID to int", "error", err)
-		http.Error(w, "invalid product ID", http.StatusBadRequest)
+		h.apiProblem(w, r, problemInvalidRequest, "invalid quantity")
 		return
 	}
 
-	userID := 1
-	quantity := 1
-	if err := h.Basket.RemoveFromBasket(r.Context(), userID, productID, quantity); err != nil {
-		h.logger.Error("failed to remove from basket", "error", err)
-		http.Error(w, "failed to remove from basket", http.StatusInternalServerError)
+	userID, _ := userIDFromContext(r.Context())
+	if err := h.Basket.SetQuantity(r.Context(), userID, productID, variantID, quantity); err != nil {
+		h.apiError(w, r, err, "set basket quantity")
+		return
+	}
+}
+
+func (h *Handler) apiApplyCoupon(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	r.ParseForm()
+	code := r.Form.Get("code")
+	if code == "" {
+		h.apiProblem(w, r, problemInvalidRequest, "missing coupon code")
+		return
+	}
+
+	userID, _ := userIDFromContext(r.Context())
+	basket, err := h.Basket.ApplyCoupon(r.Context(), userID, code)
+	if err != nil {
+		h.apiError(w, r, err, "apply coupon")
+		return
+	}
+
+	h.writeJSON(w, r, http.StatusOK, apiVersionFromContext(r.Context()).basket(basket))
+}
+
+func (h *Handler) apiRemoveCoupon(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	userID, _ := userIDFromContext(r.Context())
+	if err := h.Basket.RemoveCoupon(r.Context(), userID); err != nil {
+		h.apiError(w, r, err, "remove coupon")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// formBasketLine reads the product_id and optional variant_id of a basket line,
+// it replies with the problem when they can't be read
+func (h *Handler) formBasketLine(w http.ResponseWriter, r *http.Request) (productID, variantID int, ok bool) {
+	productID, err := strconv.Atoi(r.Form.Get("product_id"))
+	if err != nil {
+		h.apiProblem(w, r, problemInvalidRequest, "invalid product ID")
+		return 0, 0, false
+	}
+	variantID, err = formVariantID(r)
+	if err != nil {
+		h.apiProblem(w, r, problemInvalidRequest, "invalid variant ID")
+		return 0, 0, false
+	}
+	return productID, variantID, true
+}
+
+// formQuantity reads the optional `quantity` form value
+func formQuantity(r *http.Request, fallback int) (int, error) {
+	q := r.Form.Get("quantity")
+	if q == "" {
+		return fallback, nil
+	}
+	return strconv.Atoi(q)
+}
+
+// formVariantID reads the optional variant_id, products without variants don't have one
+func formVariantID(r *http.Request) (int, error) {
+	v := r.Form.Get("variant_id")
+	if v == "" {
+		return 0, nil
+	}
+	return strconv.Atoi(v)
+}
+
+func (h *Handler) apiCheckout(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	r.ParseForm()
+	userID, _ := userIDFromContext(r.Context())
+	order, err := h.Order.Checkout(r.Context(), userID, r.Form.Get("country"))
+	if err != nil {
+		h.apiError(w, r, err, "check out")
+		return
+	}
+
+	w.Header().Set("Location", apiURL(r, "/orders/%d", order.ID))
+	h.writeJSON(w, r, http.StatusCreated, apiVersionFromContext(r.Context()).order(order))
+}
+
+func (h *Handler) apiOrders(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	userID, _ := userIDFromContext(r.Context())
+	orders, err := h.Order.ListOrders(r.Context(), userID)
+	if err != nil {
+		h.apiError(w, r, err, "fetch orders")
+		return
+	}
+
+	h.writeJSON(w, r, http.StatusOK, apiVersionFromContext(r.Context()).orders(orders))
+}
+
+func (h *Handler) apiOrderByID(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+	orderID, err := strconv.Atoi(p.ByName("id"))
+	if err != nil {
+		h.apiProblem(w, r, problemInvalidRequest, "invalid order ID")
+		return
+	}
+
+	userID, _ := userIDFromContext(r.Context())
+	order, err := h.Order.GetOrder(r.Context(), userID, orderID)
+	if err != nil {
+		h.apiError(w, r, err, "fetch order")
 		return
 	}
+
+	h.writeJSON(w, r, http.StatusOK, apiVersionFromContext(r.Context()).order(order))
+}
+
+func (h *Handler) apiPayOrder(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+	orderID, err := strconv.Atoi(p.ByName("id"))
+	if err != nil {
+		h.apiProblem(w, r, problemInvalidRequest, "invalid order ID")
+		return
+	}
+
+	r.ParseForm()
+	userID, _ := userIDFromContext(r.Context())
+	auth, err := h.Order.Pay(r.Context(), userID, orderID, r.Form.Get("method"), r.Form.Get("return_url"))
+	if err != nil {
+		h.apiError(w, r, err, "pay order")
+		return
+	}
+
+	h.writeJSON(w, r, http.StatusOK, apiVersionFromContext(r.Context()).payment(auth))
 }
@@ -0,0 +1,51 @@
+// Package apiv1 is the wire format of version 1 of the API. It's the format
+// the API had before it got versions, so its JSON has to stay exactly as it is.
+// The domain types are turned into these DTOs before they're written, so they
+// can change without breaking our clients.
+package apiv1
+
+import (
+	_ "embed"
+
+	app "github.com/gerbenjacobs/go-webshop-course"
+)
+
+// OpenAPI documents version 1 of the API
+//
+//go:embed openapi.json
+var OpenAPI []byte
+
+type Money struct {
+	Amount   int64  `json:"amount"`
+	Currency string `json:"currency"`
+}
+
+func NewMoney(m app.Money) Money {
+	return Money(m)
+}
+
+// UnmarshalJSON is as strict as the domain, we never accept half a money value
+func (m *Money) UnmarshalJSON(b []byte) error {
+	var money app.Money
+	if err := money.UnmarshalJSON(b); err != nil {
+		return err
+	}
+	*m = Money(money)
+	return nil
+}
+
+func (m Money) App() app.Money {
+	return app.Money(m)
+}
+
+// mapSlice converts every element, nil stays nil so the JSON doesn't change from null to []
+func mapSlice[T, D any](in []T, f func(T) D) []D {
+	if in == nil {
+		return nil
+	}
+	out := make([]D, len(in))
+	for i, v := range in {
+		out[i] = f(v)
+	}
+	return out
+}
@@ -0,0 +1,108 @@
+package apiv1_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	app "github.com/gerbenjacobs/go-webshop-course"
+	"github.com/gerbenjacobs/go-webshop-course/handler/apiv1"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files in testdata")
+
+// TestWireFormat pins the JSON of v1 to the golden files in testdata, v1 is what our
+// clients had before the API got versions so it may never change. Run the test with
+// -update only when a field is added on purpose.
+func TestWireFormat(t *testing.T) {
+	created := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	pink := app.EUR(2200)
+
+	tests := []struct {
+		name string
+		v    any
+	}{
+		{"product", apiv1.NewProduct(app.Product{
+			ID: 1, Name: "Gopher plushie", Description: "A small purple plushie", Image: "/static/gopher.png",
+			Price: app.EUR(1299), TaxClass: app.TaxClassStandard, CategoryIDs: []int{2}, Stock: 50, Reserved: 3,
+		})},
+		{"product_with_variants", apiv1.NewProduct(app.Product{
+			ID: 2, Name: "PHP Elephant plushie", Description: "An elephant with the PHP logo",
+			Price: app.EUR(2000), TaxClass: app.TaxClassStandard, CategoryIDs: []int{2},
+			Options: []app.ProductOption{{Name: "Colour", Values: []string{"Blue", "Pink"}}},
+			Variants: []app.Variant{
+				{ID: 1, ProductID: 2, SKU: "PHP-ELEPHANT-BLUE", Options: map[string]string{"Colour": "Blue"}, Stock: 25},
+				{ID: 2, ProductID: 2, SKU: "PHP-ELEPHANT-PINK", Options: map[string]string{"Colour": "Pink"}, Price: &pink, Stock: 10, Reserved: 10, Image: "/static/pink.png"},
+			},
+		})},
+		{"basket", apiv1.NewBasketView(app.BasketView{
+			UserID: -42,
+			Lines: []app.BasketLine{
+				{ProductID: 1, Name: "Gopher plushie", Image: "/static/gopher.png", UnitPrice: app.EUR(1299), CategoryIDs: []int{2},
+					TaxClass: app.TaxClassStandard, Quantity: 3, LineTotal: app.EUR(3897), Available: 47, StockStatus: app.StockStatusInStock, Discount: app.EUR(1299)},
+				{ProductID: 2, VariantID: 2, SKU: "PHP-ELEPHANT-PINK", Name: "PHP Elephant plushie", Variant: "Pink", UnitPrice: app.EUR(2200),
+					TaxClass: app.TaxClassStandard, Quantity: 1, LineTotal: app.EUR(2200), Available: 1, StockStatus: app.StockStatusLowStock, Discount: app.EUR(0)},
+			},
+			ItemCount: 4,
+			Subtotal:  app.EUR(6097),
+			Shipping:  app.EUR(495),
+			Coupon:    "GOPHER3FOR2",
+			Discounts: []app.AppliedDiscount{{PromotionID: 3, Code: "GOPHER3FOR2", Name: "3 Gophers for the price of 2", Amount: app.EUR(1299)}},
+			Discount:  app.EUR(1299),
+			Country:   "NL",
+			Taxes:     []app.TaxLine{{Class: app.TaxClassStandard, Rate: 2100, Base: app.EUR(4798), Amount: app.EUR(1008)}},
+			Tax:       app.EUR(1008),
+			Total:     app.EUR(6301),
+		})},
+		{"empty_basket", apiv1.NewBasketView(app.BasketView{
+			UserID: 7, Lines: []app.BasketLine{}, Subtotal: app.EUR(0), Shipping: app.EUR(0),
+			Discounts: []app.AppliedDiscount{}, Discount: app.EUR(0), Country: "NL", PricesIncludeTax: true,
+			Taxes: []app.TaxLine{}, Tax: app.EUR(0), Total: app.EUR(0),
+		})},
+		{"order", apiv1.NewOrder(app.Order{
+			ID: 4, UserID: 7, Status: app.OrderStatusPaid,
+			Items: []app.OrderItem{
+				{ProductID: 1, Name: "Gopher plushie", UnitPrice: app.EUR(1299), TaxClass: app.TaxClassStandard, Quantity: 3},
+				{ProductID: 2, VariantID: 2, SKU: "PHP-ELEPHANT-PINK", Name: "PHP Elephant plushie", Variant: "Pink", UnitPrice: app.EUR(2200), TaxClass: app.TaxClassStandard, Quantity: 1},
+			},
+			Subtotal:      app.EUR(6097),
+			Shipping:      app.EUR(495),
+			Discounts:     []app.AppliedDiscount{{PromotionID: 3, Code: "GOPHER3FOR2", Name: "3 Gophers for the price of 2", Amount: app.EUR(1299)}},
+			Discount:      app.EUR(1299),
+			Country:       "NL",
+			Taxes:         []app.TaxLine{{Class: app.TaxClassStandard, Rate: 2100, Base: app.EUR(4798), Amount: app.EUR(1008)}},
+			Tax:           app.EUR(1008),
+			Total:         app.EUR(6301),
+			PaymentID:     "pay_123",
+			CreatedAt:     created,
+			ReservedUntil: created.Add(30 * time.Minute),
+		})},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := json.MarshalIndent(tt.v, "", "  ")
+			if err != nil {
+				t.Fatalf("failed to encode: %v", err)
+			}
+			got = append(got, '\n')
+
+			golden := filepath.Join("testdata", tt.name+".json")
+			if *update {
+				if err := os.WriteFile(golden, got, 0o644); err != nil {
+					t.Fatalf("failed to update golden file: %v", err)
+				}
+			}
+			want, err := os.ReadFile(golden)
+			if err != nil {
+				t.Fatalf("failed to read golden file: %v", err)
+			}
+			if !bytes.Equal(got, want) {
+				t.Errorf("the JSON differs from %s\ngot:\n%s\nwant:\n%s", golden, got, want)
+			}
+		})
+	}
+}
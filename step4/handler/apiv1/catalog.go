@@ -0,0 +1,108 @@
+package apiv1
+
+import (
+	"slices"
+
+	app "github.com/gerbenjacobs/go-webshop-course"
+)
+
+// Category is also the body to create or replace a category
+type Category struct {
+	ID          int    `json:"id"`
+	ParentID    int    `json:"parent_id,omitempty"`
+	Slug        string `json:"slug"`
+	Name        string `json:"name"`
+	Description string `json:"desc"`
+	Position    int    `json:"position"`
+}
+
+type CategoryNode struct {
+	Category
+	Children []CategoryNode `json:"children"`
+}
+
+// Collection is also the body to create or replace a collection
+type Collection struct {
+	ID          int    `json:"id"`
+	Slug        string `json:"slug"`
+	Name        string `json:"name"`
+	Description string `json:"desc"`
+	ProductIDs  []int  `json:"product_ids"`
+}
+
+type CategoryPage struct {
+	Category    Category   `json:"category"`
+	Breadcrumbs []Category `json:"breadcrumbs"`
+	Children    []Category `json:"children"`
+	Products    []Product  `json:"products"`
+}
+
+type CollectionPage struct {
+	Collection Collection `json:"collection"`
+	Products   []Product  `json:"products"`
+}
+
+func NewCategory(c app.Category) Category {
+	return Category{
+		ID:          c.ID,
+		ParentID:    c.ParentID,
+		Slug:        c.Slug,
+		Name:        c.Name,
+		Description: c.Description,
+		Position:    c.Position,
+	}
+}
+
+func NewCategoryTree(tree []app.CategoryNode) []CategoryNode {
+	return mapSlice(tree, func(n app.CategoryNode) CategoryNode {
+		return CategoryNode{Category: NewCategory(n.Category), Children: NewCategoryTree(n.Children)}
+	})
+}
+
+func NewCategoryPage(page app.CategoryPage) CategoryPage {
+	return CategoryPage{
+		Category:    NewCategory(page.Category),
+		Breadcrumbs: mapSlice(page.Breadcrumbs, NewCategory),
+		Children:    mapSlice(page.Children, NewCategory),
+		Products:    NewProducts(page.Products),
+	}
+}
+
+func NewCollection(c app.Collection) Collection {
+	return Collection{
+		ID:          c.ID,
+		Slug:        c.Slug,
+		Name:        c.Name,
+		Description: c.Description,
+		ProductIDs:  slices.Clone(c.ProductIDs),
+	}
+}
+
+func NewCollections(collections []app.Collection) []Collection {
+	return mapSlice(collections, NewCollection)
+}
+
+func NewCollectionPage(page app.CollectionPage) CollectionPage {
+	return CollectionPage{Collection: NewCollection(page.Collection), Products: NewProducts(page.Products)}
+}
+
+func (c Category) App() (app.Category, error) {
+	return app.Category{
+		ID:          c.ID,
+		ParentID:    c.ParentID,
+		Slug:        c.Slug,
+		Name:        c.Name,
+		Description: c.Description,
+		Position:    c.Position,
+	}, nil
+}
+
+func (c Collection) App() (app.Collection, error) {
+	return app.Collection{
+		ID:          c.ID,
+		Slug:        c.Slug,
+		Name:        c.Name,
+		Description: c.Description,
+		ProductIDs:  slices.Clone(c.ProductIDs),
+	}, nil
+}
@@ -0,0 +1,172 @@
+package apiv1
+
+import (
+	"maps"
+	"slices"
+
+	app "github.com/gerbenjacobs/go-webshop-course"
+)
+
+// Product is also the body to create or replace a product, the read-only fields are ignored
+type Product struct {
+	ID          int             `json:"id"`
+	Name        string          `json:"name"`
+	Description string          `json:"desc"`
+	Image       string          `json:"img"`
+	Price       Money           `json:"price"`
+	TaxClass    string          `json:"tax_class"`
+	CategoryIDs []int           `json:"category_ids,omitempty"`
+	Stock       int             `json:"stock"`
+	Reserved    int             `json:"reserved"`
+	Options     []ProductOption `json:"options,omitempty"`
+	Variants    []Variant       `json:"variants,omitempty"`
+	StockStatus string          `json:"stock_status"`
+}
+
+type ProductOption struct {
+	Name   string   `json:"name"`
+	Values []string `json:"values"`
+}
+
+type Variant struct {
+	ID          int               `json:"id"`
+	ProductID   int               `json:"product_id"`
+	SKU         string            `json:"sku"`
+	Options     map[string]string `json:"options"`
+	Price       *Money            `json:"price,omitempty"`
+	Stock       int               `json:"stock"`
+	Reserved    int               `json:"reserved"`
+	Image       string            `json:"img,omitempty"`
+	StockStatus string            `json:"stock_status"`
+}
+
+type SearchResult struct {
+	Product    Product           `json:"product"`
+	Score      float64           `json:"score"`
+	Highlights map[string]string `json:"highlights"`
+}
+
+// ProductPatch changes the fields that are sent
+type ProductPatch struct {
+	Name        *string          `json:"name"`
+	Description *string          `json:"desc"`
+	Image       *string          `json:"img"`
+	Price       *Money           `json:"price"`
+	TaxClass    *string          `json:"tax_class"`
+	CategoryIDs *[]int           `json:"category_ids"`
+	Stock       *int             `json:"stock"`
+	Options     *[]ProductOption `json:"options"`
+	Variants    *[]Variant       `json:"variants"`
+}
+
+func NewProduct(p app.Product) Product {
+	return Product{
+		ID:          p.ID,
+		Name:        p.Name,
+		Description: p.Description,
+		Image:       p.Image,
+		Price:       NewMoney(p.Price),
+		TaxClass:    string(p.TaxClass),
+		CategoryIDs: slices.Clone(p.CategoryIDs),
+		Stock:       p.Stock,
+		Reserved:    p.Reserved,
+		Options:     mapSlice(p.Options, newProductOption),
+		Variants:    mapSlice(p.Variants, newVariant),
+		StockStatus: string(p.StockStatus()),
+	}
+}
+
+func NewProducts(products []app.Product) []Product {
+	return mapSlice(products, NewProduct)
+}
+
+func NewSearchResults(results []app.SearchResult) []SearchResult {
+	return mapSlice(results, func(r app.SearchResult) SearchResult {
+		return SearchResult{Product: NewProduct(r.Product), Score: r.Score, Highlights: maps.Clone(r.Highlights)}
+	})
+}
+
+func newProductOption(o app.ProductOption) ProductOption {
+	return ProductOption{Name: o.Name, Values: slices.Clone(o.Values)}
+}
+
+func newVariant(v app.Variant) Variant {
+	variant := Variant{
+		ID:          v.ID,
+		ProductID:   v.ProductID,
+		SKU:         v.SKU,
+		Options:     maps.Clone(v.Options),
+		Stock:       v.Stock,
+		Reserved:    v.Reserved,
+		Image:       v.Image,
+		StockStatus: string(v.StockStatus()),
+	}
+	if v.Price != nil {
+		price := NewMoney(*v.Price)
+		variant.Price = &price
+	}
+	return variant
+}
+
+// App turns the body into a product, what's reserved and the stock status are up to us
+func (p Product) App() (app.Product, error) {
+	return app.Product{
+		ID:          p.ID,
+		Name:        p.Name,
+		Description: p.Description,
+		Image:       p.Image,
+		Price:       p.Price.App(),
+		TaxClass:    app.TaxClass(p.TaxClass),
+		CategoryIDs: slices.Clone(p.CategoryIDs),
+		Stock:       p.Stock,
+		Options:     mapSlice(p.Options, ProductOption.app),
+		Variants:    mapSlice(p.Variants, Variant.app),
+	}, nil
+}
+
+func (pp ProductPatch) App() (app.ProductPatch, error) {
+	patch := app.ProductPatch{
+		Name:        pp.Name,
+		Description: pp.Description,
+		Image:       pp.Image,
+		CategoryIDs: pp.CategoryIDs,
+		Stock:       pp.Stock,
+	}
+	if pp.Price != nil {
+		price := pp.Price.App()
+		patch.Price = &price
+	}
+	if pp.TaxClass != nil {
+		class := app.TaxClass(*pp.TaxClass)
+		patch.TaxClass = &class
+	}
+	if pp.Options != nil {
+		options := mapSlice(*pp.Options, ProductOption.app)
+		patch.Options = &options
+	}
+	if pp.Variants != nil {
+		variants := mapSlice(*pp.Variants, Variant.app)
+		patch.Variants = &variants
+	}
+	return patch, nil
+}
+
+func (o ProductOption) app() app.ProductOption {
+	return app.ProductOption{Name: o.Name, Values: slices.Clone(o.Values)}
+}
+
+func (v Variant) app() app.Variant {
+	variant := app.Variant{
+		ID:        v.ID,
+		ProductID: v.ProductID,
+		SKU:       v.SKU,
+		Options:   maps.Clone(v.Options),
+		Stock:     v.Stock,
+		Image:     v.Image,
+	}
+	if v.Price != nil {
+		price := v.Price.App()
+		variant.Price = &price
+	}
+	return variant
+}
@@ -0,0 +1,63 @@
+// Package apiv2 is the wire format of version 2 of the API. Compared to v1,
+// money is a decimal string, products list their images and describe their
+// stock in one object, every variant carries its own price, and lists are
+// never null.
+package apiv2
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+
+	app "github.com/gerbenjacobs/go-webshop-course"
+)
+
+// OpenAPI documents version 2 of the API
+//
+//go:embed openapi.json
+var OpenAPI []byte
+
+// Money has the amount in the major unit of the currency, like "12.99"
+type Money struct {
+	Amount   string `json:"amount"`
+	Currency string `json:"currency"`
+}
+
+func NewMoney(m app.Money) Money {
+	return Money{Amount: m.Decimal(), Currency: m.Currency}
+}
+
+// UnmarshalJSON makes sure we never accept half a money value, or an amount in a float
+func (m *Money) UnmarshalJSON(b []byte) error {
+	var v struct {
+		Amount   *string `json:"amount"`
+		Currency string  `json:"currency"`
+	}
+	if err := json.Unmarshal(b, &v); err != nil {
+		return fmt.Errorf("%w: expected an object with a decimal amount string and a currency", app.ErrInvalidMoney)
+	}
+	if v.Amount == nil || len(v.Currency) != 3 {
+		return fmt.Errorf("%w: amount and a 3 letter currency are required", app.ErrInvalidMoney)
+	}
+	money, err := app.ParseMoney(*v.Amount, v.Currency)
+	if err != nil {
+		return err
+	}
+	*m = NewMoney(money)
+	return nil
+}
+
+func (m Money) App() app.Money {
+	// UnmarshalJSON and NewMoney only make valid amounts
+	money, _ := app.ParseMoney(m.Amount, m.Currency)
+	return money
+}
+
+// mapSlice converts every element, the result is never nil so lists are [] rather than null
+func mapSlice[T, D any](in []T, f func(T) D) []D {
+	out := make([]D, len(in))
+	for i, v := range in {
+		out[i] = f(v)
+	}
+	return out
+}
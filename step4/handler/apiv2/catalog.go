@@ -0,0 +1,113 @@
+package apiv2
+
+import (
+	"slices"
+
+	app "github.com/gerbenjacobs/go-webshop-course"
+)
+
+// Category is also the body to create or replace a category, the ID is ignored there
+type Category struct {
+	ID int `json:"id"`
+	// ParentID is null for top level categories
+	ParentID    *int   `json:"parent_id"`
+	Slug        string `json:"slug"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Position    int    `json:"position"`
+}
+
+type CategoryNode struct {
+	Category
+	Children []CategoryNode `json:"children"`
+}
+
+// Collection is also the body to create or replace a collection, the ID is ignored there
+type Collection struct {
+	ID          int    `json:"id"`
+	Slug        string `json:"slug"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	ProductIDs  []int  `json:"product_ids"`
+}
+
+type CategoryPage struct {
+	Category    Category   `json:"category"`
+	Breadcrumbs []Category `json:"breadcrumbs"`
+	Children    []Category `json:"children"`
+	Products    []Product  `json:"products"`
+}
+
+type CollectionPage struct {
+	Collection Collection `json:"collection"`
+	Products   []Product  `json:"products"`
+}
+
+func NewCategory(c app.Category) Category {
+	category := Category{
+		ID:          c.ID,
+		Slug:        c.Slug,
+		Name:        c.Name,
+		Description: c.Description,
+		Position:    c.Position,
+	}
+	if c.ParentID != 0 {
+		category.ParentID = &c.ParentID
+	}
+	return category
+}
+
+func NewCategoryTree(tree []app.CategoryNode) []CategoryNode {
+	return mapSlice(tree, func(n app.CategoryNode) CategoryNode {
+		return CategoryNode{Category: NewCategory(n.Category), Children: NewCategoryTree(n.Children)}
+	})
+}
+
+func NewCategoryPage(page app.CategoryPage) CategoryPage {
+	return CategoryPage{
+		Category:    NewCategory(page.Category),
+		Breadcrumbs: mapSlice(page.Breadcrumbs, NewCategory),
+		Children:    mapSlice(page.Children, NewCategory),
+		Products:    NewProducts(page.Products),
+	}
+}
+
+func NewCollection(c app.Collection) Collection {
+	return Collection{
+		ID:          c.ID,
+		Slug:        c.Slug,
+		Name:        c.Name,
+		Description: c.Description,
+		ProductIDs:  mapSlice(c.ProductIDs, func(id int) int { return id }),
+	}
+}
+
+func NewCollections(collections []app.Collection) []Collection {
+	return mapSlice(collections, NewCollection)
+}
+
+func NewCollectionPage(page app.CollectionPage) CollectionPage {
+	return CollectionPage{Collection: NewCollection(page.Collection), Products: NewProducts(page.Products)}
+}
+
+func (c Category) App() (app.Category, error) {
+	category := app.Category{
+		Slug:        c.Slug,
+		Name:        c.Name,
+		Description: c.Description,
+		Position:    c.Position,
+	}
+	if c.ParentID != nil {
+		category.ParentID = *c.ParentID
+	}
+	return category, nil
+}
+
+func (c Collection) App() (app.Collection, error) {
+	return app.Collection{
+		Slug:        c.Slug,
+		Name:        c.Name,
+		Description: c.Description,
+		ProductIDs:  slices.Clone(c.ProductIDs),
+	}, nil
+}
@@ -0,0 +1,192 @@
+package apiv2
+
+import (
+	"time"
+
+	app "github.com/gerbenjacobs/go-webshop-course"
+	"github.com/gerbenjacobs/go-webshop-course/payment"
+)
+
+type BasketView struct {
+	UserID           int               `json:"user_id"`
+	Lines            []BasketLine      `json:"lines"`
+	ItemCount        int               `json:"item_count"`
+	Subtotal         Money             `json:"subtotal"`
+	Shipping         Money             `json:"shipping"`
+	Coupon           string            `json:"coupon,omitempty"`
+	CouponError      string            `json:"coupon_error,omitempty"`
+	Discounts        []AppliedDiscount `json:"discounts"`
+	Discount         Money             `json:"discount"`
+	Country          string            `json:"country"`
+	PricesIncludeTax bool              `json:"prices_include_tax"`
+	Taxes            []TaxLine         `json:"taxes"`
+	Tax              Money             `json:"tax"`
+	Total            Money             `json:"total"`
+}
+
+// BasketLine has the image of the variant when there is one
+type BasketLine struct {
+	ProductID   int    `json:"product_id"`
+	VariantID   int    `json:"variant_id,omitempty"`
+	SKU         string `json:"sku,omitempty"`
+	Name        string `json:"name"`
+	Variant     string `json:"variant,omitempty"`
+	Image       string `json:"image,omitempty"`
+	UnitPrice   Money  `json:"unit_price"`
+	CategoryIDs []int  `json:"category_ids"`
+	TaxClass    string `json:"tax_class"`
+	Quantity    int    `json:"quantity"`
+	LineTotal   Money  `json:"line_total"`
+	Available   int    `json:"available"`
+	StockStatus string `json:"stock_status"`
+	Discount    Money  `json:"discount"`
+}
+
+type AppliedDiscount struct {
+	PromotionID int    `json:"promotion_id"`
+	Code        string `json:"code,omitempty"`
+	Name        string `json:"name"`
+	Amount      Money  `json:"amount"`
+}
+
+type TaxLine struct {
+	Class  string `json:"class"`
+	Rate   int    `json:"rate_bps"`
+	Base   Money  `json:"base"`
+	Amount Money  `json:"amount"`
+}
+
+type Order struct {
+	ID               int               `json:"id"`
+	UserID           int               `json:"user_id"`
+	Status           string            `json:"status"`
+	Items            []OrderItem       `json:"items"`
+	Subtotal         Money             `json:"subtotal"`
+	Shipping         Money             `json:"shipping"`
+	Discounts        []AppliedDiscount `json:"discounts"`
+	Discount         Money             `json:"discount"`
+	Country          string            `json:"country"`
+	PricesIncludeTax bool              `json:"prices_include_tax"`
+	Taxes            []TaxLine         `json:"taxes"`
+	Tax              Money             `json:"tax"`
+	Total            Money             `json:"total"`
+	PaymentID        string            `json:"payment_id,omitempty"`
+	CreatedAt        time.Time         `json:"created_at"`
+	ReservedUntil    time.Time         `json:"reserved_until"`
+}
+
+type OrderItem struct {
+	ProductID int    `json:"product_id"`
+	VariantID int    `json:"variant_id,omitempty"`
+	SKU       string `json:"sku,omitempty"`
+	Name      string `json:"name"`
+	Variant   string `json:"variant,omitempty"`
+	UnitPrice Money  `json:"unit_price"`
+	TaxClass  string `json:"tax_class"`
+	Quantity  int    `json:"quantity"`
+}
+
+type PaymentAuthorization struct {
+	PaymentID   string `json:"payment_id"`
+	Status      string `json:"status"`
+	RedirectURL string `json:"redirect_url,omitempty"`
+}
+
+type TokenPair struct {
+	AccessToken  string `json:"access_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int    `json:"expires_in"`
+	RefreshToken string `json:"refresh_token"`
+}
+
+func NewBasketView(b app.BasketView) BasketView {
+	return BasketView{
+		UserID:           b.UserID,
+		Lines:            mapSlice(b.Lines, newBasketLine),
+		ItemCount:        b.ItemCount,
+		Subtotal:         NewMoney(b.Subtotal),
+		Shipping:         NewMoney(b.Shipping),
+		Coupon:           b.Coupon,
+		CouponError:      b.CouponError,
+		Discounts:        mapSlice(b.Discounts, newAppliedDiscount),
+		Discount:         NewMoney(b.Discount),
+		Country:          b.Country,
+		PricesIncludeTax: b.PricesIncludeTax,
+		Taxes:            mapSlice(b.Taxes, newTaxLine),
+		Tax:              NewMoney(b.Tax),
+		Total:            NewMoney(b.Total),
+	}
+}
+
+func newBasketLine(l app.BasketLine) BasketLine {
+	return BasketLine{
+		ProductID:   l.ProductID,
+		VariantID:   l.VariantID,
+		SKU:         l.SKU,
+		Name:        l.Name,
+		Variant:     l.Variant,
+		Image:       l.Image,
+		UnitPrice:   NewMoney(l.UnitPrice),
+		CategoryIDs: mapSlice(l.CategoryIDs, func(id int) int { return id }),
+		TaxClass:    string(l.TaxClass),
+		Quantity:    l.Quantity,
+		LineTotal:   NewMoney(l.LineTotal),
+		Available:   l.Available,
+		StockStatus: string(l.StockStatus),
+		Discount:    NewMoney(l.Discount),
+	}
+}
+
+func newAppliedDiscount(d app.AppliedDiscount) AppliedDiscount {
+	return AppliedDiscount{PromotionID: d.PromotionID, Code: d.Code, Name: d.Name, Amount: NewMoney(d.Amount)}
+}
+
+func newTaxLine(t app.TaxLine) TaxLine {
+	return TaxLine{Class: string(t.Class), Rate: int(t.Rate), Base: NewMoney(t.Base), Amount: NewMoney(t.Amount)}
+}
+
+func NewOrder(o app.Order) Order {
+	return Order{
+		ID:               o.ID,
+		UserID:           o.UserID,
+		Status:           string(o.Status),
+		Items:            mapSlice(o.Items, newOrderItem),
+		Subtotal:         NewMoney(o.Subtotal),
+		Shipping:         NewMoney(o.Shipping),
+		Discounts:        mapSlice(o.Discounts, newAppliedDiscount),
+		Discount:         NewMoney(o.Discount),
+		Country:          o.Country,
+		PricesIncludeTax: o.PricesIncludeTax,
+		Taxes:            mapSlice(o.Taxes, newTaxLine),
+		Tax:              NewMoney(o.Tax),
+		Total:            NewMoney(o.Total),
+		PaymentID:        o.PaymentID,
+		CreatedAt:        o.CreatedAt,
+		ReservedUntil:    o.ReservedUntil,
+	}
+}
+
+func NewOrders(orders []app.Order) []Order {
+	return mapSlice(orders, NewOrder)
+}
+
+func newOrderItem(i app.OrderItem) OrderItem {
+	return OrderItem{
+		ProductID: i.ProductID,
+		VariantID: i.VariantID,
+		SKU:       i.SKU,
+		Name:      i.Name,
+		Variant:   i.Variant,
+		UnitPrice: NewMoney(i.UnitPrice),
+		TaxClass:  string(i.TaxClass),
+		Quantity:  i.Quantity,
+	}
+}
+
+func NewPaymentAuthorization(a payment.Authorization) PaymentAuthorization {
+	return PaymentAuthorization{PaymentID: a.PaymentID, Status: string(a.Status), RedirectURL: a.RedirectURL}
+}
+
+func NewTokenPair(t app.TokenPair) TokenPair {
+	return TokenPair(t)
+}
@@ -0,0 +1,277 @@
+package apiv2
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+
+	app "github.com/gerbenjacobs/go-webshop-course"
+	"github.com/gerbenjacobs/go-webshop-course/search"
+)
+
+type Product struct {
+	ID          int             `json:"id"`
+	Name        string          `json:"name"`
+	Description string          `json:"description"`
+	Images      []Image         `json:"images"`
+	Price       Money           `json:"price"`
+	TaxClass    string          `json:"tax_class"`
+	CategoryIDs []int           `json:"category_ids"`
+	Options     []ProductOption `json:"options"`
+	Variants    []Variant       `json:"variants"`
+	// Stock adds up the stock of the variants for products that have them
+	Stock Stock `json:"stock"`
+}
+
+type Image struct {
+	URL string `json:"url"`
+	Alt string `json:"alt"`
+}
+
+type ProductOption struct {
+	Name   string   `json:"name"`
+	Values []string `json:"values"`
+}
+
+// Variant has its own price and images, they're the product's unless the variant overrides them
+type Variant struct {
+	ID      int               `json:"id"`
+	SKU     string            `json:"sku"`
+	Options map[string]string `json:"options"`
+	Price   Money             `json:"price"`
+	Images  []Image           `json:"images"`
+	Stock   Stock             `json:"stock"`
+}
+
+// Stock tells what's on hand, what's held for unpaid orders and what we can still sell
+type Stock struct {
+	OnHand    int    `json:"on_hand"`
+	Reserved  int    `json:"reserved"`
+	Available int    `json:"available"`
+	Status    string `json:"status"`
+}
+
+type SearchResult struct {
+	Product Product `json:"product"`
+	Score   float64 `json:"score"`
+	// Highlights has the name, description and categories with the matching words in <mark>
+	Highlights map[string]string `json:"highlights"`
+}
+
+// ProductInput is the body to create or replace a product
+type ProductInput struct {
+	Name        string          `json:"name"`
+	Description string          `json:"description"`
+	Images      []ImageInput    `json:"images"`
+	Price       Money           `json:"price"`
+	TaxClass    string          `json:"tax_class"`
+	CategoryIDs []int           `json:"category_ids"`
+	Options     []ProductOption `json:"options"`
+	Variants    []VariantInput  `json:"variants"`
+	Stock       StockInput      `json:"stock"`
+}
+
+// ImageInput only has the URL, the alt text is the name of the product
+type ImageInput struct {
+	URL string `json:"url"`
+}
+
+type VariantInput struct {
+	// ID is left out for new variants
+	ID      int               `json:"id"`
+	SKU     string            `json:"sku"`
+	Options map[string]string `json:"options"`
+	// Price and Images are left out when they're the same as the product's
+	Price  *Money       `json:"price"`
+	Images []ImageInput `json:"images"`
+	Stock  StockInput   `json:"stock"`
+}
+
+type StockInput struct {
+	OnHand int `json:"on_hand"`
+}
+
+// ProductPatch changes the fields that are sent
+type ProductPatch struct {
+	Name        *string          `json:"name"`
+	Description *string          `json:"description"`
+	Images      *[]ImageInput    `json:"images"`
+	Price       *Money           `json:"price"`
+	TaxClass    *string          `json:"tax_class"`
+	CategoryIDs *[]int           `json:"category_ids"`
+	Options     *[]ProductOption `json:"options"`
+	Variants    *[]VariantInput  `json:"variants"`
+	Stock       *StockInput      `json:"stock"`
+}
+
+func NewProduct(p app.Product) Product {
+	product := Product{
+		ID:          p.ID,
+		Name:        p.Name,
+		Description: p.Description,
+		Images:      newImages(p.Image, p.Name),
+		Price:       NewMoney(p.Price),
+		TaxClass:    string(p.TaxClass),
+		CategoryIDs: mapSlice(p.CategoryIDs, func(id int) int { return id }),
+		Options:     mapSlice(p.Options, newProductOption),
+		Variants:    mapSlice(p.Variants, func(v app.Variant) Variant { return newVariant(p, v) }),
+		Stock: Stock{
+			OnHand:    p.Stock,
+			Reserved:  p.Reserved,
+			Available: p.Available(),
+			Status:    string(p.StockStatus()),
+		},
+	}
+	if p.HasVariants() {
+		product.Stock.OnHand, product.Stock.Reserved = 0, 0
+		for _, v := range p.Variants {
+			product.Stock.OnHand += v.Stock
+			product.Stock.Reserved += v.Reserved
+		}
+	}
+	return product
+}
+
+func NewProducts(products []app.Product) []Product {
+	return mapSlice(products, NewProduct)
+}
+
+// highlightFields renames the fields of the search index to the ones of our Product
+var highlightFields = map[string]string{
+	search.FieldName:        "name",
+	search.FieldDescription: "description",
+	search.FieldCategories:  "categories",
+}
+
+func NewSearchResults(results []app.SearchResult) []SearchResult {
+	return mapSlice(results, func(r app.SearchResult) SearchResult {
+		highlights := make(map[string]string, len(r.Highlights))
+		for field, html := range r.Highlights {
+			if name, ok := highlightFields[field]; ok {
+				highlights[name] = html
+			}
+		}
+		return SearchResult{Product: NewProduct(r.Product), Score: r.Score, Highlights: highlights}
+	})
+}
+
+func newImages(url, alt string) []Image {
+	if url == "" {
+		return []Image{}
+	}
+	return []Image{{URL: url, Alt: alt}}
+}
+
+func newProductOption(o app.ProductOption) ProductOption {
+	return ProductOption{Name: o.Name, Values: mapSlice(o.Values, func(v string) string { return v })}
+}
+
+func newVariant(p app.Product, v app.Variant) Variant {
+	return Variant{
+		ID:      v.ID,
+		SKU:     v.SKU,
+		Options: maps.Clone(v.Options),
+		Price:   NewMoney(p.VariantPrice(v)),
+		Images:  newImages(p.VariantImage(v), p.Name+" "+p.VariantLabel(v)),
+		Stock: Stock{
+			OnHand:    v.Stock,
+			Reserved:  v.Reserved,
+			Available: v.Available(),
+			Status:    string(v.StockStatus()),
+		},
+	}
+}
+
+func (p ProductInput) App() (app.Product, error) {
+	fe := app.FieldErrors{}
+	product := app.Product{
+		Name:        p.Name,
+		Description: p.Description,
+		Image:       imageURL(fe, "images", p.Images),
+		Price:       p.Price.App(),
+		TaxClass:    app.TaxClass(p.TaxClass),
+		CategoryIDs: slices.Clone(p.CategoryIDs),
+		Stock:       p.Stock.OnHand,
+		Options:     mapInput(p.Options, ProductOption.app),
+	}
+	product.Variants = variantsApp(fe, "variants", p.Variants)
+	return product, fe.Err()
+}
+
+func (pp ProductPatch) App() (app.ProductPatch, error) {
+	fe := app.FieldErrors{}
+	patch := app.ProductPatch{
+		Name:        pp.Name,
+		Description: pp.Description,
+		CategoryIDs: pp.CategoryIDs,
+	}
+	if pp.Images != nil {
+		image := imageURL(fe, "images", *pp.Images)
+		patch.Image = &image
+	}
+	if pp.Price != nil {
+		price := pp.Price.App()
+		patch.Price = &price
+	}
+	if pp.TaxClass != nil {
+		class := app.TaxClass(*pp.TaxClass)
+		patch.TaxClass = &class
+	}
+	if pp.Stock != nil {
+		patch.Stock = &pp.Stock.OnHand
+	}
+	if pp.Options != nil {
+		options := mapInput(*pp.Options, ProductOption.app)
+		patch.Options = &options
+	}
+	if pp.Variants != nil {
+		variants := variantsApp(fe, "variants", *pp.Variants)
+		patch.Variants = &variants
+	}
+	return patch, fe.Err()
+}
+
+// imageURL picks the URL out of the images, our products have one image for now
+func imageURL(fe app.FieldErrors, field string, images []ImageInput) string {
+	switch len(images) {
+	case 0:
+		return ""
+	case 1:
+		return images[0].URL
+	}
+	fe.Add(field, "can have one image at most")
+	return ""
+}
+
+func variantsApp(fe app.FieldErrors, field string, variants []VariantInput) []app.Variant {
+	if variants == nil {
+		return nil
+	}
+	out := make([]app.Variant, len(variants))
+	for i, v := range variants {
+		out[i] = app.Variant{
+			ID:      v.ID,
+			SKU:     v.SKU,
+			Options: maps.Clone(v.Options),
+			Image:   imageURL(fe, fmt.Sprintf("%s[%d].images", field, i), v.Images),
+			Stock:   v.Stock.OnHand,
+		}
+		if v.Price != nil {
+			price := v.Price.App()
+			out[i].Price = &price
+		}
+	}
+	return out
+}
+
+func (o ProductOption) app() app.ProductOption {
+	return app.ProductOption{Name: o.Name, Values: slices.Clone(o.Values)}
+}
+
+// mapInput converts the elements of a body, a list that's left out stays nil
+func mapInput[T, D any](in []T, f func(T) D) []D {
+	if in == nil {
+		return nil
+	}
+	return mapSlice(in, f)
+}
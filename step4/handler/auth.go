@@ -0,0 +1,129 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+
+	app "github.com/gerbenjacobs/go-webshop-course"
+	"github.com/julienschmidt/httprouter"
+)
+
+type contextKey string
+
+const userIDKey contextKey = "user_id"
+
+func withUserID(ctx context.Context, userID int) context.Context {
+	return context.WithValue(ctx, userIDKey, userID)
+}
+
+func userIDFromContext(ctx context.Context) (int, bool) {
+	userID, ok := ctx.Value(userIDKey).(int)
+	return userID, ok
+}
+
+// apiAuth is middleware that only lets requests with a valid
+// `Authorization: Bearer <token>` header through, the user ID
+// from the token is available via userIDFromContext
+func (h *Handler) apiAuth(next httprouter.Handle) httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || token == "" {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="webshop"`)
+			h.apiProblem(w, r, problemUnauthorized, "missing bearer token")
+			return
+		}
+
+		userID, err := h.Auth.Authenticate(r.Context(), token)
+		if err != nil {
+			h.logger.WarnContext(r.Context(), "invalid bearer token", "error", err)
+			w.Header().Set("WWW-Authenticate", `Bearer realm="webshop", error="invalid_token"`)
+			h.apiProblem(w, r, problemUnauthorized, "invalid bearer token")
+			return
+		}
+
+		next(w, r.WithContext(withUserID(r.Context(), userID)), p)
+	}
+}
+
+// apiAdmin is middleware that only lets admins through, it authenticates like apiAuth
+func (h *Handler) apiAdmin(next httprouter.Handle) httprouter.Handle {
+	return h.apiAuth(func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+		userID, _ := userIDFromContext(r.Context())
+		user, err := h.User.GetUser(r.Context(), userID)
+		switch {
+		case errors.Is(err, app.ErrUserNotFound):
+			h.apiProblem(w, r, problemUnauthorized, "unknown user")
+			return
+		case err != nil:
+			h.apiError(w, r, err, "fetch user")
+			return
+		}
+		if !user.IsAdmin() {
+			h.logger.WarnContext(r.Context(), "non-admin tried to use the admin API", "user_id", userID, "url", r.URL.Path)
+			h.apiProblem(w, r, problemForbidden, "admins only")
+			return
+		}
+		next(w, r, p)
+	})
+}
+
+// apiGuest is middleware for endpoints that also work without logging in,
+// requests with a bearer token are authenticated like apiAuth, others
+// get a guest ID from a signed cookie. Only requests that change the basket
+// hand out a new guest ID, a GET without one has no user ID in its context.
+func (h *Handler) apiGuest(next httprouter.Handle) httprouter.Handle {
+	authenticated := h.apiAuth(next)
+	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+		if r.Header.Get("Authorization") != "" {
+			authenticated(w, r, p)
+			return
+		}
+
+		guest, ok, err := h.guestID(r, w, r.Method != http.MethodGet)
+		if err != nil {
+			h.apiError(w, r, err, "create guest")
+			return
+		}
+		if ok {
+			r = r.WithContext(withUserID(r.Context(), guest))
+		}
+		next(w, r, p)
+	}
+}
+
+func (h *Handler) apiToken(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	r.ParseForm()
+
+	var (
+		tokens app.TokenPair
+		err    error
+	)
+	switch grantType := r.PostForm.Get("grant_type"); grantType {
+	case "password":
+		tokens, err = h.Auth.IssueTokens(r.Context(), r.PostForm.Get("email"), r.PostForm.Get("password"))
+	case "refresh_token":
+		tokens, err = h.Auth.RefreshTokens(r.Context(), r.PostForm.Get("refresh_token"))
+	default:
+		h.apiProblem(w, r, problemInvalidRequest, "unsupported grant_type, use password or refresh_token")
+		return
+	}
+	if err != nil {
+		if errors.Is(err, app.ErrInvalidCredentials) || errors.Is(err, app.ErrInvalidToken) {
+			h.logger.WarnContext(r.Context(), "failed to issue token", "error", err)
+		}
+		h.apiError(w, r, err, "issue token")
+		return
+	}
+
+	// a guest that logs in through the API keeps their basket
+	if userID, err := h.Auth.Authenticate(r.Context(), tokens.AccessToken); err == nil {
+		if err := h.mergeGuestBasket(r, w, userID); err != nil {
+			h.logger.Error("failed to merge guest basket", "error", err)
+		}
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	h.writeJSON(w, r, http.StatusOK, apiVersionFromContext(r.Context()).tokens(tokens))
+}
@@ -0,0 +1,60 @@
+package handler_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gerbenjacobs/go-webshop-course/handler/handlertest"
+)
+
+func TestAPIGuest(t *testing.T) {
+	h := handlertest.NewHandler(t)
+
+	// looking at the basket doesn't make anyone a guest
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/basket", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("GET /api/basket status = %d, want %d: %s", rec.Code, http.StatusOK, rec.Body)
+	}
+	if cookie := rec.Header().Get("Set-Cookie"); cookie != "" {
+		t.Errorf("GET /api/basket set a cookie: %s", cookie)
+	}
+	var basket struct {
+		Lines []json.RawMessage `json:"lines"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&basket); err != nil {
+		t.Fatalf("failed to decode basket: %v", err)
+	}
+	if len(basket.Lines) != 0 {
+		t.Errorf("basket has %d lines, want none", len(basket.Lines))
+	}
+
+	// adding something does
+	req := httptest.NewRequest(http.MethodPost, "/api/basket/items", strings.NewReader(`{"product_id": 1, "quantity": 1}`))
+	req.Header.Set("Content-Type", "application/json")
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if rec.Code >= http.StatusBadRequest {
+		t.Fatalf("POST /api/basket/items status = %d: %s", rec.Code, rec.Body)
+	}
+	cookies := rec.Result().Cookies()
+	if len(cookies) == 0 {
+		t.Fatal("POST /api/basket/items didn't set a cookie")
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/api/basket", nil)
+	for _, c := range cookies {
+		req.AddCookie(c)
+	}
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if err := json.NewDecoder(rec.Body).Decode(&basket); err != nil {
+		t.Fatalf("failed to decode basket: %v", err)
+	}
+	if len(basket.Lines) != 1 {
+		t.Errorf("basket has %d lines, want 1", len(basket.Lines))
+	}
+}
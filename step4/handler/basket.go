@@ -0,0 +1,225 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"html/template"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	app "github.com/gerbenjacobs/go-webshop-course"
+	"github.com/julienschmidt/httprouter"
+)
+
+// basketOwner returns the ID of whoever owns the basket for this request,
+// that's the logged-in user or otherwise a (new) guest
+func (h *Handler) basketOwner(r *http.Request, w http.ResponseWriter) (int, error) {
+	if userID, ok := h.sessionUserID(r); ok {
+		return userID, nil
+	}
+	guest, _, err := h.guestID(r, w, true)
+	return guest, err
+}
+
+func (h *Handler) addToBasket(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	r.ParseForm()
+	productIDParam := r.PostForm.Get("product_id")
+
+	productID, err := strconv.Atoi(productIDParam)
+	if err != nil {
+		h.logger.ErrorContext(r.Context(), "couldn't convert product ID to int", "error", err)
+		_ = h.storeAndSaveFlash(r, w, "warning|Invalid product ID given")
+		http.Redirect(w, r, "/", http.StatusSeeOther)
+		return
+	}
+	productURL := fmt.Sprintf("/product/%d", productID)
+	variantID, err := formVariantID(r)
+	if err != nil {
+		_ = h.storeAndSaveFlash(r, w, "warning|Invalid variant given")
+		http.Redirect(w, r, productURL, http.StatusSeeOther)
+		return
+	}
+
+	userID, err := h.basketOwner(r, w)
+	if err != nil {
+		h.logger.Error("failed to identify basket owner", "error", err)
+		http.Error(w, "something went wrong", http.StatusInternalServerError)
+		return
+	}
+
+	// make sure the basket exists before we add to it
+	if _, err := h.Basket.GetBasket(r.Context(), userID); err != nil {
+		h.logger.Error("failed to fetch basket", "error", err)
+		http.Error(w, "something went wrong", http.StatusInternalServerError)
+		return
+	}
+	err = h.Basket.AddToBasket(r.Context(), userID, productID, variantID, 1)
+	switch {
+	case errors.Is(err, app.ErrVariantRequired), errors.Is(err, app.ErrVariantNotFound):
+		_ = h.storeAndSaveFlash(r, w, "warning|Please pick one of the options")
+		http.Redirect(w, r, productURL, http.StatusSeeOther)
+		return
+	case errors.Is(err, app.ErrInsufficientStock):
+		_ = h.storeAndSaveFlash(r, w, "warning|Sorry, we don't have any more of this in stock")
+		http.Redirect(w, r, productURL, http.StatusSeeOther)
+		return
+	case err != nil:
+		h.logger.Error("failed to add to basket", "error", err)
+		_ = h.storeAndSaveFlash(r, w, "danger|We couldn't add this product to your basket")
+		http.Redirect(w, r, productURL, http.StatusSeeOther)
+		return
+	}
+
+	_ = h.storeAndSaveFlash(r, w, "success|Added to your basket")
+	http.Redirect(w, r, productURL, http.StatusSeeOther)
+}
+
+func (h *Handler) showBasket(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	tmpl := template.Must(template.ParseFiles(
+		"static/layout.html",
+		"static/basket/basket.html",
+	))
+
+	// don't hand out a guest ID just for looking at an empty basket
+	userID, ok := h.sessionUserID(r)
+	if !ok {
+		guest, hasGuest, err := h.guestID(r, w, false)
+		if err != nil {
+			h.logger.Error("failed to identify basket owner", "error", err)
+			http.Error(w, "something went wrong", http.StatusInternalServerError)
+			return
+		}
+		userID, ok = guest, hasGuest
+	}
+
+	country := strings.ToUpper(r.URL.Query().Get("country"))
+	if !slices.Contains(h.Tax.Countries(), country) {
+		country = h.Tax.DefaultCountry()
+	}
+
+	var basket app.BasketView
+	if ok {
+		var err error
+		basket, err = h.Basket.GetBasketView(r.Context(), userID, country)
+		if err != nil {
+			h.logger.Error("failed to fetch basket", "error", err)
+			http.Error(w, "failed to fetch basket", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	type pageData struct {
+		User      *app.User
+		Flashes   map[string]string
+		Basket    app.BasketView
+		Country   string
+		Countries []string
+	}
+	flashes, err := h.getFlashes(r, w)
+	if err != nil {
+		h.logger.Warn("failed to get flashes", "error", err)
+	}
+	data := pageData{
+		User:      h.currentUser(r),
+		Flashes:   flashes,
+		Basket:    basket,
+		Country:   country,
+		Countries: h.Tax.Countries(),
+	}
+
+	if err := tmpl.Execute(w, data); err != nil {
+		h.logger.Error("failed to execute layout", "error", err)
+		http.Error(w, "failed to create layout", http.StatusInternalServerError)
+		return
+	}
+}
+
+func (h *Handler) setBasketQuantity(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	r.ParseForm()
+	productID, err := strconv.Atoi(r.PostForm.Get("product_id"))
+	if err != nil {
+		h.logger.ErrorContext(r.Context(), "couldn't convert product ID to int", "error", err)
+		_ = h.storeAndSaveFlash(r, w, "warning|Invalid product ID given")
+		http.Redirect(w, r, "/basket", http.StatusSeeOther)
+		return
+	}
+	variantID, err := formVariantID(r)
+	if err != nil {
+		_ = h.storeAndSaveFlash(r, w, "warning|Invalid variant given")
+		http.Redirect(w, r, "/basket", http.StatusSeeOther)
+		return
+	}
+	quantity, err := strconv.Atoi(r.PostForm.Get("quantity"))
+	if err != nil {
+		_ = h.storeAndSaveFlash(r, w, "warning|Invalid quantity given")
+		http.Redirect(w, r, "/basket", http.StatusSeeOther)
+		return
+	}
+
+	userID, err := h.basketOwner(r, w)
+	if err != nil {
+		h.logger.Error("failed to identify basket owner", "error", err)
+		http.Error(w, "something went wrong", http.StatusInternalServerError)
+		return
+	}
+
+	err = h.Basket.SetQuantity(r.Context(), userID, productID, variantID, quantity)
+	switch {
+	case errors.Is(err, app.ErrInvalidQuantity):
+		_ = h.storeAndSaveFlash(r, w, "warning|Invalid quantity given")
+	case errors.Is(err, app.ErrProductNotFound), errors.Is(err, app.ErrVariantNotFound), errors.Is(err, app.ErrVariantRequired):
+		_ = h.storeAndSaveFlash(r, w, "warning|This product is no longer available")
+	case errors.Is(err, app.ErrInsufficientStock):
+		_ = h.storeAndSaveFlash(r, w, "warning|Sorry, we don't have that many in stock")
+	case errors.Is(err, app.ErrBasketNotFound):
+		_ = h.storeAndSaveFlash(r, w, "warning|Your basket is empty")
+	case err != nil:
+		h.logger.Error("failed to set basket quantity", "error", err)
+		_ = h.storeAndSaveFlash(r, w, "danger|We couldn't update your basket")
+	}
+	http.Redirect(w, r, "/basket", http.StatusSeeOther)
+}
+
+func (h *Handler) applyCoupon(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	r.ParseForm()
+	userID, err := h.basketOwner(r, w)
+	if err != nil {
+		h.logger.Error("failed to identify basket owner", "error", err)
+		http.Error(w, "something went wrong", http.StatusInternalServerError)
+		return
+	}
+
+	_, err = h.Basket.ApplyCoupon(r.Context(), userID, r.PostForm.Get("code"))
+	switch {
+	case errors.Is(err, app.ErrPromotionNotFound):
+		_ = h.storeAndSaveFlash(r, w, "warning|We don't know that coupon")
+	case errors.Is(err, app.ErrPromotionNotActive):
+		_ = h.storeAndSaveFlash(r, w, "warning|This coupon is not valid right now")
+	case errors.Is(err, app.ErrPromotionUsedUp):
+		_ = h.storeAndSaveFlash(r, w, "warning|This coupon has already been used")
+	case errors.Is(err, app.ErrPromotionNotApplicable):
+		_ = h.storeAndSaveFlash(r, w, "warning|This coupon doesn't apply to your basket")
+	case err != nil:
+		h.logger.Error("failed to apply coupon", "error", err)
+		_ = h.storeAndSaveFlash(r, w, "danger|We couldn't apply your coupon")
+	default:
+		_ = h.storeAndSaveFlash(r, w, "success|Your coupon has been applied")
+	}
+	http.Redirect(w, r, "/basket", http.StatusSeeOther)
+}
+
+func (h *Handler) removeCoupon(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	userID, err := h.basketOwner(r, w)
+	if err != nil {
+		h.logger.Error("failed to identify basket owner", "error", err)
+		http.Error(w, "something went wrong", http.StatusInternalServerError)
+		return
+	}
+	if err := h.Basket.RemoveCoupon(r.Context(), userID); err != nil {
+		h.logger.Error("failed to remove coupon", "error", err)
+		_ = h.storeAndSaveFlash(r, w, "danger|We couldn't remove your coupon")
+	}
+	http.Redirect(w, r, "/basket", http.StatusSeeOther)
+}
@@ -0,0 +1,128 @@
+package handler
+
+import (
+	"errors"
+	"html/template"
+	"net/http"
+
+	app "github.com/gerbenjacobs/go-webshop-course"
+	"github.com/julienschmidt/httprouter"
+)
+
+func (h *Handler) categoryBySlug(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+	tmpl := template.Must(template.ParseFiles(
+		"static/layout.html",
+		"static/catalog/category.html",
+	))
+
+	page, err := h.Catalog.ShowCategory(r.Context(), p.ByName("slug"))
+	switch {
+	case errors.Is(err, app.ErrCategoryNotFound):
+		h.notFound(w, r)
+		return
+	case err != nil:
+		h.logger.Error("failed to fetch category", "error", err)
+		http.Error(w, "something went wrong", http.StatusInternalServerError)
+		return
+	}
+
+	type pageData struct {
+		User    *app.User
+		Flashes map[string]string
+		Page    app.CategoryPage
+	}
+	flashes, err := h.getFlashes(r, w)
+	if err != nil {
+		h.logger.Warn("failed to get flashes", "error", err)
+	}
+	data := pageData{
+		User:    h.currentUser(r),
+		Flashes: flashes,
+		Page:    page,
+	}
+
+	if err := tmpl.Execute(w, data); err != nil {
+		h.logger.Error("failed to execute layout", "error", err)
+		http.Error(w, "failed to create layout", http.StatusInternalServerError)
+		return
+	}
+}
+
+func (h *Handler) collectionBySlug(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+	tmpl := template.Must(template.ParseFiles(
+		"static/layout.html",
+		"static/catalog/collection.html",
+	))
+
+	page, err := h.Catalog.ShowCollection(r.Context(), p.ByName("slug"))
+	switch {
+	case errors.Is(err, app.ErrCollectionNotFound):
+		h.notFound(w, r)
+		return
+	case err != nil:
+		h.logger.Error("failed to fetch collection", "error", err)
+		http.Error(w, "something went wrong", http.StatusInternalServerError)
+		return
+	}
+
+	type pageData struct {
+		User    *app.User
+		Flashes map[string]string
+		Page    app.CollectionPage
+	}
+	flashes, err := h.getFlashes(r, w)
+	if err != nil {
+		h.logger.Warn("failed to get flashes", "error", err)
+	}
+	data := pageData{
+		User:    h.currentUser(r),
+		Flashes: flashes,
+		Page:    page,
+	}
+
+	if err := tmpl.Execute(w, data); err != nil {
+		h.logger.Error("failed to execute layout", "error", err)
+		http.Error(w, "failed to create layout", http.StatusInternalServerError)
+		return
+	}
+}
+
+func (h *Handler) apiCategories(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	tree, err := h.Catalog.CategoryTree(r.Context())
+	if err != nil {
+		h.apiError(w, r, err, "fetch categories")
+		return
+	}
+
+	h.writeJSON(w, r, http.StatusOK, apiVersionFromContext(r.Context()).categoryTree(tree))
+}
+
+func (h *Handler) apiCategoryBySlug(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+	page, err := h.Catalog.ShowCategory(r.Context(), p.ByName("slug"))
+	if err != nil {
+		h.apiError(w, r, err, "fetch category")
+		return
+	}
+
+	h.writeJSON(w, r, http.StatusOK, apiVersionFromContext(r.Context()).categoryPage(page))
+}
+
+func (h *Handler) apiCollections(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	collections, err := h.Catalog.ListCollections(r.Context())
+	if err != nil {
+		h.apiError(w, r, err, "fetch collections")
+		return
+	}
+
+	h.writeJSON(w, r, http.StatusOK, apiVersionFromContext(r.Context()).collections(collections))
+}
+
+func (h *Handler) apiCollectionBySlug(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+	page, err := h.Catalog.ShowCollection(r.Context(), p.ByName("slug"))
+	if err != nil {
+		h.apiError(w, r, err, "fetch collection")
+		return
+	}
+
+	h.writeJSON(w, r, http.StatusOK, apiVersionFromContext(r.Context()).collectionPage(page))
+}
@@ -0,0 +1,518 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"html/template"
+	"net/http"
+	"slices"
+	"strconv"
+
+	app "github.com/gerbenjacobs/go-webshop-course"
+	"github.com/julienschmidt/httprouter"
+)
+
+// webAdmin is middleware for the admin pages, visitors that aren't logged in
+// are sent to the login page and customers back to the shop
+func (h *Handler) webAdmin(next httprouter.Handle) httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+		user := h.currentUser(r)
+		if user == nil {
+			_ = h.storeAndSaveFlash(r, w, "warning|Please log in first")
+			http.Redirect(w, r, "/login", http.StatusSeeOther)
+			return
+		}
+		if !user.IsAdmin() {
+			h.logger.WarnContext(r.Context(), "non-admin tried to use the admin pages", "user_id", user.ID, "url", r.URL.Path)
+			_ = h.storeAndSaveFlash(r, w, "danger|Only admins can go there")
+			http.Redirect(w, r, "/", http.StatusSeeOther)
+			return
+		}
+		if r.Method != http.MethodGet && r.Method != http.MethodHead && !h.validCSRFToken(r) {
+			h.logger.WarnContext(r.Context(), "admin form without a valid CSRF token", "user_id", user.ID, "url", r.URL.Path)
+			_ = h.storeAndSaveFlash(r, w, "danger|The form has expired, please try again")
+			http.Redirect(w, r, "/admin", http.StatusSeeOther)
+			return
+		}
+		next(w, r, p)
+	}
+}
+
+func (h *Handler) adminProducts(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	tmpl := template.Must(template.ParseFiles(
+		"static/layout.html",
+		"static/admin/products.html",
+	))
+
+	page, err := h.Product.ListProducts(r.Context(), app.ProductQuery{})
+	if err != nil {
+		h.logger.Error("failed to fetch products", "error", err)
+		http.Error(w, "failed to fetch products", http.StatusInternalServerError)
+		return
+	}
+
+	type pageData struct {
+		User     *app.User
+		Flashes  map[string]string
+		Products []app.Product
+	}
+	flashes, err := h.getFlashes(r, w)
+	if err != nil {
+		h.logger.Warn("failed to get flashes", "error", err)
+	}
+	data := pageData{
+		User:     h.currentUser(r),
+		Flashes:  flashes,
+		Products: page.Products,
+	}
+
+	if err := tmpl.Execute(w, data); err != nil {
+		h.logger.Error("failed to execute layout", "error", err)
+		http.Error(w, "failed to create layout", http.StatusInternalServerError)
+		return
+	}
+}
+
+// adminProductForm shows the form to edit a product, the product ID "new" gives an empty form
+func (h *Handler) adminProductForm(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+	product := app.Product{
+		Price:    app.NewMoney(0, app.DefaultCurrency),
+		TaxClass: app.TaxClassStandard,
+	}
+	if p.ByName("id") != "new" {
+		productID, err := strconv.Atoi(p.ByName("id"))
+		if err != nil {
+			_ = h.storeAndSaveFlash(r, w, "warning|Invalid product ID given")
+			http.Redirect(w, r, "/admin/products", http.StatusSeeOther)
+			return
+		}
+		product, err = h.Product.ShowProduct(r.Context(), productID)
+		switch {
+		case errors.Is(err, app.ErrProductNotFound):
+			h.notFound(w, r)
+			return
+		case err != nil:
+			h.logger.Error("failed to fetch product", "error", err)
+			http.Error(w, "failed to fetch product", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	h.renderProductForm(w, r, http.StatusOK, product, nil)
+}
+
+// adminProductSubmit saves the product form, creating the product when the ID is "new".
+// Invalid input shows the form again with the problem of every field.
+func (h *Handler) adminProductSubmit(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+	var (
+		product app.Product
+		err     error
+	)
+	isNew := p.ByName("id") == "new"
+	if !isNew {
+		productID, err := strconv.Atoi(p.ByName("id"))
+		if err != nil {
+			_ = h.storeAndSaveFlash(r, w, "warning|Invalid product ID given")
+			http.Redirect(w, r, "/admin/products", http.StatusSeeOther)
+			return
+		}
+		product, err = h.Product.ShowProduct(r.Context(), productID)
+		switch {
+		case errors.Is(err, app.ErrProductNotFound):
+			h.notFound(w, r)
+			return
+		case err != nil:
+			h.logger.Error("failed to fetch product", "error", err)
+			http.Error(w, "failed to fetch product", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	r.ParseForm()
+	product, fields := productFromForm(r, product)
+	if len(fields) > 0 {
+		// show the other problems too, rather than one after the other
+		var invalid app.FieldErrors
+		if errors.As(product.Validate(), &invalid) {
+			for field, problem := range invalid {
+				fields.Add(field, "%s", problem)
+			}
+		}
+		h.renderProductForm(w, r, http.StatusUnprocessableEntity, product, fields)
+		return
+	}
+
+	var saved app.Product
+	if isNew {
+		saved, err = h.Product.CreateProduct(r.Context(), product)
+	} else {
+		saved, err = h.Product.UpdateProduct(r.Context(), product)
+	}
+	switch {
+	case errors.As(err, &fields):
+		h.renderProductForm(w, r, http.StatusUnprocessableEntity, product, fields)
+		return
+	case errors.Is(err, app.ErrSKUExists):
+		h.renderProductForm(w, r, http.StatusConflict, product, app.FieldErrors{"variants": err.Error()})
+		return
+	case errors.Is(err, app.ErrProductNotFound):
+		h.notFound(w, r)
+		return
+	case err != nil:
+		h.logger.Error("failed to save product", "error", err)
+		http.Error(w, "failed to save product", http.StatusInternalServerError)
+		return
+	}
+
+	h.logger.InfoContext(r.Context(), "Product saved", "product_id", saved.ID, "new", isNew)
+	_ = h.storeAndSaveFlash(r, w, "success|"+saved.Name+" has been saved")
+	http.Redirect(w, r, fmt.Sprintf("/admin/products/%d", saved.ID), http.StatusSeeOther)
+}
+
+func (h *Handler) adminProductDelete(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+	productID, err := strconv.Atoi(p.ByName("id"))
+	if err != nil {
+		_ = h.storeAndSaveFlash(r, w, "warning|Invalid product ID given")
+		http.Redirect(w, r, "/admin/products", http.StatusSeeOther)
+		return
+	}
+
+	err = h.Product.DeleteProduct(r.Context(), productID)
+	switch {
+	case errors.Is(err, app.ErrProductNotFound):
+		_ = h.storeAndSaveFlash(r, w, "warning|This product was already deleted")
+	case err != nil:
+		h.logger.Error("failed to delete product", "error", err)
+		_ = h.storeAndSaveFlash(r, w, "danger|We couldn't delete this product")
+	default:
+		h.logger.InfoContext(r.Context(), "Product deleted", "product_id", productID)
+		_ = h.storeAndSaveFlash(r, w, "success|The product has been deleted")
+	}
+	http.Redirect(w, r, "/admin/products", http.StatusSeeOther)
+}
+
+func (h *Handler) renderProductForm(w http.ResponseWriter, r *http.Request, status int, product app.Product, fields app.FieldErrors) {
+	tmpl := template.Must(template.ParseFiles(
+		"static/layout.html",
+		"static/admin/product.html",
+	))
+
+	type pageData struct {
+		User       *app.User
+		Flashes    map[string]string
+		Product    app.Product
+		TaxClasses []app.TaxClass
+		Errors     app.FieldErrors
+		CSRFToken  string
+	}
+	flashes, err := h.getFlashes(r, w)
+	if err != nil {
+		h.logger.Warn("failed to get flashes", "error", err)
+	}
+	csrfToken, err := h.csrfToken(r, w)
+	if err != nil {
+		h.logger.Error("failed to create CSRF token", "error", err)
+		http.Error(w, "failed to create CSRF token", http.StatusInternalServerError)
+		return
+	}
+	data := pageData{
+		User:       h.currentUser(r),
+		Flashes:    flashes,
+		Product:    product,
+		TaxClasses: []app.TaxClass{app.TaxClassStandard, app.TaxClassReduced, app.TaxClassZero},
+		Errors:     fields,
+		CSRFToken:  csrfToken,
+	}
+
+	w.WriteHeader(status)
+	if err := tmpl.Execute(w, data); err != nil {
+		h.logger.Error("failed to execute layout", "error", err)
+		http.Error(w, "failed to create layout", http.StatusInternalServerError)
+		return
+	}
+}
+
+// productFromForm fills in the product with the posted form, the problems with
+// the amounts that can't be parsed are returned with the field names Validate uses.
+// Options and variants themselves are managed through the admin API, the form
+// only changes the price and stock of the existing variants. Prices are in our default currency.
+func productFromForm(r *http.Request, product app.Product) (app.Product, app.FieldErrors) {
+	fields := app.FieldErrors{}
+	product.Name = r.PostForm.Get("name")
+	product.Description = r.PostForm.Get("desc")
+	product.Image = r.PostForm.Get("img")
+	product.TaxClass = app.TaxClass(r.PostForm.Get("tax_class"))
+
+	if price, err := app.ParseMoney(r.PostForm.Get("price"), app.DefaultCurrency); err != nil {
+		fields.Add("price", "needs to be an amount like 12.99")
+	} else {
+		product.Price = price
+	}
+	if !product.HasVariants() {
+		if stock, err := strconv.Atoi(r.PostForm.Get("stock")); err != nil {
+			fields.Add("stock", "needs to be a whole number")
+		} else {
+			product.Stock = stock
+		}
+	}
+
+	// don't touch the variants of the product we were given
+	product.Variants = slices.Clone(product.Variants)
+	for i, v := range product.Variants {
+		field := fmt.Sprintf("variants[%d]", i)
+		switch s := r.PostForm.Get(field + ".price"); {
+		case s == "":
+			v.Price = nil
+		default:
+			price, err := app.ParseMoney(s, app.DefaultCurrency)
+			if err != nil {
+				fields.Add(field+".price", "needs to be an amount like 12.99, or empty for the product's price")
+				break
+			}
+			v.Price = &price
+		}
+		if stock, err := strconv.Atoi(r.PostForm.Get(field + ".stock")); err != nil {
+			fields.Add(field+".stock", "needs to be a whole number")
+		} else {
+			v.Stock = stock
+		}
+		product.Variants[i] = v
+	}
+	return product, fields
+}
+
+func (h *Handler) adminOrders(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	tmpl := template.Must(template.ParseFiles(
+		"static/layout.html",
+		"static/admin/orders.html",
+	))
+
+	status := app.OrderStatus(r.URL.Query().Get("status"))
+	orders, err := h.Order.ListAllOrders(r.Context(), status)
+	if err != nil {
+		h.logger.Error("failed to fetch orders", "error", err)
+		http.Error(w, "failed to fetch orders", http.StatusInternalServerError)
+		return
+	}
+
+	type pageData struct {
+		User     *app.User
+		Flashes  map[string]string
+		Orders   []app.Order
+		Status   app.OrderStatus
+		Statuses []app.OrderStatus
+	}
+	flashes, err := h.getFlashes(r, w)
+	if err != nil {
+		h.logger.Warn("failed to get flashes", "error", err)
+	}
+	data := pageData{
+		User:    h.currentUser(r),
+		Flashes: flashes,
+		Orders:  orders,
+		Status:  status,
+		Statuses: []app.OrderStatus{
+			app.OrderStatusPendingPayment,
+			app.OrderStatusPaymentFailed,
+			app.OrderStatusPaid,
+			app.OrderStatusRefunded,
+		},
+	}
+
+	if err := tmpl.Execute(w, data); err != nil {
+		h.logger.Error("failed to execute layout", "error", err)
+		http.Error(w, "failed to create layout", http.StatusInternalServerError)
+		return
+	}
+}
+
+func (h *Handler) adminOrderByID(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+	orderID, err := strconv.Atoi(p.ByName("id"))
+	if err != nil {
+		_ = h.storeAndSaveFlash(r, w, "warning|Invalid order ID given")
+		http.Redirect(w, r, "/admin/orders", http.StatusSeeOther)
+		return
+	}
+
+	tmpl := template.Must(template.ParseFiles(
+		"static/layout.html",
+		"static/admin/order.html",
+	))
+
+	order, err := h.Order.ShowOrder(r.Context(), orderID)
+	switch {
+	case errors.Is(err, app.ErrOrderNotFound):
+		h.notFound(w, r)
+		return
+	case err != nil:
+		h.logger.Error("failed to fetch order", "error", err)
+		http.Error(w, "failed to fetch order", http.StatusInternalServerError)
+		return
+	}
+	customer, err := h.User.GetUser(r.Context(), order.UserID)
+	if err != nil && !errors.Is(err, app.ErrUserNotFound) {
+		h.logger.Error("failed to fetch customer", "error", err)
+		http.Error(w, "failed to fetch customer", http.StatusInternalServerError)
+		return
+	}
+
+	type pageData struct {
+		User      *app.User
+		Flashes   map[string]string
+		Order     app.Order
+		Customer  app.User
+		CSRFToken string
+	}
+	flashes, err := h.getFlashes(r, w)
+	if err != nil {
+		h.logger.Warn("failed to get flashes", "error", err)
+	}
+	csrfToken, err := h.csrfToken(r, w)
+	if err != nil {
+		h.logger.Error("failed to create CSRF token", "error", err)
+		http.Error(w, "failed to create CSRF token", http.StatusInternalServerError)
+		return
+	}
+	data := pageData{
+		User:      h.currentUser(r),
+		Flashes:   flashes,
+		Order:     order,
+		Customer:  customer,
+		CSRFToken: csrfToken,
+	}
+
+	if err := tmpl.Execute(w, data); err != nil {
+		h.logger.Error("failed to execute layout", "error", err)
+		http.Error(w, "failed to create layout", http.StatusInternalServerError)
+		return
+	}
+}
+
+func (h *Handler) adminRefundOrder(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+	orderID, err := strconv.Atoi(p.ByName("id"))
+	if err != nil {
+		_ = h.storeAndSaveFlash(r, w, "warning|Invalid order ID given")
+		http.Redirect(w, r, "/admin/orders", http.StatusSeeOther)
+		return
+	}
+	orderURL := fmt.Sprintf("/admin/orders/%d", orderID)
+
+	err = h.Order.RefundOrder(r.Context(), orderID)
+	switch {
+	case errors.Is(err, app.ErrOrderNotFound):
+		h.notFound(w, r)
+		return
+	case errors.Is(err, app.ErrOrderNotRefundable):
+		_ = h.storeAndSaveFlash(r, w, "warning|Only paid orders can be refunded")
+	case errors.Is(err, app.ErrOrderChanged):
+		_ = h.storeAndSaveFlash(r, w, "warning|The order changed in the meantime, please check it again")
+	case err != nil:
+		h.logger.Error("failed to refund order", "error", err, "order_id", orderID)
+		_ = h.storeAndSaveFlash(r, w, "danger|Something went wrong with the refund, please try again")
+	default:
+		h.logger.InfoContext(r.Context(), "Order refunded", "order_id", orderID)
+		_ = h.storeAndSaveFlash(r, w, "success|The order has been refunded")
+	}
+	http.Redirect(w, r, orderURL, http.StatusSeeOther)
+}
+
+func (h *Handler) adminCustomers(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	tmpl := template.Must(template.ParseFiles(
+		"static/layout.html",
+		"static/admin/customers.html",
+	))
+
+	query := r.URL.Query().Get("q")
+	customers, err := h.User.FindUsers(r.Context(), query)
+	if err != nil {
+		h.logger.Error("failed to find customers", "error", err)
+		http.Error(w, "failed to find customers", http.StatusInternalServerError)
+		return
+	}
+
+	type pageData struct {
+		User      *app.User
+		Flashes   map[string]string
+		Query     string
+		Customers []app.User
+	}
+	flashes, err := h.getFlashes(r, w)
+	if err != nil {
+		h.logger.Warn("failed to get flashes", "error", err)
+	}
+	data := pageData{
+		User:      h.currentUser(r),
+		Flashes:   flashes,
+		Query:     query,
+		Customers: customers,
+	}
+
+	if err := tmpl.Execute(w, data); err != nil {
+		h.logger.Error("failed to execute layout", "error", err)
+		http.Error(w, "failed to create layout", http.StatusInternalServerError)
+		return
+	}
+}
+
+// adminCustomerByID shows a customer with their orders and what's in their basket right now
+func (h *Handler) adminCustomerByID(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+	customerID, err := strconv.Atoi(p.ByName("id"))
+	if err != nil {
+		_ = h.storeAndSaveFlash(r, w, "warning|Invalid customer ID given")
+		http.Redirect(w, r, "/admin/customers", http.StatusSeeOther)
+		return
+	}
+
+	tmpl := template.Must(template.ParseFiles(
+		"static/layout.html",
+		"static/admin/customer.html",
+	))
+
+	customer, err := h.User.GetUser(r.Context(), customerID)
+	switch {
+	case errors.Is(err, app.ErrUserNotFound):
+		h.notFound(w, r)
+		return
+	case err != nil:
+		h.logger.Error("failed to fetch customer", "error", err)
+		http.Error(w, "failed to fetch customer", http.StatusInternalServerError)
+		return
+	}
+	orders, err := h.Order.ListOrders(r.Context(), customer.ID)
+	if err != nil {
+		h.logger.Error("failed to fetch orders", "error", err)
+		http.Error(w, "failed to fetch orders", http.StatusInternalServerError)
+		return
+	}
+	basket, err := h.Basket.GetBasketView(r.Context(), customer.ID, h.Tax.DefaultCountry())
+	if err != nil {
+		h.logger.Error("failed to fetch basket", "error", err)
+		http.Error(w, "failed to fetch basket", http.StatusInternalServerError)
+		return
+	}
+
+	type pageData struct {
+		User     *app.User
+		Flashes  map[string]string
+		Customer app.User
+		Orders   []app.Order
+		Basket   app.BasketView
+	}
+	flashes, err := h.getFlashes(r, w)
+	if err != nil {
+		h.logger.Warn("failed to get flashes", "error", err)
+	}
+	data := pageData{
+		User:     h.currentUser(r),
+		Flashes:  flashes,
+		Customer: customer,
+		Orders:   orders,
+		Basket:   basket,
+	}
+
+	if err := tmpl.Execute(w, data); err != nil {
+		h.logger.Error("failed to execute layout", "error", err)
+		http.Error(w, "failed to create layout", http.StatusInternalServerError)
+		return
+	}
+}
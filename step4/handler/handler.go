@@ -5,7 +5,10 @@ import (
 	"log/slog"
 	"net/http"
 
+	"github.com/gerbenjacobs/go-webshop-course/payment"
 	"github.com/gerbenjacobs/go-webshop-course/services"
+	"github.com/gerbenjacobs/go-webshop-course/tax"
+	"github.com/gorilla/sessions"
 	"github.com/julienschmidt/httprouter"
 )
 
@@ -13,23 +16,36 @@ import (
 // it will have dependencies
 // and deal with routing
 type Handler struct {
-	logger *slog.Logger
-	mux    http.Handler
+	logger   *slog.Logger
+	mux      http.Handler
+	routes   []Route
+	sessions *sessions.CookieStore
 	Dependencies
 }
 
 type Dependencies struct {
-	Product services.ProductService
-	Basket  services.BasketService
+	Product  services.ProductService
+	Catalog  services.CatalogService
+	Basket   services.BasketService
+	User     services.UserService
+	Auth     services.AuthService
+	Order    services.OrderService
+	Payments payment.PaymentProvider
+	Tax      *tax.Engine
+	// SessionKeys sign and encrypt the cookies, see ParseSessionKeys
+	SessionKeys []SessionKey
+	// SecureCookies only sends the cookies over HTTPS, turn it off to log in over plain HTTP in local development
+	SecureCookies bool
 }
 
 func New(logger *slog.Logger, deps Dependencies) *Handler {
 	// create handler
 	h := new(Handler)
 	h.Dependencies = deps
+	h.sessions = newSessionStore(deps.SessionKeys, deps.SecureCookies)
 
 	// create router
-	r := httprouter.New()
+	r := &router{Router: httprouter.New()}
 
 	// set logger
 	h.logger = logger
@@ -37,19 +53,93 @@ func New(logger *slog.Logger, deps Dependencies) *Handler {
 	// create routes
 	r.GET("/", h.products)
 	r.GET("/product/:id", h.productByID)
+	r.GET("/search", h.search)
+	r.GET("/category/:slug", h.categoryBySlug)
+	r.GET("/collection/:slug", h.collectionBySlug)
+	r.GET("/basket", h.showBasket)
+	r.POST("/basket/add", h.addToBasket)
+	r.POST("/basket/quantity", h.setBasketQuantity)
+	r.POST("/basket/coupon", h.applyCoupon)
+	r.POST("/basket/coupon/remove", h.removeCoupon)
+	r.POST("/checkout", h.checkout)
+	r.GET("/orders", h.orders)
+	r.GET("/orders/:id", h.orderByID)
+	r.POST("/orders/:id/pay", h.payOrder)
+	r.POST("/webhooks/payment", h.paymentWebhook)
 
-	// API routes
-	r.GET("/api/products", h.apiProducts)
-	r.GET("/api/products/:id", h.apiProductByID)
+	r.GET("/login", h.login)
+	r.POST("/login", h.loginSubmit)
+	r.GET("/signup", h.signup)
+	r.POST("/signup", h.signupSubmit)
+	r.POST("/logout", h.logout)
+	r.GET("/profile", h.profile)
+	r.GET("/settings", h.settings)
+	r.POST("/settings", h.settingsSubmit)
 
-	r.GET("/api/basket", h.apiBasket)
-	r.POST("/api/basket/add", h.apiAddToBasket)
-	r.POST("/api/basket/remove", h.apiRemoveFromBasket)
+	// admin pages
+	r.GET("/admin", h.webAdmin(func(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+		http.Redirect(w, r, "/admin/products", http.StatusSeeOther)
+	}))
+	r.GET("/admin/products", h.webAdmin(h.adminProducts))
+	r.GET("/admin/products/:id", h.webAdmin(h.adminProductForm))
+	r.POST("/admin/products/:id", h.webAdmin(h.adminProductSubmit))
+	r.POST("/admin/products/:id/delete", h.webAdmin(h.adminProductDelete))
+	r.GET("/admin/orders", h.webAdmin(h.adminOrders))
+	r.GET("/admin/orders/:id", h.webAdmin(h.adminOrderByID))
+	r.POST("/admin/orders/:id/refund", h.webAdmin(h.adminRefundOrder))
+	r.GET("/admin/customers", h.webAdmin(h.adminCustomers))
+	r.GET("/admin/customers/:id", h.webAdmin(h.adminCustomerByID))
+
+	// API routes, see apiRouter for how the versions are mounted
+	api := apiRouter{h: h, r: r, versions: apiVersions}
+	api.GET("/openapi.json", h.apiOpenAPI)
+	api.GET("/docs", h.apiDocs)
+	api.GET("/products", h.apiProducts)
+	api.GET("/products/:id", h.apiProductByID)
+	api.GET("/categories", h.apiCategories)
+	api.GET("/categories/:slug", h.apiCategoryBySlug)
+	api.GET("/collections", h.apiCollections)
+	api.GET("/collections/:slug", h.apiCollectionBySlug)
+
+	api.POST("/auth/token", h.apiToken)
+
+	api.GET("/basket", h.apiGuest(h.apiBasket))
+	api.DELETE("/basket", h.apiGuest(h.apiClearBasket))
+	api.POST("/basket/items", h.apiGuest(h.apiAddBasketItem))
+	api.PATCH("/basket/items/:id", h.apiGuest(h.apiUpdateBasketItem))
+	api.DELETE("/basket/items/:id", h.apiGuest(h.apiDeleteBasketItem))
+	// the form based endpoints from before the items resource, they were left out of v2
+	v1 := apiRouter{h: h, r: r, versions: []*apiVersion{apiV1}}
+	v1.POST("/basket/add", deprecated("/basket/items", h.apiGuest(h.apiAddToBasket)))
+	v1.POST("/basket/remove", deprecated("/basket/items/{id}", h.apiGuest(h.apiRemoveFromBasket)))
+	v1.POST("/basket/quantity", deprecated("/basket/items/{id}", h.apiGuest(h.apiSetBasketQuantity)))
+	api.POST("/basket/coupon", h.apiGuest(h.apiApplyCoupon))
+	api.DELETE("/basket/coupon", h.apiGuest(h.apiRemoveCoupon))
+
+	api.POST("/checkout", h.apiAuth(h.apiCheckout))
+	api.GET("/orders", h.apiAuth(h.apiOrders))
+	api.GET("/orders/:id", h.apiAuth(h.apiOrderByID))
+	api.POST("/orders/:id/pay", h.apiAuth(h.apiPayOrder))
+
+	api.GET("/admin/products", h.apiAdmin(h.apiProducts))
+	api.POST("/admin/products", h.apiAdmin(h.apiCreateProduct))
+	api.GET("/admin/products/:id", h.apiAdmin(h.apiProductByID))
+	api.PUT("/admin/products/:id", h.apiAdmin(h.apiUpdateProduct))
+	api.PATCH("/admin/products/:id", h.apiAdmin(h.apiPatchProduct))
+	api.DELETE("/admin/products/:id", h.apiAdmin(h.apiDeleteProduct))
+	api.POST("/admin/categories", h.apiAdmin(h.apiCreateCategory))
+	api.PUT("/admin/categories/:id", h.apiAdmin(h.apiUpdateCategory))
+	api.DELETE("/admin/categories/:id", h.apiAdmin(h.apiDeleteCategory))
+	api.POST("/admin/collections", h.apiAdmin(h.apiCreateCollection))
+	api.PUT("/admin/collections/:id", h.apiAdmin(h.apiUpdateCollection))
+	api.DELETE("/admin/collections/:id", h.apiAdmin(h.apiDeleteCollection))
 
 	r.NotFound = http.HandlerFunc(h.notFound)
+	r.MethodNotAllowed = http.HandlerFunc(h.methodNotAllowed)
 
 	// set mux
-	h.mux = r
+	h.mux = r.Router
+	h.routes = r.routes
 
 	return h
 }
@@ -64,6 +154,10 @@ func (h *Handler) notFound(w http.ResponseWriter, r *http.Request) {
 		"method", r.Method,
 		"url", r.RequestURI,
 	)
+	if isAPI(r) {
+		h.apiProblem(w, r, problemNotFound, "no endpoint at "+r.URL.Path)
+		return
+	}
 	tmpl := template.Must(template.ParseFiles(
 		"static/layout.html",
 		"static/404.html",
@@ -75,3 +169,12 @@ func (h *Handler) notFound(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 }
+
+// methodNotAllowed only differs from the router's default for the API, the router sets the Allow header
+func (h *Handler) methodNotAllowed(w http.ResponseWriter, r *http.Request) {
+	if isAPI(r) {
+		h.apiProblem(w, r, problemNotAllowed, r.Method+" isn't allowed on "+r.URL.Path)
+		return
+	}
+	http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+}
@@ -0,0 +1,96 @@
+package handlertest
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"testing"
+
+	app "github.com/gerbenjacobs/go-webshop-course"
+	"github.com/gerbenjacobs/go-webshop-course/handler"
+	"github.com/gerbenjacobs/go-webshop-course/payment"
+	"github.com/gerbenjacobs/go-webshop-course/search"
+	"github.com/gerbenjacobs/go-webshop-course/services"
+	"github.com/gerbenjacobs/go-webshop-course/storage"
+	"github.com/gerbenjacobs/go-webshop-course/tax"
+)
+
+// Account is a user that signs up before the handler is returned
+type Account struct {
+	Email    string
+	Password string
+	Admin    bool
+}
+
+// NewHandler wires the handler like cmd/app does, with the seeded memory storage and
+// payments by the fake provider. It reads the tax rules from ../tax.json, so it's meant
+// for the tests of the packages right below the root.
+func NewHandler(t *testing.T, accounts ...Account) *handler.Handler {
+	t.Helper()
+	ctx := context.Background()
+
+	baskets := storage.NewBasketRepo()
+	products := storage.NewProductRepo()
+	promotions := storage.NewPromotionRepo()
+	orders := storage.NewOrderRepo(baskets, products, promotions)
+	categories := storage.NewCategoryRepo(products)
+
+	taxConfig, err := tax.LoadConfig("../tax.json")
+	if err != nil {
+		t.Fatalf("LoadConfig: %v", err)
+	}
+	taxEngine, err := tax.NewEngine(taxConfig)
+	if err != nil {
+		t.Fatalf("NewEngine: %v", err)
+	}
+
+	productSvc := services.NewProductService(products, categories, search.NewInvertedIndex())
+	if err := productSvc.ReindexProducts(ctx); err != nil {
+		t.Fatalf("ReindexProducts: %v", err)
+	}
+	catalogSvc := services.NewCatalogService(categories, storage.NewCollectionRepo(), productSvc)
+	promotionSvc := services.NewPromotionService(promotions, orders)
+	basketSvc := services.NewBasketService(baskets, productSvc, promotionSvc, taxEngine, app.EUR(495))
+
+	var admins []string
+	for _, a := range accounts {
+		if a.Admin {
+			admins = append(admins, a.Email)
+		}
+	}
+	userSvc := services.NewUserService(storage.NewUserRepo(), admins)
+	for _, a := range accounts {
+		if _, err := userSvc.Signup(ctx, "", a.Email, a.Password); err != nil {
+			t.Fatalf("Signup(%s): %v", a.Email, err)
+		}
+	}
+	if _, err := userSvc.AppointAdmins(ctx); err != nil {
+		t.Fatalf("AppointAdmins: %v", err)
+	}
+
+	payments := payment.NewFake(payment.FakeOptions{})
+	orderSvc := services.NewOrderService(orders, basketSvc, payments)
+	authSvc, err := services.NewAuthService(userSvc, []services.SigningKey{
+		{ID: "test", Secret: []byte("a-signing-key-that-is-only-used-in-tests")},
+	})
+	if err != nil {
+		t.Fatalf("NewAuthService: %v", err)
+	}
+	sessionKeys, err := handler.ParseSessionKeys("a-session-auth-key-that-is-only-for-tests:session-key-only-used-in-tests32")
+	if err != nil {
+		t.Fatalf("ParseSessionKeys: %v", err)
+	}
+
+	return handler.New(slog.New(slog.NewTextHandler(io.Discard, nil)), handler.Dependencies{
+		Product:  productSvc,
+		Catalog:  catalogSvc,
+		Basket:   basketSvc,
+		User:     userSvc,
+		Auth:     authSvc,
+		Order:    orderSvc,
+		Payments: payments,
+		Tax:      taxEngine,
+
+		SessionKeys: sessionKeys,
+	})
+}
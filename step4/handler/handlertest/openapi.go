@@ -0,0 +1,321 @@
+// Package handlertest contains conformance tests for the handler,
+// they check that the API does what its OpenAPI documents say.
+// NewHandler wires a handler to run them, and other end-to-end tests, against.
+package handlertest
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"slices"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/gerbenjacobs/go-webshop-course/handler"
+)
+
+// methods are the operations of a path item, in the order they're tried
+var methods = []string{http.MethodGet, http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete}
+
+// TestOpenAPI runs the OpenAPI conformance suite. Every version of the API has
+// its own document, at openapi.json under each of the servers it lists. The suite
+// checks that every API route of the handler is in a document and the other way
+// around, and that the responses match the documented schemas. Every operation
+// is called once, under the first server of its document, with the examples of
+// the document and without logging in, so the handler needs the seed data of our storage.
+//
+//	func TestOpenAPIDocument(t *testing.T) {
+//		handlertest.TestOpenAPI(t, handlertest.NewHandler(t))
+//	}
+func TestOpenAPI(t *testing.T, h *handler.Handler) {
+	docs := fetchDocuments(t, h)
+
+	t.Run("every API route is documented", func(t *testing.T) {
+		for _, route := range h.Routes() {
+			if !strings.HasPrefix(route.Path, "/api/") {
+				continue
+			}
+			documented := slices.ContainsFunc(docs, func(doc document) bool {
+				for _, server := range doc.servers() {
+					path, ok := strings.CutPrefix(route.Path, server)
+					if !ok || !strings.HasPrefix(path, "/") {
+						continue
+					}
+					if _, ok := doc.operation(specPath(path), route.Method); ok {
+						return true
+					}
+				}
+				return false
+			})
+			if !documented {
+				t.Errorf("%s %s isn't in an OpenAPI document", route.Method, route.Path)
+			}
+		}
+	})
+
+	t.Run("every documented operation has a route", func(t *testing.T) {
+		for _, doc := range docs {
+			for _, o := range doc.operations() {
+				for _, server := range doc.servers() {
+					routed := slices.ContainsFunc(h.Routes(), func(r handler.Route) bool {
+						return r.Method == o.method && specPath(r.Path) == server+o.path
+					})
+					if !routed {
+						t.Errorf("%s %s%s is documented but there's no such route", o.method, server, o.path)
+					}
+				}
+			}
+		}
+	})
+
+	t.Run("responses match the document", func(t *testing.T) {
+		// the basket endpoints share the guest cookie, like a browser would
+		var cookies []*http.Cookie
+		for _, doc := range docs {
+			server := doc.servers()[0]
+			for _, o := range doc.operations() {
+				req, err := doc.exampleRequest(server, o)
+				if err != nil {
+					t.Errorf("%s %s%s: %v", o.method, server, o.path, err)
+					continue
+				}
+				for _, c := range cookies {
+					req.AddCookie(c)
+				}
+
+				rec := httptest.NewRecorder()
+				h.ServeHTTP(rec, req)
+				res := rec.Result()
+				for _, c := range res.Cookies() {
+					cookies = slices.DeleteFunc(cookies, func(old *http.Cookie) bool { return old.Name == c.Name })
+					cookies = append(cookies, c)
+				}
+
+				for _, problem := range doc.checkResponse(o, res) {
+					t.Errorf("%s %s%s: %d: %s", o.method, server, o.path, res.StatusCode, problem)
+				}
+			}
+		}
+	})
+}
+
+// document is an OpenAPI document, decoded as it is so we can follow its references
+type document map[string]any
+
+type operation struct {
+	path   string
+	method string
+	spec   map[string]any
+}
+
+// fetchDocuments gets the documents of all versions. A document is served under each
+// of its servers, so they're told apart by their first server.
+func fetchDocuments(t *testing.T, h *handler.Handler) []document {
+	t.Helper()
+	var docs []document
+	for _, route := range h.Routes() {
+		if route.Method != http.MethodGet || !strings.HasPrefix(route.Path, "/api/") || !strings.HasSuffix(route.Path, "/openapi.json") {
+			continue
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, route.Path, nil))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("GET %s: status = %d, want %d", route.Path, rec.Code, http.StatusOK)
+		}
+
+		var doc document
+		if err := decodeJSON(rec.Body, &doc); err != nil {
+			t.Fatalf("GET %s: %v", route.Path, err)
+		}
+		if v, _ := doc["openapi"].(string); !strings.HasPrefix(v, "3.1.") {
+			t.Fatalf("GET %s: openapi = %q, want 3.1.x", route.Path, v)
+		}
+		if len(doc.servers()) == 0 {
+			t.Fatalf("GET %s: the document has no servers", route.Path)
+		}
+		if !slices.ContainsFunc(docs, func(d document) bool { return d.servers()[0] == doc.servers()[0] }) {
+			docs = append(docs, doc)
+		}
+	}
+	if len(docs) == 0 {
+		t.Fatal("there's no route for an OpenAPI document")
+	}
+	slices.SortFunc(docs, func(a, b document) int { return strings.Compare(a.servers()[0], b.servers()[0]) })
+	return docs
+}
+
+// servers are the URLs the paths of the document are relative to
+func (d document) servers() []string {
+	servers, _ := d["servers"].([]any)
+	var urls []string
+	for _, s := range servers {
+		server, _ := s.(map[string]any)
+		if u, ok := server["url"].(string); ok {
+			urls = append(urls, strings.TrimSuffix(u, "/"))
+		}
+	}
+	return urls
+}
+
+// operations lists the documented operations, sorted by path so the suite runs the same every time
+func (d document) operations() []operation {
+	paths, _ := d["paths"].(map[string]any)
+	var ops []operation
+	for _, path := range sortedKeys(paths) {
+		item, _ := paths[path].(map[string]any)
+		for _, method := range methods {
+			if spec, ok := item[strings.ToLower(method)].(map[string]any); ok {
+				ops = append(ops, operation{path: path, method: method, spec: spec})
+			}
+		}
+	}
+	return ops
+}
+
+func (d document) operation(path, method string) (map[string]any, bool) {
+	paths, _ := d["paths"].(map[string]any)
+	item, _ := paths[path].(map[string]any)
+	spec, ok := item[strings.ToLower(method)].(map[string]any)
+	return spec, ok
+}
+
+// exampleRequest builds a request for the operation under the server, out of the examples of its path parameters and body
+func (d document) exampleRequest(server string, o operation) (*http.Request, error) {
+	path := server + o.path
+	params, _ := o.spec["parameters"].([]any)
+	for _, p := range params {
+		param := d.resolve(p)
+		if param["in"] != "path" {
+			continue
+		}
+		example, ok := param["example"]
+		if !ok {
+			return nil, fmt.Errorf("path parameter %v has no example", param["name"])
+		}
+		path = strings.ReplaceAll(path, fmt.Sprintf("{%v}", param["name"]), url.PathEscape(fmt.Sprint(example)))
+	}
+
+	requestBody, ok := o.spec["requestBody"]
+	if !ok {
+		return httptest.NewRequest(o.method, path, nil), nil
+	}
+	content, _ := d.resolve(requestBody)["content"].(map[string]any)
+	for _, contentType := range sortedKeys(content) {
+		media, _ := content[contentType].(map[string]any)
+		example, ok := media["example"]
+		if !ok {
+			continue
+		}
+
+		var body []byte
+		switch {
+		case isJSON(contentType):
+			body, _ = json.Marshal(example)
+		case contentType == "application/x-www-form-urlencoded":
+			fields, _ := example.(map[string]any)
+			form := url.Values{}
+			for k, v := range fields {
+				form.Set(k, fmt.Sprint(v))
+			}
+			body = []byte(form.Encode())
+		default:
+			continue
+		}
+		req := httptest.NewRequest(o.method, path, bytes.NewReader(body))
+		req.Header.Set("Content-Type", contentType)
+		return req, nil
+	}
+	return nil, fmt.Errorf("request body has no example")
+}
+
+// checkResponse compares the response with the documented one for its status
+func (d document) checkResponse(o operation, res *http.Response) []string {
+	responses, _ := o.spec["responses"].(map[string]any)
+	documented, ok := responses[fmt.Sprint(res.StatusCode)]
+	if !ok {
+		documented, ok = responses["default"]
+	}
+	if !ok {
+		return []string{"the status isn't documented"}
+	}
+
+	body, err := io.ReadAll(res.Body)
+	if err != nil {
+		return []string{"failed to read the body: " + err.Error()}
+	}
+	content, _ := d.resolve(documented)["content"].(map[string]any)
+	if len(content) == 0 {
+		if len(body) > 0 {
+			return []string{"there's a body, but none is documented"}
+		}
+		return nil
+	}
+
+	contentType, _, _ := mime.ParseMediaType(res.Header.Get("Content-Type"))
+	media, ok := content[contentType].(map[string]any)
+	if !ok {
+		return []string{fmt.Sprintf("Content-Type %q isn't documented", contentType)}
+	}
+	if !isJSON(contentType) {
+		return nil
+	}
+	var value any
+	if err := decodeJSON(bytes.NewReader(body), &value); err != nil {
+		return []string{"the body isn't JSON: " + err.Error()}
+	}
+	return d.validate(media["schema"], value, "$")
+}
+
+// resolve follows a $ref to the part of the document it points at
+func (d document) resolve(v any) map[string]any {
+	m, _ := v.(map[string]any)
+	ref, ok := m["$ref"].(string)
+	if !ok {
+		return m
+	}
+	var node any = map[string]any(d)
+	for _, part := range strings.Split(strings.TrimPrefix(ref, "#/"), "/") {
+		parent, _ := node.(map[string]any)
+		node = parent[part]
+	}
+	return d.resolve(node)
+}
+
+// specPath turns a route of our router into an OpenAPI path, :id becomes {id}
+func specPath(route string) string {
+	parts := strings.Split(route, "/")
+	for i, part := range parts {
+		if name, ok := strings.CutPrefix(part, ":"); ok {
+			parts[i] = "{" + name + "}"
+		} else if name, ok := strings.CutPrefix(part, "*"); ok {
+			parts[i] = "{" + name + "}"
+		}
+	}
+	return strings.Join(parts, "/")
+}
+
+func isJSON(contentType string) bool {
+	return contentType == "application/json" || strings.HasSuffix(contentType, "+json")
+}
+
+// decodeJSON keeps numbers as they are, so integers can be told apart from other numbers
+func decodeJSON(r io.Reader, v any) error {
+	dec := json.NewDecoder(r)
+	dec.UseNumber()
+	return dec.Decode(v)
+}
+
+func sortedKeys(m map[string]any) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,115 @@
+package handlertest
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+)
+
+// validate checks a value against a schema of the document and returns what doesn't match,
+// path is where the value is in the body. It knows the parts of JSON Schema our
+// document uses: $ref, type, enum, oneOf, properties, required, additionalProperties and items.
+func (d document) validate(schema any, value any, path string) []string {
+	s := d.resolve(schema)
+	if s == nil {
+		return nil
+	}
+
+	if oneOf, ok := s["oneOf"].([]any); ok {
+		matches := 0
+		for _, option := range oneOf {
+			if len(d.validate(option, value, path)) == 0 {
+				matches++
+			}
+		}
+		if matches != 1 {
+			return []string{fmt.Sprintf("%s matches %d of the oneOf schemas, want 1", path, matches)}
+		}
+		return nil
+	}
+
+	if types := schemaTypes(s["type"]); len(types) > 0 && !slices.ContainsFunc(types, func(t string) bool { return hasType(value, t) }) {
+		return []string{fmt.Sprintf("%s is %s, want %s", path, typeOf(value), strings.Join(types, " or "))}
+	}
+	if enum, ok := s["enum"].([]any); ok && !slices.ContainsFunc(enum, func(e any) bool { return reflect.DeepEqual(e, value) }) {
+		return []string{fmt.Sprintf("%s is %v, want one of %v", path, value, enum)}
+	}
+
+	var problems []string
+	switch v := value.(type) {
+	case map[string]any:
+		properties, _ := s["properties"].(map[string]any)
+		required, _ := s["required"].([]any)
+		for _, name := range required {
+			if _, ok := v[name.(string)]; !ok {
+				problems = append(problems, fmt.Sprintf("%s.%s is required", path, name))
+			}
+		}
+		for _, name := range sortedKeys(v) {
+			if property, ok := properties[name]; ok {
+				problems = append(problems, d.validate(property, v[name], path+"."+name)...)
+				continue
+			}
+			switch additional := s["additionalProperties"].(type) {
+			case bool:
+				if !additional {
+					problems = append(problems, fmt.Sprintf("%s.%s isn't documented", path, name))
+				}
+			case map[string]any:
+				problems = append(problems, d.validate(additional, v[name], path+"."+name)...)
+			}
+		}
+	case []any:
+		if items, ok := s["items"]; ok {
+			for i, item := range v {
+				problems = append(problems, d.validate(items, item, fmt.Sprintf("%s[%d]", path, i))...)
+			}
+		}
+	}
+	return problems
+}
+
+// schemaTypes reads the type of a schema, which is a single type or a list of them
+func schemaTypes(v any) []string {
+	switch t := v.(type) {
+	case string:
+		return []string{t}
+	case []any:
+		types := make([]string, 0, len(t))
+		for _, s := range t {
+			types = append(types, fmt.Sprint(s))
+		}
+		return types
+	}
+	return nil
+}
+
+func hasType(value any, want string) bool {
+	got := typeOf(value)
+	// every integer is a number too
+	return got == want || want == "number" && got == "integer"
+}
+
+// typeOf names the JSON Schema type of a value decoded with UseNumber
+func typeOf(value any) string {
+	switch v := value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case string:
+		return "string"
+	case json.Number:
+		if _, err := v.Int64(); err == nil {
+			return "integer"
+		}
+		return "number"
+	case []any:
+		return "array"
+	case map[string]any:
+		return "object"
+	}
+	return fmt.Sprintf("%T", value)
+}
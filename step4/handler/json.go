@@ -0,0 +1,74 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+)
+
+// maxJSONBody is the largest request body we read, our bodies are a lot smaller
+const maxJSONBody = 1 << 20
+
+// decodeJSON reads the JSON body of the request into v. The body needs to be sent
+// as JSON and can't have fields v doesn't know about. When it can't be read
+// decodeJSON replies with the problem and returns false.
+func (h *Handler) decodeJSON(w http.ResponseWriter, r *http.Request, v any) bool {
+	if !isJSON(r.Header.Get("Content-Type")) {
+		// tell the client what we do accept
+		switch r.Method {
+		case http.MethodPost:
+			w.Header().Set("Accept-Post", "application/json")
+		case http.MethodPatch:
+			w.Header().Set("Accept-Patch", "application/json")
+		}
+		h.apiProblem(w, r, problemMediaType, "the Content-Type needs to be application/json")
+		return false
+	}
+
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxJSONBody))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(v); err != nil {
+		h.apiProblem(w, r, problemInvalidRequest, "invalid JSON body: "+jsonErrorDetail(err))
+		return false
+	}
+	// one value per body, anything after it is a mistake
+	if err := dec.Decode(&struct{}{}); !errors.Is(err, io.EOF) {
+		h.apiProblem(w, r, problemInvalidRequest, "invalid JSON body: only one JSON value is allowed")
+		return false
+	}
+	return true
+}
+
+// isJSON accepts application/json and the types that build on it, like application/merge-patch+json
+func isJSON(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	return mediaType == "application/json" || strings.HasPrefix(mediaType, "application/") && strings.HasSuffix(mediaType, "+json")
+}
+
+// jsonErrorDetail words the errors of the JSON decoder for our clients
+func jsonErrorDetail(err error) string {
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	var maxErr *http.MaxBytesError
+	switch {
+	case errors.Is(err, io.EOF):
+		return "the body is empty"
+	case errors.Is(err, io.ErrUnexpectedEOF):
+		return "the body ends too soon"
+	case errors.As(err, &syntaxErr):
+		return fmt.Sprintf("syntax error at offset %d", syntaxErr.Offset)
+	case errors.As(err, &typeErr) && typeErr.Field != "":
+		return fmt.Sprintf("field %q needs to be of type %s", typeErr.Field, typeErr.Type)
+	case errors.As(err, &maxErr):
+		return fmt.Sprintf("the body can't be larger than %d bytes", maxErr.Limit)
+	}
+	// unknown fields only come as a plain error: json: unknown field "name"
+	return strings.TrimPrefix(err.Error(), "json: ")
+}
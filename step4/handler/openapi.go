@@ -0,0 +1,72 @@
+package handler
+
+import (
+	_ "embed"
+	"net/http"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+// Route is a method and path as they're registered on the router, like GET /api/products/:id
+type Route struct {
+	Method string
+	Path   string
+}
+
+// Routes lists all routes of the handler, in the order they were registered
+func (h *Handler) Routes() []Route {
+	return h.routes
+}
+
+// router remembers the routes that are registered on it
+type router struct {
+	*httprouter.Router
+	routes []Route
+}
+
+func (r *router) Handle(method, path string, handle httprouter.Handle) {
+	r.routes = append(r.routes, Route{Method: method, Path: path})
+	r.Router.Handle(method, path, handle)
+}
+
+func (r *router) GET(path string, handle httprouter.Handle) {
+	r.Handle(http.MethodGet, path, handle)
+}
+
+func (r *router) POST(path string, handle httprouter.Handle) {
+	r.Handle(http.MethodPost, path, handle)
+}
+
+func (r *router) PUT(path string, handle httprouter.Handle) {
+	r.Handle(http.MethodPut, path, handle)
+}
+
+func (r *router) PATCH(path string, handle httprouter.Handle) {
+	r.Handle(http.MethodPatch, path, handle)
+}
+
+func (r *router) DELETE(path string, handle httprouter.Handle) {
+	r.Handle(http.MethodDelete, path, handle)
+}
+
+// apiOpenAPI serves the document of the requested version, handlertest.TestOpenAPI keeps the documents in line with the routes
+func (h *Handler) apiOpenAPI(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	w.Header().Set("Content-Type", "application/json")
+	if _, err := w.Write(apiVersionFromContext(r.Context()).spec); err != nil {
+		h.logger.Error("failed to write OpenAPI spec", "error", err)
+	}
+}
+
+// docsPage loads Swagger UI with the documents, it's embedded so it doesn't
+// depend on the directory the shop is started from
+//
+//go:embed docs.html
+var docsPage []byte
+
+// apiDocs shows the documents in Swagger UI, so the API can be browsed and tried out
+func (h *Handler) apiDocs(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if _, err := w.Write(docsPage); err != nil {
+		h.logger.Error("failed to write API docs", "error", err)
+	}
+}
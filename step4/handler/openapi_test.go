@@ -0,0 +1,11 @@
+package handler_test
+
+import (
+	"testing"
+
+	"github.com/gerbenjacobs/go-webshop-course/handler/handlertest"
+)
+
+func TestOpenAPIDocument(t *testing.T) {
+	handlertest.TestOpenAPI(t, handlertest.NewHandler(t))
+}
@@ -0,0 +1,243 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"html/template"
+	"net/http"
+	"strconv"
+
+	app "github.com/gerbenjacobs/go-webshop-course"
+	"github.com/gerbenjacobs/go-webshop-course/payment"
+	"github.com/gerbenjacobs/go-webshop-course/tax"
+	"github.com/julienschmidt/httprouter"
+)
+
+func (h *Handler) checkout(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	user := h.currentUser(r)
+	if user == nil {
+		_ = h.storeAndSaveFlash(r, w, "warning|Please log in to check out, your basket will be kept")
+		http.Redirect(w, r, "/login", http.StatusSeeOther)
+		return
+	}
+
+	r.ParseForm()
+	order, err := h.Order.Checkout(r.Context(), user.ID, r.PostForm.Get("country"))
+	switch {
+	case errors.Is(err, tax.ErrUnknownCountry):
+		_ = h.storeAndSaveFlash(r, w, "warning|We don't ship to that country yet")
+		http.Redirect(w, r, "/basket", http.StatusSeeOther)
+		return
+	case errors.Is(err, app.ErrEmptyBasket):
+		_ = h.storeAndSaveFlash(r, w, "warning|Your basket is empty")
+		http.Redirect(w, r, "/", http.StatusSeeOther)
+		return
+	case errors.Is(err, app.ErrBasketChanged):
+		_ = h.storeAndSaveFlash(r, w, "warning|Your basket changed while checking out, please try again")
+		http.Redirect(w, r, "/orders", http.StatusSeeOther)
+		return
+	case errors.Is(err, app.ErrInsufficientStock):
+		_ = h.storeAndSaveFlash(r, w, "warning|Sorry, some products in your basket are no longer in stock")
+		http.Redirect(w, r, "/basket", http.StatusSeeOther)
+		return
+	case errors.Is(err, app.ErrPromotionUsedUp):
+		_ = h.storeAndSaveFlash(r, w, "warning|Sorry, a promotion in your basket has just been used up")
+		http.Redirect(w, r, "/basket", http.StatusSeeOther)
+		return
+	case err != nil:
+		h.logger.Error("failed to check out", "error", err)
+		http.Error(w, "something went wrong", http.StatusInternalServerError)
+		return
+	}
+
+	_ = h.storeAndSaveFlash(r, w, "success|Thank you for your order!")
+	http.Redirect(w, r, fmt.Sprintf("/orders/%d", order.ID), http.StatusSeeOther)
+}
+
+func (h *Handler) orders(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	user := h.currentUser(r)
+	if user == nil {
+		_ = h.storeAndSaveFlash(r, w, "warning|Please log in first")
+		http.Redirect(w, r, "/login", http.StatusSeeOther)
+		return
+	}
+
+	tmpl := template.Must(template.ParseFiles(
+		"static/layout.html",
+		"static/order/orders.html",
+	))
+
+	orders, err := h.Order.ListOrders(r.Context(), user.ID)
+	if err != nil {
+		h.logger.Error("failed to fetch orders", "error", err)
+		http.Error(w, "failed to fetch orders", http.StatusInternalServerError)
+		return
+	}
+
+	type pageData struct {
+		User    *app.User
+		Flashes map[string]string
+		Orders  []app.Order
+	}
+	flashes, err := h.getFlashes(r, w)
+	if err != nil {
+		h.logger.Warn("failed to get flashes", "error", err)
+	}
+	data := pageData{
+		User:    user,
+		Flashes: flashes,
+		Orders:  orders,
+	}
+
+	if err := tmpl.Execute(w, data); err != nil {
+		h.logger.Error("failed to execute layout", "error", err)
+		http.Error(w, "failed to create layout", http.StatusInternalServerError)
+		return
+	}
+}
+
+func (h *Handler) orderByID(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+	user := h.currentUser(r)
+	if user == nil {
+		_ = h.storeAndSaveFlash(r, w, "warning|Please log in first")
+		http.Redirect(w, r, "/login", http.StatusSeeOther)
+		return
+	}
+
+	orderID, err := strconv.Atoi(p.ByName("id"))
+	if err != nil {
+		h.logger.ErrorContext(r.Context(), "couldn't convert order ID to int", "error", err)
+		_ = h.storeAndSaveFlash(r, w, "warning|Invalid order ID given")
+		http.Redirect(w, r, "/orders", http.StatusSeeOther)
+		return
+	}
+
+	tmpl := template.Must(template.ParseFiles(
+		"static/layout.html",
+		"static/order/order.html",
+	))
+
+	order, err := h.Order.GetOrder(r.Context(), user.ID, orderID)
+	switch {
+	case errors.Is(err, app.ErrOrderNotFound):
+		h.notFound(w, r)
+		return
+	case err != nil:
+		h.logger.Error("something went wrong", "error", err)
+		http.Error(w, "something went wrong", http.StatusInternalServerError)
+		return
+	}
+
+	type pageData struct {
+		User    *app.User
+		Flashes map[string]string
+		Order   app.Order
+	}
+	flashes, err := h.getFlashes(r, w)
+	if err != nil {
+		h.logger.Warn("failed to get flashes", "error", err)
+	}
+	data := pageData{
+		User:    user,
+		Flashes: flashes,
+		Order:   order,
+	}
+
+	if err := tmpl.Execute(w, data); err != nil {
+		h.logger.Error("failed to execute layout", "error", err)
+		http.Error(w, "failed to create layout", http.StatusInternalServerError)
+		return
+	}
+}
+
+func (h *Handler) payOrder(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+	user := h.currentUser(r)
+	if user == nil {
+		_ = h.storeAndSaveFlash(r, w, "warning|Please log in first")
+		http.Redirect(w, r, "/login", http.StatusSeeOther)
+		return
+	}
+
+	orderID, err := strconv.Atoi(p.ByName("id"))
+	if err != nil {
+		h.logger.ErrorContext(r.Context(), "couldn't convert order ID to int", "error", err)
+		_ = h.storeAndSaveFlash(r, w, "warning|Invalid order ID given")
+		http.Redirect(w, r, "/orders", http.StatusSeeOther)
+		return
+	}
+	orderURL := fmt.Sprintf("/orders/%d", orderID)
+
+	r.ParseForm()
+	auth, err := h.Order.Pay(r.Context(), user.ID, orderID, r.PostForm.Get("method"), absoluteURL(r, orderURL))
+	switch {
+	case errors.Is(err, app.ErrOrderNotFound):
+		h.notFound(w, r)
+		return
+	case errors.Is(err, app.ErrOrderNotPayable), errors.Is(err, app.ErrOrderChanged):
+		_ = h.storeAndSaveFlash(r, w, "warning|This order can't be paid anymore")
+		http.Redirect(w, r, orderURL, http.StatusSeeOther)
+		return
+	case errors.Is(err, app.ErrInsufficientStock):
+		_ = h.storeAndSaveFlash(r, w, "warning|Sorry, some products of this order are no longer in stock")
+		http.Redirect(w, r, orderURL, http.StatusSeeOther)
+		return
+	case errors.Is(err, app.ErrPromotionUsedUp):
+		_ = h.storeAndSaveFlash(r, w, "warning|Sorry, a promotion of this order has been used up, please order again")
+		http.Redirect(w, r, orderURL, http.StatusSeeOther)
+		return
+	case errors.Is(err, payment.ErrDeclined):
+		_ = h.storeAndSaveFlash(r, w, "danger|Your payment was declined, please try another payment method")
+		http.Redirect(w, r, orderURL, http.StatusSeeOther)
+		return
+	case err != nil:
+		h.logger.Error("failed to pay order", "error", err)
+		_ = h.storeAndSaveFlash(r, w, "danger|Something went wrong with your payment, please try again")
+		http.Redirect(w, r, orderURL, http.StatusSeeOther)
+		return
+	}
+
+	switch auth.Status {
+	case payment.StatusRequiresAction:
+		http.Redirect(w, r, auth.RedirectURL, http.StatusSeeOther)
+		return
+	case payment.StatusPending:
+		_ = h.storeAndSaveFlash(r, w, "info|We're waiting for your bank to confirm the payment")
+	default:
+		_ = h.storeAndSaveFlash(r, w, "success|Thank you, your order has been paid")
+	}
+	http.Redirect(w, r, orderURL, http.StatusSeeOther)
+}
+
+// paymentWebhook receives the status updates of our payment provider
+func (h *Handler) paymentWebhook(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	event, err := h.Payments.ParseWebhook(r)
+	if err != nil {
+		h.logger.WarnContext(r.Context(), "invalid payment webhook", "error", err)
+		http.Error(w, "invalid webhook", http.StatusBadRequest)
+		return
+	}
+
+	err = h.Order.HandlePaymentEvent(r.Context(), event)
+	switch {
+	case errors.Is(err, app.ErrOrderNotFound), errors.Is(err, payment.ErrInvalidWebhook):
+		h.logger.WarnContext(r.Context(), "rejected payment webhook", "error", err, "event", event.Type)
+		http.Error(w, "invalid webhook", http.StatusBadRequest)
+		return
+	case err != nil:
+		// the provider will retry
+		h.logger.Error("failed to handle payment webhook", "error", err, "event", event.Type)
+		http.Error(w, "failed to handle webhook", http.StatusInternalServerError)
+		return
+	}
+	h.logger.InfoContext(r.Context(), "Payment webhook handled", "event", event.Type, "order_id", event.OrderID)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// absoluteURL turns a path into a URL on our own host, for sending to third parties
+func absoluteURL(r *http.Request, path string) string {
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	return fmt.Sprintf("%s://%s%s", scheme, r.Host, path)
+}
@@ -0,0 +1,136 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	app "github.com/gerbenjacobs/go-webshop-course"
+	"github.com/gerbenjacobs/go-webshop-course/payment"
+	"github.com/gerbenjacobs/go-webshop-course/tax"
+)
+
+// problemTypePrefix makes our error codes into the problem type URIs,
+// they identify the problem and aren't meant to be visited
+const problemTypePrefix = "urn:webshop:problem:"
+
+// problem is how the API reports errors, as RFC 9457 problem details.
+// Code is our own addition, clients can rely on it as it never changes
+// when the wording of the title or detail does.
+type problem struct {
+	Type   string `json:"type"`
+	Title  string `json:"title"`
+	Status int    `json:"status"`
+	Detail string `json:"detail,omitempty"`
+	// Instance is the path of the request that went wrong
+	Instance string `json:"instance,omitempty"`
+	Code     string `json:"code"`
+	// Fields has what's wrong with every field when the input is invalid
+	Fields app.FieldErrors `json:"fields,omitempty"`
+}
+
+// problemKind is a problem that can happen on more than one endpoint
+type problemKind struct {
+	status int
+	code   string
+	title  string
+}
+
+var (
+	problemInvalidRequest = problemKind{http.StatusBadRequest, "invalid_request", "The request can't be read"}
+	problemUnauthorized   = problemKind{http.StatusUnauthorized, "unauthorized", "Authentication is required"}
+	problemForbidden      = problemKind{http.StatusForbidden, "forbidden", "You're not allowed to do this"}
+	problemNotFound       = problemKind{http.StatusNotFound, "not_found", "There's nothing here"}
+	problemNotAllowed     = problemKind{http.StatusMethodNotAllowed, "method_not_allowed", "This method isn't allowed here"}
+	problemMediaType      = problemKind{http.StatusUnsupportedMediaType, "unsupported_media_type", "The body needs to be JSON"}
+	problemNotAcceptable  = problemKind{http.StatusNotAcceptable, "not_acceptable", "We can't answer in the format that's asked for"}
+	problemInternal       = problemKind{http.StatusInternalServerError, "internal_error", "Something went wrong on our side"}
+)
+
+// domainProblems turns the errors of our services into problems, the first match wins
+var domainProblems = []struct {
+	err error
+	problemKind
+}{
+	{app.ErrInvalidInput, problemKind{http.StatusUnprocessableEntity, "invalid_input", "The input isn't valid"}},
+	{app.ErrInvalidMoney, problemKind{http.StatusUnprocessableEntity, "invalid_money", "The amount isn't valid"}},
+	{app.ErrInvalidQuantity, problemKind{http.StatusUnprocessableEntity, "invalid_quantity", "The quantity isn't valid"}},
+	{app.ErrProductNotFound, problemKind{http.StatusNotFound, "product_not_found", "The product doesn't exist"}},
+	{app.ErrVariantNotFound, problemKind{http.StatusNotFound, "variant_not_found", "The variant doesn't exist"}},
+	{app.ErrVariantRequired, problemKind{http.StatusUnprocessableEntity, "variant_required", "The product comes in variants, pick one"}},
+	{app.ErrSKUExists, problemKind{http.StatusConflict, "sku_exists", "The SKU is already in use"}},
+	{app.ErrInsufficientStock, problemKind{http.StatusConflict, "insufficient_stock", "There's not enough stock"}},
+	{app.ErrCategoryNotFound, problemKind{http.StatusNotFound, "category_not_found", "The category doesn't exist"}},
+	{app.ErrCategoryHasChildren, problemKind{http.StatusConflict, "category_has_children", "The category still has subcategories"}},
+	{app.ErrCollectionNotFound, problemKind{http.StatusNotFound, "collection_not_found", "The collection doesn't exist"}},
+	{app.ErrSlugExists, problemKind{http.StatusConflict, "slug_exists", "The slug is already in use"}},
+	{app.ErrBasketNotFound, problemKind{http.StatusNotFound, "basket_not_found", "The basket doesn't exist"}},
+	{app.ErrItemNotInBasket, problemKind{http.StatusNotFound, "item_not_in_basket", "The item isn't in the basket"}},
+	{app.ErrEmptyBasket, problemKind{http.StatusUnprocessableEntity, "empty_basket", "The basket is empty"}},
+	{app.ErrBasketChanged, problemKind{http.StatusConflict, "basket_changed", "The basket changed during checkout, please try again"}},
+	{app.ErrInvalidTotal, problemKind{http.StatusUnprocessableEntity, "invalid_total", "The order total needs to be more than zero"}},
+	{app.ErrPromotionNotFound, problemKind{http.StatusNotFound, "coupon_not_found", "We don't know this coupon"}},
+	{app.ErrPromotionNotActive, problemKind{http.StatusUnprocessableEntity, "coupon_not_active", "The coupon isn't valid right now"}},
+	{app.ErrPromotionUsedUp, problemKind{http.StatusUnprocessableEntity, "coupon_used_up", "The coupon has been used up"}},
+	{app.ErrPromotionNotApplicable, problemKind{http.StatusUnprocessableEntity, "coupon_not_applicable", "The coupon doesn't apply to this basket"}},
+	{app.ErrOrderNotFound, problemKind{http.StatusNotFound, "order_not_found", "The order doesn't exist"}},
+	{app.ErrOrderNotPayable, problemKind{http.StatusConflict, "order_not_payable", "The order can't be paid in its current state"}},
+	{app.ErrOrderNotRefundable, problemKind{http.StatusConflict, "order_not_refundable", "The order can't be refunded in its current state"}},
+	{app.ErrOrderChanged, problemKind{http.StatusConflict, "order_changed", "The order changed in the meantime, please try again"}},
+	{payment.ErrDeclined, problemKind{http.StatusPaymentRequired, "payment_declined", "The payment was declined"}},
+	{tax.ErrUnknownCountry, problemKind{http.StatusBadRequest, "unknown_country", "We don't ship to this country"}},
+	{app.ErrUserNotFound, problemKind{http.StatusNotFound, "user_not_found", "The user doesn't exist"}},
+	{app.ErrUserExists, problemKind{http.StatusConflict, "user_exists", "There's already a user with this email address"}},
+	{app.ErrInvalidEmail, problemKind{http.StatusUnprocessableEntity, "invalid_email", "The email address isn't valid"}},
+	{app.ErrPasswordTooShort, problemKind{http.StatusUnprocessableEntity, "password_too_short", "The password is too short"}},
+	{app.ErrInvalidCredentials, problemKind{http.StatusUnauthorized, "invalid_credentials", "The email address or password is wrong"}},
+	{app.ErrInvalidToken, problemKind{http.StatusUnauthorized, "invalid_token", "The token isn't valid"}},
+}
+
+// apiError replies with the problem behind err. Errors we don't know about
+// are logged and become a 500, action says what we were trying to do.
+func (h *Handler) apiError(w http.ResponseWriter, r *http.Request, err error, action string) {
+	for _, dp := range domainProblems {
+		if !errors.Is(err, dp.err) {
+			continue
+		}
+		p := dp.newProblem(r, err.Error())
+		errors.As(err, &p.Fields)
+		h.writeProblem(w, p)
+		return
+	}
+
+	h.logger.ErrorContext(r.Context(), "failed to "+action, "error", err)
+	h.writeProblem(w, problemInternal.newProblem(r, "failed to "+action))
+}
+
+// apiProblem replies with a problem that isn't caused by the domain, like a malformed request
+func (h *Handler) apiProblem(w http.ResponseWriter, r *http.Request, kind problemKind, detail string) {
+	h.writeProblem(w, kind.newProblem(r, detail))
+}
+
+func (k problemKind) newProblem(r *http.Request, detail string) problem {
+	return problem{
+		Type:     problemTypePrefix + k.code,
+		Title:    k.title,
+		Status:   k.status,
+		Detail:   detail,
+		Instance: r.URL.Path,
+		Code:     k.code,
+	}
+}
+
+func (h *Handler) writeProblem(w http.ResponseWriter, p problem) {
+	w.Header().Set("Content-Type", "application/problem+json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(p.Status)
+	if err := json.NewEncoder(w).Encode(p); err != nil {
+		h.logger.Error("failed to write problem JSON", "error", err)
+	}
+}
+
+// isAPI tells the API requests apart from the ones for our web pages
+func isAPI(r *http.Request) bool {
+	return strings.HasPrefix(r.URL.Path, "/api/")
+}
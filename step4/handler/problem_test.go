@@ -0,0 +1,122 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	app "github.com/gerbenjacobs/go-webshop-course"
+	"github.com/gerbenjacobs/go-webshop-course/payment"
+	"github.com/gerbenjacobs/go-webshop-course/tax"
+)
+
+func TestAPIError(t *testing.T) {
+	tests := []struct {
+		err        error
+		wantStatus int
+		wantCode   string
+	}{
+		{fmt.Errorf("%w: for ID: 7", app.ErrProductNotFound), http.StatusNotFound, "product_not_found"},
+		{fmt.Errorf("%w: product 2 has no variant 9", app.ErrVariantNotFound), http.StatusNotFound, "variant_not_found"},
+		{fmt.Errorf("%w: 3 available for product ID: 1", app.ErrInsufficientStock), http.StatusConflict, "insufficient_stock"},
+		{fmt.Errorf("%w: for code: NOPE", app.ErrPromotionNotFound), http.StatusNotFound, "coupon_not_found"},
+		{fmt.Errorf("%w: WELCOME10", app.ErrPromotionUsedUp), http.StatusUnprocessableEntity, "coupon_used_up"},
+		{fmt.Errorf("%w: order 4 is paid", app.ErrOrderNotPayable), http.StatusConflict, "order_not_payable"},
+		{fmt.Errorf("%w: order 4 is paid, not pending_payment", app.ErrOrderChanged), http.StatusConflict, "order_changed"},
+		{fmt.Errorf("failed to pay: %w", payment.ErrDeclined), http.StatusPaymentRequired, "payment_declined"},
+		{fmt.Errorf("%w: \"US\"", tax.ErrUnknownCountry), http.StatusBadRequest, "unknown_country"},
+		{app.ErrInvalidCredentials, http.StatusUnauthorized, "invalid_credentials"},
+		{fmt.Errorf("%w: expired", app.ErrInvalidToken), http.StatusUnauthorized, "invalid_token"},
+		// the first match wins
+		{errors.Join(app.ErrProductNotFound, app.ErrInvalidInput), http.StatusUnprocessableEntity, "invalid_input"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.wantCode, func(t *testing.T) {
+			p := writeAPIError(t, tt.err)
+			if p.Status != tt.wantStatus || p.Code != tt.wantCode {
+				t.Errorf("problem is %d %s, want %d %s", p.Status, p.Code, tt.wantStatus, tt.wantCode)
+			}
+			if p.Type != problemTypePrefix+tt.wantCode {
+				t.Errorf("Type = %q, want %q", p.Type, problemTypePrefix+tt.wantCode)
+			}
+			if p.Detail != tt.err.Error() {
+				t.Errorf("Detail = %q, want %q", p.Detail, tt.err.Error())
+			}
+			if p.Title == "" || p.Instance != "/api/v2/test" {
+				t.Errorf("problem has title %q and instance %q, want a title and /api/v2/test", p.Title, p.Instance)
+			}
+		})
+	}
+}
+
+func TestAPIErrorFields(t *testing.T) {
+	fields := app.FieldErrors{}
+	fields.Add("price", "can't be negative")
+	fields.Add("variants[1].sku", "is required")
+
+	p := writeAPIError(t, fmt.Errorf("failed to create product: %w", fields.Err()))
+	if p.Status != http.StatusUnprocessableEntity || p.Code != "invalid_input" {
+		t.Errorf("problem is %d %s, want 422 invalid_input", p.Status, p.Code)
+	}
+	if !reflect.DeepEqual(p.Fields, fields) {
+		t.Errorf("Fields = %v, want %v", p.Fields, fields)
+	}
+}
+
+func TestAPIErrorUnknown(t *testing.T) {
+	p := writeAPIError(t, errors.New("database is on fire"))
+	if p.Status != http.StatusInternalServerError || p.Code != "internal_error" {
+		t.Errorf("problem is %d %s, want 500 internal_error", p.Status, p.Code)
+	}
+	// we don't leak what went wrong inside
+	if p.Detail != "failed to test" {
+		t.Errorf("Detail = %q, want %q", p.Detail, "failed to test")
+	}
+}
+
+func TestDomainProblems(t *testing.T) {
+	codes := make(map[string]bool)
+	for _, dp := range domainProblems {
+		if dp.err == nil || dp.code == "" || dp.title == "" {
+			t.Errorf("incomplete problem %+v", dp)
+		}
+		if dp.status < 400 || dp.status >= 500 {
+			t.Errorf("%s has status %d, domain errors are the client's", dp.code, dp.status)
+		}
+		if codes[dp.code] {
+			t.Errorf("code %s is used twice", dp.code)
+		}
+		codes[dp.code] = true
+	}
+	for _, kind := range []problemKind{problemInvalidRequest, problemUnauthorized, problemForbidden, problemNotFound, problemNotAllowed, problemMediaType, problemNotAcceptable, problemInternal} {
+		if codes[kind.code] {
+			t.Errorf("code %s is used by a domain problem too", kind.code)
+		}
+	}
+}
+
+// writeAPIError replies to a request with the error and reads back the problem
+func writeAPIError(t *testing.T, err error) problem {
+	t.Helper()
+	h := &Handler{logger: slog.New(slog.NewTextHandler(io.Discard, nil))}
+	rec := httptest.NewRecorder()
+	h.apiError(rec, httptest.NewRequest(http.MethodGet, "/api/v2/test", nil), err, "test")
+
+	if ct := rec.Header().Get("Content-Type"); ct != "application/problem+json" {
+		t.Errorf("Content-Type = %q, want application/problem+json", ct)
+	}
+	var p problem
+	if err := json.NewDecoder(rec.Body).Decode(&p); err != nil {
+		t.Fatalf("failed to decode problem: %v", err)
+	}
+	if p.Status != rec.Code {
+		t.Errorf("problem has status %d, but the response is a %d", p.Status, rec.Code)
+	}
+	return p
+}
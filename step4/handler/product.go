@@ -2,11 +2,15 @@ package handler
 
 import (
 	"errors"
+	"fmt"
 	"html/template"
 	"net/http"
+	"net/url"
 	"strconv"
+	"strings"
 
 	app "github.com/gerbenjacobs/go-webshop-course"
+	"github.com/gerbenjacobs/go-webshop-course/search"
 	"github.com/julienschmidt/httprouter"
 )
 
@@ -20,28 +24,67 @@ func (h *Handler) products(w http.ResponseWriter, r *http.Request, _ httprouter.
 		"static/homepage.html",
 	))
 
+	query, err := productQuery(r.URL.Query())
+	if err != nil {
+		_ = h.storeAndSaveFlash(r, w, "warning|We couldn't filter the products like that")
+		http.Redirect(w, r, "/", http.StatusSeeOther)
+		return
+	}
+
 	type pageData struct {
-		User     bool
-		Flashes  map[string]string
-		Products []app.Product
+		User        *app.User
+		Flashes     map[string]string
+		Query       app.ProductQuery
+		Page        app.ProductPage
+		PrevURL     string
+		NextURL     string
+		Categories  []app.CategoryNode
+		Collections []app.Collection
 	}
 
 	// fetch our products
-	products, err := h.Product.ListProducts(r.Context())
-	if err != nil {
+	page, err := h.Product.ListProducts(r.Context(), query)
+	var fields app.FieldErrors
+	switch {
+	case errors.As(err, &fields):
+		_ = h.storeAndSaveFlash(r, w, "warning|We couldn't filter the products like that")
+		http.Redirect(w, r, "/", http.StatusSeeOther)
+		return
+	case err != nil:
 		h.logger.Error("failed to fetch products", "error", err)
 		http.Error(w, "failed to fetch products", http.StatusInternalServerError)
 		return
 	}
+	categories, err := h.Catalog.CategoryTree(r.Context())
+	if err != nil {
+		h.logger.Error("failed to fetch categories", "error", err)
+		http.Error(w, "failed to fetch categories", http.StatusInternalServerError)
+		return
+	}
+	collections, err := h.Catalog.ListCollections(r.Context())
+	if err != nil {
+		h.logger.Error("failed to fetch collections", "error", err)
+		http.Error(w, "failed to fetch collections", http.StatusInternalServerError)
+		return
+	}
 
-	flashes, err := getFlashes(r, w)
+	flashes, err := h.getFlashes(r, w)
 	if err != nil {
 		h.logger.Warn("failed to get flashes", "error", err)
 	}
 	data := pageData{
-		User:     false,
-		Flashes:  flashes,
-		Products: products,
+		User:        h.currentUser(r),
+		Flashes:     flashes,
+		Query:       query,
+		Page:        page,
+		Categories:  categories,
+		Collections: collections,
+	}
+	if page.PrevCursor != "" {
+		data.PrevURL = pageURL(r.URL, page.PrevCursor)
+	}
+	if page.NextCursor != "" {
+		data.NextURL = pageURL(r.URL, page.NextCursor)
 	}
 	// render the templates
 	if err := tmpl.Execute(w, data); err != nil {
@@ -63,7 +106,7 @@ func (h *Handler) productByID(w http.ResponseWriter, r *http.Request, p httprout
 	productID, err := strconv.Atoi(productIDParam)
 	if err != nil {
 		h.logger.ErrorContext(r.Context(), "couldn't convert product ID to int", "error", err)
-		_ = storeAndSaveFlash(r, w, "warning|Invalid product ID given")
+		_ = h.storeAndSaveFlash(r, w, "warning|Invalid product ID given")
 		http.Redirect(w, r, "/", http.StatusTemporaryRedirect)
 		return
 	}
@@ -88,16 +131,16 @@ func (h *Handler) productByID(w http.ResponseWriter, r *http.Request, p httprout
 
 	// set up our page data
 	type pageData struct {
-		User    bool
+		User    *app.User
 		Flashes map[string]string
 		Product app.Product
 	}
-	flashes, err := getFlashes(r, w)
+	flashes, err := h.getFlashes(r, w)
 	if err != nil {
 		h.logger.Warn("failed to get flashes", "error", err)
 	}
 	data := pageData{
-		User:    false,
+		User:    h.currentUser(r),
 		Flashes: flashes,
 		Product: product,
 	}
@@ -109,3 +152,142 @@ func (h *Handler) productByID(w http.ResponseWriter, r *http.Request, p httprout
 		return
 	}
 }
+
+func (h *Handler) search(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	tmpl := template.Must(template.ParseFiles(
+		"static/layout.html",
+		"static/product/search.html",
+	))
+
+	query := strings.TrimSpace(r.URL.Query().Get("q"))
+	var results []app.SearchResult
+	if query != "" {
+		var err error
+		results, err = h.Product.SearchProducts(r.Context(), query)
+		if err != nil {
+			h.logger.Error("failed to search products", "error", err)
+			http.Error(w, "failed to search products", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	// the highlights are escaped by the search index, only the <mark> tags are left as HTML
+	type result struct {
+		Product     app.Product
+		Name        template.HTML
+		Description template.HTML
+		Categories  template.HTML
+	}
+	type pageData struct {
+		User    *app.User
+		Flashes map[string]string
+		Query   string
+		Results []result
+	}
+	flashes, err := h.getFlashes(r, w)
+	if err != nil {
+		h.logger.Warn("failed to get flashes", "error", err)
+	}
+	data := pageData{
+		User:    h.currentUser(r),
+		Flashes: flashes,
+		Query:   query,
+	}
+	for _, res := range results {
+		data.Results = append(data.Results, result{
+			Product:     res.Product,
+			Name:        template.HTML(res.Highlights[search.FieldName]),
+			Description: template.HTML(res.Highlights[search.FieldDescription]),
+			Categories:  template.HTML(res.Highlights[search.FieldCategories]),
+		})
+	}
+
+	if err := tmpl.Execute(w, data); err != nil {
+		h.logger.Error("failed to execute layout", "error", err)
+		http.Error(w, "failed to create layout", http.StatusInternalServerError)
+		return
+	}
+}
+
+// default and maximum number of products on a page of a listing
+const (
+	defaultPageSize = 20
+	maxPageSize     = 100
+)
+
+// productQuery reads the sorting, filters and paging of a product listing from the URL,
+// prices are in our default currency
+func productQuery(values url.Values) (app.ProductQuery, error) {
+	fields := app.FieldErrors{}
+	query := app.ProductQuery{
+		Sort:     app.ProductSort(values.Get("sort")),
+		Category: values.Get("category"),
+		Cursor:   values.Get("cursor"),
+		Limit:    defaultPageSize,
+	}
+	for _, field := range []string{"min_price", "max_price"} {
+		s := values.Get(field)
+		if s == "" {
+			continue
+		}
+		price, err := app.ParseMoney(s, app.DefaultCurrency)
+		if err != nil {
+			fields.Add(field, "needs to be an amount like 12.99")
+			continue
+		}
+		if field == "min_price" {
+			query.MinPrice = &price
+		} else {
+			query.MaxPrice = &price
+		}
+	}
+	if s := values.Get("in_stock"); s != "" {
+		inStock, err := strconv.ParseBool(s)
+		if err != nil {
+			fields.Add("in_stock", "needs to be true or false")
+		}
+		query.InStock = inStock
+	}
+	if s := values.Get("limit"); s != "" {
+		limit, err := strconv.Atoi(s)
+		if err != nil || limit < 1 || limit > maxPageSize {
+			fields.Add("limit", "needs to be a number from 1 to %d", maxPageSize)
+		}
+		query.Limit = limit
+	}
+	if len(fields) > 0 {
+		// show the other problems too, rather than one after the other
+		var invalid app.FieldErrors
+		if errors.As(query.Validate(), &invalid) {
+			for field, problem := range invalid {
+				fields.Add(field, "%s", problem)
+			}
+		}
+	}
+	return query, fields.Err()
+}
+
+// pageURL is the URL of the listing with another cursor, the other parameters stay
+func pageURL(u *url.URL, cursor string) string {
+	values := u.Query()
+	values.Del("cursor")
+	if cursor != "" {
+		values.Set("cursor", cursor)
+	}
+	if len(values) == 0 {
+		return u.Path
+	}
+	return u.Path + "?" + values.Encode()
+}
+
+// pageLinks is the Link header (RFC 8288) that points to the first, previous and next page
+func pageLinks(u *url.URL, page app.ProductPage) string {
+	links := []string{fmt.Sprintf(`<%s>; rel="first"`, pageURL(u, ""))}
+	if page.PrevCursor != "" {
+		links = append(links, fmt.Sprintf(`<%s>; rel="prev"`, pageURL(u, page.PrevCursor)))
+	}
+	if page.NextCursor != "" {
+		links = append(links, fmt.Sprintf(`<%s>; rel="next"`, pageURL(u, page.NextCursor)))
+	}
+	return strings.Join(links, ", ")
+}
@@ -0,0 +1,237 @@
+package handler
+
+import (
+	"errors"
+	"html/template"
+	"net/http"
+
+	app "github.com/gerbenjacobs/go-webshop-course"
+	"github.com/julienschmidt/httprouter"
+)
+
+func (h *Handler) login(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	if h.currentUser(r) != nil {
+		http.Redirect(w, r, "/profile", http.StatusSeeOther)
+		return
+	}
+
+	tmpl := template.Must(template.ParseFiles(
+		"static/layout.html",
+		"static/user/login.html",
+	))
+
+	type pageData struct {
+		User    *app.User
+		Flashes map[string]string
+	}
+	flashes, err := h.getFlashes(r, w)
+	if err != nil {
+		h.logger.Warn("failed to get flashes", "error", err)
+	}
+	data := pageData{
+		Flashes: flashes,
+	}
+
+	if err := tmpl.Execute(w, data); err != nil {
+		h.logger.Error("failed to execute layout", "error", err)
+		http.Error(w, "failed to create layout", http.StatusInternalServerError)
+		return
+	}
+}
+
+func (h *Handler) loginSubmit(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	r.ParseForm()
+	user, err := h.User.Login(r.Context(), r.PostForm.Get("email"), r.PostForm.Get("password"))
+	switch {
+	case errors.Is(err, app.ErrInvalidCredentials):
+		_ = h.storeAndSaveFlash(r, w, "danger|Invalid email or password")
+		http.Redirect(w, r, "/login", http.StatusSeeOther)
+		return
+	case err != nil:
+		h.logger.Error("failed to log in", "error", err)
+		http.Error(w, "something went wrong", http.StatusInternalServerError)
+		return
+	}
+
+	if err := h.startSession(r, w, user.ID); err != nil {
+		h.logger.Error("failed to start session", "error", err)
+		http.Error(w, "something went wrong", http.StatusInternalServerError)
+		return
+	}
+	if err := h.mergeGuestBasket(r, w, user.ID); err != nil {
+		h.logger.Error("failed to merge guest basket", "error", err)
+	}
+	_ = h.storeAndSaveFlash(r, w, "success|Welcome back, "+user.Name)
+	http.Redirect(w, r, "/", http.StatusSeeOther)
+}
+
+func (h *Handler) signup(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	if h.currentUser(r) != nil {
+		http.Redirect(w, r, "/profile", http.StatusSeeOther)
+		return
+	}
+
+	tmpl := template.Must(template.ParseFiles(
+		"static/layout.html",
+		"static/user/signup.html",
+	))
+
+	type pageData struct {
+		User    *app.User
+		Flashes map[string]string
+	}
+	flashes, err := h.getFlashes(r, w)
+	if err != nil {
+		h.logger.Warn("failed to get flashes", "error", err)
+	}
+	data := pageData{
+		Flashes: flashes,
+	}
+
+	if err := tmpl.Execute(w, data); err != nil {
+		h.logger.Error("failed to execute layout", "error", err)
+		http.Error(w, "failed to create layout", http.StatusInternalServerError)
+		return
+	}
+}
+
+func (h *Handler) signupSubmit(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	r.ParseForm()
+	user, err := h.User.Signup(r.Context(), r.PostForm.Get("name"), r.PostForm.Get("email"), r.PostForm.Get("password"))
+	var fields app.FieldErrors
+	switch {
+	case errors.Is(err, app.ErrUserExists):
+		_ = h.storeAndSaveFlash(r, w, "warning|An account with this email already exists, try logging in")
+		http.Redirect(w, r, "/signup", http.StatusSeeOther)
+		return
+	case errors.Is(err, app.ErrInvalidEmail):
+		_ = h.storeAndSaveFlash(r, w, "warning|Please enter a valid email address")
+		http.Redirect(w, r, "/signup", http.StatusSeeOther)
+		return
+	case errors.Is(err, app.ErrPasswordTooShort):
+		_ = h.storeAndSaveFlash(r, w, "warning|Your password needs to be at least 8 characters")
+		http.Redirect(w, r, "/signup", http.StatusSeeOther)
+		return
+	case errors.As(err, &fields) && fields["password"] != "":
+		_ = h.storeAndSaveFlash(r, w, "warning|Your password "+fields["password"])
+		http.Redirect(w, r, "/signup", http.StatusSeeOther)
+		return
+	case err != nil:
+		h.logger.Error("failed to sign up", "error", err)
+		http.Error(w, "something went wrong", http.StatusInternalServerError)
+		return
+	}
+
+	if err := h.startSession(r, w, user.ID); err != nil {
+		h.logger.Error("failed to start session", "error", err)
+		http.Error(w, "something went wrong", http.StatusInternalServerError)
+		return
+	}
+	if err := h.mergeGuestBasket(r, w, user.ID); err != nil {
+		h.logger.Error("failed to merge guest basket", "error", err)
+	}
+	_ = h.storeAndSaveFlash(r, w, "success|Welcome to the webshop, "+user.Name)
+	http.Redirect(w, r, "/", http.StatusSeeOther)
+}
+
+func (h *Handler) logout(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	if err := h.endSession(r, w); err != nil {
+		h.logger.Error("failed to end session", "error", err)
+	}
+	_ = h.storeAndSaveFlash(r, w, "success|You have been logged out")
+	http.Redirect(w, r, "/", http.StatusSeeOther)
+}
+
+func (h *Handler) profile(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	user := h.currentUser(r)
+	if user == nil {
+		_ = h.storeAndSaveFlash(r, w, "warning|Please log in first")
+		http.Redirect(w, r, "/login", http.StatusSeeOther)
+		return
+	}
+
+	tmpl := template.Must(template.ParseFiles(
+		"static/layout.html",
+		"static/user/profile.html",
+	))
+
+	type pageData struct {
+		User    *app.User
+		Flashes map[string]string
+	}
+	flashes, err := h.getFlashes(r, w)
+	if err != nil {
+		h.logger.Warn("failed to get flashes", "error", err)
+	}
+	data := pageData{
+		User:    user,
+		Flashes: flashes,
+	}
+
+	if err := tmpl.Execute(w, data); err != nil {
+		h.logger.Error("failed to execute layout", "error", err)
+		http.Error(w, "failed to create layout", http.StatusInternalServerError)
+		return
+	}
+}
+
+func (h *Handler) settings(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	user := h.currentUser(r)
+	if user == nil {
+		_ = h.storeAndSaveFlash(r, w, "warning|Please log in first")
+		http.Redirect(w, r, "/login", http.StatusSeeOther)
+		return
+	}
+
+	tmpl := template.Must(template.ParseFiles(
+		"static/layout.html",
+		"static/user/settings.html",
+	))
+
+	type pageData struct {
+		User    *app.User
+		Flashes map[string]string
+	}
+	flashes, err := h.getFlashes(r, w)
+	if err != nil {
+		h.logger.Warn("failed to get flashes", "error", err)
+	}
+	data := pageData{
+		User:    user,
+		Flashes: flashes,
+	}
+
+	if err := tmpl.Execute(w, data); err != nil {
+		h.logger.Error("failed to execute layout", "error", err)
+		http.Error(w, "failed to create layout", http.StatusInternalServerError)
+		return
+	}
+}
+
+func (h *Handler) settingsSubmit(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	user := h.currentUser(r)
+	if user == nil {
+		_ = h.storeAndSaveFlash(r, w, "warning|Please log in first")
+		http.Redirect(w, r, "/login", http.StatusSeeOther)
+		return
+	}
+
+	r.ParseForm()
+	err := h.User.ChangePassword(r.Context(), user.ID, r.PostForm.Get("current_password"), r.PostForm.Get("new_password"))
+	var fields app.FieldErrors
+	switch {
+	case errors.Is(err, app.ErrInvalidCredentials):
+		_ = h.storeAndSaveFlash(r, w, "danger|Your current password is incorrect")
+	case errors.Is(err, app.ErrPasswordTooShort):
+		_ = h.storeAndSaveFlash(r, w, "warning|Your new password needs to be at least 8 characters")
+	case errors.As(err, &fields) && fields["new_password"] != "":
+		_ = h.storeAndSaveFlash(r, w, "warning|Your new password "+fields["new_password"])
+	case err != nil:
+		h.logger.Error("failed to change password", "error", err)
+		http.Error(w, "something went wrong", http.StatusInternalServerError)
+		return
+	default:
+		_ = h.storeAndSaveFlash(r, w, "success|Your password has been changed")
+	}
+	http.Redirect(w, r, "/settings", http.StatusSeeOther)
+}
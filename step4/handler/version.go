@@ -0,0 +1,260 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net/http"
+	"slices"
+	"strings"
+
+	app "github.com/gerbenjacobs/go-webshop-course"
+	"github.com/gerbenjacobs/go-webshop-course/handler/apiv1"
+	"github.com/gerbenjacobs/go-webshop-course/handler/apiv2"
+	"github.com/gerbenjacobs/go-webshop-course/payment"
+	"github.com/julienschmidt/httprouter"
+)
+
+// apiVersion is a version of the API's wire format. Every version has its own
+// DTOs, so the domain types can change without breaking our clients.
+type apiVersion struct {
+	name string
+	spec []byte
+	wire
+}
+
+var (
+	apiV1 = &apiVersion{name: "v1", spec: apiv1.OpenAPI, wire: v1Wire{}}
+	apiV2 = &apiVersion{name: "v2", spec: apiv2.OpenAPI, wire: v2Wire{}}
+	// apiVersions are all the versions we serve, the first is the default
+	apiVersions = []*apiVersion{apiV1, apiV2}
+)
+
+// vendorMediaType is how clients pick a version with the Accept header, like application/vnd.webshop.v2+json
+const vendorMediaType = "application/vnd.webshop.%s+json"
+
+// wire turns our domain types into the DTOs of a version, and the bodies of requests into domain types
+type wire interface {
+	product(app.Product) any
+	products([]app.Product) any
+	searchResults([]app.SearchResult) any
+	categoryTree([]app.CategoryNode) any
+	category(app.Category) any
+	categoryPage(app.CategoryPage) any
+	collection(app.Collection) any
+	collections([]app.Collection) any
+	collectionPage(app.CollectionPage) any
+	basket(app.BasketView) any
+	order(app.Order) any
+	orders([]app.Order) any
+	payment(payment.Authorization) any
+	tokens(app.TokenPair) any
+
+	// the inputs are new DTOs to decode a body into
+	productInput() input[app.Product]
+	productPatchInput() input[app.ProductPatch]
+	categoryInput() input[app.Category]
+	collectionInput() input[app.Collection]
+}
+
+// input is a decoded body, App turns it into the domain type
+type input[T any] interface {
+	App() (T, error)
+}
+
+type v1Wire struct{}
+
+func (v1Wire) product(p app.Product) any                  { return apiv1.NewProduct(p) }
+func (v1Wire) products(p []app.Product) any               { return apiv1.NewProducts(p) }
+func (v1Wire) searchResults(r []app.SearchResult) any     { return apiv1.NewSearchResults(r) }
+func (v1Wire) categoryTree(t []app.CategoryNode) any      { return apiv1.NewCategoryTree(t) }
+func (v1Wire) category(c app.Category) any                { return apiv1.NewCategory(c) }
+func (v1Wire) categoryPage(p app.CategoryPage) any        { return apiv1.NewCategoryPage(p) }
+func (v1Wire) collection(c app.Collection) any            { return apiv1.NewCollection(c) }
+func (v1Wire) collections(c []app.Collection) any         { return apiv1.NewCollections(c) }
+func (v1Wire) collectionPage(p app.CollectionPage) any    { return apiv1.NewCollectionPage(p) }
+func (v1Wire) basket(b app.BasketView) any                { return apiv1.NewBasketView(b) }
+func (v1Wire) order(o app.Order) any                      { return apiv1.NewOrder(o) }
+func (v1Wire) orders(o []app.Order) any                   { return apiv1.NewOrders(o) }
+func (v1Wire) payment(a payment.Authorization) any        { return apiv1.NewPaymentAuthorization(a) }
+func (v1Wire) tokens(t app.TokenPair) any                 { return apiv1.NewTokenPair(t) }
+func (v1Wire) productInput() input[app.Product]           { return new(apiv1.Product) }
+func (v1Wire) productPatchInput() input[app.ProductPatch] { return new(apiv1.ProductPatch) }
+func (v1Wire) categoryInput() input[app.Category]         { return new(apiv1.Category) }
+func (v1Wire) collectionInput() input[app.Collection]     { return new(apiv1.Collection) }
+
+type v2Wire struct{}
+
+func (v2Wire) product(p app.Product) any                  { return apiv2.NewProduct(p) }
+func (v2Wire) products(p []app.Product) any               { return apiv2.NewProducts(p) }
+func (v2Wire) searchResults(r []app.SearchResult) any     { return apiv2.NewSearchResults(r) }
+func (v2Wire) categoryTree(t []app.CategoryNode) any      { return apiv2.NewCategoryTree(t) }
+func (v2Wire) category(c app.Category) any                { return apiv2.NewCategory(c) }
+func (v2Wire) categoryPage(p app.CategoryPage) any        { return apiv2.NewCategoryPage(p) }
+func (v2Wire) collection(c app.Collection) any            { return apiv2.NewCollection(c) }
+func (v2Wire) collections(c []app.Collection) any         { return apiv2.NewCollections(c) }
+func (v2Wire) collectionPage(p app.CollectionPage) any    { return apiv2.NewCollectionPage(p) }
+func (v2Wire) basket(b app.BasketView) any                { return apiv2.NewBasketView(b) }
+func (v2Wire) order(o app.Order) any                      { return apiv2.NewOrder(o) }
+func (v2Wire) orders(o []app.Order) any                   { return apiv2.NewOrders(o) }
+func (v2Wire) payment(a payment.Authorization) any        { return apiv2.NewPaymentAuthorization(a) }
+func (v2Wire) tokens(t app.TokenPair) any                 { return apiv2.NewTokenPair(t) }
+func (v2Wire) productInput() input[app.Product]           { return new(apiv2.ProductInput) }
+func (v2Wire) productPatchInput() input[app.ProductPatch] { return new(apiv2.ProductPatch) }
+func (v2Wire) categoryInput() input[app.Category]         { return new(apiv2.Category) }
+func (v2Wire) collectionInput() input[app.Collection]     { return new(apiv2.Collection) }
+
+// apiRouter mounts API routes for some of the versions, under /api/v1, /api/v2 and so on,
+// and under /api where the Accept header picks the version and the first one is the default
+type apiRouter struct {
+	h        *Handler
+	r        *router
+	versions []*apiVersion
+}
+
+func (a apiRouter) Handle(method, path string, handle httprouter.Handle) {
+	a.r.Handle(method, "/api"+path, a.h.negotiate(a.versions, nil, handle))
+	for _, v := range a.versions {
+		a.r.Handle(method, "/api/"+v.name+path, a.h.negotiate(a.versions, v, handle))
+	}
+}
+
+func (a apiRouter) GET(path string, handle httprouter.Handle) {
+	a.Handle(http.MethodGet, path, handle)
+}
+
+func (a apiRouter) POST(path string, handle httprouter.Handle) {
+	a.Handle(http.MethodPost, path, handle)
+}
+
+func (a apiRouter) PUT(path string, handle httprouter.Handle) {
+	a.Handle(http.MethodPut, path, handle)
+}
+
+func (a apiRouter) PATCH(path string, handle httprouter.Handle) {
+	a.Handle(http.MethodPatch, path, handle)
+}
+
+func (a apiRouter) DELETE(path string, handle httprouter.Handle) {
+	a.Handle(http.MethodDelete, path, handle)
+}
+
+// apiRequest is what we agreed on with the client
+type apiRequest struct {
+	version     *apiVersion
+	contentType string
+	// prefix is where the API lives for this request, like /api or /api/v2
+	prefix string
+}
+
+const apiRequestKey contextKey = "api_request"
+
+func withAPIRequest(ctx context.Context, req apiRequest) context.Context {
+	return context.WithValue(ctx, apiRequestKey, req)
+}
+
+// apiRequestFromContext defaults to the unversioned v1 API
+func apiRequestFromContext(ctx context.Context) apiRequest {
+	if req, ok := ctx.Value(apiRequestKey).(apiRequest); ok {
+		return req
+	}
+	return apiRequest{version: apiVersions[0], contentType: "application/json", prefix: "/api"}
+}
+
+func apiVersionFromContext(ctx context.Context) *apiVersion {
+	return apiRequestFromContext(ctx).version
+}
+
+// negotiate is middleware that picks the version of the request. A route under
+// a versioned path has a fixed version, otherwise the Accept header decides.
+func (h *Handler) negotiate(versions []*apiVersion, fixed *apiVersion, next httprouter.Handle) httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+		req := apiRequestFromContext(r.Context())
+		if fixed == nil {
+			// the same URL answers differently per version
+			w.Header().Add("Vary", "Accept")
+			req.version = versions[0]
+		} else {
+			req.version = fixed
+			req.prefix = "/api/" + fixed.name
+		}
+
+		accepted, err := acceptedVersion(r.Header.Get("Accept"))
+		switch {
+		case err != nil:
+			h.apiProblem(w, r, problemNotAcceptable, err.Error())
+			return
+		case accepted == nil:
+		case fixed != nil && accepted != fixed:
+			h.apiProblem(w, r, problemNotAcceptable, fmt.Sprintf("the path is for %s and the Accept header asks for %s", fixed.name, accepted.name))
+			return
+		case !slices.Contains(versions, accepted):
+			h.apiProblem(w, r, problemNotAcceptable, fmt.Sprintf("%s %s isn't in %s of the API", r.Method, r.URL.Path, accepted.name))
+			return
+		default:
+			req.version = accepted
+			req.contentType = fmt.Sprintf(vendorMediaType, accepted.name)
+		}
+
+		next(w, r.WithContext(withAPIRequest(r.Context(), req)), p)
+	}
+}
+
+// acceptedVersion finds the first version the Accept header asks for, it's nil when none is asked for
+func acceptedVersion(accept string) (*apiVersion, error) {
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, _, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		name, ok := strings.CutPrefix(mediaType, "application/vnd.webshop.")
+		if !ok {
+			continue
+		}
+		name, ok = strings.CutSuffix(name, "+json")
+		if !ok {
+			continue
+		}
+		for _, v := range apiVersions {
+			if v.name == name {
+				return v, nil
+			}
+		}
+		names := make([]string, len(apiVersions))
+		for i, v := range apiVersions {
+			names[i] = fmt.Sprintf(vendorMediaType, v.name)
+		}
+		return nil, fmt.Errorf("unknown media type %s, use one of %s", mediaType, strings.Join(names, ", "))
+	}
+	return nil, nil
+}
+
+// writeJSON replies with v, which should be a DTO of the version of the request
+func (h *Handler) writeJSON(w http.ResponseWriter, r *http.Request, status int, v any) {
+	w.Header().Set("Content-Type", apiRequestFromContext(r.Context()).contentType)
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		h.logger.Error("failed to write JSON", "error", err, "url", r.URL.Path)
+	}
+}
+
+// apiURL is a path in the API as the client used it, so links stay in the same version
+func apiURL(r *http.Request, format string, args ...any) string {
+	return apiRequestFromContext(r.Context()).prefix + fmt.Sprintf(format, args...)
+}
+
+// readInput decodes the body into the input of the request's version with decodeJSON and
+// turns it into the domain type, it replies with the problem when either fails
+func readInput[T any](h *Handler, w http.ResponseWriter, r *http.Request, in input[T], what string) (T, bool) {
+	var zero T
+	if !h.decodeJSON(w, r, in) {
+		return zero, false
+	}
+	v, err := in.App()
+	if err != nil {
+		h.apiError(w, r, err, "read "+what)
+		return zero, false
+	}
+	return v, true
+}
@@ -0,0 +1,79 @@
+package handler_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gerbenjacobs/go-webshop-course/handler/handlertest"
+)
+
+func TestNegotiate(t *testing.T) {
+	h := handlertest.NewHandler(t)
+	const (
+		v1 = "application/vnd.webshop.v1+json"
+		v2 = "application/vnd.webshop.v2+json"
+	)
+
+	tests := []struct {
+		name        string
+		method      string
+		path        string
+		accept      string
+		wantStatus  int
+		wantType    string
+		wantVersion string
+		wantVary    bool
+	}{
+		{"/api defaults to v1", http.MethodGet, "/api/products/1", "", http.StatusOK, "application/json", "v1", true},
+		{"/api with plain JSON is v1", http.MethodGet, "/api/products/1", "application/json", http.StatusOK, "application/json", "v1", true},
+		{"/api with the v2 media type", http.MethodGet, "/api/products/1", v2, http.StatusOK, v2, "v2", true},
+		{"/api with the v1 media type", http.MethodGet, "/api/products/1", v1, http.StatusOK, v1, "v1", true},
+		{"the first vendor media type wins", http.MethodGet, "/api/products/1", "text/html, " + v2 + ";q=0.9, " + v1, http.StatusOK, v2, "v2", true},
+		{"a versioned path without an Accept header", http.MethodGet, "/api/v2/products/1", "", http.StatusOK, "application/json", "v2", false},
+		{"a versioned path with its own media type", http.MethodGet, "/api/v2/products/1", v2, http.StatusOK, v2, "v2", false},
+		{"a versioned path with another version's media type", http.MethodGet, "/api/v1/products/1", v2, http.StatusNotAcceptable, "application/problem+json", "", false},
+		{"an unknown version", http.MethodGet, "/api/products/1", "application/vnd.webshop.v9+json", http.StatusNotAcceptable, "application/problem+json", "", true},
+		{"a route that isn't in the version asked for", http.MethodPost, "/api/basket/add", v2, http.StatusNotAcceptable, "application/problem+json", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.path, nil)
+			if tt.accept != "" {
+				req.Header.Set("Accept", tt.accept)
+			}
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, tt.wantStatus, rec.Body)
+			}
+			if ct := rec.Header().Get("Content-Type"); ct != tt.wantType {
+				t.Errorf("Content-Type = %q, want %q", ct, tt.wantType)
+			}
+			if vary := rec.Header().Get("Vary") == "Accept"; vary != tt.wantVary {
+				t.Errorf("Vary = %q, want Vary: Accept: %t", rec.Header().Get("Vary"), tt.wantVary)
+			}
+
+			var body map[string]any
+			if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+				t.Fatalf("failed to decode body: %v", err)
+			}
+			if tt.wantStatus == http.StatusNotAcceptable {
+				if body["code"] != "not_acceptable" || body["detail"] == "" {
+					t.Errorf("problem = %v, want not_acceptable with a detail", body)
+				}
+				return
+			}
+			// v1 has the description as desc, v2 spells it out
+			version := "v2"
+			if _, ok := body["desc"]; ok {
+				version = "v1"
+			}
+			if version != tt.wantVersion {
+				t.Errorf("product is in the %s format, want %s", version, tt.wantVersion)
+			}
+		})
+	}
+}
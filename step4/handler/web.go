@@ -1,24 +1,75 @@
 package handler
 
 import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
 	"net/http"
 	"strings"
 
+	app "github.com/gerbenjacobs/go-webshop-course"
 	"github.com/gorilla/sessions"
 )
 
 const cookieName = "flashes"
 
-var store = sessions.NewCookieStore([]byte("this-really-should-be-a-secure-token-thats-not-stored-in-code"))
+// SessionKey signs and encrypts our cookies. Auth is the HMAC key and needs
+// at least 32 bytes, Encryption is an AES key of 16, 24 or 32 bytes.
+type SessionKey struct {
+	Auth       []byte
+	Encryption []byte
+}
+
+// ParseSessionKeys reads keys in the form "auth1:encryption1,auth2:encryption2".
+// New cookies use the first key, the others are still accepted, so to rotate
+// keys, put the new key in front and remove the old one once its cookies have expired.
+func ParseSessionKeys(s string) ([]SessionKey, error) {
+	var keys []SessionKey
+	for _, pair := range strings.Split(s, ",") {
+		auth, encryption, ok := strings.Cut(strings.TrimSpace(pair), ":")
+		if !ok || len(auth) < 32 {
+			return nil, fmt.Errorf("invalid session key, expected auth:encryption with an auth key of at least 32 bytes")
+		}
+		switch len(encryption) {
+		case 16, 24, 32:
+		default:
+			return nil, fmt.Errorf("invalid session key, the encryption key needs 16, 24 or 32 bytes, not %d", len(encryption))
+		}
+		keys = append(keys, SessionKey{Auth: []byte(auth), Encryption: []byte(encryption)})
+	}
+	return keys, nil
+}
 
-func storeAndSaveFlash(r *http.Request, w http.ResponseWriter, msg string) error {
-	session, _ := store.Get(r, cookieName)
+// newSessionStore keeps the sessions in cookies that scripts can't read
+// and that don't go along with cross-site posts, secure ones are only sent over HTTPS
+func newSessionStore(keys []SessionKey, secure bool) *sessions.CookieStore {
+	if len(keys) == 0 {
+		panic("handler: at least one session key is required")
+	}
+	var pairs [][]byte
+	for _, k := range keys {
+		pairs = append(pairs, k.Auth, k.Encryption)
+	}
+	store := sessions.NewCookieStore(pairs...)
+	store.Options = &sessions.Options{
+		Path:     "/",
+		MaxAge:   30 * 24 * 60 * 60,
+		HttpOnly: true,
+		Secure:   secure,
+		SameSite: http.SameSiteLaxMode,
+	}
+	return store
+}
+
+func (h *Handler) storeAndSaveFlash(r *http.Request, w http.ResponseWriter, msg string) error {
+	session, _ := h.sessions.Get(r, cookieName)
 	session.AddFlash(msg)
 	return session.Save(r, w)
 }
 
-func getFlashes(r *http.Request, w http.ResponseWriter) (map[string]string, error) {
-	session, _ := store.Get(r, cookieName)
+func (h *Handler) getFlashes(r *http.Request, w http.ResponseWriter) (map[string]string, error) {
+	session, _ := h.sessions.Get(r, cookieName)
 	flashes := session.Flashes()
 
 	m := map[string]string{}
@@ -30,3 +81,109 @@ func getFlashes(r *http.Request, w http.ResponseWriter) (map[string]string, erro
 	}
 	return m, session.Save(r, w)
 }
+
+const sessionName = "session"
+
+// startSession remembers the logged-in user in a signed cookie
+func (h *Handler) startSession(r *http.Request, w http.ResponseWriter, userID int) error {
+	session, _ := h.sessions.Get(r, sessionName)
+	session.Values["user_id"] = userID
+	// a new login gets a new CSRF token, see csrfToken
+	delete(session.Values, csrfTokenKey)
+	return session.Save(r, w)
+}
+
+func (h *Handler) endSession(r *http.Request, w http.ResponseWriter) error {
+	session, _ := h.sessions.Get(r, sessionName)
+	session.Options.MaxAge = -1
+	return session.Save(r, w)
+}
+
+func (h *Handler) sessionUserID(r *http.Request) (int, bool) {
+	session, _ := h.sessions.Get(r, sessionName)
+	userID, ok := session.Values["user_id"].(int)
+	return userID, ok
+}
+
+// currentUser returns the logged-in user, or nil when there is none
+func (h *Handler) currentUser(r *http.Request) *app.User {
+	userID, ok := h.sessionUserID(r)
+	if !ok {
+		return nil
+	}
+	user, err := h.User.GetUser(r.Context(), userID)
+	if err != nil {
+		h.logger.WarnContext(r.Context(), "session has unknown user", "user_id", userID, "error", err)
+		return nil
+	}
+	return &user
+}
+
+const (
+	csrfTokenKey   = "csrf_token"
+	csrfTokenField = "csrf_token"
+)
+
+// csrfToken returns the token the admin forms of the session send along in the
+// csrf_token field, so another site can't post them for a logged-in admin.
+// The session gets a token the first time it's asked for one.
+func (h *Handler) csrfToken(r *http.Request, w http.ResponseWriter) (string, error) {
+	session, _ := h.sessions.Get(r, sessionName)
+	if token, ok := session.Values[csrfTokenKey].(string); ok && token != "" {
+		return token, nil
+	}
+	b := make([]byte, 32)
+	_, _ = rand.Read(b)
+	token := base64.RawURLEncoding.EncodeToString(b)
+	session.Values[csrfTokenKey] = token
+	return token, session.Save(r, w)
+}
+
+// validCSRFToken checks the token of the posted form against the one of the session
+func (h *Handler) validCSRFToken(r *http.Request) bool {
+	session, _ := h.sessions.Get(r, sessionName)
+	want, ok := session.Values[csrfTokenKey].(string)
+	if !ok || want == "" {
+		return false
+	}
+	got := r.PostFormValue(csrfTokenField)
+	return subtle.ConstantTimeCompare([]byte(got), []byte(want)) == 1
+}
+
+const guestCookieName = "basket"
+
+// guestID returns the guest basket owner ID stored in a signed cookie,
+// when create is true and there's no ID yet, a new one is handed out
+func (h *Handler) guestID(r *http.Request, w http.ResponseWriter, create bool) (int, bool, error) {
+	session, _ := h.sessions.Get(r, guestCookieName)
+	if id, ok := session.Values["guest_id"].(int); ok && app.IsGuest(id) {
+		return id, true, nil
+	}
+	if !create {
+		return 0, false, nil
+	}
+
+	id := app.NewGuestID()
+	session.Values["guest_id"] = id
+	session.Options.MaxAge = int(app.GuestBasketTTL.Seconds())
+	return id, true, session.Save(r, w)
+}
+
+func (h *Handler) forgetGuest(r *http.Request, w http.ResponseWriter) error {
+	session, _ := h.sessions.Get(r, guestCookieName)
+	session.Options.MaxAge = -1
+	return session.Save(r, w)
+}
+
+// mergeGuestBasket moves the guest's basket into the basket of
+// the user that just logged in and forgets the guest
+func (h *Handler) mergeGuestBasket(r *http.Request, w http.ResponseWriter, userID int) error {
+	guest, ok, _ := h.guestID(r, w, false)
+	if !ok {
+		return nil
+	}
+	if err := h.Basket.MergeBaskets(r.Context(), guest, userID); err != nil {
+		return err
+	}
+	return h.forgetGuest(r, w)
+}
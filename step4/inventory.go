@@ -0,0 +1,44 @@
+package go_webshop_course
+
+import (
+	"errors"
+	"time"
+)
+
+var ErrInsufficientStock = errors.New("insufficient stock")
+
+// LowStockThreshold is the number of items left at which we tell customers to be quick
+const LowStockThreshold = 5
+
+type StockStatus string
+
+const (
+	StockStatusInStock    StockStatus = "in_stock"
+	StockStatusLowStock   StockStatus = "low_stock"
+	StockStatusOutOfStock StockStatus = "out_of_stock"
+)
+
+// StockStatusOf tells how well stocked we are with the given number of items available
+func StockStatusOf(available int) StockStatus {
+	switch {
+	case available <= 0:
+		return StockStatusOutOfStock
+	case available <= LowStockThreshold:
+		return StockStatusLowStock
+	}
+	return StockStatusInStock
+}
+
+// Reservation holds stock for an order until it's paid, or until ExpiresAt when it isn't
+type Reservation struct {
+	OrderID   int
+	ProductID int
+	VariantID int
+	Quantity  int
+	ExpiresAt time.Time
+}
+
+// Active reports whether the reservation still holds its stock at time t
+func (r Reservation) Active(t time.Time) bool {
+	return t.Before(r.ExpiresAt)
+}
@@ -0,0 +1,236 @@
+package go_webshop_course
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+var (
+	ErrCurrencyMismatch = errors.New("currencies don't match")
+	ErrInvalidMoney     = errors.New("invalid money amount")
+)
+
+// DefaultCurrency is the currency our shop sells in
+const DefaultCurrency = "EUR"
+
+// currencies contains the symbol and the number of decimals (the exponent of the minor unit)
+// of the ISO 4217 currencies we know about, others are printed with their code and 2 decimals
+var currencies = map[string]struct {
+	symbol   string
+	decimals int
+}{
+	"EUR": {"€", 2},
+	"USD": {"$", 2},
+	"GBP": {"£", 2},
+	"JPY": {"¥", 0},
+}
+
+// Money is an amount in the minor unit of a currency, such as cents for EUR.
+// Using integers means we never lose a cent to floating point rounding.
+type Money struct {
+	Amount   int64  `json:"amount"`
+	Currency string `json:"currency"`
+}
+
+func NewMoney(amount int64, currency string) Money {
+	return Money{Amount: amount, Currency: strings.ToUpper(currency)}
+}
+
+// EUR creates an amount of euro cents
+func EUR(cents int64) Money {
+	return NewMoney(cents, "EUR")
+}
+
+// ParseMoney parses a decimal amount such as "12.99" in the major unit of the currency
+func ParseMoney(s, currency string) (Money, error) {
+	currency = strings.ToUpper(currency)
+	decimals := currencyDecimals(currency)
+
+	s = strings.TrimSpace(s)
+	negative := strings.HasPrefix(s, "-")
+	s = strings.TrimPrefix(s, "-")
+
+	whole, fraction, _ := strings.Cut(s, ".")
+	if whole == "" || len(fraction) > decimals {
+		return Money{}, fmt.Errorf("%w: %q", ErrInvalidMoney, s)
+	}
+	fraction += strings.Repeat("0", decimals-len(fraction))
+
+	amount, err := strconv.ParseInt(whole+fraction, 10, 64)
+	if err != nil || strings.ContainsAny(whole+fraction, "+-") {
+		return Money{}, fmt.Errorf("%w: %q", ErrInvalidMoney, s)
+	}
+	if negative {
+		amount = -amount
+	}
+	return NewMoney(amount, currency), nil
+}
+
+func (m Money) IsZero() bool {
+	return m.Amount == 0
+}
+
+func (m Money) IsNegative() bool {
+	return m.Amount < 0
+}
+
+// Add returns the sum of both amounts, the zero value can be added to any currency.
+// Adding different currencies is a programming error and panics.
+func (m Money) Add(o Money) Money {
+	return NewMoney(m.Amount+o.Amount, m.sameCurrency(o))
+}
+
+// Sub subtracts o from m, see Add
+func (m Money) Sub(o Money) Money {
+	return NewMoney(m.Amount-o.Amount, m.sameCurrency(o))
+}
+
+// Mul multiplies the amount, for example by a quantity
+func (m Money) Mul(n int) Money {
+	return NewMoney(m.Amount*int64(n), m.Currency)
+}
+
+// Cmp returns -1, 0 or +1 depending on whether m is less than, equal to or more than o
+func (m Money) Cmp(o Money) int {
+	m.sameCurrency(o)
+	switch {
+	case m.Amount < o.Amount:
+		return -1
+	case m.Amount > o.Amount:
+		return 1
+	}
+	return 0
+}
+
+// Allocate splits the amount by the given ratios without losing any cents,
+// the remainder is handed out one by one starting with the first part.
+// Allocating €0.05 by 3:7 gives €0.02 and €0.03.
+func (m Money) Allocate(ratios ...int) []Money {
+	var total int64
+	for _, r := range ratios {
+		if r < 0 {
+			panic("money: negative ratio")
+		}
+		total += int64(r)
+	}
+	if total == 0 {
+		panic("money: ratios add up to zero")
+	}
+
+	parts := make([]Money, len(ratios))
+	remainder := m.Amount
+	for i, r := range ratios {
+		parts[i] = NewMoney(m.Amount*int64(r)/total, m.Currency)
+		remainder -= parts[i].Amount
+	}
+
+	step := int64(1)
+	if remainder < 0 {
+		step = -1
+	}
+	for i := 0; remainder != 0; i = (i + 1) % len(parts) {
+		if ratios[i] == 0 {
+			continue
+		}
+		parts[i].Amount += step
+		remainder -= step
+	}
+	return parts
+}
+
+// Split divides the amount in n (nearly) equal parts, see Allocate
+func (m Money) Split(n int) []Money {
+	ratios := make([]int, n)
+	for i := range ratios {
+		ratios[i] = 1
+	}
+	return m.Allocate(ratios...)
+}
+
+// Decimal returns the amount in the major unit without a symbol, like "12.99"
+func (m Money) Decimal() string {
+	decimals := currencyDecimals(m.Currency)
+	amount := m.Amount
+	sign := ""
+	if amount < 0 {
+		sign = "-"
+		amount = -amount
+	}
+	if decimals == 0 {
+		return sign + strconv.FormatInt(amount, 10)
+	}
+	unit := int64(math.Pow10(decimals))
+	return fmt.Sprintf("%s%d.%0*d", sign, amount/unit, decimals, amount%unit)
+}
+
+// String formats the amount for humans, like "€12.99"
+func (m Money) String() string {
+	if c, ok := currencies[m.Currency]; ok {
+		if m.Amount < 0 {
+			return "-" + c.symbol + NewMoney(-m.Amount, m.Currency).Decimal()
+		}
+		return c.symbol + m.Decimal()
+	}
+	return strings.TrimSpace(m.Currency + " " + m.Decimal())
+}
+
+// UnmarshalJSON makes sure we never accept half a money value
+func (m *Money) UnmarshalJSON(b []byte) error {
+	var v struct {
+		Amount   *int64 `json:"amount"`
+		Currency string `json:"currency"`
+	}
+	if err := json.Unmarshal(b, &v); err != nil {
+		return fmt.Errorf("%w: expected an object with an amount and a currency", ErrInvalidMoney)
+	}
+	if v.Amount == nil || len(v.Currency) != 3 {
+		return fmt.Errorf("%w: amount and a 3 letter currency are required", ErrInvalidMoney)
+	}
+	*m = NewMoney(*v.Amount, v.Currency)
+	return nil
+}
+
+// Value stores the amount in minor units, so the database can sum and sort it.
+// The currency lives in a column of its own, scan it into Currency after the amount:
+//
+//	row.Scan(&p.Price, &p.Price.Currency)
+func (m Money) Value() (driver.Value, error) {
+	return m.Amount, nil
+}
+
+// Scan reads an amount in minor units, it leaves the currency alone, see Value
+func (m *Money) Scan(src any) error {
+	switch v := src.(type) {
+	case int64:
+		m.Amount = v
+	case nil:
+		m.Amount = 0
+	default:
+		return fmt.Errorf("%w: can't scan %T", ErrInvalidMoney, src)
+	}
+	return nil
+}
+
+func (m Money) sameCurrency(o Money) string {
+	switch {
+	case m.Currency == o.Currency:
+		return m.Currency
+	case m.Currency == "" && m.Amount == 0:
+		return o.Currency
+	case o.Currency == "" && o.Amount == 0:
+		return m.Currency
+	}
+	panic(fmt.Sprintf("money: %v: %s and %s", ErrCurrencyMismatch, m.Currency, o.Currency))
+}
+
+func currencyDecimals(currency string) int {
+	if c, ok := currencies[currency]; ok {
+		return c.decimals
+	}
+	return 2
+}
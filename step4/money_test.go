@@ -0,0 +1,236 @@
+package go_webshop_course_test
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+
+	app "github.com/gerbenjacobs/go-webshop-course"
+)
+
+func TestParseMoney(t *testing.T) {
+	tests := []struct {
+		in       string
+		currency string
+		want     app.Money
+		wantErr  bool
+	}{
+		{in: "12.99", currency: "EUR", want: app.EUR(1299)},
+		{in: "12.9", currency: "EUR", want: app.EUR(1290)},
+		{in: "12", currency: "EUR", want: app.EUR(1200)},
+		{in: "12.", currency: "EUR", want: app.EUR(1200)},
+		{in: " 0.05 ", currency: "EUR", want: app.EUR(5)},
+		{in: "-3.50", currency: "EUR", want: app.EUR(-350)},
+		{in: "1500", currency: "jpy", want: app.NewMoney(1500, "JPY")},
+		{in: "1.25", currency: "XYZ", want: app.NewMoney(125, "XYZ")},
+		{in: "12.999", currency: "EUR", wantErr: true},
+		{in: "15.5", currency: "JPY", wantErr: true},
+		{in: ".50", currency: "EUR", wantErr: true},
+		{in: "", currency: "EUR", wantErr: true},
+		{in: "abc", currency: "EUR", wantErr: true},
+		{in: "1.-5", currency: "EUR", wantErr: true},
+		{in: "--1", currency: "EUR", wantErr: true},
+		{in: "+1", currency: "EUR", wantErr: true},
+		{in: "1,50", currency: "EUR", wantErr: true},
+		{in: "99999999999999999999", currency: "EUR", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := app.ParseMoney(tt.in, tt.currency)
+		if tt.wantErr {
+			if !errors.Is(err, app.ErrInvalidMoney) {
+				t.Errorf("ParseMoney(%q, %s) error = %v, want %v", tt.in, tt.currency, err, app.ErrInvalidMoney)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("ParseMoney(%q, %s): %v", tt.in, tt.currency, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("ParseMoney(%q, %s) = %#v, want %#v", tt.in, tt.currency, got, tt.want)
+		}
+	}
+}
+
+func TestMoneyArithmetic(t *testing.T) {
+	tests := []struct {
+		name string
+		got  app.Money
+		want app.Money
+	}{
+		{"Add", app.EUR(1299).Add(app.EUR(1)), app.EUR(1300)},
+		{"Add to the zero value", app.Money{}.Add(app.EUR(500)), app.EUR(500)},
+		{"Add the zero value", app.EUR(500).Add(app.Money{}), app.EUR(500)},
+		{"Sub", app.EUR(1000).Sub(app.EUR(1299)), app.EUR(-299)},
+		{"Sub from the zero value", app.Money{}.Sub(app.EUR(5)), app.EUR(-5)},
+		{"Mul", app.EUR(1299).Mul(3), app.EUR(3897)},
+		{"Mul by zero", app.EUR(1299).Mul(0), app.EUR(0)},
+	}
+	for _, tt := range tests {
+		if tt.got != tt.want {
+			t.Errorf("%s = %#v, want %#v", tt.name, tt.got, tt.want)
+		}
+	}
+
+	if got := app.EUR(5).Cmp(app.EUR(7)); got != -1 {
+		t.Errorf("Cmp of a smaller amount = %d, want -1", got)
+	}
+	if got := app.EUR(7).Cmp(app.EUR(7)); got != 0 {
+		t.Errorf("Cmp of the same amount = %d, want 0", got)
+	}
+	if got := app.EUR(9).Cmp(app.EUR(7)); got != 1 {
+		t.Errorf("Cmp of a larger amount = %d, want 1", got)
+	}
+}
+
+func TestMoneyMixedCurrenciesPanic(t *testing.T) {
+	for name, fn := range map[string]func(){
+		"Add": func() { app.EUR(1).Add(app.NewMoney(1, "USD")) },
+		"Sub": func() { app.EUR(1).Sub(app.NewMoney(1, "USD")) },
+		"Cmp": func() { app.EUR(1).Cmp(app.NewMoney(1, "USD")) },
+	} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("%s of EUR and USD didn't panic", name)
+				}
+			}()
+			fn()
+		}()
+	}
+}
+
+func TestMoneyAllocate(t *testing.T) {
+	tests := []struct {
+		amount app.Money
+		ratios []int
+		want   []int64
+	}{
+		{app.EUR(5), []int{3, 7}, []int64{2, 3}},
+		{app.EUR(100), []int{1, 1, 1}, []int64{34, 33, 33}},
+		{app.EUR(-100), []int{1, 1, 1}, []int64{-34, -33, -33}},
+		{app.EUR(1000), []int{0, 1}, []int64{0, 1000}},
+		{app.EUR(1), []int{0, 1, 1}, []int64{0, 1, 0}},
+		{app.EUR(0), []int{2, 5}, []int64{0, 0}},
+		{app.EUR(1299), []int{1}, []int64{1299}},
+	}
+	for _, tt := range tests {
+		parts := tt.amount.Allocate(tt.ratios...)
+		got := make([]int64, len(parts))
+		var sum int64
+		for i, p := range parts {
+			got[i] = p.Amount
+			sum += p.Amount
+			if p.Currency != tt.amount.Currency {
+				t.Errorf("Allocate(%v) part %d has currency %q", tt.ratios, i, p.Currency)
+			}
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s.Allocate(%v) = %v, want %v", tt.amount, tt.ratios, got, tt.want)
+		}
+		if sum != tt.amount.Amount {
+			t.Errorf("%s.Allocate(%v) adds up to %d", tt.amount, tt.ratios, sum)
+		}
+	}
+
+	if got := app.EUR(10).Split(3); !reflect.DeepEqual(got, []app.Money{app.EUR(4), app.EUR(3), app.EUR(3)}) {
+		t.Errorf("Split(3) = %v, want €0.04, €0.03 and €0.03", got)
+	}
+}
+
+func TestMoneyString(t *testing.T) {
+	tests := []struct {
+		m           app.Money
+		wantString  string
+		wantDecimal string
+	}{
+		{app.EUR(1299), "€12.99", "12.99"},
+		{app.EUR(5), "€0.05", "0.05"},
+		{app.EUR(-350), "-€3.50", "-3.50"},
+		{app.EUR(0), "€0.00", "0.00"},
+		{app.NewMoney(1500, "JPY"), "¥1500", "1500"},
+		{app.NewMoney(125, "XYZ"), "XYZ 1.25", "1.25"},
+	}
+	for _, tt := range tests {
+		if got := tt.m.String(); got != tt.wantString {
+			t.Errorf("String of %#v = %q, want %q", tt.m, got, tt.wantString)
+		}
+		if got := tt.m.Decimal(); got != tt.wantDecimal {
+			t.Errorf("Decimal of %#v = %q, want %q", tt.m, got, tt.wantDecimal)
+		}
+	}
+}
+
+func TestMoneyJSON(t *testing.T) {
+	b, err := json.Marshal(app.EUR(1299))
+	if err != nil {
+		t.Fatalf("Marshal: %v", err)
+	}
+	if string(b) != `{"amount":1299,"currency":"EUR"}` {
+		t.Errorf("Marshal = %s", b)
+	}
+	var m app.Money
+	if err := json.Unmarshal(b, &m); err != nil {
+		t.Fatalf("Unmarshal: %v", err)
+	}
+	if m != app.EUR(1299) {
+		t.Errorf("round trip = %#v, want €12.99", m)
+	}
+
+	tests := []struct {
+		in      string
+		want    app.Money
+		wantErr bool
+	}{
+		{in: `{"amount":0,"currency":"eur"}`, want: app.EUR(0)},
+		{in: `{"amount":-5,"currency":"USD"}`, want: app.NewMoney(-5, "USD")},
+		{in: `{"currency":"EUR"}`, wantErr: true},
+		{in: `{"amount":100}`, wantErr: true},
+		{in: `{"amount":100,"currency":"EURO"}`, wantErr: true},
+		{in: `{"amount":12.99,"currency":"EUR"}`, wantErr: true},
+		{in: `"12.99"`, wantErr: true},
+		{in: `1299`, wantErr: true},
+	}
+	for _, tt := range tests {
+		var got app.Money
+		err := json.Unmarshal([]byte(tt.in), &got)
+		if tt.wantErr {
+			if !errors.Is(err, app.ErrInvalidMoney) {
+				t.Errorf("Unmarshal(%s) error = %v, want %v", tt.in, err, app.ErrInvalidMoney)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Unmarshal(%s): %v", tt.in, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("Unmarshal(%s) = %#v, want %#v", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestMoneySQL(t *testing.T) {
+	v, err := app.EUR(-1299).Value()
+	if err != nil {
+		t.Fatalf("Value: %v", err)
+	}
+	if v != int64(-1299) {
+		t.Errorf("Value = %#v, want int64(-1299)", v)
+	}
+
+	m := app.Money{Currency: "EUR"}
+	if err := m.Scan(v); err != nil {
+		t.Fatalf("Scan: %v", err)
+	}
+	if m != app.EUR(-1299) {
+		t.Errorf("round trip = %#v, want -€12.99", m)
+	}
+	if err := m.Scan(nil); err != nil || m != app.EUR(0) {
+		t.Errorf("Scan(nil) = %#v, %v, want €0.00", m, err)
+	}
+	if err := m.Scan(12.99); !errors.Is(err, app.ErrInvalidMoney) {
+		t.Errorf("Scan(12.99) error = %v, want %v", err, app.ErrInvalidMoney)
+	}
+}
@@ -0,0 +1,97 @@
+package go_webshop_course
+
+import (
+	"errors"
+	"time"
+)
+
+var (
+	ErrOrderNotFound      = errors.New("order not found")
+	ErrEmptyBasket        = errors.New("basket is empty")
+	ErrBasketChanged      = errors.New("basket changed during checkout")
+	ErrInvalidTotal       = errors.New("order total needs to be more than zero")
+	ErrOrderNotPayable    = errors.New("order can't be paid in its current state")
+	ErrOrderNotRefundable = errors.New("order can't be refunded in its current state")
+	ErrOrderChanged       = errors.New("order changed in the meantime")
+)
+
+type OrderStatus string
+
+const (
+	OrderStatusPendingPayment OrderStatus = "pending_payment"
+	OrderStatusPaymentFailed  OrderStatus = "payment_failed"
+	OrderStatusPaid           OrderStatus = "paid"
+	OrderStatusRefunded       OrderStatus = "refunded"
+)
+
+type Order struct {
+	ID        int               `json:"id"`
+	UserID    int               `json:"user_id"`
+	Status    OrderStatus       `json:"status"`
+	Items     []OrderItem       `json:"items"`
+	Subtotal  Money             `json:"subtotal"`
+	Shipping  Money             `json:"shipping"`
+	Discounts []AppliedDiscount `json:"discounts"`
+	Discount  Money             `json:"discount"`
+	// Country is where the order is taxed
+	Country          string    `json:"country"`
+	PricesIncludeTax bool      `json:"prices_include_tax"`
+	Taxes            []TaxLine `json:"taxes"`
+	Tax              Money     `json:"tax"`
+	Total            Money     `json:"total"`
+	PaymentID        string    `json:"payment_id,omitempty"`
+	CreatedAt        time.Time `json:"created_at"`
+	// ReservedUntil is how long we hold the stock for the order when it's not paid
+	ReservedUntil time.Time `json:"reserved_until"`
+}
+
+// OrderItem is a snapshot of a product at the time of purchase,
+// so changing a product's price later doesn't change existing orders
+type OrderItem struct {
+	ProductID int      `json:"product_id"`
+	VariantID int      `json:"variant_id,omitempty"`
+	SKU       string   `json:"sku,omitempty"`
+	Name      string   `json:"name"`
+	Variant   string   `json:"variant,omitempty"`
+	UnitPrice Money    `json:"unit_price"`
+	TaxClass  TaxClass `json:"tax_class"`
+	Quantity  int      `json:"quantity"`
+}
+
+// Payable reports whether we can (still) ask the customer to pay for this order
+func (o Order) Payable() bool {
+	return o.Status.Payable()
+}
+
+// Payable reports whether orders with this status haven't been paid yet
+func (s OrderStatus) Payable() bool {
+	return s == OrderStatusPendingPayment || s == OrderStatusPaymentFailed
+}
+
+func (o Order) FormattedSubtotal() string {
+	return o.Subtotal.String()
+}
+
+func (o Order) FormattedShipping() string {
+	return o.Shipping.String()
+}
+
+func (o Order) FormattedDiscount() string {
+	return o.Discount.String()
+}
+
+func (o Order) FormattedTotal() string {
+	return o.Total.String()
+}
+
+func (i OrderItem) LineTotal() Money {
+	return i.UnitPrice.Mul(i.Quantity)
+}
+
+func (i OrderItem) FormattedUnitPrice() string {
+	return i.UnitPrice.String()
+}
+
+func (i OrderItem) FormattedLineTotal() string {
+	return i.LineTotal().String()
+}
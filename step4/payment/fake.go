@@ -0,0 +1,271 @@
+package payment
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+
+	app "github.com/gerbenjacobs/go-webshop-course"
+)
+
+// Payment methods understood by the fake provider, each simulates a different flow
+const (
+	FakeMethodSuccess      = "fake_success"
+	FakeMethodDecline      = "fake_decline"
+	FakeMethod3DS          = "fake_3ds"
+	FakeMethodAsync        = "fake_async"
+	FakeMethodAsyncDecline = "fake_async_decline"
+)
+
+const signatureHeader = "Fake-Signature"
+
+type FakeOptions struct {
+	// BaseURL is where the fake's own HTTP handler can be reached,
+	// it's used to build the 3-D Secure redirect URLs
+	BaseURL string
+	// WebhookURL receives our events, if empty no webhooks are sent
+	WebhookURL string
+	// WebhookSecret is used to sign webhooks
+	WebhookSecret []byte
+	// AsyncDelay is how long the async methods take to send their webhook
+	AsyncDelay time.Duration
+	Logger     *slog.Logger
+}
+
+type fakePayment struct {
+	id           string
+	orderID      int
+	amount       app.Money
+	captured     app.Money
+	refunded     app.Money
+	status       Status
+	returnURL    string
+	approveAsync bool
+}
+
+// Fake is an in-process payment provider, it keeps its payments in memory.
+// It also implements http.Handler, so it can be served as a tiny PSP with
+// a JSON API and 3-D Secure pages, see FakeClient and cmd/fakepay.
+type Fake struct {
+	opts   FakeOptions
+	client *http.Client
+
+	mu       sync.Mutex
+	payments map[string]*fakePayment
+}
+
+func NewFake(opts FakeOptions) *Fake {
+	if opts.Logger == nil {
+		opts.Logger = slog.New(slog.NewTextHandler(io.Discard, nil))
+	}
+	if opts.AsyncDelay == 0 {
+		opts.AsyncDelay = 2 * time.Second
+	}
+	return &Fake{
+		opts:     opts,
+		client:   &http.Client{Timeout: 5 * time.Second},
+		payments: make(map[string]*fakePayment),
+	}
+}
+
+func (f *Fake) Authorize(_ context.Context, req AuthorizeRequest) (Authorization, error) {
+	if req.Amount.Amount <= 0 {
+		return Authorization{}, fmt.Errorf("invalid amount: %s", req.Amount)
+	}
+
+	p := &fakePayment{
+		id:        newPaymentID(),
+		orderID:   req.OrderID,
+		amount:    req.Amount,
+		returnURL: req.ReturnURL,
+	}
+
+	auth := Authorization{PaymentID: p.id}
+	switch req.Method {
+	case "", FakeMethodSuccess:
+		p.status = StatusAuthorized
+	case FakeMethodDecline:
+		p.status = StatusFailed
+	case FakeMethod3DS:
+		p.status = StatusRequiresAction
+		auth.RedirectURL = fmt.Sprintf("%s/3ds/%s", f.opts.BaseURL, p.id)
+	case FakeMethodAsync, FakeMethodAsyncDecline:
+		p.status = StatusPending
+		p.approveAsync = req.Method == FakeMethodAsync
+	default:
+		return Authorization{}, fmt.Errorf("unknown payment method %q", req.Method)
+	}
+	auth.Status = p.status
+
+	f.mu.Lock()
+	f.payments[p.id] = p
+	f.mu.Unlock()
+
+	if p.status == StatusFailed {
+		return auth, ErrDeclined
+	}
+	if p.status == StatusPending {
+		time.AfterFunc(f.opts.AsyncDelay, func() {
+			_, _ = f.Confirm(p.id, p.approveAsync)
+		})
+	}
+	return auth, nil
+}
+
+// Confirm finishes a payment that's waiting for the customer or the bank,
+// like the customer does on the 3-D Secure page. It sends the matching webhook
+// and returns the URL to send the customer back to.
+func (f *Fake) Confirm(paymentID string, approve bool) (string, error) {
+	f.mu.Lock()
+	p, ok := f.payments[paymentID]
+	if !ok {
+		f.mu.Unlock()
+		return "", ErrPaymentNotFound
+	}
+	if p.status != StatusRequiresAction && p.status != StatusPending {
+		f.mu.Unlock()
+		return "", fmt.Errorf("%w: can't confirm a %s payment", ErrInvalidState, p.status)
+	}
+
+	event := Event{Type: EventAuthorized, PaymentID: p.id, OrderID: p.orderID}
+	p.status = StatusAuthorized
+	if !approve {
+		event.Type = EventFailed
+		p.status = StatusFailed
+	}
+	returnURL := p.returnURL
+	f.mu.Unlock()
+
+	f.sendWebhook(event)
+	return returnURL, nil
+}
+
+func (f *Fake) Capture(_ context.Context, paymentID string, amount app.Money) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	p, ok := f.payments[paymentID]
+	if !ok {
+		return ErrPaymentNotFound
+	}
+	if p.status != StatusAuthorized {
+		return fmt.Errorf("%w: can't capture a %s payment", ErrInvalidState, p.status)
+	}
+	if amount.Currency != p.amount.Currency || amount.Amount <= 0 || amount.Amount > p.amount.Amount {
+		return fmt.Errorf("invalid capture amount %s, authorized %s", amount, p.amount)
+	}
+	p.captured = amount
+	p.status = StatusCaptured
+	return nil
+}
+
+func (f *Fake) Refund(_ context.Context, paymentID string, amount app.Money) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	p, ok := f.payments[paymentID]
+	if !ok {
+		return ErrPaymentNotFound
+	}
+	if p.status != StatusCaptured {
+		return fmt.Errorf("%w: can't refund a %s payment", ErrInvalidState, p.status)
+	}
+	if amount.Currency != p.captured.Currency || amount.Amount <= 0 || p.refunded.Amount+amount.Amount > p.captured.Amount {
+		return fmt.Errorf("invalid refund amount %s, captured %s", amount, p.captured)
+	}
+	p.refunded = p.refunded.Add(amount)
+	if p.refunded == p.captured {
+		p.status = StatusRefunded
+	}
+	return nil
+}
+
+func (f *Fake) ParseWebhook(r *http.Request) (Event, error) {
+	return parseSignedWebhook(r, f.opts.WebhookSecret)
+}
+
+// Status returns the current status of a payment, which is handy in tests
+func (f *Fake) Status(paymentID string) (Status, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	p, ok := f.payments[paymentID]
+	if !ok {
+		return "", ErrPaymentNotFound
+	}
+	return p.status, nil
+}
+
+func (f *Fake) sendWebhook(event Event) {
+	if f.opts.WebhookURL == "" {
+		return
+	}
+
+	body, err := json.Marshal(event)
+	if err != nil {
+		f.opts.Logger.Error("failed to encode webhook", "error", err)
+		return
+	}
+	req, err := http.NewRequest(http.MethodPost, f.opts.WebhookURL, bytes.NewReader(body))
+	if err != nil {
+		f.opts.Logger.Error("failed to create webhook request", "error", err)
+		return
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(signatureHeader, sign(f.opts.WebhookSecret, body))
+
+	resp, err := f.client.Do(req)
+	if err != nil {
+		f.opts.Logger.Error("failed to send webhook", "error", err, "event", event.Type)
+		return
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		f.opts.Logger.Error("webhook was not accepted", "status", resp.StatusCode, "event", event.Type)
+		return
+	}
+	f.opts.Logger.Info("Webhook sent", "event", event.Type, "payment_id", event.PaymentID)
+}
+
+func parseSignedWebhook(r *http.Request, secret []byte) (Event, error) {
+	body, err := io.ReadAll(io.LimitReader(r.Body, 1<<20))
+	if err != nil {
+		return Event{}, fmt.Errorf("%w: %w", ErrInvalidWebhook, err)
+	}
+	got, err := hex.DecodeString(r.Header.Get(signatureHeader))
+	if err != nil {
+		return Event{}, fmt.Errorf("%w: malformed signature", ErrInvalidWebhook)
+	}
+	want, _ := hex.DecodeString(sign(secret, body))
+	if !hmac.Equal(got, want) {
+		return Event{}, fmt.Errorf("%w: signature mismatch", ErrInvalidWebhook)
+	}
+
+	var event Event
+	if err := json.Unmarshal(body, &event); err != nil {
+		return Event{}, fmt.Errorf("%w: %w", ErrInvalidWebhook, err)
+	}
+	return event, nil
+}
+
+func sign(secret, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func newPaymentID() string {
+	var b [8]byte
+	_, _ = rand.Read(b[:])
+	return "pay_" + hex.EncodeToString(b[:])
+}
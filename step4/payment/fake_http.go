@@ -0,0 +1,199 @@
+package payment
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html/template"
+	"net/http"
+	"time"
+
+	app "github.com/gerbenjacobs/go-webshop-course"
+)
+
+var threeDSPage = template.Must(template.New("3ds").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+    <meta charset="utf-8">
+    <title>Fake 3-D Secure</title>
+    <link href="https://cdn.jsdelivr.net/npm/bootstrap@5.3.2/dist/css/bootstrap.min.css" rel="stylesheet"
+          integrity="sha384-T3c6CoIi6uLrA9TneNEoa7RxnatzjcDSCmG1MXxSR1GAsXEV/Dwwykc2MPK8M2HN" crossorigin="anonymous">
+</head>
+<body>
+<main class="container mt-5 text-center">
+    <h2>Fake bank</h2>
+    <p>Do you want to approve payment <code>{{ . }}</code>?</p>
+    <form method="post">
+        <button type="submit" name="result" value="approve" class="btn btn-success">Approve</button>
+        <button type="submit" name="result" value="decline" class="btn btn-danger">Decline</button>
+    </form>
+</main>
+</body>
+</html>`))
+
+type amountRequest struct {
+	Amount app.Money `json:"amount"`
+}
+
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+// ServeHTTP serves the fake's JSON API, used by FakeClient, and the 3-D Secure pages
+func (f *Fake) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /payments", func(w http.ResponseWriter, r *http.Request) {
+		var req AuthorizeRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeJSON(w, http.StatusBadRequest, errorResponse{Error: err.Error()})
+			return
+		}
+		auth, err := f.Authorize(r.Context(), req)
+		switch {
+		case errors.Is(err, ErrDeclined):
+			writeJSON(w, http.StatusPaymentRequired, auth)
+		case err != nil:
+			writeJSON(w, http.StatusBadRequest, errorResponse{Error: err.Error()})
+		default:
+			writeJSON(w, http.StatusCreated, auth)
+		}
+	})
+	mux.HandleFunc("POST /payments/{id}/capture", func(w http.ResponseWriter, r *http.Request) {
+		var req amountRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeJSON(w, http.StatusBadRequest, errorResponse{Error: err.Error()})
+			return
+		}
+		writeResult(w, f.Capture(r.Context(), r.PathValue("id"), req.Amount))
+	})
+	mux.HandleFunc("POST /payments/{id}/refund", func(w http.ResponseWriter, r *http.Request) {
+		var req amountRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeJSON(w, http.StatusBadRequest, errorResponse{Error: err.Error()})
+			return
+		}
+		writeResult(w, f.Refund(r.Context(), r.PathValue("id"), req.Amount))
+	})
+	mux.HandleFunc("GET /3ds/{id}", func(w http.ResponseWriter, r *http.Request) {
+		if _, err := f.Status(r.PathValue("id")); err != nil {
+			http.NotFound(w, r)
+			return
+		}
+		_ = threeDSPage.Execute(w, r.PathValue("id"))
+	})
+	mux.HandleFunc("POST /3ds/{id}", func(w http.ResponseWriter, r *http.Request) {
+		returnURL, err := f.Confirm(r.PathValue("id"), r.FormValue("result") == "approve")
+		switch {
+		case errors.Is(err, ErrPaymentNotFound):
+			http.NotFound(w, r)
+		case err != nil:
+			http.Error(w, err.Error(), http.StatusConflict)
+		case returnURL == "":
+			_, _ = fmt.Fprintln(w, "Payment confirmed, you can close this window.")
+		default:
+			http.Redirect(w, r, returnURL, http.StatusSeeOther)
+		}
+	})
+	mux.ServeHTTP(w, r)
+}
+
+func writeResult(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, ErrPaymentNotFound):
+		writeJSON(w, http.StatusNotFound, errorResponse{Error: err.Error()})
+	case errors.Is(err, ErrInvalidState):
+		writeJSON(w, http.StatusConflict, errorResponse{Error: err.Error()})
+	case err != nil:
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: err.Error()})
+	default:
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+// FakeClient is a PaymentProvider that talks to a Fake over HTTP,
+// for when the fake runs as its own server (see cmd/fakepay)
+type FakeClient struct {
+	baseURL       string
+	webhookSecret []byte
+	client        *http.Client
+}
+
+func NewFakeClient(baseURL string, webhookSecret []byte) *FakeClient {
+	return &FakeClient{
+		baseURL:       baseURL,
+		webhookSecret: webhookSecret,
+		client:        &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+func (c *FakeClient) Authorize(ctx context.Context, req AuthorizeRequest) (Authorization, error) {
+	var auth Authorization
+	status, err := c.post(ctx, "/payments", req, &auth)
+	if err != nil {
+		return Authorization{}, err
+	}
+	if status == http.StatusPaymentRequired {
+		return auth, ErrDeclined
+	}
+	return auth, nil
+}
+
+func (c *FakeClient) Capture(ctx context.Context, paymentID string, amount app.Money) error {
+	_, err := c.post(ctx, "/payments/"+paymentID+"/capture", amountRequest{Amount: amount}, nil)
+	return err
+}
+
+func (c *FakeClient) Refund(ctx context.Context, paymentID string, amount app.Money) error {
+	_, err := c.post(ctx, "/payments/"+paymentID+"/refund", amountRequest{Amount: amount}, nil)
+	return err
+}
+
+func (c *FakeClient) ParseWebhook(r *http.Request) (Event, error) {
+	return parseSignedWebhook(r, c.webhookSecret)
+}
+
+func (c *FakeClient) post(ctx context.Context, path string, body, out any) (int, error) {
+	b, err := json.Marshal(body)
+	if err != nil {
+		return 0, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.baseURL+path, bytes.NewReader(b))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("failed to reach payment provider: %w", err)
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return resp.StatusCode, ErrPaymentNotFound
+	case resp.StatusCode == http.StatusConflict:
+		var e errorResponse
+		_ = json.NewDecoder(resp.Body).Decode(&e)
+		return resp.StatusCode, fmt.Errorf("%w: %s", ErrInvalidState, e.Error)
+	case resp.StatusCode >= 400 && resp.StatusCode != http.StatusPaymentRequired:
+		var e errorResponse
+		_ = json.NewDecoder(resp.Body).Decode(&e)
+		return resp.StatusCode, fmt.Errorf("payment provider returned %d: %s", resp.StatusCode, e.Error)
+	}
+
+	if out != nil {
+		if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+			return resp.StatusCode, fmt.Errorf("failed to decode payment provider response: %w", err)
+		}
+	}
+	return resp.StatusCode, nil
+}
@@ -0,0 +1,70 @@
+// Package payment contains the PaymentProvider interface that payment service providers (PSPs)
+// have to implement, together with a fake provider for local development and tests.
+package payment
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	app "github.com/gerbenjacobs/go-webshop-course"
+)
+
+var (
+	ErrDeclined        = errors.New("payment declined")
+	ErrPaymentNotFound = errors.New("payment not found")
+	ErrInvalidState    = errors.New("payment is not in the right state")
+	ErrInvalidWebhook  = errors.New("invalid webhook")
+)
+
+type Status string
+
+const (
+	// StatusAuthorized means the money is reserved and can be captured
+	StatusAuthorized Status = "authorized"
+	// StatusRequiresAction means the customer needs to visit RedirectURL, for example for 3-D Secure
+	StatusRequiresAction Status = "requires_action"
+	// StatusPending means the result will arrive later through a webhook
+	StatusPending  Status = "pending"
+	StatusCaptured Status = "captured"
+	StatusRefunded Status = "refunded"
+	StatusFailed   Status = "failed"
+)
+
+type EventType string
+
+const (
+	EventAuthorized EventType = "payment.authorized"
+	EventFailed     EventType = "payment.failed"
+)
+
+type AuthorizeRequest struct {
+	OrderID int       `json:"order_id"`
+	Amount  app.Money `json:"amount"`
+	// Method is the payment method chosen by the customer,
+	// for cards this is the token the PSP handed out
+	Method string `json:"method"`
+	// ReturnURL is where the customer is sent after completing a redirect
+	ReturnURL string `json:"return_url"`
+}
+
+type Authorization struct {
+	PaymentID   string `json:"payment_id"`
+	Status      Status `json:"status"`
+	RedirectURL string `json:"redirect_url,omitempty"`
+}
+
+// Event is a status update that the provider sends us through a webhook
+type Event struct {
+	Type      EventType `json:"type"`
+	PaymentID string    `json:"payment_id"`
+	OrderID   int       `json:"order_id"`
+}
+
+type PaymentProvider interface {
+	Authorize(ctx context.Context, req AuthorizeRequest) (Authorization, error)
+	Capture(ctx context.Context, paymentID string, amount app.Money) error
+	Refund(ctx context.Context, paymentID string, amount app.Money) error
+	// ParseWebhook verifies an incoming webhook request and returns its event
+	ParseWebhook(r *http.Request) (Event, error)
+}
@@ -1,24 +1,359 @@
 package go_webshop_course
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
+	"slices"
+	"strings"
+	"unicode/utf8"
 )
 
-var ErrProductNotFound = errors.New("product not found")
+var (
+	ErrProductNotFound = errors.New("product not found")
+	ErrVariantNotFound = errors.New("variant not found")
+	ErrVariantRequired = errors.New("product comes in variants, pick one")
+	ErrSKUExists       = errors.New("SKU already in use")
+)
+
+// maxNameLength is the longest product name we accept, in characters
+const maxNameLength = 200
 
 type Product struct {
-	ID          int     `json:"id"`
-	Name        string  `json:"name"`
-	Description string  `json:"desc"`
-	Image       string  `json:"img"`
-	Price       float64 `json:"price"`
+	ID          int      `json:"id"`
+	Name        string   `json:"name"`
+	Description string   `json:"desc"`
+	Image       string   `json:"img"`
+	Price       Money    `json:"price"`
+	TaxClass    TaxClass `json:"tax_class"`
+	// CategoryIDs are the categories the product is listed in, it can be in more than one
+	CategoryIDs []int `json:"category_ids,omitempty"`
+	// Stock is the number of items on hand and Reserved what's held for unpaid orders,
+	// for products with variants every variant keeps its own stock
+	Stock    int `json:"stock"`
+	Reserved int `json:"reserved"`
+	// Options are the axes the product comes in, such as colour and size.
+	// A product with options can only be bought as one of its Variants.
+	Options  []ProductOption `json:"options,omitempty"`
+	Variants []Variant       `json:"variants,omitempty"`
+}
+
+// ProductOption is an axis a product comes in, like "Colour" with the values "Blue" and "Pink"
+type ProductOption struct {
+	Name   string   `json:"name"`
+	Values []string `json:"values"`
+}
+
+// Variant is a buyable version of a product, with a value for each of the product's options
+type Variant struct {
+	ID        int               `json:"id"`
+	ProductID int               `json:"product_id"`
+	SKU       string            `json:"sku"`
+	Options   map[string]string `json:"options"`
+	// Price overrides the product's price, nil means the variant costs the same
+	Price *Money `json:"price,omitempty"`
+	// Stock and Reserved work like those of a product
+	Stock    int `json:"stock"`
+	Reserved int `json:"reserved"`
+	// Image overrides the product's image when set
+	Image string `json:"img,omitempty"`
+}
+
+// SearchResult is a product that matches a search query
+type SearchResult struct {
+	Product Product `json:"product"`
+	Score   float64 `json:"score"`
+	// Highlights has the HTML-escaped name, desc and categories of the product with the matching words in <mark>
+	Highlights map[string]string `json:"highlights"`
+}
+
+// ProductPatch changes some of a product's fields, the fields that are nil are left alone.
+// Options and Variants are replaced as a whole, like they are when updating a product.
+type ProductPatch struct {
+	Name        *string          `json:"name"`
+	Description *string          `json:"desc"`
+	Image       *string          `json:"img"`
+	Price       *Money           `json:"price"`
+	TaxClass    *TaxClass        `json:"tax_class"`
+	CategoryIDs *[]int           `json:"category_ids"`
+	Stock       *int             `json:"stock"`
+	Options     *[]ProductOption `json:"options"`
+	Variants    *[]Variant       `json:"variants"`
+}
+
+// Apply returns the product with the patch's fields
+func (pp ProductPatch) Apply(p Product) Product {
+	if pp.Name != nil {
+		p.Name = *pp.Name
+	}
+	if pp.Description != nil {
+		p.Description = *pp.Description
+	}
+	if pp.Image != nil {
+		p.Image = *pp.Image
+	}
+	if pp.Price != nil {
+		p.Price = *pp.Price
+	}
+	if pp.TaxClass != nil {
+		p.TaxClass = *pp.TaxClass
+	}
+	if pp.CategoryIDs != nil {
+		p.CategoryIDs = *pp.CategoryIDs
+	}
+	if pp.Stock != nil {
+		p.Stock = *pp.Stock
+	}
+	if pp.Options != nil {
+		p.Options = *pp.Options
+	}
+	if pp.Variants != nil {
+		p.Variants = *pp.Variants
+	}
+	return p
 }
 
 func (p Product) String() string {
-	return fmt.Sprintf("[%d] %s - %s (€%.2f)", p.ID, p.Name, p.Description, p.Price)
+	return fmt.Sprintf("[%d] %s - %s (%s)", p.ID, p.Name, p.Description, p.FormattedPrice())
 }
 
+// FormattedPrice shows the price, or the lowest price when the variants differ
 func (p Product) FormattedPrice() string {
-	return fmt.Sprintf("€%.2f", p.Price)
+	lowest, highest := p.priceRange()
+	if lowest != highest {
+		return "from " + lowest.String()
+	}
+	return lowest.String()
+}
+
+// LowestPrice is what the product costs at least, it's what listings sort and filter on
+func (p Product) LowestPrice() Money {
+	lowest, _ := p.priceRange()
+	return lowest
+}
+
+func (p Product) priceRange() (lowest, highest Money) {
+	lowest, highest = p.Price, p.Price
+	for i, v := range p.Variants {
+		price := p.VariantPrice(v)
+		if i == 0 || price.Cmp(lowest) < 0 {
+			lowest = price
+		}
+		if i == 0 || price.Cmp(highest) > 0 {
+			highest = price
+		}
+	}
+	return lowest, highest
+}
+
+// Available is the number of items we can still sell, of all variants together
+func (p Product) Available() int {
+	if !p.HasVariants() {
+		return max(p.Stock-p.Reserved, 0)
+	}
+	available := 0
+	for _, v := range p.Variants {
+		available += v.Available()
+	}
+	return available
+}
+
+func (p Product) StockStatus() StockStatus {
+	return StockStatusOf(p.Available())
+}
+
+// MarshalJSON adds the stock status, so clients don't need to know our low stock threshold
+func (p Product) MarshalJSON() ([]byte, error) {
+	type product Product
+	return json.Marshal(struct {
+		product
+		StockStatus StockStatus `json:"stock_status"`
+	}{product(p), p.StockStatus()})
+}
+
+// Available is the number of items of this variant we can still sell
+func (v Variant) Available() int {
+	return max(v.Stock-v.Reserved, 0)
+}
+
+func (v Variant) StockStatus() StockStatus {
+	return StockStatusOf(v.Available())
+}
+
+// MarshalJSON adds the stock status, see Product.MarshalJSON
+func (v Variant) MarshalJSON() ([]byte, error) {
+	type variant Variant
+	return json.Marshal(struct {
+		variant
+		StockStatus StockStatus `json:"stock_status"`
+	}{variant(v), v.StockStatus()})
+}
+
+// VariantAvailable is the number of items we can still sell of a variant,
+// the zero Variant stands for the product itself when it has no variants
+func (p Product) VariantAvailable(v Variant) int {
+	if v.ID == 0 {
+		return p.Available()
+	}
+	return v.Available()
+}
+
+// Validate checks a product before it's stored, all problems are returned as FieldErrors
+func (p Product) Validate() error {
+	fe := FieldErrors{}
+	switch {
+	case strings.TrimSpace(p.Name) == "":
+		fe.Add("name", "is required")
+	case utf8.RuneCountInString(p.Name) > maxNameLength:
+		fe.Add("name", "can't be longer than %d characters", maxNameLength)
+	}
+	validatePrice(fe, "price", p.Price)
+	if !p.TaxClass.Valid() {
+		fe.Add("tax_class", "must be %s, %s or %s", TaxClassStandard, TaxClassReduced, TaxClassZero)
+	}
+	for i, id := range p.CategoryIDs {
+		field := fmt.Sprintf("category_ids[%d]", i)
+		switch {
+		case id <= 0:
+			fe.Add(field, "needs to be a category ID")
+		case slices.Index(p.CategoryIDs, id) < i:
+			fe.Add(field, "category %d is listed twice", id)
+		}
+	}
+	switch {
+	case p.Stock < 0:
+		fe.Add("stock", "can't be negative")
+	case p.Stock != 0 && p.HasVariants():
+		fe.Add("stock", "products with variants keep their stock on the variants")
+	}
+
+	// options and variants go together, a product with options can only be bought as a variant
+	switch {
+	case len(p.Options) > 0 && !p.HasVariants():
+		fe.Add("variants", "products with options need at least one variant")
+	case len(p.Options) == 0 && p.HasVariants():
+		fe.Add("options", "products with variants need at least one option")
+	}
+	optionNames := map[string]bool{}
+	for i, o := range p.Options {
+		field := fmt.Sprintf("options[%d]", i)
+		switch {
+		case strings.TrimSpace(o.Name) == "":
+			fe.Add(field+".name", "is required")
+		case optionNames[o.Name]:
+			fe.Add(field+".name", "%q is used by another option", o.Name)
+		}
+		optionNames[o.Name] = true
+		if len(o.Values) == 0 {
+			fe.Add(field+".values", "needs at least one value")
+		}
+		for j, value := range o.Values {
+			switch {
+			case strings.TrimSpace(value) == "":
+				fe.Add(fmt.Sprintf("%s.values[%d]", field, j), "can't be empty")
+			case slices.Index(o.Values, value) < j:
+				fe.Add(fmt.Sprintf("%s.values[%d]", field, j), "%q is listed twice", value)
+			}
+		}
+	}
+
+	skus := map[string]bool{}
+	labels := map[string]bool{}
+	for i, v := range p.Variants {
+		field := fmt.Sprintf("variants[%d]", i)
+		switch {
+		case strings.TrimSpace(v.SKU) == "":
+			fe.Add(field+".sku", "is required")
+		case skus[v.SKU]:
+			fe.Add(field+".sku", "%q is used by another variant", v.SKU)
+		}
+		skus[v.SKU] = true
+		if v.Price != nil {
+			validatePrice(fe, field+".price", *v.Price)
+		}
+		if v.Stock < 0 {
+			fe.Add(field+".stock", "can't be negative")
+		}
+		for _, o := range p.Options {
+			value, ok := v.Options[o.Name]
+			switch {
+			case !ok:
+				fe.Add(field+".options", "needs a value for %s", o.Name)
+			case !slices.Contains(o.Values, value):
+				fe.Add(field+".options", "%q is not a value of %s", value, o.Name)
+			}
+		}
+		for name := range v.Options {
+			if !optionNames[name] {
+				fe.Add(field+".options", "the product has no option %q", name)
+			}
+		}
+		label := p.VariantLabel(v)
+		if labels[label] {
+			fe.Add(field+".options", "another variant has the same options")
+		}
+		labels[label] = true
+	}
+	return fe.Err()
+}
+
+// validatePrice only allows our own currency, baskets and orders can't add up different ones
+func validatePrice(fe FieldErrors, field string, price Money) {
+	switch {
+	case price.Currency != DefaultCurrency:
+		fe.Add(field, "must be in %s", DefaultCurrency)
+	case price.IsNegative():
+		fe.Add(field, "can't be negative")
+	}
+}
+
+func (p Product) HasVariants() bool {
+	return len(p.Variants) > 0
+}
+
+// Variant finds the variant to buy. Products without variants are bought with variant ID 0,
+// products with variants need one of theirs.
+func (p Product) Variant(variantID int) (Variant, error) {
+	if !p.HasVariants() {
+		if variantID != 0 {
+			return Variant{}, fmt.Errorf("%w: product %d has no variant %d", ErrVariantNotFound, p.ID, variantID)
+		}
+		return Variant{}, nil
+	}
+	if variantID == 0 {
+		return Variant{}, fmt.Errorf("%w: for product ID: %d", ErrVariantRequired, p.ID)
+	}
+	for _, v := range p.Variants {
+		if v.ID == variantID {
+			return v, nil
+		}
+	}
+	return Variant{}, fmt.Errorf("%w: product %d has no variant %d", ErrVariantNotFound, p.ID, variantID)
+}
+
+// VariantPrice is what the variant costs, the product's price unless the variant overrides it
+func (p Product) VariantPrice(v Variant) Money {
+	if v.Price != nil {
+		return *v.Price
+	}
+	return p.Price
+}
+
+// VariantImage is the image of the variant, or the product's image when it has none
+func (p Product) VariantImage(v Variant) string {
+	if v.Image != "" {
+		return v.Image
+	}
+	return p.Image
+}
+
+// VariantLabel describes the variant with its option values in the order of the product's options, like "Blue, L"
+func (p Product) VariantLabel(v Variant) string {
+	values := make([]string, 0, len(p.Options))
+	for _, o := range p.Options {
+		if value, ok := v.Options[o.Name]; ok {
+			values = append(values, value)
+		}
+	}
+	return strings.Join(values, ", ")
 }
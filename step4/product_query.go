@@ -0,0 +1,214 @@
+package go_webshop_course
+
+import (
+	"cmp"
+	"encoding/base64"
+	"encoding/json"
+	"slices"
+	"strings"
+)
+
+// ProductSort is the order products are listed in
+type ProductSort string
+
+const (
+	SortName      ProductSort = "name"
+	SortPriceAsc  ProductSort = "price"
+	SortPriceDesc ProductSort = "-price"
+	// SortNewest lists the products that were added last first
+	SortNewest ProductSort = "newest"
+)
+
+var productSorts = []ProductSort{SortName, SortPriceAsc, SortPriceDesc, SortNewest}
+
+// ProductQuery picks, orders and pages the products of a listing.
+// The zero value lists all products by name.
+type ProductQuery struct {
+	Sort ProductSort
+	// MinPrice and MaxPrice filter on the lowest price of the product, both ends included
+	MinPrice *Money
+	MaxPrice *Money
+	// Category is the slug of a category, its subcategories are included
+	Category string
+	InStock  bool
+	// Cursor is where the page starts, as handed out in ProductPage
+	Cursor string
+	// Limit is the size of the page, 0 means everything
+	Limit int
+}
+
+// ProductPage is one page of a product listing
+type ProductPage struct {
+	Products []Product `json:"products"`
+	// Total is the number of products that match the filters, on all pages together
+	Total int `json:"total"`
+	// NextCursor and PrevCursor are empty when there's no page after or before this one
+	NextCursor string `json:"next_cursor,omitempty"`
+	PrevCursor string `json:"prev_cursor,omitempty"`
+}
+
+// cursor points in between two products of a listing, it's opaque to our clients.
+// It holds the sort key and ID of the product next to it, so a page doesn't shift when
+// products are added or removed in front of it.
+type cursor struct {
+	Sort   ProductSort `json:"s"`
+	Name   string      `json:"n,omitempty"`
+	Price  *Money      `json:"p,omitempty"`
+	ID     int         `json:"id"`
+	Before bool        `json:"b,omitempty"`
+}
+
+// Validate checks the query, the category is checked by whoever knows the categories
+func (q ProductQuery) Validate() error {
+	fe := FieldErrors{}
+	if q.Sort != "" && !slices.Contains(productSorts, q.Sort) {
+		fe.Add("sort", "needs to be one of %s", joinSorts())
+	}
+	if q.MinPrice != nil && q.MinPrice.IsNegative() {
+		fe.Add("min_price", "can't be negative")
+	}
+	if q.MaxPrice != nil && q.MaxPrice.IsNegative() {
+		fe.Add("max_price", "can't be negative")
+	}
+	if q.MinPrice != nil && q.MaxPrice != nil && q.MinPrice.Currency == q.MaxPrice.Currency && q.MinPrice.Amount > q.MaxPrice.Amount {
+		fe.Add("max_price", "can't be below min_price")
+	}
+	if q.Limit < 0 {
+		fe.Add("limit", "can't be negative")
+	}
+	if q.Cursor != "" {
+		if c, err := decodeCursor(q.Cursor); err != nil || c.Sort != q.sort() {
+			fe.Add("cursor", "is not a cursor of this listing")
+		}
+	}
+	return fe.Err()
+}
+
+// Match reports whether the product passes the filters, categoryIDs are the
+// category and its subcategories when the query has one
+func (q ProductQuery) Match(p Product, categoryIDs []int) bool {
+	price := p.LowestPrice()
+	if q.MinPrice != nil && (price.Currency != q.MinPrice.Currency || price.Amount < q.MinPrice.Amount) {
+		return false
+	}
+	if q.MaxPrice != nil && (price.Currency != q.MaxPrice.Currency || price.Amount > q.MaxPrice.Amount) {
+		return false
+	}
+	if q.InStock && p.Available() <= 0 {
+		return false
+	}
+	if q.Category != "" && !slices.ContainsFunc(p.CategoryIDs, func(id int) bool { return slices.Contains(categoryIDs, id) }) {
+		return false
+	}
+	return true
+}
+
+// Page sorts the products that passed the filters and cuts out the page the cursor points at
+func (q ProductQuery) Page(products []Product) (ProductPage, error) {
+	if err := q.Validate(); err != nil {
+		return ProductPage{}, err
+	}
+	products = slices.Clone(products)
+	slices.SortFunc(products, func(a, b Product) int {
+		return q.compare(q.cursorOf(a, false), q.cursorOf(b, false))
+	})
+
+	start, end := 0, len(products)
+	before := false
+	if q.Cursor != "" {
+		c, _ := decodeCursor(q.Cursor)
+		// where the product of the cursor is, or would be when it changed since
+		i, found := slices.BinarySearchFunc(products, c, func(p Product, c cursor) int {
+			return q.compare(q.cursorOf(p, false), c)
+		})
+		if c.Before {
+			end, before = i, true
+		} else {
+			if found {
+				i++
+			}
+			start = i
+		}
+	}
+	if q.Limit > 0 {
+		if before {
+			start = max(end-q.Limit, 0)
+		} else {
+			end = min(start+q.Limit, end)
+		}
+	}
+
+	page := ProductPage{Products: slices.Clip(products[start:end]), Total: len(products)}
+	if page.Products == nil {
+		page.Products = []Product{}
+	}
+	if end < len(products) && end > start {
+		page.NextCursor = q.cursorOf(products[end-1], false).encode()
+	}
+	if start > 0 && end > start {
+		page.PrevCursor = q.cursorOf(products[start], true).encode()
+	}
+	return page, nil
+}
+
+func (q ProductQuery) sort() ProductSort {
+	return cmp.Or(q.Sort, SortName)
+}
+
+func (q ProductQuery) cursorOf(p Product, before bool) cursor {
+	c := cursor{Sort: q.sort(), ID: p.ID, Before: before}
+	switch c.Sort {
+	case SortName:
+		c.Name = p.Name
+	case SortPriceAsc, SortPriceDesc:
+		price := p.LowestPrice()
+		c.Price = &price
+	}
+	return c
+}
+
+// compare orders two positions in the listing, the product ID breaks ties
+func (q ProductQuery) compare(a, b cursor) int {
+	byID := cmp.Compare(a.ID, b.ID)
+	switch q.sort() {
+	case SortPriceAsc:
+		return cmp.Or(comparePrices(a.Price, b.Price), byID)
+	case SortPriceDesc:
+		return cmp.Or(-comparePrices(a.Price, b.Price), byID)
+	case SortNewest:
+		return -byID
+	default:
+		return cmp.Or(cmp.Compare(strings.ToLower(a.Name), strings.ToLower(b.Name)), byID)
+	}
+}
+
+// comparePrices doesn't mix currencies, they're grouped by their code
+func comparePrices(a, b *Money) int {
+	if a == nil || b == nil {
+		return 0
+	}
+	return cmp.Or(cmp.Compare(a.Currency, b.Currency), cmp.Compare(a.Amount, b.Amount))
+}
+
+func (c cursor) encode() string {
+	b, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func decodeCursor(s string) (cursor, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return cursor{}, err
+	}
+	var c cursor
+	err = json.Unmarshal(b, &c)
+	return c, err
+}
+
+func joinSorts() string {
+	sorts := make([]string, len(productSorts))
+	for i, s := range productSorts {
+		sorts[i] = string(s)
+	}
+	return strings.Join(sorts, ", ")
+}
@@ -0,0 +1,265 @@
+package go_webshop_course_test
+
+import (
+	"errors"
+	"reflect"
+	"slices"
+	"testing"
+
+	app "github.com/gerbenjacobs/go-webshop-course"
+)
+
+// fruit is listed as Apple, banana, Cherry, Date and Elder by name
+func fruit() []app.Product {
+	return []app.Product{
+		{ID: 3, Name: "Cherry", Price: app.EUR(900), Stock: 1, CategoryIDs: []int{2}},
+		{ID: 1, Name: "Apple", Price: app.EUR(500), Stock: 5, CategoryIDs: []int{1}},
+		{ID: 5, Name: "Elder", Price: app.EUR(100), Stock: 0, CategoryIDs: []int{2}},
+		{ID: 2, Name: "banana", Price: app.EUR(300), Stock: 2, Reserved: 2, CategoryIDs: []int{1}},
+		{ID: 4, Name: "Date", Price: app.EUR(300), Stock: 9, CategoryIDs: []int{3}},
+	}
+}
+
+func TestProductQuerySort(t *testing.T) {
+	tests := []struct {
+		sort app.ProductSort
+		want []int
+	}{
+		{"", []int{1, 2, 3, 4, 5}},
+		{app.SortName, []int{1, 2, 3, 4, 5}},
+		// the ID breaks ties
+		{app.SortPriceAsc, []int{5, 2, 4, 1, 3}},
+		{app.SortPriceDesc, []int{3, 1, 2, 4, 5}},
+		{app.SortNewest, []int{5, 4, 3, 2, 1}},
+	}
+	for _, tt := range tests {
+		page, err := app.ProductQuery{Sort: tt.sort}.Page(fruit())
+		if err != nil {
+			t.Fatalf("Page sorted by %q: %v", tt.sort, err)
+		}
+		if got := productIDs(page); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("sorted by %q = %v, want %v", tt.sort, got, tt.want)
+		}
+		if page.Total != 5 || page.NextCursor != "" || page.PrevCursor != "" {
+			t.Errorf("sorted by %q: Total = %d with cursors %q and %q, want 5 without cursors", tt.sort, page.Total, page.NextCursor, page.PrevCursor)
+		}
+	}
+}
+
+func TestProductQueryPage(t *testing.T) {
+	for _, sort := range []app.ProductSort{app.SortName, app.SortPriceAsc, app.SortPriceDesc, app.SortNewest} {
+		t.Run(string(sort), func(t *testing.T) {
+			all, err := app.ProductQuery{Sort: sort}.Page(fruit())
+			if err != nil {
+				t.Fatalf("Page: %v", err)
+			}
+			want := productIDs(all)
+
+			// forwards
+			q := app.ProductQuery{Sort: sort, Limit: 2}
+			var pages []app.ProductPage
+			for {
+				page := mustPage(t, q, fruit())
+				pages = append(pages, page)
+				if page.NextCursor == "" {
+					break
+				}
+				q.Cursor = page.NextCursor
+			}
+			var got []int
+			for _, page := range pages {
+				got = append(got, productIDs(page)...)
+			}
+			if len(pages) != 3 || !reflect.DeepEqual(got, want) {
+				t.Fatalf("%d pages forwards with %v, want 3 pages with %v", len(pages), got, want)
+			}
+			if pages[0].PrevCursor != "" {
+				t.Errorf("the first page has a PrevCursor")
+			}
+
+			// and backwards again
+			for i := len(pages) - 1; i > 0; i-- {
+				q.Cursor = pages[i].PrevCursor
+				page := mustPage(t, q, fruit())
+				if !reflect.DeepEqual(productIDs(page), productIDs(pages[i-1])) {
+					t.Errorf("page before page %d = %v, want %v", i+1, productIDs(page), productIDs(pages[i-1]))
+				}
+				if page.NextCursor == "" {
+					t.Errorf("page before page %d has no NextCursor", i+1)
+				}
+			}
+		})
+	}
+}
+
+func TestProductQueryCursorEdgeCases(t *testing.T) {
+	first := mustPage(t, app.ProductQuery{Limit: 2}, fruit())
+	second := mustPage(t, app.ProductQuery{Limit: 2, Cursor: first.NextCursor}, fruit())
+	if !reflect.DeepEqual(productIDs(second), []int{3, 4}) {
+		t.Fatalf("second page = %v, want [3 4]", productIDs(second))
+	}
+
+	without := func(id int) []app.Product {
+		return slices.DeleteFunc(fruit(), func(p app.Product) bool { return p.ID == id })
+	}
+	tests := []struct {
+		name     string
+		query    app.ProductQuery
+		products []app.Product
+		want     []int
+		wantNext bool
+		wantPrev bool
+	}{
+		{
+			name:     "the product under the cursor was deleted",
+			query:    app.ProductQuery{Limit: 2, Cursor: first.NextCursor},
+			products: without(2),
+			want:     []int{3, 4},
+			wantNext: true,
+			wantPrev: true,
+		},
+		{
+			name:     "a product was added in front of the cursor",
+			query:    app.ProductQuery{Limit: 2, Cursor: first.NextCursor},
+			products: append(fruit(), app.Product{ID: 6, Name: "Aardvark", Price: app.EUR(100)}),
+			want:     []int{3, 4},
+			wantNext: true,
+			wantPrev: true,
+		},
+		{
+			name:     "the product under a Before cursor was deleted",
+			query:    app.ProductQuery{Limit: 2, Cursor: second.PrevCursor},
+			products: without(3),
+			want:     []int{1, 2},
+			wantNext: true,
+		},
+		{
+			name:     "a Before cursor with fewer products in front than the limit",
+			query:    app.ProductQuery{Limit: 3, Cursor: second.PrevCursor},
+			products: fruit(),
+			want:     []int{1, 2},
+			wantNext: true,
+		},
+		{
+			name:     "a Before cursor without a limit",
+			query:    app.ProductQuery{Cursor: second.PrevCursor},
+			products: fruit(),
+			want:     []int{1, 2},
+			wantNext: true,
+		},
+		{
+			name:     "a cursor without a limit",
+			query:    app.ProductQuery{Cursor: first.NextCursor},
+			products: fruit(),
+			want:     []int{3, 4, 5},
+			wantPrev: true,
+		},
+		{
+			name:     "everything after the cursor was deleted",
+			query:    app.ProductQuery{Limit: 2, Cursor: second.NextCursor},
+			products: without(5),
+			want:     []int{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			page := mustPage(t, tt.query, tt.products)
+			if got := productIDs(page); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("page = %v, want %v", got, tt.want)
+			}
+			if page.Products == nil {
+				t.Error("Products is nil, want an empty slice")
+			}
+			if page.Total != len(tt.products) {
+				t.Errorf("Total = %d, want %d", page.Total, len(tt.products))
+			}
+			if (page.NextCursor != "") != tt.wantNext {
+				t.Errorf("NextCursor = %q, want one: %t", page.NextCursor, tt.wantNext)
+			}
+			if (page.PrevCursor != "") != tt.wantPrev {
+				t.Errorf("PrevCursor = %q, want one: %t", page.PrevCursor, tt.wantPrev)
+			}
+		})
+	}
+}
+
+func TestProductQueryValidate(t *testing.T) {
+	byName := mustPage(t, app.ProductQuery{Limit: 1}, fruit())
+	tests := []struct {
+		name  string
+		query app.ProductQuery
+		field string
+	}{
+		{"unknown sort", app.ProductQuery{Sort: "popular"}, "sort"},
+		{"negative min price", app.ProductQuery{MinPrice: ptr(app.EUR(-1))}, "min_price"},
+		{"negative max price", app.ProductQuery{MaxPrice: ptr(app.EUR(-1))}, "max_price"},
+		{"max price below min price", app.ProductQuery{MinPrice: ptr(app.EUR(500)), MaxPrice: ptr(app.EUR(499))}, "max_price"},
+		{"negative limit", app.ProductQuery{Limit: -1}, "limit"},
+		{"garbage cursor", app.ProductQuery{Cursor: "not a cursor"}, "cursor"},
+		{"cursor of another sort", app.ProductQuery{Sort: app.SortNewest, Cursor: byName.NextCursor}, "cursor"},
+	}
+	for _, tt := range tests {
+		_, err := tt.query.Page(fruit())
+		var fields app.FieldErrors
+		if !errors.As(err, &fields) || !errors.Is(err, app.ErrInvalidInput) {
+			t.Errorf("%s: error = %v, want field errors", tt.name, err)
+			continue
+		}
+		if _, ok := fields[tt.field]; !ok || len(fields) != 1 {
+			t.Errorf("%s: field errors = %v, want one for %s", tt.name, fields, tt.field)
+		}
+	}
+
+	if err := (app.ProductQuery{MinPrice: ptr(app.EUR(300)), MaxPrice: ptr(app.EUR(300))}).Validate(); err != nil {
+		t.Errorf("Validate with the same min and max price: %v", err)
+	}
+}
+
+func TestProductQueryMatch(t *testing.T) {
+	tests := []struct {
+		name        string
+		query       app.ProductQuery
+		categoryIDs []int
+		want        []int
+	}{
+		{"no filters", app.ProductQuery{}, nil, []int{1, 2, 3, 4, 5}},
+		{"min price is included", app.ProductQuery{MinPrice: ptr(app.EUR(500))}, nil, []int{1, 3}},
+		{"max price is included", app.ProductQuery{MaxPrice: ptr(app.EUR(300))}, nil, []int{2, 4, 5}},
+		{"other currencies don't match", app.ProductQuery{MinPrice: ptr(app.NewMoney(0, "USD"))}, nil, []int{}},
+		{"in stock leaves out what's sold out or reserved", app.ProductQuery{InStock: true}, nil, []int{1, 3, 4}},
+		{"category and its subcategories", app.ProductQuery{Category: "fruit"}, []int{1, 3}, []int{1, 2, 4}},
+	}
+	for _, tt := range tests {
+		got := []int{}
+		for _, p := range fruit() {
+			if tt.query.Match(p, tt.categoryIDs) {
+				got = append(got, p.ID)
+			}
+		}
+		slices.Sort(got)
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: matched %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func mustPage(t *testing.T, q app.ProductQuery, products []app.Product) app.ProductPage {
+	t.Helper()
+	page, err := q.Page(products)
+	if err != nil {
+		t.Fatalf("Page: %v", err)
+	}
+	return page
+}
+
+func productIDs(page app.ProductPage) []int {
+	ids := make([]int, len(page.Products))
+	for i, p := range page.Products {
+		ids[i] = p.ID
+	}
+	return ids
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
@@ -0,0 +1,104 @@
+package go_webshop_course
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+var (
+	ErrPromotionNotFound      = errors.New("promotion not found")
+	ErrPromotionNotActive     = errors.New("promotion is not active")
+	ErrPromotionUsedUp        = errors.New("promotion has been used up")
+	ErrPromotionNotApplicable = errors.New("promotion does not apply to this basket")
+)
+
+type PromotionType string
+
+const (
+	// PromotionPercentage takes Percentage percent off the targeted products
+	PromotionPercentage PromotionType = "percentage"
+	// PromotionFixedAmount takes Amount off the targeted products
+	PromotionFixedAmount PromotionType = "fixed_amount"
+	// PromotionBuyXGetY gives GetQuantity of the targeted products for free
+	// for every BuyQuantity bought, the cheapest ones are free
+	PromotionBuyXGetY PromotionType = "buy_x_get_y"
+	// PromotionFreeShipping waives the shipping fee
+	PromotionFreeShipping PromotionType = "free_shipping"
+)
+
+// Promotion is a discount rule. Promotions with a Code are coupons that the
+// customer has to enter, those without one apply to every basket automatically.
+type Promotion struct {
+	ID   int           `json:"id"`
+	Code string        `json:"code,omitempty"`
+	Name string        `json:"name"`
+	Type PromotionType `json:"type"`
+
+	Percentage  int   `json:"percentage,omitempty"`
+	Amount      Money `json:"amount"`
+	BuyQuantity int   `json:"buy_quantity,omitempty"`
+	GetQuantity int   `json:"get_quantity,omitempty"`
+
+	// MinimumSubtotal is the basket value needed before the promotion applies
+	MinimumSubtotal Money `json:"minimum_subtotal"`
+	// ProductIDs and CategoryIDs limit the promotion to those products,
+	// when both are empty the promotion is for the whole basket
+	ProductIDs  []int `json:"product_ids,omitempty"`
+	CategoryIDs []int `json:"category_ids,omitempty"`
+
+	// StartsAt and EndsAt are the validity window, a zero time means no limit
+	StartsAt time.Time `json:"starts_at,omitempty"`
+	EndsAt   time.Time `json:"ends_at,omitempty"`
+
+	// MaxUses and MaxUsesPerCustomer count paid orders, zero means unlimited
+	MaxUses            int `json:"max_uses,omitempty"`
+	MaxUsesPerCustomer int `json:"max_uses_per_customer,omitempty"`
+}
+
+// CheckUses tells whether the promotion can be used once more, now that it has been
+// used total times and byUser times by the customer
+func (p Promotion) CheckUses(total, byUser int) error {
+	if p.MaxUses > 0 && total >= p.MaxUses {
+		return fmt.Errorf("%w: %s", ErrPromotionUsedUp, p.Name)
+	}
+	if p.MaxUsesPerCustomer > 0 && byUser >= p.MaxUsesPerCustomer {
+		return fmt.Errorf("%w: %s can only be used %d time(s) per customer", ErrPromotionUsedUp, p.Name, p.MaxUsesPerCustomer)
+	}
+	return nil
+}
+
+// ActiveAt reports whether t falls in the promotion's validity window
+func (p Promotion) ActiveAt(t time.Time) bool {
+	if !p.StartsAt.IsZero() && t.Before(p.StartsAt) {
+		return false
+	}
+	if !p.EndsAt.IsZero() && !t.Before(p.EndsAt) {
+		return false
+	}
+	return true
+}
+
+// Targets reports whether the promotion is meant for a product in the given categories
+func (p Promotion) Targets(productID int, categoryIDs []int) bool {
+	if len(p.ProductIDs) == 0 && len(p.CategoryIDs) == 0 {
+		return true
+	}
+	return slices.Contains(p.ProductIDs, productID) ||
+		slices.ContainsFunc(categoryIDs, func(id int) bool { return slices.Contains(p.CategoryIDs, id) })
+}
+
+// NormalizeCoupon makes coupon codes case-insensitive
+func NormalizeCoupon(code string) string {
+	return strings.ToUpper(strings.TrimSpace(code))
+}
+
+// AppliedDiscount is a promotion that lowered the price of a basket or order
+type AppliedDiscount struct {
+	PromotionID int    `json:"promotion_id"`
+	Code        string `json:"code,omitempty"`
+	Name        string `json:"name"`
+	Amount      Money  `json:"amount"`
+}
@@ -0,0 +1,165 @@
+// Package promotion evaluates promotion rules against a basket. It only does the maths,
+// checking usage limits is up to the caller since that needs the order history.
+package promotion
+
+import (
+	"fmt"
+	"slices"
+	"time"
+
+	app "github.com/gerbenjacobs/go-webshop-course"
+)
+
+// Line is a basket line as far as promotions are concerned
+type Line struct {
+	ProductID   int
+	CategoryIDs []int
+	UnitPrice   app.Money
+	Quantity    int
+}
+
+func (l Line) total() app.Money {
+	return l.UnitPrice.Mul(l.Quantity)
+}
+
+type Basket struct {
+	Lines    []Line
+	Subtotal app.Money
+	Shipping app.Money
+}
+
+// Result is the discount of one promotion, LineDiscounts follows the order of the basket lines
+type Result struct {
+	LineDiscounts    []app.Money
+	ShippingDiscount app.Money
+	Total            app.Money
+}
+
+// Check returns why a promotion can't be used for the basket at the given time, if at all
+func Check(p app.Promotion, b Basket, now time.Time) error {
+	if !p.ActiveAt(now) {
+		return fmt.Errorf("%w: %s is only valid %s", app.ErrPromotionNotActive, p.Name, window(p))
+	}
+	if !p.MinimumSubtotal.IsZero() && b.Subtotal.Cmp(p.MinimumSubtotal) < 0 {
+		return fmt.Errorf("%w: spend at least %s for %s", app.ErrPromotionNotApplicable, p.MinimumSubtotal, p.Name)
+	}
+	if p.Type == app.PromotionFreeShipping {
+		if b.Shipping.IsZero() {
+			return fmt.Errorf("%w: there is no shipping fee to waive", app.ErrPromotionNotApplicable)
+		}
+		return nil
+	}
+	for _, line := range b.Lines {
+		if p.Targets(line.ProductID, line.CategoryIDs) {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: none of the products are part of %s", app.ErrPromotionNotApplicable, p.Name)
+}
+
+// Evaluate calculates the discount that the promotion gives on the basket
+func Evaluate(p app.Promotion, b Basket, now time.Time) (Result, error) {
+	if err := Check(p, b, now); err != nil {
+		return Result{}, err
+	}
+
+	currency := b.Subtotal.Currency
+	res := Result{
+		LineDiscounts:    make([]app.Money, len(b.Lines)),
+		ShippingDiscount: app.NewMoney(0, currency),
+	}
+	for i := range res.LineDiscounts {
+		res.LineDiscounts[i] = app.NewMoney(0, currency)
+	}
+
+	// the lines this promotion is for, and what they're worth together
+	var eligible []int
+	targeted := app.NewMoney(0, currency)
+	for i, line := range b.Lines {
+		if p.Targets(line.ProductID, line.CategoryIDs) {
+			eligible = append(eligible, i)
+			targeted = targeted.Add(line.total())
+		}
+	}
+
+	switch p.Type {
+	case app.PromotionPercentage:
+		if p.Percentage <= 0 || p.Percentage > 100 {
+			return Result{}, fmt.Errorf("invalid percentage %d for promotion %d", p.Percentage, p.ID)
+		}
+		off := app.NewMoney((targeted.Amount*int64(p.Percentage)+50)/100, currency)
+		spread(res.LineDiscounts, b.Lines, eligible, off)
+	case app.PromotionFixedAmount:
+		off := p.Amount
+		if off.Cmp(targeted) > 0 {
+			off = targeted
+		}
+		spread(res.LineDiscounts, b.Lines, eligible, off)
+	case app.PromotionBuyXGetY:
+		if p.BuyQuantity <= 0 || p.GetQuantity <= 0 {
+			return Result{}, fmt.Errorf("invalid buy %d get %d for promotion %d", p.BuyQuantity, p.GetQuantity, p.ID)
+		}
+		freeUnits(res.LineDiscounts, b.Lines, eligible, p.BuyQuantity, p.GetQuantity)
+	case app.PromotionFreeShipping:
+		res.ShippingDiscount = b.Shipping
+	default:
+		return Result{}, fmt.Errorf("unknown promotion type %q", p.Type)
+	}
+
+	res.Total = res.ShippingDiscount
+	for _, d := range res.LineDiscounts {
+		res.Total = res.Total.Add(d)
+	}
+	return res, nil
+}
+
+// spread divides a discount over the eligible lines, relative to their value
+func spread(discounts []app.Money, lines []Line, eligible []int, off app.Money) {
+	if off.IsZero() || len(eligible) == 0 {
+		return
+	}
+	ratios := make([]int, len(eligible))
+	for i, idx := range eligible {
+		ratios[i] = int(lines[idx].total().Amount)
+	}
+	if !slices.ContainsFunc(ratios, func(r int) bool { return r > 0 }) {
+		return
+	}
+	for i, part := range off.Allocate(ratios...) {
+		discounts[eligible[i]] = discounts[eligible[i]].Add(part)
+	}
+}
+
+// freeUnits gives away the cheapest units, get of them for every buy+get units in the basket
+func freeUnits(discounts []app.Money, lines []Line, eligible []int, buy, get int) {
+	units := 0
+	for _, idx := range eligible {
+		units += lines[idx].Quantity
+	}
+	free := units / (buy + get) * get
+
+	cheapest := slices.Clone(eligible)
+	slices.SortStableFunc(cheapest, func(a, b int) int {
+		return lines[a].UnitPrice.Cmp(lines[b].UnitPrice)
+	})
+	for _, idx := range cheapest {
+		if free == 0 {
+			break
+		}
+		n := min(free, lines[idx].Quantity)
+		discounts[idx] = discounts[idx].Add(lines[idx].UnitPrice.Mul(n))
+		free -= n
+	}
+}
+
+func window(p app.Promotion) string {
+	const layout = "2 Jan 2006 15:04"
+	switch {
+	case !p.StartsAt.IsZero() && !p.EndsAt.IsZero():
+		return fmt.Sprintf("from %s until %s", p.StartsAt.Format(layout), p.EndsAt.Format(layout))
+	case !p.StartsAt.IsZero():
+		return "from " + p.StartsAt.Format(layout)
+	default:
+		return "until " + p.EndsAt.Format(layout)
+	}
+}
@@ -0,0 +1,144 @@
+package promotion_test
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	app "github.com/gerbenjacobs/go-webshop-course"
+	"github.com/gerbenjacobs/go-webshop-course/promotion"
+)
+
+func TestEvaluate(t *testing.T) {
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	basket := promotion.Basket{
+		Lines: []promotion.Line{
+			{ProductID: 1, CategoryIDs: []int{2}, UnitPrice: app.EUR(1299), Quantity: 1},
+			{ProductID: 2, CategoryIDs: []int{3}, UnitPrice: app.EUR(2000), Quantity: 2},
+			{ProductID: 3, CategoryIDs: []int{3}, UnitPrice: app.EUR(500), Quantity: 1},
+		},
+		Subtotal: app.EUR(5799),
+		Shipping: app.EUR(495),
+	}
+
+	tests := []struct {
+		name      string
+		promotion app.Promotion
+		wantLines []int64
+		wantShip  int64
+	}{
+		{
+			name:      "percentage of the whole basket is spread by line value",
+			promotion: app.Promotion{Type: app.PromotionPercentage, Percentage: 10},
+			wantLines: []int64{130, 400, 50},
+		},
+		{
+			name:      "percentage of a product",
+			promotion: app.Promotion{Type: app.PromotionPercentage, Percentage: 15, ProductIDs: []int{1}},
+			// 15% of €12.99 is €1.9485, rounded half up
+			wantLines: []int64{195, 0, 0},
+		},
+		{
+			name:      "percentage of a category",
+			promotion: app.Promotion{Type: app.PromotionPercentage, Percentage: 50, CategoryIDs: []int{3}},
+			wantLines: []int64{0, 2000, 250},
+		},
+		{
+			name:      "fixed amount is spread by line value",
+			promotion: app.Promotion{Type: app.PromotionFixedAmount, Amount: app.EUR(1000), CategoryIDs: []int{3}},
+			wantLines: []int64{0, 889, 111},
+		},
+		{
+			name:      "fixed amount is capped at the value of the products",
+			promotion: app.Promotion{Type: app.PromotionFixedAmount, Amount: app.EUR(5000), ProductIDs: []int{1}},
+			wantLines: []int64{1299, 0, 0},
+		},
+		{
+			name:      "buy x get y gives the cheapest units away",
+			promotion: app.Promotion{Type: app.PromotionBuyXGetY, BuyQuantity: 1, GetQuantity: 1},
+			// 4 units, so 2 free: the €5 one and the €12.99 one
+			wantLines: []int64{1299, 0, 500},
+		},
+		{
+			name:      "buy x get y needs enough units",
+			promotion: app.Promotion{Type: app.PromotionBuyXGetY, BuyQuantity: 2, GetQuantity: 1, ProductIDs: []int{2}},
+			wantLines: []int64{0, 0, 0},
+		},
+		{
+			name:      "buy x get y counts the units of all targeted lines",
+			promotion: app.Promotion{Type: app.PromotionBuyXGetY, BuyQuantity: 2, GetQuantity: 1, CategoryIDs: []int{3}},
+			wantLines: []int64{0, 0, 500},
+		},
+		{
+			name:      "free shipping",
+			promotion: app.Promotion{Type: app.PromotionFreeShipping, MinimumSubtotal: app.EUR(5000)},
+			wantLines: []int64{0, 0, 0},
+			wantShip:  495,
+		},
+		{
+			name: "within the validity window",
+			promotion: app.Promotion{
+				Type: app.PromotionPercentage, Percentage: 100, ProductIDs: []int{3},
+				StartsAt: now, EndsAt: now.Add(time.Hour),
+			},
+			wantLines: []int64{0, 0, 500},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res, err := promotion.Evaluate(tt.promotion, basket, now)
+			if err != nil {
+				t.Fatalf("Evaluate: %v", err)
+			}
+			got := make([]int64, len(res.LineDiscounts))
+			total := res.ShippingDiscount.Amount
+			for i, d := range res.LineDiscounts {
+				got[i] = d.Amount
+				total += d.Amount
+			}
+			if !reflect.DeepEqual(got, tt.wantLines) {
+				t.Errorf("LineDiscounts = %v, want %v", got, tt.wantLines)
+			}
+			if res.ShippingDiscount.Amount != tt.wantShip {
+				t.Errorf("ShippingDiscount = %v, want %d cents", res.ShippingDiscount, tt.wantShip)
+			}
+			if res.Total != app.EUR(total) {
+				t.Errorf("Total = %v, want the sum of the discounts, %v", res.Total, app.EUR(total))
+			}
+		})
+	}
+}
+
+func TestEvaluateErrors(t *testing.T) {
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	basket := promotion.Basket{
+		Lines:    []promotion.Line{{ProductID: 1, CategoryIDs: []int{2}, UnitPrice: app.EUR(1299), Quantity: 1}},
+		Subtotal: app.EUR(1299),
+		Shipping: app.EUR(0),
+	}
+
+	tests := []struct {
+		name      string
+		promotion app.Promotion
+		want      error
+	}{
+		{"not started yet", app.Promotion{Type: app.PromotionPercentage, Percentage: 10, StartsAt: now.Add(time.Second)}, app.ErrPromotionNotActive},
+		{"ended", app.Promotion{Type: app.PromotionPercentage, Percentage: 10, EndsAt: now}, app.ErrPromotionNotActive},
+		{"below the minimum subtotal", app.Promotion{Type: app.PromotionFixedAmount, Amount: app.EUR(500), MinimumSubtotal: app.EUR(2500)}, app.ErrPromotionNotApplicable},
+		{"no targeted products", app.Promotion{Type: app.PromotionPercentage, Percentage: 10, ProductIDs: []int{2}, CategoryIDs: []int{3}}, app.ErrPromotionNotApplicable},
+		{"no shipping fee", app.Promotion{Type: app.PromotionFreeShipping}, app.ErrPromotionNotApplicable},
+		{"invalid percentage", app.Promotion{Type: app.PromotionPercentage, Percentage: 110}, nil},
+		{"invalid buy x get y", app.Promotion{Type: app.PromotionBuyXGetY, BuyQuantity: 2}, nil},
+		{"unknown type", app.Promotion{Type: "lottery"}, nil},
+	}
+	for _, tt := range tests {
+		_, err := promotion.Evaluate(tt.promotion, basket, now)
+		switch {
+		case err == nil:
+			t.Errorf("%s: Evaluate didn't fail", tt.name)
+		case tt.want != nil && !errors.Is(err, tt.want):
+			t.Errorf("%s: Evaluate error = %v, want %v", tt.name, err, tt.want)
+		}
+	}
+}
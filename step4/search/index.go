@@ -0,0 +1,223 @@
+package search
+
+import (
+	"cmp"
+	"context"
+	"maps"
+	"math"
+	"slices"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+// fieldWeights makes a match in the name count more than one in the description
+var fieldWeights = map[string]float64{
+	FieldName:        3,
+	FieldCategories:  2,
+	FieldDescription: 1,
+}
+
+// how much a match counts when the word isn't exactly what was asked for
+const (
+	prefixMatch = 0.7
+	typoMatch   = 0.5
+	// minPrefix is how long a word needs to be before we look for words starting with it
+	minPrefix = 3
+)
+
+// posting is a field of a document that contains a term, and how often
+type posting struct {
+	ID    int
+	Field string
+	Count int
+}
+
+// InvertedIndex keeps, for every term, the documents it appears in.
+// It lives in memory and is safe for concurrent use.
+type InvertedIndex struct {
+	mu       sync.RWMutex
+	docs     map[int]Document
+	postings map[string][]posting
+}
+
+func NewInvertedIndex() *InvertedIndex {
+	return &InvertedIndex{
+		docs:     make(map[int]Document),
+		postings: make(map[string][]posting),
+	}
+}
+
+func (ix *InvertedIndex) Index(_ context.Context, doc Document) error {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+
+	ix.remove(doc.ID)
+	ix.add(doc)
+	return nil
+}
+
+func (ix *InvertedIndex) Remove(_ context.Context, id int) error {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+
+	ix.remove(id)
+	return nil
+}
+
+func (ix *InvertedIndex) Replace(_ context.Context, docs []Document) error {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+
+	ix.docs = make(map[int]Document)
+	ix.postings = make(map[string][]posting)
+	for _, doc := range docs {
+		ix.add(doc)
+	}
+	return nil
+}
+
+// Search scores every document with a variant of TF-IDF: rare words count more than common ones
+// and a word that's in the name counts more than one in the description.
+// Words that aren't in the index are looked up by prefix and with typos.
+func (ix *InvertedIndex) Search(_ context.Context, query string, limit int) ([]Hit, error) {
+	ix.mu.RLock()
+	defer ix.mu.RUnlock()
+
+	var terms []string
+	for _, t := range tokenize(query) {
+		if !slices.Contains(terms, t.Term) {
+			terms = append(terms, t.Term)
+		}
+	}
+	if len(terms) == 0 {
+		return []Hit{}, nil
+	}
+
+	scores := make(map[int]float64)
+	// matched has the terms of every document that matched the query, for highlighting
+	matched := make(map[int]map[string]bool)
+	for i, term := range terms {
+		termScores := make(map[int]float64)
+		for candidate, quality := range ix.candidates(term) {
+			postings := ix.postings[candidate]
+			idf := math.Log(1 + float64(len(ix.docs))/float64(distinctDocs(postings)))
+			candidateScores := make(map[int]float64)
+			for _, p := range postings {
+				// more of the same word helps, but less and less
+				tf := float64(p.Count) / float64(p.Count+1)
+				candidateScores[p.ID] += quality * idf * tf * fieldWeights[p.Field]
+				if matched[p.ID] == nil {
+					matched[p.ID] = make(map[string]bool)
+				}
+				matched[p.ID][candidate] = true
+			}
+			// a word counts once, for the best way it matched
+			for id, score := range candidateScores {
+				termScores[id] = max(termScores[id], score)
+			}
+		}
+
+		// every word needs to match
+		if i == 0 {
+			maps.Copy(scores, termScores)
+			continue
+		}
+		for id := range scores {
+			if s, ok := termScores[id]; ok {
+				scores[id] += s
+			} else {
+				delete(scores, id)
+			}
+		}
+	}
+
+	hits := make([]Hit, 0, len(scores))
+	for id, score := range scores {
+		doc := ix.docs[id]
+		hits = append(hits, Hit{
+			ID:    id,
+			Score: score,
+			Highlights: map[string]string{
+				FieldName:        highlight(doc.Name, matched[id]),
+				FieldDescription: highlight(doc.Description, matched[id]),
+				FieldCategories:  highlight(strings.Join(doc.Categories, ", "), matched[id]),
+			},
+		})
+	}
+	slices.SortFunc(hits, func(a, b Hit) int {
+		return cmp.Or(cmp.Compare(b.Score, a.Score), cmp.Compare(a.ID, b.ID))
+	})
+	if limit > 0 && len(hits) > limit {
+		hits = hits[:limit]
+	}
+	return hits, nil
+}
+
+// candidates returns the terms in the index that could be meant by term,
+// with how good a match they are. The caller needs to hold the lock.
+func (ix *InvertedIndex) candidates(term string) map[string]float64 {
+	candidates := make(map[string]float64)
+	if _, ok := ix.postings[term]; ok {
+		candidates[term] = 1
+	}
+	typos := maxTypos(term)
+	for t := range ix.postings {
+		switch {
+		case t == term:
+		case utf8.RuneCountInString(term) >= minPrefix && strings.HasPrefix(t, term):
+			candidates[t] = prefixMatch
+		case typos > 0:
+			if d := distance(term, t, typos); d <= typos {
+				candidates[t] = typoMatch / float64(d)
+			}
+		}
+	}
+	return candidates
+}
+
+// add indexes the document, the caller needs to hold the lock
+func (ix *InvertedIndex) add(doc Document) {
+	doc.Categories = slices.Clone(doc.Categories)
+	ix.docs[doc.ID] = doc
+
+	fields := map[string]string{
+		FieldName:        doc.Name,
+		FieldDescription: doc.Description,
+		FieldCategories:  strings.Join(doc.Categories, ", "),
+	}
+	for field, text := range fields {
+		counts := make(map[string]int)
+		for _, t := range tokenize(text) {
+			counts[t.Term]++
+		}
+		for term, count := range counts {
+			ix.postings[term] = append(ix.postings[term], posting{ID: doc.ID, Field: field, Count: count})
+		}
+	}
+}
+
+// remove drops the document from the index, the caller needs to hold the lock
+func (ix *InvertedIndex) remove(id int) {
+	if _, ok := ix.docs[id]; !ok {
+		return
+	}
+	delete(ix.docs, id)
+	for term, postings := range ix.postings {
+		postings = slices.DeleteFunc(postings, func(p posting) bool { return p.ID == id })
+		if len(postings) == 0 {
+			delete(ix.postings, term)
+			continue
+		}
+		ix.postings[term] = postings
+	}
+}
+
+// distinctDocs counts the documents in the postings, a term can be in several fields of one document
+func distinctDocs(postings []posting) int {
+	seen := make(map[int]bool, len(postings))
+	for _, p := range postings {
+		seen[p.ID] = true
+	}
+	return len(seen)
+}
@@ -0,0 +1,146 @@
+package search_test
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/gerbenjacobs/go-webshop-course/search"
+)
+
+func newIndex(t *testing.T) *search.InvertedIndex {
+	t.Helper()
+	ix := search.NewInvertedIndex()
+	err := ix.Replace(context.Background(), []search.Document{
+		{ID: 1, Name: "Gopher plushie", Description: "A small purple plushie, perfect for kids", Categories: []string{"Toys", "Plushies"}},
+		{ID: 2, Name: "PHP Elephant plushie", Description: "An elephant with the PHP logo", Categories: []string{"Toys", "Plushies"}},
+		{ID: 3, Name: "Running shoes", Description: "Shoes for running & jumping <fast>", Categories: []string{"Sports"}},
+		{ID: 4, Name: "Coffee mug", Description: "Holds 300ml of coffee, with an elephant on it", Categories: []string{"Kitchen"}},
+	})
+	if err != nil {
+		t.Fatalf("Replace: %v", err)
+	}
+	return ix
+}
+
+func TestSearch(t *testing.T) {
+	ix := newIndex(t)
+	tests := []struct {
+		name  string
+		query string
+		want  []int
+	}{
+		{"exact word", "gopher", []int{1}},
+		{"case doesn't matter", "GOPHER", []int{1}},
+		{"plurals are stemmed", "plushies", []int{1, 2}},
+		{"y and ie are stemmed alike", "plushy", []int{1, 2}},
+		{"ing is stemmed", "run", []int{3}},
+		{"doubled consonants are stemmed", "runs", []int{3}},
+		{"categories match", "toys", []int{1, 2}},
+		{"a match in the name ranks above one in the description", "elephant", []int{2, 4}},
+		{"words are looked up by prefix", "eleph", []int{2, 4}},
+		{"short prefixes aren't", "el", []int{}},
+		{"a swapped letter is forgiven", "gohper", []int{1}},
+		{"a missing letter is forgiven", "cofee", []int{4}},
+		{"short words have to be right", "mog", []int{}},
+		{"long words forgive two typos", "plushhiees", []int{1, 2}},
+		{"every word needs to match", "purple gopher", []int{1}},
+		{"every word needs to match, not just one", "purple elephant", []int{}},
+		{"numbers are words", "300ml", []int{4}},
+		{"an empty query", "", []int{}},
+		{"a query without words", " &&& ", []int{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hits, err := ix.Search(context.Background(), tt.query, 0)
+			if err != nil {
+				t.Fatalf("Search: %v", err)
+			}
+			got := make([]int, len(hits))
+			for i, h := range hits {
+				got[i] = h.ID
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Search(%q) = %v, want %v", tt.query, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSearchHighlights(t *testing.T) {
+	ix := newIndex(t)
+	hits, err := ix.Search(context.Background(), "jumping shoe", 0)
+	if err != nil {
+		t.Fatalf("Search: %v", err)
+	}
+	if len(hits) != 1 {
+		t.Fatalf("got %d hits, want 1", len(hits))
+	}
+	want := map[string]string{
+		search.FieldName:        "Running <mark>shoes</mark>",
+		search.FieldDescription: "<mark>Shoes</mark> for running &amp; <mark>jumping</mark> &lt;fast&gt;",
+		search.FieldCategories:  "Sports",
+	}
+	if !reflect.DeepEqual(hits[0].Highlights, want) {
+		t.Errorf("Highlights = %q, want %q", hits[0].Highlights, want)
+	}
+
+	// typos are highlighted as the word that's in the text
+	hits, err = ix.Search(context.Background(), "gohper", 0)
+	if err != nil {
+		t.Fatalf("Search: %v", err)
+	}
+	if len(hits) != 1 || hits[0].Highlights[search.FieldName] != "<mark>Gopher</mark> plushie" {
+		t.Errorf("hits = %+v, want the Gopher with its name highlighted", hits)
+	}
+}
+
+func TestIndexChanges(t *testing.T) {
+	ctx := context.Background()
+	ix := newIndex(t)
+
+	if err := ix.Index(ctx, search.Document{ID: 1, Name: "Rust crab"}); err != nil {
+		t.Fatalf("Index: %v", err)
+	}
+	assertHits(t, ix, "gopher", nil)
+	assertHits(t, ix, "crab", []int{1})
+
+	if err := ix.Remove(ctx, 2); err != nil {
+		t.Fatalf("Remove: %v", err)
+	}
+	assertHits(t, ix, "plushie", nil)
+	assertHits(t, ix, "elephant", []int{4})
+
+	hits, err := ix.Search(ctx, "elephant crab coffee shoes", 1)
+	if err != nil {
+		t.Fatalf("Search: %v", err)
+	}
+	if len(hits) != 0 {
+		t.Errorf("got %d hits for words of different products, want 0", len(hits))
+	}
+}
+
+func TestSearchLimit(t *testing.T) {
+	hits, err := newIndex(t).Search(context.Background(), "plushies", 1)
+	if err != nil {
+		t.Fatalf("Search: %v", err)
+	}
+	if len(hits) != 1 || hits[0].ID != 1 {
+		t.Errorf("hits = %+v, want only the best one, the Gopher", hits)
+	}
+}
+
+func assertHits(t *testing.T, ix search.SearchIndex, query string, want []int) {
+	t.Helper()
+	hits, err := ix.Search(context.Background(), query, 0)
+	if err != nil {
+		t.Fatalf("Search: %v", err)
+	}
+	var got []int
+	for _, h := range hits {
+		got = append(got, h.ID)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Search(%q) = %v, want %v", query, got, want)
+	}
+}
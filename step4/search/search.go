@@ -0,0 +1,42 @@
+// Package search contains the SearchIndex interface that product search engines
+// have to implement, together with an in-process inverted index that needs nothing but Go.
+package search
+
+import (
+	"context"
+)
+
+// Field names, they match the JSON names of the product fields
+const (
+	FieldName        = "name"
+	FieldDescription = "desc"
+	FieldCategories  = "categories"
+)
+
+// Document is what we know about a product when searching
+type Document struct {
+	ID          int
+	Name        string
+	Description string
+	// Categories are the names of the product's categories and the ones above them
+	Categories []string
+}
+
+// Hit is a document that matches the query
+type Hit struct {
+	ID    int
+	Score float64
+	// Highlights has the HTML-escaped text of every field with the matching words in <mark>
+	Highlights map[string]string
+}
+
+type SearchIndex interface {
+	// Index adds the document, or replaces the one with the same ID
+	Index(ctx context.Context, doc Document) error
+	Remove(ctx context.Context, id int) error
+	// Replace swaps the contents of the index for the documents
+	Replace(ctx context.Context, docs []Document) error
+	// Search returns at most limit hits for the query, the most relevant first.
+	// A document has to match every word of the query, an empty query has no hits.
+	Search(ctx context.Context, query string, limit int) ([]Hit, error)
+}
@@ -0,0 +1,147 @@
+package search
+
+import (
+	"html"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// token is a word of a text, Start and End are its byte offsets in the text
+type token struct {
+	Term       string
+	Start, End int
+}
+
+// tokenize splits the text into lowercase words and their stems
+func tokenize(text string) []token {
+	var tokens []token
+	start := -1
+	for i, r := range text + " " {
+		isWord := unicode.IsLetter(r) || unicode.IsDigit(r)
+		switch {
+		case isWord && start < 0:
+			start = i
+		case !isWord && start >= 0:
+			tokens = append(tokens, token{Term: stem(strings.ToLower(text[start:i])), Start: start, End: i})
+			start = -1
+		}
+	}
+	return tokens
+}
+
+// stem strips the common English suffixes, so "plushies", "plushie" and "plushy" all become "plushi".
+// It's a lot lighter than Porter's algorithm, but the same word always gets the same stem,
+// which is what matters when the query and the documents go through it alike.
+func stem(word string) string {
+	if utf8.RuneCountInString(word) <= 3 {
+		return word
+	}
+	switch {
+	case strings.HasSuffix(word, "sses"):
+		word = strings.TrimSuffix(word, "es")
+	case strings.HasSuffix(word, "ies"), strings.HasSuffix(word, "xes"),
+		strings.HasSuffix(word, "ches"), strings.HasSuffix(word, "shes"):
+		word = strings.TrimSuffix(word, "es")
+	case strings.HasSuffix(word, "s") && !strings.HasSuffix(word, "ss") &&
+		!strings.HasSuffix(word, "us") && !strings.HasSuffix(word, "is"):
+		word = strings.TrimSuffix(word, "s")
+	}
+	for _, suffix := range []string{"ing", "ed"} {
+		if base, ok := strings.CutSuffix(word, suffix); ok && len(base) >= 3 && hasVowel(base) {
+			word = undouble(base)
+			break
+		}
+	}
+	if base, ok := strings.CutSuffix(word, "y"); ok && len(base) >= 3 && !isVowel(base[len(base)-1]) {
+		word = base + "i"
+	}
+	if base, ok := strings.CutSuffix(word, "e"); ok && len(base) >= 4 {
+		word = base
+	}
+	return word
+}
+
+func isVowel(b byte) bool {
+	return strings.IndexByte("aeiou", b) >= 0
+}
+
+func hasVowel(s string) bool {
+	return strings.ContainsAny(s, "aeiouy")
+}
+
+// undouble turns "runn" into "run", what's left of "running"
+func undouble(s string) string {
+	n := len(s)
+	if n >= 2 && s[n-1] == s[n-2] && !isVowel(s[n-1]) && strings.IndexByte("lsz", s[n-1]) < 0 {
+		return s[:n-1]
+	}
+	return s
+}
+
+// maxTypos is the number of typos we forgive in a word, short words have to be right
+func maxTypos(term string) int {
+	switch n := utf8.RuneCountInString(term); {
+	case n >= 8:
+		return 2
+	case n >= 4:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// distance is the Damerau-Levenshtein distance between a and b, where swapping
+// two letters counts as one typo. It gives up once the distance is above max.
+func distance(a, b string, max int) int {
+	ra, rb := []rune(a), []rune(b)
+	if d := len(ra) - len(rb); d > max || -d > max {
+		return max + 1
+	}
+
+	// we only need the last two rows of the matrix
+	prev2 := make([]int, len(rb)+1)
+	prev := make([]int, len(rb)+1)
+	cur := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		cur[0] = i
+		best := cur[0]
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+			if i > 1 && j > 1 && ra[i-1] == rb[j-2] && ra[i-2] == rb[j-1] {
+				cur[j] = min(cur[j], prev2[j-2]+1)
+			}
+			best = min(best, cur[j])
+		}
+		if best > max {
+			return max + 1
+		}
+		prev2, prev, cur = prev, cur, prev2
+	}
+	return prev[len(rb)]
+}
+
+// highlight escapes the text and puts the words whose stem is in terms in <mark>
+func highlight(text string, terms map[string]bool) string {
+	var b strings.Builder
+	last := 0
+	for _, t := range tokenize(text) {
+		if !terms[t.Term] {
+			continue
+		}
+		b.WriteString(html.EscapeString(text[last:t.Start]))
+		b.WriteString("<mark>")
+		b.WriteString(html.EscapeString(text[t.Start:t.End]))
+		b.WriteString("</mark>")
+		last = t.End
+	}
+	b.WriteString(html.EscapeString(text[last:]))
+	return b.String()
+}
@@ -0,0 +1,159 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	app "github.com/gerbenjacobs/go-webshop-course"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	tokenIssuer     = "go-webshop-course"
+	accessTokenTTL  = 15 * time.Minute
+	refreshTokenTTL = 7 * 24 * time.Hour
+
+	useAccess  = "access"
+	useRefresh = "refresh"
+)
+
+// SigningKey is an HMAC secret with an ID, the ID ends up in the `kid` header
+// of every token so we know which key to verify it with
+type SigningKey struct {
+	ID     string
+	Secret []byte
+}
+
+// ParseSigningKeys reads keys in the form "kid1:secret1,kid2:secret2"
+func ParseSigningKeys(s string) ([]SigningKey, error) {
+	var keys []SigningKey
+	for _, pair := range strings.Split(s, ",") {
+		id, secret, ok := strings.Cut(strings.TrimSpace(pair), ":")
+		if !ok || id == "" || secret == "" {
+			return nil, fmt.Errorf("invalid signing key %q, expected kid:secret", pair)
+		}
+		keys = append(keys, SigningKey{ID: id, Secret: []byte(secret)})
+	}
+	return keys, nil
+}
+
+type tokenClaims struct {
+	Use string `json:"use"`
+	jwt.RegisteredClaims
+}
+
+type AuthSvc struct {
+	users UserService
+	keys  []SigningKey
+}
+
+// NewAuthService creates an AuthSvc that signs new tokens with the first key
+// and accepts tokens signed by any of the keys. To rotate keys, put the new key
+// in front and remove the old one once all of its tokens have expired.
+func NewAuthService(users UserService, keys []SigningKey) (*AuthSvc, error) {
+	if len(keys) == 0 {
+		return nil, errors.New("at least one signing key is required")
+	}
+	return &AuthSvc{users: users, keys: keys}, nil
+}
+
+func (a *AuthSvc) IssueTokens(ctx context.Context, email, password string) (app.TokenPair, error) {
+	user, err := a.users.Login(ctx, email, password)
+	if err != nil {
+		return app.TokenPair{}, err
+	}
+	return a.issue(user.ID)
+}
+
+func (a *AuthSvc) RefreshTokens(ctx context.Context, refreshToken string) (app.TokenPair, error) {
+	userID, err := a.verify(refreshToken, useRefresh)
+	if err != nil {
+		return app.TokenPair{}, err
+	}
+
+	// make sure the user still exists before handing out new tokens
+	if _, err := a.users.GetUser(ctx, userID); err != nil {
+		if errors.Is(err, app.ErrUserNotFound) {
+			return app.TokenPair{}, fmt.Errorf("%w: unknown user", app.ErrInvalidToken)
+		}
+		return app.TokenPair{}, err
+	}
+	return a.issue(userID)
+}
+
+func (a *AuthSvc) Authenticate(_ context.Context, accessToken string) (int, error) {
+	return a.verify(accessToken, useAccess)
+}
+
+func (a *AuthSvc) issue(userID int) (app.TokenPair, error) {
+	access, err := a.sign(userID, useAccess, accessTokenTTL)
+	if err != nil {
+		return app.TokenPair{}, err
+	}
+	refresh, err := a.sign(userID, useRefresh, refreshTokenTTL)
+	if err != nil {
+		return app.TokenPair{}, err
+	}
+	return app.TokenPair{
+		AccessToken:  access,
+		TokenType:    "Bearer",
+		ExpiresIn:    int(accessTokenTTL.Seconds()),
+		RefreshToken: refresh,
+	}, nil
+}
+
+func (a *AuthSvc) sign(userID int, use string, ttl time.Duration) (string, error) {
+	now := time.Now()
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, tokenClaims{
+		Use: use,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    tokenIssuer,
+			Subject:   strconv.Itoa(userID),
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(ttl)),
+		},
+	})
+	key := a.keys[0]
+	token.Header["kid"] = key.ID
+
+	signed, err := token.SignedString(key.Secret)
+	if err != nil {
+		return "", fmt.Errorf("failed to sign token: %w", err)
+	}
+	return signed, nil
+}
+
+func (a *AuthSvc) verify(tokenString, use string) (int, error) {
+	var claims tokenClaims
+	_, err := jwt.ParseWithClaims(tokenString, &claims, a.keyFunc,
+		jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}),
+		jwt.WithIssuer(tokenIssuer),
+		jwt.WithExpirationRequired(),
+	)
+	if err != nil {
+		return 0, fmt.Errorf("%w: %w", app.ErrInvalidToken, err)
+	}
+	if claims.Use != use {
+		return 0, fmt.Errorf("%w: expected %s token, got %q", app.ErrInvalidToken, use, claims.Use)
+	}
+
+	userID, err := strconv.Atoi(claims.Subject)
+	if err != nil {
+		return 0, fmt.Errorf("%w: invalid subject", app.ErrInvalidToken)
+	}
+	return userID, nil
+}
+
+func (a *AuthSvc) keyFunc(token *jwt.Token) (any, error) {
+	kid, _ := token.Header["kid"].(string)
+	for _, key := range a.keys {
+		if key.ID == kid {
+			return key.Secret, nil
+		}
+	}
+	return nil, fmt.Errorf("unknown key ID %q", kid)
+}
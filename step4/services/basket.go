@@ -2,25 +2,319 @@ package services
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
 
 	app "github.com/gerbenjacobs/go-webshop-course"
 	"github.com/gerbenjacobs/go-webshop-course/storage"
+	"github.com/gerbenjacobs/go-webshop-course/tax"
 )
 
 type BasketSvc struct {
-	repo storage.BasketRepository
+	repo        storage.BasketRepository
+	products    ProductService
+	promotions  PromotionService
+	taxes       *tax.Engine
+	shippingFee app.Money
 }
 
-func NewBasketService(repo storage.BasketRepository) *BasketSvc {
-	return &BasketSvc{repo: repo}
+func NewBasketService(repo storage.BasketRepository, products ProductService, promotions PromotionService, taxes *tax.Engine, shippingFee app.Money) *BasketSvc {
+	return &BasketSvc{repo: repo, products: products, promotions: promotions, taxes: taxes, shippingFee: shippingFee}
 }
 
 func (b *BasketSvc) GetBasket(ctx context.Context, userID int) (app.Basket, error) {
 	return b.repo.GetBasket(ctx, userID)
 }
-func (b *BasketSvc) AddToBasket(ctx context.Context, userID, productID, quantity int) error {
-	return b.repo.AddToBasket(ctx, userID, productID, quantity)
+
+// GetBasketView returns the basket with the current product details and prices,
+// taxed for the given country or our default country when it's empty.
+// Products and variants that no longer exist are left out, they can't be bought anyway.
+func (b *BasketSvc) GetBasketView(ctx context.Context, userID int, country string) (app.BasketView, error) {
+	basket, err := b.repo.GetBasket(ctx, userID)
+	if err != nil {
+		return app.BasketView{}, err
+	}
+	return b.view(ctx, basket, country)
+}
+
+func (b *BasketSvc) EmptyBasketView(ctx context.Context, country string) (app.BasketView, error) {
+	return b.view(ctx, app.Basket{Items: []app.BasketItem{}}, country)
+}
+
+// view prices the basket and applies the promotions and tax
+func (b *BasketSvc) view(ctx context.Context, basket app.Basket, country string) (app.BasketView, error) {
+	view := app.BasketView{
+		UserID:   basket.UserID,
+		Lines:    make([]app.BasketLine, 0, len(basket.Items)),
+		Subtotal: app.NewMoney(0, app.DefaultCurrency),
+		Shipping: app.NewMoney(0, app.DefaultCurrency),
+		Coupon:   basket.Coupon,
+	}
+	for _, item := range basket.Items {
+		product, variant, ok, err := b.lineProduct(ctx, item)
+		if err != nil {
+			return app.BasketView{}, err
+		}
+		if !ok {
+			continue
+		}
+
+		price := product.VariantPrice(variant)
+		line := app.BasketLine{
+			ProductID:   product.ID,
+			VariantID:   variant.ID,
+			SKU:         variant.SKU,
+			Name:        product.Name,
+			Variant:     product.VariantLabel(variant),
+			Image:       product.VariantImage(variant),
+			UnitPrice:   price,
+			CategoryIDs: product.CategoryIDs,
+			TaxClass:    product.TaxClass,
+			Quantity:    item.Quantity,
+			LineTotal:   price.Mul(item.Quantity),
+		}
+		line.Available = product.VariantAvailable(variant)
+		line.StockStatus = app.StockStatusOf(line.Available)
+		view.Lines = append(view.Lines, line)
+		view.ItemCount += line.Quantity
+		view.Subtotal = view.Subtotal.Add(line.LineTotal)
+	}
+
+	if len(view.Lines) > 0 {
+		view.Shipping = b.shippingFee
+	}
+	if err := b.promotions.ApplyPromotions(ctx, &view); err != nil {
+		return app.BasketView{}, err
+	}
+	if err := b.applyTax(&view, country); err != nil {
+		return app.BasketView{}, err
+	}
+	return view, nil
+}
+
+// lineProduct looks up the product and variant of a basket item,
+// ok is false when one of them no longer exists
+func (b *BasketSvc) lineProduct(ctx context.Context, item app.BasketItem) (app.Product, app.Variant, bool, error) {
+	product, err := b.products.ShowProduct(ctx, item.ProductID)
+	switch {
+	case errors.Is(err, app.ErrProductNotFound):
+		return app.Product{}, app.Variant{}, false, nil
+	case err != nil:
+		return app.Product{}, app.Variant{}, false, fmt.Errorf("failed to fetch product for basket: %w", err)
+	}
+	variant, err := product.Variant(item.VariantID)
+	if err != nil {
+		return app.Product{}, app.Variant{}, false, nil
+	}
+	return product, variant, true, nil
+}
+
+// PruneBasket takes the products and variants that no longer exist out of the basket
+func (b *BasketSvc) PruneBasket(ctx context.Context, userID int) error {
+	basket, err := b.repo.GetBasket(ctx, userID)
+	if err != nil {
+		return err
+	}
+	return b.prune(ctx, basket)
+}
+
+// prune removes the lines that GetBasketView leaves out, they'd make the checkout fail
+func (b *BasketSvc) prune(ctx context.Context, basket app.Basket) error {
+	for _, item := range basket.Items {
+		_, _, ok, err := b.lineProduct(ctx, item)
+		if err != nil {
+			return err
+		}
+		if ok {
+			continue
+		}
+		if err := b.repo.SetQuantity(ctx, basket.UserID, item.ProductID, item.VariantID, 0); err != nil {
+			return fmt.Errorf("failed to remove deleted product from basket: %w", err)
+		}
+	}
+	return nil
+}
+
+// applyTax calculates the tax over the discounted lines and shipping, and sets the basket total
+func (b *BasketSvc) applyTax(view *app.BasketView, country string) error {
+	if country == "" {
+		country = b.taxes.DefaultCountry()
+	}
+	lines := make([]tax.Line, 0, len(view.Lines)+1)
+	shippingDiscount := view.Discount
+	for _, line := range view.Lines {
+		lines = append(lines, tax.Line{Class: line.TaxClass, Amount: line.LineTotal.Sub(line.Discount)})
+		shippingDiscount = shippingDiscount.Sub(line.Discount)
+	}
+	// shipping is taxed at the standard rate
+	if shipping := view.Shipping.Sub(shippingDiscount); !shipping.IsZero() {
+		lines = append(lines, tax.Line{Class: app.TaxClassStandard, Amount: shipping})
+	}
+	res, err := b.taxes.Calculate(country, lines)
+	if err != nil {
+		return err
+	}
+
+	view.Country = strings.ToUpper(country)
+	view.PricesIncludeTax = b.taxes.PricesIncludeTax()
+	view.Taxes = res.Lines
+	view.Tax = app.NewMoney(res.Tax.Amount, view.Subtotal.Currency)
+	view.Total = view.Subtotal.Add(view.Shipping).Sub(view.Discount)
+	if !view.PricesIncludeTax {
+		view.Total = view.Total.Add(view.Tax)
+	}
+	return nil
 }
-func (b *BasketSvc) RemoveFromBasket(ctx context.Context, userID, productID, quantity int) error {
-	return b.repo.RemoveFromBasket(ctx, userID, productID, quantity)
+
+// ApplyCoupon puts a coupon on the basket, it has to give a discount right now
+func (b *BasketSvc) ApplyCoupon(ctx context.Context, userID int, code string) (app.BasketView, error) {
+	code = app.NormalizeCoupon(code)
+	// make sure the basket exists
+	if _, err := b.repo.GetBasket(ctx, userID); err != nil {
+		return app.BasketView{}, err
+	}
+	if _, err := b.promotions.ValidateCoupon(ctx, userID, code); err != nil {
+		return app.BasketView{}, err
+	}
+	if err := b.repo.SetCoupon(ctx, userID, code); err != nil {
+		return app.BasketView{}, err
+	}
+
+	view, err := b.GetBasketView(ctx, userID, "")
+	if err != nil {
+		return app.BasketView{}, err
+	}
+	if view.CouponError != "" {
+		// take it off again, so it doesn't linger on the basket
+		if err := b.repo.SetCoupon(ctx, userID, ""); err != nil {
+			return app.BasketView{}, err
+		}
+		return app.BasketView{}, fmt.Errorf("%w: %s", app.ErrPromotionNotApplicable, view.CouponError)
+	}
+	return view, nil
+}
+
+func (b *BasketSvc) RemoveCoupon(ctx context.Context, userID int) error {
+	if _, err := b.repo.GetBasket(ctx, userID); err != nil {
+		return err
+	}
+	return b.repo.SetCoupon(ctx, userID, "")
+}
+
+func (b *BasketSvc) AddToBasket(ctx context.Context, userID, productID, variantID, quantity int) error {
+	if quantity <= 0 || quantity > app.MaxLineQuantity {
+		return fmt.Errorf("%w: %d", app.ErrInvalidQuantity, quantity)
+	}
+	basket, err := b.repo.GetBasket(ctx, userID)
+	if err != nil {
+		return err
+	}
+	// the basket gets written anyway, a good moment to clean it up
+	if err := b.prune(ctx, basket); err != nil {
+		return err
+	}
+	inBasket := 0
+	for _, item := range basket.Items {
+		if item.ProductID == productID && item.VariantID == variantID {
+			inBasket = item.Quantity
+		}
+	}
+	// written this way around so a huge quantity can't overflow the sum
+	if inBasket > app.MaxLineQuantity-quantity {
+		return fmt.Errorf("%w: %d already in basket, at most %d allowed", app.ErrInvalidQuantity, inBasket, app.MaxLineQuantity)
+	}
+	if err := b.checkStock(ctx, productID, variantID, inBasket+quantity); err != nil {
+		return err
+	}
+	return b.repo.AddToBasket(ctx, userID, productID, variantID, quantity)
+}
+func (b *BasketSvc) RemoveFromBasket(ctx context.Context, userID, productID, variantID, quantity int) error {
+	if quantity <= 0 {
+		return fmt.Errorf("%w: %d", app.ErrInvalidQuantity, quantity)
+	}
+	return b.repo.RemoveFromBasket(ctx, userID, productID, variantID, quantity)
+}
+
+// SetQuantity changes the quantity of a product in the basket,
+// a quantity of 0 removes the product
+func (b *BasketSvc) SetQuantity(ctx context.Context, userID, productID, variantID, quantity int) error {
+	if quantity < 0 || quantity > app.MaxLineQuantity {
+		return fmt.Errorf("%w: %d", app.ErrInvalidQuantity, quantity)
+	}
+	if quantity > 0 {
+		if err := b.checkStock(ctx, productID, variantID, quantity); err != nil {
+			return err
+		}
+	}
+	return b.repo.SetQuantity(ctx, userID, productID, variantID, quantity)
+}
+
+// UpdateItem sets the quantity of a line in the basket, 0 removes it
+func (b *BasketSvc) UpdateItem(ctx context.Context, userID, productID, variantID, quantity int) error {
+	if err := b.findItem(ctx, userID, productID, variantID); err != nil {
+		return err
+	}
+	return b.SetQuantity(ctx, userID, productID, variantID, quantity)
+}
+
+func (b *BasketSvc) RemoveItem(ctx context.Context, userID, productID, variantID int) error {
+	if err := b.findItem(ctx, userID, productID, variantID); err != nil {
+		return err
+	}
+	return b.repo.SetQuantity(ctx, userID, productID, variantID, 0)
+}
+
+// ClearBasket empties the basket and takes off the coupon
+func (b *BasketSvc) ClearBasket(ctx context.Context, userID int) error {
+	// make sure the basket exists, an empty basket is cleared just fine
+	if _, err := b.repo.GetBasket(ctx, userID); err != nil {
+		return err
+	}
+	return b.repo.ClearBasket(ctx, userID)
+}
+
+// findItem makes sure the line is in the basket
+func (b *BasketSvc) findItem(ctx context.Context, userID, productID, variantID int) error {
+	basket, err := b.repo.GetBasket(ctx, userID)
+	if err != nil {
+		return err
+	}
+	for _, item := range basket.Items {
+		if item.ProductID == productID && item.VariantID == variantID {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: product ID: %d, variant ID: %d", app.ErrItemNotInBasket, productID, variantID)
+}
+
+// checkStock makes sure the product exists, can be bought as the given variant
+// and that we have enough of it to put quantity in the basket
+func (b *BasketSvc) checkStock(ctx context.Context, productID, variantID, quantity int) error {
+	product, err := b.products.ShowProduct(ctx, productID)
+	if err != nil {
+		return err
+	}
+	variant, err := product.Variant(variantID)
+	if err != nil {
+		return err
+	}
+	if available := product.VariantAvailable(variant); quantity > available {
+		return fmt.Errorf("%w: %d available for product ID: %d", app.ErrInsufficientStock, available, productID)
+	}
+	return nil
+}
+
+// MergeBaskets moves everything in the guest's basket to the user's basket,
+// the guest basket is removed afterwards
+func (b *BasketSvc) MergeBaskets(ctx context.Context, guestID, userID int) error {
+	if !app.IsGuest(guestID) || app.IsGuest(userID) {
+		return fmt.Errorf("can only merge a guest basket into a user basket, got %d and %d", guestID, userID)
+	}
+	return b.repo.MergeBaskets(ctx, guestID, userID)
+}
+
+func (b *BasketSvc) DeleteExpiredGuestBaskets(ctx context.Context) (int, error) {
+	return b.repo.DeleteGuestBaskets(ctx, time.Now().Add(-app.GuestBasketTTL))
 }
@@ -0,0 +1,102 @@
+package services_test
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	app "github.com/gerbenjacobs/go-webshop-course"
+	"github.com/gerbenjacobs/go-webshop-course/payment"
+	"github.com/gerbenjacobs/go-webshop-course/search"
+	"github.com/gerbenjacobs/go-webshop-course/services"
+	"github.com/gerbenjacobs/go-webshop-course/storage"
+	"github.com/gerbenjacobs/go-webshop-course/tax"
+)
+
+func TestBasketWithDeletedProducts(t *testing.T) {
+	const userID = 1
+	ctx := context.Background()
+	baskets := storage.NewBasketRepo()
+	products := storage.NewProductRepo()
+	promotions := storage.NewPromotionRepo()
+	orders := storage.NewOrderRepo(baskets, products, promotions)
+
+	taxConfig, err := tax.LoadConfig("../tax.json")
+	if err != nil {
+		t.Fatalf("LoadConfig: %v", err)
+	}
+	taxEngine, err := tax.NewEngine(taxConfig)
+	if err != nil {
+		t.Fatalf("NewEngine: %v", err)
+	}
+	productSvc := services.NewProductService(products, storage.NewCategoryRepo(products), search.NewInvertedIndex())
+	basketSvc := services.NewBasketService(baskets, productSvc, services.NewPromotionService(promotions, orders), taxEngine, app.EUR(495))
+	orderSvc := services.NewOrderService(orders, basketSvc, payment.NewFake(payment.FakeOptions{}))
+
+	// a Gopher, a blue and a pink elephant, then the Gopher and the pink elephant are gone
+	for _, item := range []app.BasketItem{{ProductID: 1}, {ProductID: 2, VariantID: 1}, {ProductID: 2, VariantID: 2}} {
+		if err := basketSvc.AddToBasket(ctx, userID, item.ProductID, item.VariantID, 1); err != nil {
+			t.Fatalf("AddToBasket: %v", err)
+		}
+	}
+	if err := productSvc.DeleteProduct(ctx, 1); err != nil {
+		t.Fatalf("DeleteProduct: %v", err)
+	}
+	elephant, err := productSvc.ShowProduct(ctx, 2)
+	if err != nil {
+		t.Fatalf("ShowProduct: %v", err)
+	}
+	elephant.Variants = elephant.Variants[:1]
+	if _, err := productSvc.UpdateProduct(ctx, elephant); err != nil {
+		t.Fatalf("UpdateProduct: %v", err)
+	}
+
+	view, err := basketSvc.GetBasketView(ctx, userID, "")
+	if err != nil {
+		t.Fatalf("GetBasketView: %v", err)
+	}
+	if len(view.Lines) != 1 || view.Lines[0].VariantID != 1 {
+		t.Fatalf("lines = %+v, want only the blue elephant", view.Lines)
+	}
+	// looking at the basket doesn't change it
+	assertBasketItems(t, basketSvc, userID, []app.BasketItem{
+		{ProductID: 1, Quantity: 1}, {ProductID: 2, VariantID: 1, Quantity: 1}, {ProductID: 2, VariantID: 2, Quantity: 1},
+	})
+
+	t.Run("AddToBasket prunes the basket", func(t *testing.T) {
+		const userID = 2
+		if err := basketSvc.AddToBasket(ctx, userID, 2, 1, 1); err != nil {
+			t.Fatalf("AddToBasket: %v", err)
+		}
+		// it can't be added anymore, so put it in the repository directly
+		if err := baskets.AddToBasket(ctx, userID, 1, 0, 1); err != nil {
+			t.Fatalf("AddToBasket of the repository: %v", err)
+		}
+		if err := basketSvc.AddToBasket(ctx, userID, 2, 1, 1); err != nil {
+			t.Fatalf("AddToBasket: %v", err)
+		}
+		assertBasketItems(t, basketSvc, userID, []app.BasketItem{{ProductID: 2, VariantID: 1, Quantity: 2}})
+	})
+
+	t.Run("Checkout leaves out what's gone", func(t *testing.T) {
+		order, err := orderSvc.Checkout(ctx, userID, "")
+		if err != nil {
+			t.Fatalf("Checkout: %v", err)
+		}
+		if len(order.Items) != 1 || order.Items[0].VariantID != 1 {
+			t.Errorf("order items = %+v, want only the blue elephant", order.Items)
+		}
+		assertBasketItems(t, basketSvc, userID, []app.BasketItem{})
+	})
+}
+
+func assertBasketItems(t *testing.T, basketSvc services.BasketService, userID int, want []app.BasketItem) {
+	t.Helper()
+	basket, err := basketSvc.GetBasket(context.Background(), userID)
+	if err != nil {
+		t.Fatalf("GetBasket: %v", err)
+	}
+	if !reflect.DeepEqual(basket.Items, want) {
+		t.Errorf("basket items = %+v, want %+v", basket.Items, want)
+	}
+}
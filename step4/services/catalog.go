@@ -0,0 +1,210 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	app "github.com/gerbenjacobs/go-webshop-course"
+	"github.com/gerbenjacobs/go-webshop-course/storage"
+)
+
+// CatalogSvc organizes the products in categories and collections
+type CatalogSvc struct {
+	categories  storage.CategoryRepository
+	collections storage.CollectionRepository
+	products    ProductService
+}
+
+func NewCatalogService(categories storage.CategoryRepository, collections storage.CollectionRepository, products ProductService) *CatalogSvc {
+	return &CatalogSvc{categories: categories, collections: collections, products: products}
+}
+
+func (c *CatalogSvc) CategoryTree(ctx context.Context) ([]app.CategoryNode, error) {
+	categories, err := c.categories.GetAllCategories(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return app.Categories(categories).Tree(), nil
+}
+
+// ShowCategory returns the category with its breadcrumbs, its subcategories and
+// its products, the products of the subcategories included
+func (c *CatalogSvc) ShowCategory(ctx context.Context, slug string) (app.CategoryPage, error) {
+	category, err := c.categories.GetCategoryBySlug(ctx, slug)
+	if err != nil {
+		return app.CategoryPage{}, err
+	}
+	all, err := c.categories.GetAllCategories(ctx)
+	if err != nil {
+		return app.CategoryPage{}, err
+	}
+	categories := app.Categories(all)
+
+	products, err := c.products.ListProducts(ctx, app.ProductQuery{Category: category.Slug})
+	if err != nil {
+		return app.CategoryPage{}, err
+	}
+
+	return app.CategoryPage{
+		Category:    category,
+		Breadcrumbs: categories.Breadcrumbs(category.ID),
+		Children:    categories.Children(category.ID),
+		Products:    products.Products,
+	}, nil
+}
+
+func (c *CatalogSvc) CreateCategory(ctx context.Context, category app.Category) (app.Category, error) {
+	category = normalizeCategory(category)
+	if err := category.Validate(); err != nil {
+		return app.Category{}, err
+	}
+	if err := c.checkParent(ctx, category); err != nil {
+		return app.Category{}, err
+	}
+	return c.categories.CreateCategory(ctx, category)
+}
+
+func (c *CatalogSvc) UpdateCategory(ctx context.Context, category app.Category) (app.Category, error) {
+	if _, err := c.categories.GetCategory(ctx, category.ID); err != nil {
+		return app.Category{}, err
+	}
+	category = normalizeCategory(category)
+	if err := category.Validate(); err != nil {
+		return app.Category{}, err
+	}
+	if err := c.checkParent(ctx, category); err != nil {
+		return app.Category{}, err
+	}
+	category, err := c.categories.UpdateCategory(ctx, category)
+	if err != nil {
+		return app.Category{}, err
+	}
+	// products are found by the names of their categories
+	return category, c.products.ReindexProducts(ctx)
+}
+
+func (c *CatalogSvc) DeleteCategory(ctx context.Context, categoryID int) error {
+	if err := c.categories.DeleteCategory(ctx, categoryID); err != nil {
+		return err
+	}
+	return c.products.ReindexProducts(ctx)
+}
+
+func (c *CatalogSvc) ListCollections(ctx context.Context) ([]app.Collection, error) {
+	return c.collections.GetAllCollections(ctx)
+}
+
+// ShowCollection returns the collection with its products in the curated order,
+// products that no longer exist are left out
+func (c *CatalogSvc) ShowCollection(ctx context.Context, slug string) (app.CollectionPage, error) {
+	collection, err := c.collections.GetCollectionBySlug(ctx, slug)
+	if err != nil {
+		return app.CollectionPage{}, err
+	}
+
+	products := make([]app.Product, 0, len(collection.ProductIDs))
+	for _, productID := range collection.ProductIDs {
+		product, err := c.products.ShowProduct(ctx, productID)
+		switch {
+		case errors.Is(err, app.ErrProductNotFound):
+			continue
+		case err != nil:
+			return app.CollectionPage{}, fmt.Errorf("failed to fetch product for collection: %w", err)
+		}
+		products = append(products, product)
+	}
+	return app.CollectionPage{Collection: collection, Products: products}, nil
+}
+
+func (c *CatalogSvc) CreateCollection(ctx context.Context, collection app.Collection) (app.Collection, error) {
+	collection = normalizeCollection(collection)
+	if err := collection.Validate(); err != nil {
+		return app.Collection{}, err
+	}
+	if err := c.checkProducts(ctx, collection); err != nil {
+		return app.Collection{}, err
+	}
+	return c.collections.CreateCollection(ctx, collection)
+}
+
+func (c *CatalogSvc) UpdateCollection(ctx context.Context, collection app.Collection) (app.Collection, error) {
+	if _, err := c.collections.GetCollection(ctx, collection.ID); err != nil {
+		return app.Collection{}, err
+	}
+	collection = normalizeCollection(collection)
+	if err := collection.Validate(); err != nil {
+		return app.Collection{}, err
+	}
+	if err := c.checkProducts(ctx, collection); err != nil {
+		return app.Collection{}, err
+	}
+	return c.collections.UpdateCollection(ctx, collection)
+}
+
+func (c *CatalogSvc) DeleteCollection(ctx context.Context, collectionID int) error {
+	return c.collections.DeleteCollection(ctx, collectionID)
+}
+
+// checkParent makes sure the parent exists and isn't below the category itself,
+// that would cut the category and its subcategories loose from the tree
+func (c *CatalogSvc) checkParent(ctx context.Context, category app.Category) error {
+	if category.ParentID == 0 {
+		return nil
+	}
+	all, err := c.categories.GetAllCategories(ctx)
+	if err != nil {
+		return err
+	}
+	categories := app.Categories(all)
+
+	fe := app.FieldErrors{}
+	if _, ok := categories.Find(category.ParentID); !ok {
+		fe.Add("parent_id", "category %d doesn't exist", category.ParentID)
+	}
+	if category.ID != 0 && slices.Contains(categories.Descendants(category.ID), category.ParentID) {
+		fe.Add("parent_id", "category %d is one of this category's subcategories", category.ParentID)
+	}
+	return fe.Err()
+}
+
+func (c *CatalogSvc) checkProducts(ctx context.Context, collection app.Collection) error {
+	fe := app.FieldErrors{}
+	for i, productID := range collection.ProductIDs {
+		_, err := c.products.ShowProduct(ctx, productID)
+		switch {
+		case errors.Is(err, app.ErrProductNotFound):
+			fe.Add(fmt.Sprintf("product_ids[%d]", i), "product %d doesn't exist", productID)
+		case err != nil:
+			return err
+		}
+	}
+	return fe.Err()
+}
+
+// normalizeCategory trims the input, a category without slug gets one from its name
+func normalizeCategory(category app.Category) app.Category {
+	category.Name = strings.TrimSpace(category.Name)
+	category.Description = strings.TrimSpace(category.Description)
+	category.Slug = strings.TrimSpace(category.Slug)
+	if category.Slug == "" {
+		category.Slug = app.Slugify(category.Name)
+	}
+	return category
+}
+
+// normalizeCollection works like normalizeCategory
+func normalizeCollection(collection app.Collection) app.Collection {
+	collection.Name = strings.TrimSpace(collection.Name)
+	collection.Description = strings.TrimSpace(collection.Description)
+	collection.Slug = strings.TrimSpace(collection.Slug)
+	if collection.Slug == "" {
+		collection.Slug = app.Slugify(collection.Name)
+	}
+	if collection.ProductIDs == nil {
+		collection.ProductIDs = []int{}
+	}
+	return collection
+}
@@ -0,0 +1,228 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	app "github.com/gerbenjacobs/go-webshop-course"
+	"github.com/gerbenjacobs/go-webshop-course/payment"
+	"github.com/gerbenjacobs/go-webshop-course/storage"
+)
+
+// reservationTime is how long we hold the stock of an order while waiting for its payment
+const reservationTime = 30 * time.Minute
+
+type OrderSvc struct {
+	repo     storage.OrderRepository
+	basket   BasketService
+	payments payment.PaymentProvider
+}
+
+func NewOrderService(repo storage.OrderRepository, basket BasketService, payments payment.PaymentProvider) *OrderSvc {
+	return &OrderSvc{repo: repo, basket: basket, payments: payments}
+}
+
+// Checkout turns the user's basket into an order, with the current product prices
+// and the taxes of the given country, reserves its stock and empties the basket
+func (o *OrderSvc) Checkout(ctx context.Context, userID int, country string) (app.Order, error) {
+	if app.IsGuest(userID) {
+		return app.Order{}, fmt.Errorf("guests can't check out, user ID: %d", userID)
+	}
+
+	// the order has to match the basket, so lines that can't be bought anymore go first
+	if err := o.basket.PruneBasket(ctx, userID); err != nil {
+		return app.Order{}, err
+	}
+	basket, err := o.basket.GetBasketView(ctx, userID, country)
+	if err != nil {
+		return app.Order{}, err
+	}
+	if len(basket.Lines) == 0 {
+		return app.Order{}, app.ErrEmptyBasket
+	}
+	if basket.Total.Amount <= 0 {
+		return app.Order{}, fmt.Errorf("%w: %s", app.ErrInvalidTotal, basket.Total)
+	}
+
+	order := app.Order{
+		UserID:           userID,
+		Status:           app.OrderStatusPendingPayment,
+		Items:            make([]app.OrderItem, 0, len(basket.Lines)),
+		Subtotal:         basket.Subtotal,
+		Shipping:         basket.Shipping,
+		Discounts:        basket.Discounts,
+		Discount:         basket.Discount,
+		Country:          basket.Country,
+		PricesIncludeTax: basket.PricesIncludeTax,
+		Taxes:            basket.Taxes,
+		Tax:              basket.Tax,
+		Total:            basket.Total,
+		CreatedAt:        time.Now().UTC(),
+		ReservedUntil:    time.Now().UTC().Add(reservationTime),
+	}
+	for _, line := range basket.Lines {
+		order.Items = append(order.Items, app.OrderItem{
+			ProductID: line.ProductID,
+			VariantID: line.VariantID,
+			SKU:       line.SKU,
+			Name:      line.Name,
+			Variant:   line.Variant,
+			UnitPrice: line.UnitPrice,
+			TaxClass:  line.TaxClass,
+			Quantity:  line.Quantity,
+		})
+	}
+
+	return o.repo.CreateOrder(ctx, order)
+}
+
+// GetOrder only returns orders that belong to the user
+func (o *OrderSvc) GetOrder(ctx context.Context, userID, orderID int) (app.Order, error) {
+	order, err := o.repo.GetOrder(ctx, orderID)
+	if err != nil {
+		return app.Order{}, err
+	}
+	if order.UserID != userID {
+		// don't leak that the order exists
+		return app.Order{}, fmt.Errorf("%w: for ID: %d", app.ErrOrderNotFound, orderID)
+	}
+	return order, nil
+}
+
+func (o *OrderSvc) ListOrders(ctx context.Context, userID int) ([]app.Order, error) {
+	return o.repo.GetOrdersByUser(ctx, userID)
+}
+
+func (o *OrderSvc) ListAllOrders(ctx context.Context, status app.OrderStatus) ([]app.Order, error) {
+	return o.repo.GetAllOrders(ctx, status)
+}
+
+func (o *OrderSvc) ShowOrder(ctx context.Context, orderID int) (app.Order, error) {
+	return o.repo.GetOrder(ctx, orderID)
+}
+
+// Pay starts the payment of an order with the given payment method.
+// When the returned authorization has a RedirectURL, the customer needs to go there
+// and the result will arrive through HandlePaymentEvent.
+func (o *OrderSvc) Pay(ctx context.Context, userID, orderID int, method, returnURL string) (payment.Authorization, error) {
+	order, err := o.GetOrder(ctx, userID, orderID)
+	if err != nil {
+		return payment.Authorization{}, err
+	}
+	if !order.Payable() {
+		return payment.Authorization{}, fmt.Errorf("%w: order %d is %s", app.ErrOrderNotPayable, order.ID, order.Status)
+	}
+	// hold on to the stock while the customer pays, the reservation may have expired already
+	if err := o.repo.ReserveStock(ctx, order.ID, time.Now().UTC().Add(reservationTime)); err != nil {
+		return payment.Authorization{}, err
+	}
+
+	auth, err := o.payments.Authorize(ctx, payment.AuthorizeRequest{
+		OrderID:   order.ID,
+		Amount:    order.Total,
+		Method:    method,
+		ReturnURL: returnURL,
+	})
+	from := order.Status
+	switch {
+	case errors.Is(err, payment.ErrDeclined):
+		order.Status = app.OrderStatusPaymentFailed
+		order.PaymentID = auth.PaymentID
+		if err := o.repo.UpdateOrder(ctx, order, from); err != nil {
+			return auth, err
+		}
+		return auth, payment.ErrDeclined
+	case err != nil:
+		return auth, fmt.Errorf("failed to authorize payment: %w", err)
+	}
+
+	order.PaymentID = auth.PaymentID
+	if auth.Status == payment.StatusAuthorized {
+		return auth, o.capture(ctx, order, from)
+	}
+	order.Status = app.OrderStatusPendingPayment
+	return auth, o.repo.UpdateOrder(ctx, order, from)
+}
+
+// HandlePaymentEvent processes the asynchronous result of a payment, sent by the provider's webhook
+func (o *OrderSvc) HandlePaymentEvent(ctx context.Context, event payment.Event) error {
+	order, err := o.repo.GetOrder(ctx, event.OrderID)
+	if err != nil {
+		return err
+	}
+	if order.PaymentID != event.PaymentID {
+		return fmt.Errorf("%w: payment %s does not belong to order %d", payment.ErrInvalidWebhook, event.PaymentID, order.ID)
+	}
+	if order.Status != app.OrderStatusPendingPayment {
+		// we've already processed this event, providers can send them more than once
+		return nil
+	}
+
+	switch event.Type {
+	case payment.EventAuthorized:
+		err = o.capture(ctx, order, app.OrderStatusPendingPayment)
+	case payment.EventFailed:
+		order.Status = app.OrderStatusPaymentFailed
+		err = o.repo.UpdateOrder(ctx, order, app.OrderStatusPendingPayment)
+	default:
+		return fmt.Errorf("%w: unknown event type %q", payment.ErrInvalidWebhook, event.Type)
+	}
+	switch {
+	case errors.Is(err, app.ErrOrderChanged):
+		// the same event came in twice at once, the other one processed it
+		return nil
+	case errors.Is(err, app.ErrPromotionUsedUp):
+		// the order failed for good, sending the event again won't change that
+		return nil
+	}
+	return err
+}
+
+func (o *OrderSvc) RefundOrder(ctx context.Context, orderID int) error {
+	order, err := o.repo.GetOrder(ctx, orderID)
+	if err != nil {
+		return err
+	}
+	if order.Status != app.OrderStatusPaid {
+		return fmt.Errorf("%w: order %d is %s", app.ErrOrderNotRefundable, order.ID, order.Status)
+	}
+
+	// mark it refunded first, so refunding it twice at once only pays the money back once
+	order.Status = app.OrderStatusRefunded
+	if err := o.repo.UpdateOrder(ctx, order, app.OrderStatusPaid); err != nil {
+		return err
+	}
+	if err := o.payments.Refund(ctx, order.PaymentID, order.Total); err != nil {
+		order.Status = app.OrderStatusPaid
+		if uerr := o.repo.UpdateOrder(ctx, order, app.OrderStatusRefunded); uerr != nil {
+			return fmt.Errorf("failed to refund payment: %w, and to mark order %d as paid again: %w", err, order.ID, uerr)
+		}
+		return fmt.Errorf("failed to refund payment: %w", err)
+	}
+	return nil
+}
+
+// capture marks the order as paid and takes the authorized money. Marking it paid only
+// works while it still has the from status, so when the provider sends the same event
+// twice at once, only one of them commits the stock and captures, the other gets ErrOrderChanged.
+// When other paid orders used up the order's promotions, the order fails and the money isn't captured.
+func (o *OrderSvc) capture(ctx context.Context, order app.Order, from app.OrderStatus) error {
+	order.Status = app.OrderStatusPaid
+	err := o.repo.UpdateOrder(ctx, order, from)
+	if errors.Is(err, app.ErrPromotionUsedUp) {
+		order.Status = app.OrderStatusPaymentFailed
+		if err := o.repo.UpdateOrder(ctx, order, from); err != nil {
+			return err
+		}
+	}
+	if err != nil {
+		return err
+	}
+	if err := o.payments.Capture(ctx, order.PaymentID, order.Total); err != nil {
+		// the money stays authorized, it can still be captured with the provider by hand
+		return fmt.Errorf("failed to capture payment %s of paid order %d: %w", order.PaymentID, order.ID, err)
+	}
+	return nil
+}
@@ -2,23 +2,265 @@ package services
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
 
 	app "github.com/gerbenjacobs/go-webshop-course"
+	"github.com/gerbenjacobs/go-webshop-course/search"
 	"github.com/gerbenjacobs/go-webshop-course/storage"
 )
 
+// maxSearchResults is how many products a search returns at most
+const maxSearchResults = 50
+
+// ProductSvc keeps the search index in sync with the products it changes
 type ProductSvc struct {
-	repo storage.ProductRepository
+	repo       storage.ProductRepository
+	categories storage.CategoryRepository
+	index      search.SearchIndex
 }
 
-func NewProductService(repo storage.ProductRepository) *ProductSvc {
-	return &ProductSvc{repo: repo}
+func NewProductService(repo storage.ProductRepository, categories storage.CategoryRepository, index search.SearchIndex) *ProductSvc {
+	return &ProductSvc{repo: repo, categories: categories, index: index}
 }
 
-func (p *ProductSvc) ListProducts(ctx context.Context) ([]app.Product, error) {
-	return p.repo.GetAllProducts(ctx)
+func (p *ProductSvc) ListProducts(ctx context.Context, query app.ProductQuery) (app.ProductPage, error) {
+	if err := query.Validate(); err != nil {
+		return app.ProductPage{}, err
+	}
+
+	var categoryIDs []int
+	if query.Category != "" {
+		category, err := p.categories.GetCategoryBySlug(ctx, query.Category)
+		switch {
+		case errors.Is(err, app.ErrCategoryNotFound):
+			fe := app.FieldErrors{}
+			fe.Add("category", "there's no category %q", query.Category)
+			return app.ProductPage{}, fe.Err()
+		case err != nil:
+			return app.ProductPage{}, err
+		}
+		categories, err := p.categories.GetAllCategories(ctx)
+		if err != nil {
+			return app.ProductPage{}, err
+		}
+		categoryIDs = app.Categories(categories).Descendants(category.ID)
+	}
+
+	products, err := p.repo.GetAllProducts(ctx)
+	if err != nil {
+		return app.ProductPage{}, err
+	}
+	matched := make([]app.Product, 0, len(products))
+	for _, product := range products {
+		if query.Match(product, categoryIDs) {
+			matched = append(matched, product)
+		}
+	}
+	return query.Page(matched)
 }
 
 func (p *ProductSvc) ShowProduct(ctx context.Context, productID int) (app.Product, error) {
 	return p.repo.GetProduct(ctx, productID)
 }
+
+func (p *ProductSvc) CreateProduct(ctx context.Context, product app.Product) (app.Product, error) {
+	product = normalizeProduct(product)
+	if err := product.Validate(); err != nil {
+		return app.Product{}, err
+	}
+	if err := p.checkCategories(ctx, product); err != nil {
+		return app.Product{}, err
+	}
+	product, err := p.repo.CreateProduct(ctx, product)
+	if err != nil {
+		return app.Product{}, err
+	}
+	return product, p.indexProduct(ctx, product)
+}
+
+// UpdateProduct replaces all of the product's fields, variants keep their ID when it's given
+func (p *ProductSvc) UpdateProduct(ctx context.Context, product app.Product) (app.Product, error) {
+	current, err := p.repo.GetProduct(ctx, product.ID)
+	if err != nil {
+		return app.Product{}, err
+	}
+	product = normalizeProduct(product)
+	if err := product.Validate(); err != nil {
+		return app.Product{}, err
+	}
+	if err := checkVariantIDs(current, product); err != nil {
+		return app.Product{}, err
+	}
+	if err := p.checkCategories(ctx, product); err != nil {
+		return app.Product{}, err
+	}
+	product, err = p.repo.UpdateProduct(ctx, product)
+	if err != nil {
+		return app.Product{}, err
+	}
+	return product, p.indexProduct(ctx, product)
+}
+
+// PatchProduct only changes the fields that are set in the patch
+func (p *ProductSvc) PatchProduct(ctx context.Context, productID int, patch app.ProductPatch) (app.Product, error) {
+	current, err := p.repo.GetProduct(ctx, productID)
+	if err != nil {
+		return app.Product{}, err
+	}
+	product := normalizeProduct(patch.Apply(current))
+	if err := product.Validate(); err != nil {
+		return app.Product{}, err
+	}
+	if err := checkVariantIDs(current, product); err != nil {
+		return app.Product{}, err
+	}
+	if err := p.checkCategories(ctx, product); err != nil {
+		return app.Product{}, err
+	}
+	product, err = p.repo.UpdateProduct(ctx, product)
+	if err != nil {
+		return app.Product{}, err
+	}
+	return product, p.indexProduct(ctx, product)
+}
+
+func (p *ProductSvc) DeleteProduct(ctx context.Context, productID int) error {
+	if err := p.repo.DeleteProduct(ctx, productID); err != nil {
+		return err
+	}
+	if err := p.index.Remove(ctx, productID); err != nil {
+		return fmt.Errorf("failed to remove product from search index: %w", err)
+	}
+	return nil
+}
+
+// SearchProducts returns the products that match the query, the most relevant first
+func (p *ProductSvc) SearchProducts(ctx context.Context, query string) ([]app.SearchResult, error) {
+	hits, err := p.index.Search(ctx, query, maxSearchResults)
+	if err != nil {
+		return nil, fmt.Errorf("failed to search products: %w", err)
+	}
+
+	results := make([]app.SearchResult, 0, len(hits))
+	for _, hit := range hits {
+		product, err := p.repo.GetProduct(ctx, hit.ID)
+		switch {
+		case errors.Is(err, app.ErrProductNotFound):
+			// the index is a step behind
+			continue
+		case err != nil:
+			return nil, err
+		}
+		results = append(results, app.SearchResult{Product: product, Score: hit.Score, Highlights: hit.Highlights})
+	}
+	return results, nil
+}
+
+// ReindexProducts rebuilds the search index from scratch
+func (p *ProductSvc) ReindexProducts(ctx context.Context) error {
+	products, err := p.repo.GetAllProducts(ctx)
+	if err != nil {
+		return err
+	}
+	categories, err := p.categories.GetAllCategories(ctx)
+	if err != nil {
+		return err
+	}
+
+	docs := make([]search.Document, 0, len(products))
+	for _, product := range products {
+		docs = append(docs, searchDocument(product, categories))
+	}
+	if err := p.index.Replace(ctx, docs); err != nil {
+		return fmt.Errorf("failed to rebuild search index: %w", err)
+	}
+	return nil
+}
+
+// indexProduct brings the search index up to date after the product changed
+func (p *ProductSvc) indexProduct(ctx context.Context, product app.Product) error {
+	categories, err := p.categories.GetAllCategories(ctx)
+	if err != nil {
+		return err
+	}
+	if err := p.index.Index(ctx, searchDocument(product, categories)); err != nil {
+		return fmt.Errorf("failed to update search index: %w", err)
+	}
+	return nil
+}
+
+// searchDocument makes the product findable by its categories and the ones above them,
+// so a search for "plushies" also finds the language mascots
+func searchDocument(product app.Product, categories app.Categories) search.Document {
+	var names []string
+	for _, categoryID := range product.CategoryIDs {
+		for _, c := range categories.Breadcrumbs(categoryID) {
+			if !slices.Contains(names, c.Name) {
+				names = append(names, c.Name)
+			}
+		}
+	}
+	return search.Document{
+		ID:          product.ID,
+		Name:        product.Name,
+		Description: product.Description,
+		Categories:  names,
+	}
+}
+
+// normalizeProduct trims the input and fills in the defaults
+func normalizeProduct(product app.Product) app.Product {
+	product.Name = strings.TrimSpace(product.Name)
+	product.Description = strings.TrimSpace(product.Description)
+	product.Image = strings.TrimSpace(product.Image)
+	product.Price = app.NewMoney(product.Price.Amount, product.Price.Currency)
+	if product.TaxClass == "" {
+		product.TaxClass = app.TaxClassStandard
+	}
+	// don't touch the caller's variants
+	variants := make([]app.Variant, len(product.Variants))
+	for i, v := range product.Variants {
+		v.SKU = strings.ToUpper(strings.TrimSpace(v.SKU))
+		v.Image = strings.TrimSpace(v.Image)
+		if v.Price != nil {
+			price := app.NewMoney(v.Price.Amount, v.Price.Currency)
+			v.Price = &price
+		}
+		variants[i] = v
+	}
+	product.Variants = variants
+	product.CategoryIDs = slices.Clone(product.CategoryIDs)
+	return product
+}
+
+// checkVariantIDs makes sure the variants that are updated belong to the product
+func checkVariantIDs(current, updated app.Product) error {
+	fe := app.FieldErrors{}
+	for i, v := range updated.Variants {
+		if v.ID == 0 {
+			continue
+		}
+		if _, err := current.Variant(v.ID); err != nil {
+			fe.Add(fmt.Sprintf("variants[%d].id", i), "%d is not a variant of this product", v.ID)
+		}
+	}
+	return fe.Err()
+}
+
+// checkCategories makes sure the categories the product is listed in exist
+func (p *ProductSvc) checkCategories(ctx context.Context, product app.Product) error {
+	fe := app.FieldErrors{}
+	for i, categoryID := range product.CategoryIDs {
+		_, err := p.categories.GetCategory(ctx, categoryID)
+		switch {
+		case errors.Is(err, app.ErrCategoryNotFound):
+			fe.Add(fmt.Sprintf("category_ids[%d]", i), "category %d doesn't exist", categoryID)
+		case err != nil:
+			return err
+		}
+	}
+	return fe.Err()
+}
@@ -0,0 +1,163 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	app "github.com/gerbenjacobs/go-webshop-course"
+	"github.com/gerbenjacobs/go-webshop-course/promotion"
+	"github.com/gerbenjacobs/go-webshop-course/storage"
+)
+
+type PromotionSvc struct {
+	repo   storage.PromotionRepository
+	orders storage.OrderRepository
+	now    func() time.Time
+}
+
+func NewPromotionService(repo storage.PromotionRepository, orders storage.OrderRepository) *PromotionSvc {
+	return &PromotionSvc{repo: repo, orders: orders, now: time.Now}
+}
+
+// ValidateCoupon returns the promotion behind the code, as long as it's active
+// and the user hasn't reached its usage limits
+func (s *PromotionSvc) ValidateCoupon(ctx context.Context, userID int, code string) (app.Promotion, error) {
+	p, err := s.repo.GetPromotionByCode(ctx, app.NormalizeCoupon(code))
+	if err != nil {
+		return app.Promotion{}, err
+	}
+	if !p.ActiveAt(s.now()) {
+		return app.Promotion{}, fmt.Errorf("%w: %s", app.ErrPromotionNotActive, p.Code)
+	}
+	if err := s.checkUsage(ctx, p, userID); err != nil {
+		return app.Promotion{}, err
+	}
+	return p, nil
+}
+
+// ApplyPromotions gives the basket the discounts of our automatic promotions
+// and of its coupon. The discounts never exceed the value of a line or the shipping fee,
+// when the coupon gives no discount, CouponError tells why.
+func (s *PromotionSvc) ApplyPromotions(ctx context.Context, view *app.BasketView) error {
+	currency := view.Subtotal.Currency
+	view.Discounts = []app.AppliedDiscount{}
+	view.Discount = app.NewMoney(0, currency)
+	view.CouponError = ""
+
+	promotions, err := s.repo.GetAutomaticPromotions(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to fetch promotions: %w", err)
+	}
+	if view.Coupon != "" {
+		coupon, err := s.ValidateCoupon(ctx, view.UserID, view.Coupon)
+		switch {
+		case isPromotionError(err):
+			view.CouponError = couponReason(err)
+		case err != nil:
+			return err
+		default:
+			promotions = append(promotions, coupon)
+		}
+	}
+
+	basket := promotion.Basket{
+		Lines:    make([]promotion.Line, len(view.Lines)),
+		Subtotal: view.Subtotal,
+		Shipping: view.Shipping,
+	}
+	remaining := make([]app.Money, len(view.Lines))
+	for i, line := range view.Lines {
+		basket.Lines[i] = promotion.Line{
+			ProductID:   line.ProductID,
+			CategoryIDs: line.CategoryIDs,
+			UnitPrice:   line.UnitPrice,
+			Quantity:    line.Quantity,
+		}
+		remaining[i] = line.LineTotal
+		view.Lines[i].Discount = app.NewMoney(0, currency)
+	}
+	shippingLeft := view.Shipping
+
+	now := s.now()
+	for _, p := range promotions {
+		if p.Code == "" {
+			if err := s.checkUsage(ctx, p, view.UserID); isPromotionError(err) {
+				continue
+			} else if err != nil {
+				return err
+			}
+		}
+
+		res, err := promotion.Evaluate(p, basket, now)
+		if err != nil {
+			if p.Code != "" {
+				view.CouponError = couponReason(err)
+			}
+			continue
+		}
+
+		// stacked promotions can't make anything cheaper than free
+		discount := app.NewMoney(0, currency)
+		for i, d := range res.LineDiscounts {
+			if d.Cmp(remaining[i]) > 0 {
+				d = remaining[i]
+			}
+			remaining[i] = remaining[i].Sub(d)
+			view.Lines[i].Discount = view.Lines[i].Discount.Add(d)
+			discount = discount.Add(d)
+		}
+		shipping := res.ShippingDiscount
+		if shipping.Cmp(shippingLeft) > 0 {
+			shipping = shippingLeft
+		}
+		shippingLeft = shippingLeft.Sub(shipping)
+		discount = discount.Add(shipping)
+
+		if discount.IsZero() {
+			continue
+		}
+		view.Discounts = append(view.Discounts, app.AppliedDiscount{
+			PromotionID: p.ID,
+			Code:        p.Code,
+			Name:        p.Name,
+			Amount:      discount,
+		})
+		view.Discount = view.Discount.Add(discount)
+	}
+	return nil
+}
+
+func (s *PromotionSvc) checkUsage(ctx context.Context, p app.Promotion, userID int) error {
+	if p.MaxUses == 0 && p.MaxUsesPerCustomer == 0 {
+		return nil
+	}
+	total, byUser, err := s.orders.CountPromotionUses(ctx, p.ID, userID)
+	if err != nil {
+		return err
+	}
+	return p.CheckUses(total, byUser)
+}
+
+// couponReason turns a promotion error into a message for the customer
+func couponReason(err error) string {
+	if errors.Is(err, app.ErrPromotionNotFound) {
+		return "unknown coupon"
+	}
+	for _, sentinel := range []error{app.ErrPromotionNotActive, app.ErrPromotionUsedUp, app.ErrPromotionNotApplicable} {
+		if errors.Is(err, sentinel) {
+			return strings.TrimPrefix(err.Error(), sentinel.Error()+": ")
+		}
+	}
+	return err.Error()
+}
+
+// isPromotionError tells whether the error explains why a promotion can't be used
+func isPromotionError(err error) bool {
+	return errors.Is(err, app.ErrPromotionNotFound) ||
+		errors.Is(err, app.ErrPromotionNotActive) ||
+		errors.Is(err, app.ErrPromotionUsedUp) ||
+		errors.Is(err, app.ErrPromotionNotApplicable)
+}
@@ -0,0 +1,239 @@
+package services_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	app "github.com/gerbenjacobs/go-webshop-course"
+	"github.com/gerbenjacobs/go-webshop-course/services"
+	"github.com/gerbenjacobs/go-webshop-course/storage"
+)
+
+// promotionRepo serves the promotions of a test
+type promotionRepo []app.Promotion
+
+func (r promotionRepo) GetPromotion(_ context.Context, promotionID int) (app.Promotion, error) {
+	for _, p := range r {
+		if p.ID == promotionID {
+			return p, nil
+		}
+	}
+	return app.Promotion{}, fmt.Errorf("%w: for ID: %d", app.ErrPromotionNotFound, promotionID)
+}
+
+func (r promotionRepo) GetPromotionByCode(_ context.Context, code string) (app.Promotion, error) {
+	for _, p := range r {
+		if p.Code != "" && p.Code == app.NormalizeCoupon(code) {
+			return p, nil
+		}
+	}
+	return app.Promotion{}, fmt.Errorf("%w: for code: %s", app.ErrPromotionNotFound, code)
+}
+
+func (r promotionRepo) GetAutomaticPromotions(_ context.Context) ([]app.Promotion, error) {
+	var automatic []app.Promotion
+	for _, p := range r {
+		if p.Code == "" {
+			automatic = append(automatic, p)
+		}
+	}
+	return automatic, nil
+}
+
+// promotionUses pretends every promotion has been used this many times, the user's
+// uses are part of the total
+type promotionUses struct {
+	storage.OrderRepository
+	total, byUser int
+}
+
+func (u promotionUses) CountPromotionUses(context.Context, int, int) (int, int, error) {
+	return u.total, u.byUser, nil
+}
+
+// newPromotionView is a basket of two Gophers and an elephant, with €4.95 shipping
+func newPromotionView(coupon string) *app.BasketView {
+	return &app.BasketView{
+		UserID: 1,
+		Lines: []app.BasketLine{
+			{ProductID: 1, Name: "Gopher plushie", UnitPrice: app.EUR(1299), Quantity: 2, LineTotal: app.EUR(2598)},
+			{ProductID: 2, Name: "PHP Elephant plushie", UnitPrice: app.EUR(2000), Quantity: 1, LineTotal: app.EUR(2000)},
+		},
+		Subtotal: app.EUR(4598),
+		Shipping: app.EUR(495),
+		Coupon:   coupon,
+	}
+}
+
+func TestApplyPromotions(t *testing.T) {
+	ctx := context.Background()
+	freeShipping := app.Promotion{ID: 1, Name: "Free shipping", Type: app.PromotionFreeShipping}
+	halfOffGophers := app.Promotion{ID: 2, Name: "Half off Gophers", Type: app.PromotionPercentage, Percentage: 50, ProductIDs: []int{1}}
+	tenOff := app.Promotion{ID: 3, Code: "TENOFF", Name: "€10 off", Type: app.PromotionFixedAmount, Amount: app.EUR(1000)}
+	gopherVoucher := app.Promotion{ID: 4, Code: "GOPHER", Name: "€20 off Gophers", Type: app.PromotionFixedAmount, Amount: app.EUR(2000), ProductIDs: []int{1}}
+	bigSpender := app.Promotion{ID: 5, Code: "BIG", Name: "€5 off from €100", Type: app.PromotionFixedAmount, Amount: app.EUR(500), MinimumSubtotal: app.EUR(10000)}
+	once := app.Promotion{ID: 6, Code: "ONCE", Name: "€1 off once", Type: app.PromotionFixedAmount, Amount: app.EUR(100), MaxUsesPerCustomer: 1}
+	expired := app.Promotion{ID: 7, Code: "OLD", Name: "Last year's sale", Type: app.PromotionPercentage, Percentage: 10, EndsAt: time.Now().Add(-time.Hour)}
+	limitedShipping := app.Promotion{ID: 8, Name: "Free shipping for the first 100", Type: app.PromotionFreeShipping, MaxUses: 100}
+
+	tests := []struct {
+		name          string
+		promotions    promotionRepo
+		uses          promotionUses
+		coupon        string
+		wantDiscounts []app.AppliedDiscount
+		wantLines     []int64
+		wantCouponErr string
+		wantTotalOff  app.Money
+	}{
+		{
+			name:       "automatic promotions apply without a coupon",
+			promotions: promotionRepo{freeShipping, halfOffGophers},
+			wantDiscounts: []app.AppliedDiscount{
+				{PromotionID: 1, Name: "Free shipping", Amount: app.EUR(495)},
+				{PromotionID: 2, Name: "Half off Gophers", Amount: app.EUR(1299)},
+			},
+			wantLines:    []int64{1299, 0},
+			wantTotalOff: app.EUR(1794),
+		},
+		{
+			name:       "the coupon stacks on the automatic promotions",
+			promotions: promotionRepo{halfOffGophers, tenOff},
+			coupon:     "tenoff",
+			wantDiscounts: []app.AppliedDiscount{
+				{PromotionID: 2, Name: "Half off Gophers", Amount: app.EUR(1299)},
+				{PromotionID: 3, Code: "TENOFF", Name: "€10 off", Amount: app.EUR(1000)},
+			},
+			// the €10 is spread by the value of the lines before any discount
+			wantLines:    []int64{1299 + 566, 434},
+			wantTotalOff: app.EUR(2299),
+		},
+		{
+			name:       "stacked promotions can't make a line cheaper than free",
+			promotions: promotionRepo{halfOffGophers, gopherVoucher},
+			coupon:     "GOPHER",
+			wantDiscounts: []app.AppliedDiscount{
+				{PromotionID: 2, Name: "Half off Gophers", Amount: app.EUR(1299)},
+				{PromotionID: 4, Code: "GOPHER", Name: "€20 off Gophers", Amount: app.EUR(1299)},
+			},
+			wantLines:    []int64{2598, 0},
+			wantTotalOff: app.EUR(2598),
+		},
+		{
+			name:       "shipping is only waived once",
+			promotions: promotionRepo{freeShipping, app.Promotion{ID: 9, Name: "Free shipping again", Type: app.PromotionFreeShipping}},
+			wantDiscounts: []app.AppliedDiscount{
+				{PromotionID: 1, Name: "Free shipping", Amount: app.EUR(495)},
+			},
+			wantLines:    []int64{0, 0},
+			wantTotalOff: app.EUR(495),
+		},
+		{
+			name:          "an unknown coupon",
+			promotions:    promotionRepo{tenOff},
+			coupon:        "NOPE",
+			wantDiscounts: []app.AppliedDiscount{},
+			wantLines:     []int64{0, 0},
+			wantCouponErr: "unknown coupon",
+			wantTotalOff:  app.EUR(0),
+		},
+		{
+			name:          "a coupon below its minimum subtotal",
+			promotions:    promotionRepo{bigSpender},
+			coupon:        "BIG",
+			wantDiscounts: []app.AppliedDiscount{},
+			wantLines:     []int64{0, 0},
+			wantCouponErr: "spend at least €100.00 for €5 off from €100",
+			wantTotalOff:  app.EUR(0),
+		},
+		{
+			name:          "a coupon the customer used up",
+			promotions:    promotionRepo{once},
+			uses:          promotionUses{total: 3, byUser: 1},
+			coupon:        "ONCE",
+			wantDiscounts: []app.AppliedDiscount{},
+			wantLines:     []int64{0, 0},
+			wantCouponErr: "€1 off once can only be used 1 time(s) per customer",
+			wantTotalOff:  app.EUR(0),
+		},
+		{
+			name:          "an expired coupon",
+			promotions:    promotionRepo{expired},
+			coupon:        "OLD",
+			wantDiscounts: []app.AppliedDiscount{},
+			wantLines:     []int64{0, 0},
+			wantCouponErr: "OLD",
+			wantTotalOff:  app.EUR(0),
+		},
+		{
+			name:          "used up automatic promotions are skipped",
+			promotions:    promotionRepo{limitedShipping},
+			uses:          promotionUses{total: 100},
+			wantDiscounts: []app.AppliedDiscount{},
+			wantLines:     []int64{0, 0},
+			wantTotalOff:  app.EUR(0),
+		},
+		{
+			name:       "automatic promotions within their limit apply",
+			promotions: promotionRepo{limitedShipping},
+			uses:       promotionUses{total: 99},
+			wantDiscounts: []app.AppliedDiscount{
+				{PromotionID: 8, Name: "Free shipping for the first 100", Amount: app.EUR(495)},
+			},
+			wantLines:    []int64{0, 0},
+			wantTotalOff: app.EUR(495),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc := services.NewPromotionService(tt.promotions, tt.uses)
+			view := newPromotionView(tt.coupon)
+			if err := svc.ApplyPromotions(ctx, view); err != nil {
+				t.Fatalf("ApplyPromotions: %v", err)
+			}
+
+			if !reflect.DeepEqual(view.Discounts, tt.wantDiscounts) {
+				t.Errorf("Discounts = %+v, want %+v", view.Discounts, tt.wantDiscounts)
+			}
+			if view.Discount != tt.wantTotalOff {
+				t.Errorf("Discount = %v, want %v", view.Discount, tt.wantTotalOff)
+			}
+			got := make([]int64, len(view.Lines))
+			for i, line := range view.Lines {
+				got[i] = line.Discount.Amount
+			}
+			if !reflect.DeepEqual(got, tt.wantLines) {
+				t.Errorf("line discounts = %v, want %v", got, tt.wantLines)
+			}
+			if view.CouponError != tt.wantCouponErr {
+				t.Errorf("CouponError = %q, want %q", view.CouponError, tt.wantCouponErr)
+			}
+		})
+	}
+}
+
+func TestValidateCoupon(t *testing.T) {
+	ctx := context.Background()
+	svc := services.NewPromotionService(promotionRepo{
+		{ID: 1, Code: "WELCOME10", Name: "10% off", Type: app.PromotionPercentage, Percentage: 10, MaxUsesPerCustomer: 1},
+		{ID: 2, Code: "SOON", Name: "Coming soon", Type: app.PromotionPercentage, Percentage: 10, StartsAt: time.Now().Add(time.Hour)},
+		{ID: 3, Code: "FIRST100", Name: "For the first 100", Type: app.PromotionPercentage, Percentage: 10, MaxUses: 100},
+	}, promotionUses{total: 100})
+
+	if p, err := svc.ValidateCoupon(ctx, 1, " welcome10 "); err != nil || p.ID != 1 {
+		t.Errorf("ValidateCoupon of a coupon in lower case = %d, %v, want promotion 1", p.ID, err)
+	}
+	for code, want := range map[string]error{
+		"NOPE":     app.ErrPromotionNotFound,
+		"SOON":     app.ErrPromotionNotActive,
+		"FIRST100": app.ErrPromotionUsedUp,
+	} {
+		if _, err := svc.ValidateCoupon(ctx, 1, code); !errors.Is(err, want) {
+			t.Errorf("ValidateCoupon(%s) error = %v, want %v", code, err, want)
+		}
+	}
+}
@@ -4,15 +4,88 @@ import (
 	"context"
 
 	app "github.com/gerbenjacobs/go-webshop-course"
+	"github.com/gerbenjacobs/go-webshop-course/payment"
 )
 
 type ProductService interface {
-	ListProducts(context.Context) ([]app.Product, error)
+	// ListProducts fails with FieldErrors when the query isn't valid
+	ListProducts(ctx context.Context, query app.ProductQuery) (app.ProductPage, error)
 	ShowProduct(context.Context, int) (app.Product, error)
+	// CreateProduct, UpdateProduct and PatchProduct fail with FieldErrors when the product isn't valid
+	CreateProduct(ctx context.Context, product app.Product) (app.Product, error)
+	UpdateProduct(ctx context.Context, product app.Product) (app.Product, error)
+	PatchProduct(ctx context.Context, productID int, patch app.ProductPatch) (app.Product, error)
+	DeleteProduct(ctx context.Context, productID int) error
+	SearchProducts(ctx context.Context, query string) ([]app.SearchResult, error)
+	// ReindexProducts rebuilds the search index, for when the categories changed
+	ReindexProducts(ctx context.Context) error
+}
+
+type CatalogService interface {
+	CategoryTree(ctx context.Context) ([]app.CategoryNode, error)
+	ShowCategory(ctx context.Context, slug string) (app.CategoryPage, error)
+	ListCollections(ctx context.Context) ([]app.Collection, error)
+	ShowCollection(ctx context.Context, slug string) (app.CollectionPage, error)
+	// CreateCategory, UpdateCategory, CreateCollection and UpdateCollection fail with FieldErrors when the input isn't valid
+	CreateCategory(ctx context.Context, category app.Category) (app.Category, error)
+	UpdateCategory(ctx context.Context, category app.Category) (app.Category, error)
+	DeleteCategory(ctx context.Context, categoryID int) error
+	CreateCollection(ctx context.Context, collection app.Collection) (app.Collection, error)
+	UpdateCollection(ctx context.Context, collection app.Collection) (app.Collection, error)
+	DeleteCollection(ctx context.Context, collectionID int) error
 }
 
 type BasketService interface {
 	GetBasket(ctx context.Context, userID int) (app.Basket, error)
-	AddToBasket(ctx context.Context, userID, productID, quantity int) error
-	RemoveFromBasket(ctx context.Context, userID, productID, quantity int) error
+	GetBasketView(ctx context.Context, userID int, country string) (app.BasketView, error)
+	// EmptyBasketView is the view of a basket that doesn't exist yet, it doesn't create one
+	EmptyBasketView(ctx context.Context, country string) (app.BasketView, error)
+	// AddToBasket, RemoveFromBasket and SetQuantity take a variantID of 0 for products without variants
+	AddToBasket(ctx context.Context, userID, productID, variantID, quantity int) error
+	RemoveFromBasket(ctx context.Context, userID, productID, variantID, quantity int) error
+	SetQuantity(ctx context.Context, userID, productID, variantID, quantity int) error
+	// UpdateItem and RemoveItem only work on lines that are in the basket, others give ErrItemNotInBasket
+	UpdateItem(ctx context.Context, userID, productID, variantID, quantity int) error
+	RemoveItem(ctx context.Context, userID, productID, variantID int) error
+	ClearBasket(ctx context.Context, userID int) error
+	// PruneBasket takes the products and variants that no longer exist out of the basket, GetBasketView leaves them out
+	PruneBasket(ctx context.Context, userID int) error
+	MergeBaskets(ctx context.Context, guestID, userID int) error
+	// ApplyCoupon returns the basket view with the coupon's discount
+	ApplyCoupon(ctx context.Context, userID int, code string) (app.BasketView, error)
+	RemoveCoupon(ctx context.Context, userID int) error
+	// DeleteExpiredGuestBaskets removes the baskets of guests whose cookie has expired
+	DeleteExpiredGuestBaskets(ctx context.Context) (int, error)
+}
+
+type PromotionService interface {
+	ValidateCoupon(ctx context.Context, userID int, code string) (app.Promotion, error)
+	ApplyPromotions(ctx context.Context, view *app.BasketView) error
+}
+
+type UserService interface {
+	Signup(ctx context.Context, name, email, password string) (app.User, error)
+	Login(ctx context.Context, email, password string) (app.User, error)
+	GetUser(ctx context.Context, userID int) (app.User, error)
+	ChangePassword(ctx context.Context, userID int, currentPassword, newPassword string) error
+	// FindUsers looks up customers by (part of) their name or email, for admins
+	FindUsers(ctx context.Context, query string) ([]app.User, error)
+}
+
+type AuthService interface {
+	IssueTokens(ctx context.Context, email, password string) (app.TokenPair, error)
+	RefreshTokens(ctx context.Context, refreshToken string) (app.TokenPair, error)
+	Authenticate(ctx context.Context, accessToken string) (userID int, err error)
+}
+
+type OrderService interface {
+	Checkout(ctx context.Context, userID int, country string) (app.Order, error)
+	GetOrder(ctx context.Context, userID, orderID int) (app.Order, error)
+	ListOrders(ctx context.Context, userID int) ([]app.Order, error)
+	// ListAllOrders and ShowOrder are for admins, they return the orders of every user
+	ListAllOrders(ctx context.Context, status app.OrderStatus) ([]app.Order, error)
+	ShowOrder(ctx context.Context, orderID int) (app.Order, error)
+	Pay(ctx context.Context, userID, orderID int, method, returnURL string) (payment.Authorization, error)
+	HandlePaymentEvent(ctx context.Context, event payment.Event) error
+	RefundOrder(ctx context.Context, orderID int) error
 }
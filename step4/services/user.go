@@ -0,0 +1,155 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/mail"
+	"strings"
+	"time"
+
+	app "github.com/gerbenjacobs/go-webshop-course"
+	"github.com/gerbenjacobs/go-webshop-course/storage"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	minPasswordLength = 8
+	// maxPasswordLength is all bcrypt can hash, it refuses longer passwords
+	maxPasswordLength = 72
+	// dummyPasswordHash is what Login compares against for unknown emails, so they take as long
+	// as a wrong password and the response time doesn't give away which emails have an account
+	dummyPasswordHash = "$2a$10$SCpAqOIQG4RovpQzPOQWa.BEsRPWQ8n1xh/OcTP0TnYjS232WJC7S"
+)
+
+type UserSvc struct {
+	repo storage.UserRepository
+	// admins are the email addresses of the accounts AppointAdmins gives the admin role
+	admins []string
+}
+
+func NewUserService(repo storage.UserRepository, admins []string) *UserSvc {
+	normalized := make([]string, 0, len(admins))
+	for _, email := range admins {
+		if email = strings.ToLower(strings.TrimSpace(email)); email != "" {
+			normalized = append(normalized, email)
+		}
+	}
+	return &UserSvc{repo: repo, admins: normalized}
+}
+
+func (u *UserSvc) Signup(ctx context.Context, name, email, password string) (app.User, error) {
+	addr, err := mail.ParseAddress(strings.TrimSpace(email))
+	if err != nil {
+		return app.User{}, fmt.Errorf("%w: %s", app.ErrInvalidEmail, email)
+	}
+	// only the address itself, ParseAddress also takes "Gopher <gopher@example.com>"
+	email = strings.ToLower(addr.Address)
+	if err := validatePassword("password", password); err != nil {
+		return app.User{}, err
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return app.User{}, fmt.Errorf("failed to hash password: %w", err)
+	}
+
+	name = strings.TrimSpace(name)
+	if name == "" {
+		name, _, _ = strings.Cut(email, "@")
+	}
+
+	// everyone starts as a customer, even with an admin's email address: we never checked
+	// that the address belongs to whoever signs up, only AppointAdmins hands out the admin role
+	return u.repo.CreateUser(ctx, app.User{
+		Name:         name,
+		Email:        email,
+		PasswordHash: string(hash),
+		Role:         app.RoleCustomer,
+		CreatedAt:    time.Now().UTC(),
+	})
+}
+
+// AppointAdmins gives the admin role to the admins that already have an account.
+// It returns the email addresses without an account, they need to sign up first
+// and are appointed the next time this runs.
+func (u *UserSvc) AppointAdmins(ctx context.Context) ([]string, error) {
+	var missing []string
+	for _, email := range u.admins {
+		user, err := u.repo.GetUserByEmail(ctx, email)
+		switch {
+		case errors.Is(err, app.ErrUserNotFound):
+			missing = append(missing, email)
+			continue
+		case err != nil:
+			return nil, err
+		}
+		if user.IsAdmin() {
+			continue
+		}
+		user.Role = app.RoleAdmin
+		if err := u.repo.UpdateUser(ctx, user); err != nil {
+			return nil, err
+		}
+	}
+	return missing, nil
+}
+
+func (u *UserSvc) Login(ctx context.Context, email, password string) (app.User, error) {
+	email = strings.ToLower(strings.TrimSpace(email))
+	user, err := u.repo.GetUserByEmail(ctx, email)
+	switch {
+	case errors.Is(err, app.ErrUserNotFound):
+		// don't tell the caller whether the email or the password was wrong, not even by taking less time
+		bcrypt.CompareHashAndPassword([]byte(dummyPasswordHash), []byte(password))
+		return app.User{}, app.ErrInvalidCredentials
+	case err != nil:
+		return app.User{}, err
+	}
+
+	if err := bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(password)); err != nil {
+		return app.User{}, app.ErrInvalidCredentials
+	}
+	return user, nil
+}
+
+func (u *UserSvc) GetUser(ctx context.Context, userID int) (app.User, error) {
+	return u.repo.GetUser(ctx, userID)
+}
+
+func (u *UserSvc) ChangePassword(ctx context.Context, userID int, currentPassword, newPassword string) error {
+	user, err := u.repo.GetUser(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if err := bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(currentPassword)); err != nil {
+		return app.ErrInvalidCredentials
+	}
+	if err := validatePassword("new_password", newPassword); err != nil {
+		return err
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(newPassword), bcrypt.DefaultCost)
+	if err != nil {
+		return fmt.Errorf("failed to hash password: %w", err)
+	}
+	user.PasswordHash = string(hash)
+	return u.repo.UpdateUser(ctx, user)
+}
+
+// validatePassword makes sure bcrypt can hash the password, field is the name of its input
+func validatePassword(field, password string) error {
+	if len(password) < minPasswordLength {
+		return app.ErrPasswordTooShort
+	}
+	if len(password) > maxPasswordLength {
+		fe := app.FieldErrors{}
+		fe.Add(field, "can't be longer than %d bytes", maxPasswordLength)
+		return fe.Err()
+	}
+	return nil
+}
+
+func (u *UserSvc) FindUsers(ctx context.Context, query string) ([]app.User, error) {
+	return u.repo.SearchUsers(ctx, strings.TrimSpace(query))
+}
@@ -2,56 +2,226 @@ package storage
 
 import (
 	"context"
+	"slices"
+	"sync"
+	"time"
 
 	app "github.com/gerbenjacobs/go-webshop-course"
 )
 
+// BasketRepo is safe for concurrent use, baskets handed out
+// by GetBasket are copies and can be changed freely
 type BasketRepo struct {
+	mu      sync.Mutex
 	baskets map[int]app.Basket
+	// created is when each basket was created, to clean up the ones of guests
+	created map[int]time.Time
 }
 
 func NewBasketRepo() *BasketRepo {
 	return &BasketRepo{
 		baskets: make(map[int]app.Basket),
+		created: make(map[int]time.Time),
 	}
 }
 
 func (r *BasketRepo) GetBasket(ctx context.Context, userID int) (app.Basket, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
 	basket, ok := r.baskets[userID]
 	if !ok {
 		basket = app.Basket{UserID: userID, Items: []app.BasketItem{}}
 		r.baskets[userID] = basket
-		return basket, nil
+		r.created[userID] = time.Now()
 	}
+	// copy our items, so callers can't change our basket behind our back
+	basket.Items = slices.Clone(basket.Items)
 	return basket, nil
 }
 
-func (r *BasketRepo) AddToBasket(ctx context.Context, userID, productID, quantity int) error {
+func (r *BasketRepo) AddToBasket(ctx context.Context, userID, productID, variantID, quantity int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.add(userID, productID, variantID, quantity)
+}
+
+func (r *BasketRepo) RemoveFromBasket(ctx context.Context, userID, productID, variantID, quantity int) error {
+	if quantity <= 0 {
+		return app.ErrInvalidQuantity
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
 	basket, ok := r.baskets[userID]
 	if !ok {
 		return app.ErrBasketNotFound
 	}
+	for i, item := range basket.Items {
+		if item.ProductID == productID && item.VariantID == variantID {
+			if item.Quantity > quantity {
+				basket.Items[i].Quantity -= quantity
+				return nil
+			}
+			basket.Items = slices.Delete(basket.Items, i, i+1)
+			r.baskets[userID] = basket
+			return nil
+		}
+	}
+	// we have not found the product, but in essence that's the same as removing it
+	// so we don't return an error here
+	return nil
+}
+
+func (r *BasketRepo) SetQuantity(ctx context.Context, userID, productID, variantID, quantity int) error {
+	if quantity < 0 {
+		return app.ErrInvalidQuantity
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	basket, ok := r.baskets[userID]
+	if !ok {
+		return app.ErrBasketNotFound
+	}
+	for i, item := range basket.Items {
+		if item.ProductID == productID && item.VariantID == variantID {
+			if quantity > 0 {
+				basket.Items[i].Quantity = quantity
+				return nil
+			}
+			basket.Items = slices.Delete(basket.Items, i, i+1)
+			r.baskets[userID] = basket
+			return nil
+		}
+	}
+	if quantity > 0 {
+		basket.Items = append(basket.Items, app.BasketItem{
+			ProductID: productID,
+			VariantID: variantID,
+			Quantity:  quantity,
+		})
+		r.baskets[userID] = basket
+	}
+	return nil
+}
+
+func (r *BasketRepo) MergeBaskets(ctx context.Context, fromUserID, toUserID int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	from, ok := r.baskets[fromUserID]
+	if !ok {
+		// nothing to merge
+		return nil
+	}
+	if _, ok := r.baskets[toUserID]; !ok {
+		r.baskets[toUserID] = app.Basket{UserID: toUserID, Items: []app.BasketItem{}}
+		r.created[toUserID] = time.Now()
+	}
+	for _, item := range from.Items {
+		if err := r.add(toUserID, item.ProductID, item.VariantID, item.Quantity); err != nil {
+			return err
+		}
+	}
+	// both baskets can be full, the merged lines still can't hold more than the maximum
+	for i, item := range r.baskets[toUserID].Items {
+		r.baskets[toUserID].Items[i].Quantity = min(item.Quantity, app.MaxLineQuantity)
+	}
+	// the coupon of the guest is only used if the user didn't enter one yet
+	if to := r.baskets[toUserID]; to.Coupon == "" {
+		to.Coupon = from.Coupon
+		r.baskets[toUserID] = to
+	}
+	delete(r.baskets, fromUserID)
+	delete(r.created, fromUserID)
+	return nil
+}
+
+func (r *BasketRepo) SetCoupon(ctx context.Context, userID int, code string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	basket, ok := r.baskets[userID]
+	if !ok {
+		return app.ErrBasketNotFound
+	}
+	basket.Coupon = code
+	r.baskets[userID] = basket
+	return nil
+}
+
+func (r *BasketRepo) ClearBasket(ctx context.Context, userID int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	basket, ok := r.baskets[userID]
+	if !ok {
+		return app.ErrBasketNotFound
+	}
+	basket.Items = []app.BasketItem{}
+	basket.Coupon = ""
+	r.baskets[userID] = basket
+	return nil
+}
+
+func (r *BasketRepo) DeleteGuestBaskets(ctx context.Context, createdBefore time.Time) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var deleted int
+	for userID, created := range r.created {
+		if app.IsGuest(userID) && created.Before(createdBefore) {
+			delete(r.baskets, userID)
+			delete(r.created, userID)
+			deleted++
+		}
+	}
+	return deleted, nil
+}
+
+// add does the work for AddToBasket, the caller needs to hold the lock
+func (r *BasketRepo) add(userID, productID, variantID, quantity int) error {
+	if quantity <= 0 {
+		return app.ErrInvalidQuantity
+	}
+	basket, ok := r.baskets[userID]
+	if !ok {
+		return app.ErrBasketNotFound
+	}
+	for i, item := range basket.Items {
+		if item.ProductID == productID && item.VariantID == variantID {
+			basket.Items[i].Quantity += quantity
+			return nil
+		}
+	}
 	basket.Items = append(basket.Items, app.BasketItem{
 		ProductID: productID,
+		VariantID: variantID,
 		Quantity:  quantity,
 	})
 	r.baskets[userID] = basket
 	return nil
 }
 
-func (r *BasketRepo) RemoveFromBasket(ctx context.Context, userID, productID, quantity int) error {
+// checkout empties the basket, but only if it still holds exactly the ordered items.
+// It's used by OrderRepo to turn a basket into an order in one go.
+func (r *BasketRepo) checkout(userID int, ordered []app.OrderItem) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
 	basket, ok := r.baskets[userID]
 	if !ok {
 		return app.ErrBasketNotFound
 	}
-	for i, item := range basket.Items {
-		if item.ProductID == productID {
-			basket.Items = append(basket.Items[:i], basket.Items[i+1:]...)
-			r.baskets[userID] = basket
-			return nil
-		}
+	if !basketMatchesOrder(basket.Items, ordered) {
+		return app.ErrBasketChanged
 	}
-	// we have not found the product, but in essence that's the same as removing it
-	// so we don't return an error here
+	basket.Items = []app.BasketItem{}
+	basket.Coupon = ""
+	r.baskets[userID] = basket
 	return nil
 }
@@ -0,0 +1,18 @@
+package storage_test
+
+import (
+	"testing"
+
+	"github.com/gerbenjacobs/go-webshop-course/storage"
+	"github.com/gerbenjacobs/go-webshop-course/storage/storagetest"
+)
+
+func TestBasketRepo(t *testing.T) {
+	storagetest.TestBasketRepository(t, func(t *testing.T) storage.BasketRepository {
+		return storage.NewBasketRepo()
+	})
+}
+
+func TestBasketRepoStress(t *testing.T) {
+	storagetest.StressBasketRepository(t, storage.NewBasketRepo())
+}
@@ -0,0 +1,124 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sync"
+
+	app "github.com/gerbenjacobs/go-webshop-course"
+)
+
+type CategoryRepo struct {
+	mu         sync.RWMutex
+	categories map[int]app.Category
+	nextID     int
+	products   *ProductRepo
+}
+
+// NewCategoryRepo needs the product repository, since deleting a category
+// takes it off the products that are listed in it
+func NewCategoryRepo(products *ProductRepo) *CategoryRepo {
+	return &CategoryRepo{
+		nextID:   3,
+		products: products,
+		categories: map[int]app.Category{
+			1: {ID: 1, Slug: "plushies", Name: "Plushies", Description: "Soft toys for developers of all ages."},
+			2: {ID: 2, ParentID: 1, Slug: "mascots", Name: "Language mascots", Description: "The mascots of your favourite programming languages."},
+		},
+	}
+}
+
+func (r *CategoryRepo) GetAllCategories(_ context.Context) ([]app.Category, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	categories := make([]app.Category, 0, len(r.categories))
+	for _, c := range r.categories {
+		categories = append(categories, c)
+	}
+	slices.SortFunc(categories, func(a, b app.Category) int {
+		return a.ID - b.ID
+	})
+	return categories, nil
+}
+
+func (r *CategoryRepo) GetCategory(_ context.Context, categoryID int) (app.Category, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	c, ok := r.categories[categoryID]
+	if !ok {
+		return app.Category{}, fmt.Errorf("%w: for ID: %d", app.ErrCategoryNotFound, categoryID)
+	}
+	return c, nil
+}
+
+func (r *CategoryRepo) GetCategoryBySlug(_ context.Context, slug string) (app.Category, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, c := range r.categories {
+		if c.Slug == slug {
+			return c, nil
+		}
+	}
+	return app.Category{}, fmt.Errorf("%w: for slug: %s", app.ErrCategoryNotFound, slug)
+}
+
+func (r *CategoryRepo) CreateCategory(_ context.Context, category app.Category) (app.Category, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	category.ID = r.nextID
+	if err := r.store(category); err != nil {
+		return app.Category{}, err
+	}
+	r.nextID++
+	return category, nil
+}
+
+func (r *CategoryRepo) UpdateCategory(_ context.Context, category app.Category) (app.Category, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.categories[category.ID]; !ok {
+		return app.Category{}, fmt.Errorf("%w: for ID: %d", app.ErrCategoryNotFound, category.ID)
+	}
+	if err := r.store(category); err != nil {
+		return app.Category{}, err
+	}
+	return category, nil
+}
+
+func (r *CategoryRepo) DeleteCategory(_ context.Context, categoryID int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.categories[categoryID]; !ok {
+		return fmt.Errorf("%w: for ID: %d", app.ErrCategoryNotFound, categoryID)
+	}
+	for _, c := range r.categories {
+		if c.ParentID == categoryID {
+			return fmt.Errorf("%w: for ID: %d", app.ErrCategoryHasChildren, categoryID)
+		}
+	}
+	delete(r.categories, categoryID)
+	r.products.removeCategory(categoryID)
+	return nil
+}
+
+// store saves the category after checking its slug is free and its parent exists,
+// the caller needs to hold the lock
+func (r *CategoryRepo) store(category app.Category) error {
+	for _, other := range r.categories {
+		if other.ID != category.ID && other.Slug == category.Slug {
+			return fmt.Errorf("%w: %s", app.ErrSlugExists, category.Slug)
+		}
+	}
+	if _, ok := r.categories[category.ParentID]; category.ParentID != 0 && !ok {
+		return fmt.Errorf("%w: for ID: %d", app.ErrCategoryNotFound, category.ParentID)
+	}
+	r.categories[category.ID] = category
+	return nil
+}
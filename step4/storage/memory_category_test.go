@@ -0,0 +1,18 @@
+package storage_test
+
+import (
+	"testing"
+
+	"github.com/gerbenjacobs/go-webshop-course/storage"
+	"github.com/gerbenjacobs/go-webshop-course/storage/storagetest"
+)
+
+func TestCategoryRepo(t *testing.T) {
+	storagetest.TestCategoryRepository(t, func(t *testing.T) storagetest.CategoryRepos {
+		products := storage.NewProductRepo()
+		return storagetest.CategoryRepos{
+			Categories: storage.NewCategoryRepo(products),
+			Products:   products,
+		}
+	})
+}
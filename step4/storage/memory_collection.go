@@ -0,0 +1,113 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sync"
+
+	app "github.com/gerbenjacobs/go-webshop-course"
+)
+
+type CollectionRepo struct {
+	mu          sync.RWMutex
+	collections map[int]app.Collection
+	nextID      int
+}
+
+func NewCollectionRepo() *CollectionRepo {
+	return &CollectionRepo{
+		nextID: 2,
+		collections: map[int]app.Collection{
+			1: {ID: 1, Slug: "staff-picks", Name: "Staff picks", Description: "The plushies on our own desks.", ProductIDs: []int{2, 1}},
+		},
+	}
+}
+
+func (r *CollectionRepo) GetAllCollections(_ context.Context) ([]app.Collection, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	collections := make([]app.Collection, 0, len(r.collections))
+	for _, c := range r.collections {
+		c.ProductIDs = slices.Clone(c.ProductIDs)
+		collections = append(collections, c)
+	}
+	slices.SortFunc(collections, func(a, b app.Collection) int {
+		return a.ID - b.ID
+	})
+	return collections, nil
+}
+
+func (r *CollectionRepo) GetCollection(_ context.Context, collectionID int) (app.Collection, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	c, ok := r.collections[collectionID]
+	if !ok {
+		return app.Collection{}, fmt.Errorf("%w: for ID: %d", app.ErrCollectionNotFound, collectionID)
+	}
+	c.ProductIDs = slices.Clone(c.ProductIDs)
+	return c, nil
+}
+
+func (r *CollectionRepo) GetCollectionBySlug(_ context.Context, slug string) (app.Collection, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, c := range r.collections {
+		if c.Slug == slug {
+			c.ProductIDs = slices.Clone(c.ProductIDs)
+			return c, nil
+		}
+	}
+	return app.Collection{}, fmt.Errorf("%w: for slug: %s", app.ErrCollectionNotFound, slug)
+}
+
+func (r *CollectionRepo) CreateCollection(_ context.Context, collection app.Collection) (app.Collection, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	collection.ID = r.nextID
+	if err := r.store(collection); err != nil {
+		return app.Collection{}, err
+	}
+	r.nextID++
+	return collection, nil
+}
+
+func (r *CollectionRepo) UpdateCollection(_ context.Context, collection app.Collection) (app.Collection, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.collections[collection.ID]; !ok {
+		return app.Collection{}, fmt.Errorf("%w: for ID: %d", app.ErrCollectionNotFound, collection.ID)
+	}
+	if err := r.store(collection); err != nil {
+		return app.Collection{}, err
+	}
+	return collection, nil
+}
+
+func (r *CollectionRepo) DeleteCollection(_ context.Context, collectionID int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.collections[collectionID]; !ok {
+		return fmt.Errorf("%w: for ID: %d", app.ErrCollectionNotFound, collectionID)
+	}
+	delete(r.collections, collectionID)
+	return nil
+}
+
+// store saves a copy of the collection after checking its slug is free, the caller needs to hold the lock
+func (r *CollectionRepo) store(collection app.Collection) error {
+	for _, other := range r.collections {
+		if other.ID != collection.ID && other.Slug == collection.Slug {
+			return fmt.Errorf("%w: %s", app.ErrSlugExists, collection.Slug)
+		}
+	}
+	collection.ProductIDs = slices.Clone(collection.ProductIDs)
+	r.collections[collection.ID] = collection
+	return nil
+}
@@ -0,0 +1,193 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	app "github.com/gerbenjacobs/go-webshop-course"
+)
+
+type OrderRepo struct {
+	mu         sync.RWMutex
+	orders     map[int]app.Order
+	nextID     int
+	baskets    *BasketRepo
+	products   *ProductRepo
+	promotions *PromotionRepo
+}
+
+// NewOrderRepo needs the basket, product and promotion repositories, since creating an order
+// also empties the basket it was created from, reserves the stock of its items and checks
+// that its promotions aren't used up
+func NewOrderRepo(baskets *BasketRepo, products *ProductRepo, promotions *PromotionRepo) *OrderRepo {
+	return &OrderRepo{
+		orders:     make(map[int]app.Order),
+		nextID:     1,
+		baskets:    baskets,
+		products:   products,
+		promotions: promotions,
+	}
+}
+
+func (r *OrderRepo) CreateOrder(ctx context.Context, order app.Order) (app.Order, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	// the basket's promotions were checked before, but other orders may have used them up since
+	if err := r.checkPromotionUses(ctx, order); err != nil {
+		return app.Order{}, err
+	}
+	if err := r.products.reserve(r.nextID, order.Items, order.ReservedUntil); err != nil {
+		return app.Order{}, err
+	}
+	if err := r.baskets.checkout(order.UserID, order.Items); err != nil {
+		r.products.release(r.nextID)
+		return app.Order{}, err
+	}
+
+	order.ID = r.nextID
+	order.Items = slices.Clone(order.Items)
+	order.Taxes = slices.Clone(order.Taxes)
+	order.Discounts = slices.Clone(order.Discounts)
+	r.nextID++
+	r.orders[order.ID] = order
+	return order, nil
+}
+
+func (r *OrderRepo) GetOrder(_ context.Context, orderID int) (app.Order, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	order, ok := r.orders[orderID]
+	if !ok {
+		return app.Order{}, fmt.Errorf("%w: for ID: %d", app.ErrOrderNotFound, orderID)
+	}
+	order.Items = slices.Clone(order.Items)
+	order.Taxes = slices.Clone(order.Taxes)
+	order.Discounts = slices.Clone(order.Discounts)
+	return order, nil
+}
+
+func (r *OrderRepo) GetOrdersByUser(_ context.Context, userID int) ([]app.Order, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	orders := []app.Order{}
+	for _, order := range r.orders {
+		if order.UserID == userID {
+			order.Items = slices.Clone(order.Items)
+			order.Taxes = slices.Clone(order.Taxes)
+			order.Discounts = slices.Clone(order.Discounts)
+			orders = append(orders, order)
+		}
+	}
+	// newest first
+	slices.SortFunc(orders, func(a, b app.Order) int {
+		return b.ID - a.ID
+	})
+	return orders, nil
+}
+
+func (r *OrderRepo) GetAllOrders(_ context.Context, status app.OrderStatus) ([]app.Order, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	orders := []app.Order{}
+	for _, order := range r.orders {
+		if status == "" || order.Status == status {
+			order.Items = slices.Clone(order.Items)
+			order.Taxes = slices.Clone(order.Taxes)
+			order.Discounts = slices.Clone(order.Discounts)
+			orders = append(orders, order)
+		}
+	}
+	// newest first
+	slices.SortFunc(orders, func(a, b app.Order) int {
+		return b.ID - a.ID
+	})
+	return orders, nil
+}
+
+func (r *OrderRepo) UpdateOrder(ctx context.Context, order app.Order, from app.OrderStatus) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	existing, ok := r.orders[order.ID]
+	if !ok {
+		return fmt.Errorf("%w: for ID: %d", app.ErrOrderNotFound, order.ID)
+	}
+	if existing.Status != from {
+		return fmt.Errorf("%w: order %d is %s, not %s", app.ErrOrderChanged, order.ID, existing.Status, from)
+	}
+	if order.Status == app.OrderStatusPaid && existing.Status.Payable() {
+		// only paid orders count as uses, so pending orders with the same promotion can't all be paid
+		if err := r.checkPromotionUses(ctx, existing); err != nil {
+			return err
+		}
+		r.products.commit(existing.ID, existing.Items)
+	}
+	existing.Status = order.Status
+	existing.PaymentID = order.PaymentID
+	r.orders[order.ID] = existing
+	return nil
+}
+
+func (r *OrderRepo) ReserveStock(_ context.Context, orderID int, until time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	order, ok := r.orders[orderID]
+	if !ok {
+		return fmt.Errorf("%w: for ID: %d", app.ErrOrderNotFound, orderID)
+	}
+	if err := r.products.reserve(order.ID, order.Items, until); err != nil {
+		return err
+	}
+	order.ReservedUntil = until
+	r.orders[order.ID] = order
+	return nil
+}
+
+func (r *OrderRepo) CountPromotionUses(_ context.Context, promotionID, userID int) (total, byUser int, err error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	total, byUser = r.promotionUses(promotionID, userID)
+	return total, byUser, nil
+}
+
+// promotionUses counts the paid orders with a discount of the promotion, r.mu has to be held
+func (r *OrderRepo) promotionUses(promotionID, userID int) (total, byUser int) {
+	for _, order := range r.orders {
+		if order.Status != app.OrderStatusPaid {
+			continue
+		}
+		for _, d := range order.Discounts {
+			if d.PromotionID != promotionID {
+				continue
+			}
+			total++
+			if order.UserID == userID {
+				byUser++
+			}
+		}
+	}
+	return total, byUser
+}
+
+// checkPromotionUses makes sure the order's promotions can be used once more by its user, r.mu has to be held
+func (r *OrderRepo) checkPromotionUses(ctx context.Context, order app.Order) error {
+	for _, d := range order.Discounts {
+		p, err := r.promotions.GetPromotion(ctx, d.PromotionID)
+		if err != nil {
+			return err
+		}
+		if err := p.CheckUses(r.promotionUses(p.ID, order.UserID)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
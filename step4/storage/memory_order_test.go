@@ -0,0 +1,19 @@
+package storage_test
+
+import (
+	"testing"
+
+	"github.com/gerbenjacobs/go-webshop-course/storage"
+	"github.com/gerbenjacobs/go-webshop-course/storage/storagetest"
+)
+
+func TestOrderRepo(t *testing.T) {
+	storagetest.TestOrderRepository(t, func(t *testing.T) storagetest.OrderRepos {
+		baskets, products := storage.NewBasketRepo(), storage.NewProductRepo()
+		return storagetest.OrderRepos{
+			Orders:   storage.NewOrderRepo(baskets, products, storage.NewPromotionRepo()),
+			Baskets:  baskets,
+			Products: products,
+		}
+	})
+}
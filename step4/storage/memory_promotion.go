@@ -0,0 +1,100 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+
+	app "github.com/gerbenjacobs/go-webshop-course"
+)
+
+// PromotionRepo is safe for concurrent use, it comes with the same promotions as our SQLite seed
+type PromotionRepo struct {
+	mu         sync.RWMutex
+	promotions map[int]app.Promotion
+}
+
+func NewPromotionRepo() *PromotionRepo {
+	return &PromotionRepo{
+		promotions: map[int]app.Promotion{
+			1: {
+				ID:                 1,
+				Code:               "WELCOME10",
+				Name:               "10% off your first order",
+				Type:               app.PromotionPercentage,
+				Percentage:         10,
+				MaxUsesPerCustomer: 1,
+			},
+			2: {
+				ID:              2,
+				Code:            "FIVEOFF",
+				Name:            "€5 off when you spend €25",
+				Type:            app.PromotionFixedAmount,
+				Amount:          app.EUR(500),
+				MinimumSubtotal: app.EUR(2500),
+			},
+			3: {
+				ID:          3,
+				Code:        "GOPHER3FOR2",
+				Name:        "3 Gophers for the price of 2",
+				Type:        app.PromotionBuyXGetY,
+				BuyQuantity: 2,
+				GetQuantity: 1,
+				ProductIDs:  []int{1},
+			},
+			4: {
+				ID:              4,
+				Name:            "Free shipping from €50",
+				Type:            app.PromotionFreeShipping,
+				MinimumSubtotal: app.EUR(5000),
+			},
+		},
+	}
+}
+
+func (r *PromotionRepo) GetPromotion(_ context.Context, promotionID int) (app.Promotion, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	p, ok := r.promotions[promotionID]
+	if !ok {
+		return app.Promotion{}, fmt.Errorf("%w: for ID: %d", app.ErrPromotionNotFound, promotionID)
+	}
+	return clonePromotion(p), nil
+}
+
+func (r *PromotionRepo) GetPromotionByCode(_ context.Context, code string) (app.Promotion, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, p := range r.promotions {
+		if p.Code != "" && strings.EqualFold(p.Code, code) {
+			return clonePromotion(p), nil
+		}
+	}
+	return app.Promotion{}, fmt.Errorf("%w: for code: %s", app.ErrPromotionNotFound, code)
+}
+
+func (r *PromotionRepo) GetAutomaticPromotions(_ context.Context) ([]app.Promotion, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	promotions := []app.Promotion{}
+	for _, p := range r.promotions {
+		if p.Code == "" {
+			promotions = append(promotions, clonePromotion(p))
+		}
+	}
+	slices.SortFunc(promotions, func(a, b app.Promotion) int {
+		return a.ID - b.ID
+	})
+	return promotions, nil
+}
+
+func clonePromotion(p app.Promotion) app.Promotion {
+	p.ProductIDs = slices.Clone(p.ProductIDs)
+	p.CategoryIDs = slices.Clone(p.CategoryIDs)
+	return p
+}
@@ -0,0 +1,14 @@
+package storage_test
+
+import (
+	"testing"
+
+	"github.com/gerbenjacobs/go-webshop-course/storage"
+	"github.com/gerbenjacobs/go-webshop-course/storage/storagetest"
+)
+
+func TestPromotionRepo(t *testing.T) {
+	storagetest.TestPromotionRepository(t, func(t *testing.T) storage.PromotionRepository {
+		return storage.NewPromotionRepo()
+	})
+}
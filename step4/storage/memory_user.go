@@ -0,0 +1,91 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+
+	app "github.com/gerbenjacobs/go-webshop-course"
+)
+
+type UserRepo struct {
+	mu     sync.RWMutex
+	users  map[int]app.User
+	nextID int
+}
+
+func NewUserRepo() *UserRepo {
+	return &UserRepo{
+		users:  make(map[int]app.User),
+		nextID: 1,
+	}
+}
+
+func (r *UserRepo) CreateUser(_ context.Context, user app.User) (app.User, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, u := range r.users {
+		if strings.EqualFold(u.Email, user.Email) {
+			return app.User{}, fmt.Errorf("%w: %s", app.ErrUserExists, user.Email)
+		}
+	}
+
+	user.ID = r.nextID
+	r.nextID++
+	r.users[user.ID] = user
+	return user, nil
+}
+
+func (r *UserRepo) GetUser(_ context.Context, userID int) (app.User, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	user, ok := r.users[userID]
+	if !ok {
+		return app.User{}, fmt.Errorf("%w: for ID: %d", app.ErrUserNotFound, userID)
+	}
+	return user, nil
+}
+
+func (r *UserRepo) GetUserByEmail(_ context.Context, email string) (app.User, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, u := range r.users {
+		if strings.EqualFold(u.Email, email) {
+			return u, nil
+		}
+	}
+	return app.User{}, fmt.Errorf("%w: for email: %s", app.ErrUserNotFound, email)
+}
+
+func (r *UserRepo) UpdateUser(_ context.Context, user app.User) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.users[user.ID]; !ok {
+		return fmt.Errorf("%w: for ID: %d", app.ErrUserNotFound, user.ID)
+	}
+	r.users[user.ID] = user
+	return nil
+}
+
+func (r *UserRepo) SearchUsers(_ context.Context, query string) ([]app.User, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	query = strings.ToLower(query)
+	users := []app.User{}
+	for _, u := range r.users {
+		if strings.Contains(strings.ToLower(u.Name), query) || strings.Contains(strings.ToLower(u.Email), query) {
+			users = append(users, u)
+		}
+	}
+	slices.SortFunc(users, func(a, b app.User) int {
+		return a.ID - b.ID
+	})
+	return users, nil
+}
@@ -0,0 +1,7 @@
+CREATE TABLE IF NOT EXISTS products (
+    id          INTEGER PRIMARY KEY AUTOINCREMENT,
+    name        TEXT    NOT NULL,
+    description TEXT    NOT NULL DEFAULT '',
+    image       TEXT    NOT NULL DEFAULT '',
+    price       REAL    NOT NULL
+);
@@ -0,0 +1,3 @@
+INSERT OR IGNORE INTO products (id, name, description, image, price) VALUES
+    (1, 'Gopher plushie', 'A small purple Gophier plushie, perfect for kids and adults alike.', '', 12.99),
+    (2, 'PHP Elephant plushie', 'An elephant with the PHP logo, available in blue and pink', '', 20);
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS basket_items;
+DROP TABLE IF EXISTS baskets;
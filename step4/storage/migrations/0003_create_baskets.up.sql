@@ -0,0 +1,10 @@
+CREATE TABLE IF NOT EXISTS baskets (
+    user_id INTEGER PRIMARY KEY
+);
+
+CREATE TABLE IF NOT EXISTS basket_items (
+    id         INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id    INTEGER NOT NULL REFERENCES baskets (user_id) ON DELETE CASCADE,
+    product_id INTEGER NOT NULL,
+    quantity   INTEGER NOT NULL
+);
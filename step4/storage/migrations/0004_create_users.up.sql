@@ -0,0 +1,7 @@
+CREATE TABLE users (
+    id            INTEGER PRIMARY KEY AUTOINCREMENT,
+    name          TEXT     NOT NULL,
+    email         TEXT     NOT NULL UNIQUE COLLATE NOCASE,
+    password_hash TEXT     NOT NULL,
+    created_at    DATETIME NOT NULL
+);
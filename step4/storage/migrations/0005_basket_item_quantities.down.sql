@@ -0,0 +1,12 @@
+CREATE TABLE basket_items_old (
+    id         INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id    INTEGER NOT NULL REFERENCES baskets (user_id) ON DELETE CASCADE,
+    product_id INTEGER NOT NULL,
+    quantity   INTEGER NOT NULL
+);
+
+INSERT INTO basket_items_old (user_id, product_id, quantity)
+SELECT user_id, product_id, quantity FROM basket_items ORDER BY rowid;
+
+DROP TABLE basket_items;
+ALTER TABLE basket_items_old RENAME TO basket_items;
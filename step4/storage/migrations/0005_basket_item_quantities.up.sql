@@ -0,0 +1,17 @@
+-- one line per product, duplicate lines are added together
+CREATE TABLE basket_items_new (
+    user_id    INTEGER NOT NULL REFERENCES baskets (user_id) ON DELETE CASCADE,
+    product_id INTEGER NOT NULL,
+    quantity   INTEGER NOT NULL CHECK (quantity > 0),
+    PRIMARY KEY (user_id, product_id)
+);
+
+INSERT INTO basket_items_new (user_id, product_id, quantity)
+SELECT user_id, product_id, SUM(quantity)
+FROM basket_items
+GROUP BY user_id, product_id
+HAVING SUM(quantity) > 0
+ORDER BY MIN(id);
+
+DROP TABLE basket_items;
+ALTER TABLE basket_items_new RENAME TO basket_items;
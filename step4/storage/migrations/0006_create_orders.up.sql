@@ -0,0 +1,18 @@
+CREATE TABLE orders (
+    id         INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id    INTEGER  NOT NULL,
+    status     TEXT     NOT NULL,
+    total      REAL     NOT NULL,
+    created_at DATETIME NOT NULL
+);
+
+CREATE INDEX orders_user_id ON orders (user_id);
+
+CREATE TABLE order_items (
+    order_id   INTEGER NOT NULL REFERENCES orders (id) ON DELETE CASCADE,
+    product_id INTEGER NOT NULL,
+    name       TEXT    NOT NULL,
+    unit_price REAL    NOT NULL,
+    quantity   INTEGER NOT NULL,
+    PRIMARY KEY (order_id, product_id)
+);
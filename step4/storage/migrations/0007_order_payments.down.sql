@@ -0,0 +1,3 @@
+UPDATE orders SET status = 'placed' WHERE status = 'pending_payment';
+
+ALTER TABLE orders DROP COLUMN payment_id;
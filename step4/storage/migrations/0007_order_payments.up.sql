@@ -0,0 +1,4 @@
+ALTER TABLE orders ADD COLUMN payment_id TEXT NOT NULL DEFAULT '';
+
+-- orders used to be placed without paying
+UPDATE orders SET status = 'pending_payment' WHERE status = 'placed';
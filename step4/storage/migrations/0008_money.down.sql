@@ -0,0 +1,17 @@
+ALTER TABLE order_items ADD COLUMN unit_price_real REAL NOT NULL DEFAULT 0;
+UPDATE order_items SET unit_price_real = unit_price / 100.0;
+ALTER TABLE order_items DROP COLUMN unit_price;
+ALTER TABLE order_items DROP COLUMN currency;
+ALTER TABLE order_items RENAME COLUMN unit_price_real TO unit_price;
+
+ALTER TABLE orders ADD COLUMN total_real REAL NOT NULL DEFAULT 0;
+UPDATE orders SET total_real = total / 100.0;
+ALTER TABLE orders DROP COLUMN total;
+ALTER TABLE orders DROP COLUMN currency;
+ALTER TABLE orders RENAME COLUMN total_real TO total;
+
+ALTER TABLE products ADD COLUMN price_real REAL NOT NULL DEFAULT 0;
+UPDATE products SET price_real = price / 100.0;
+ALTER TABLE products DROP COLUMN price;
+ALTER TABLE products DROP COLUMN currency;
+ALTER TABLE products RENAME COLUMN price_real TO price;
@@ -0,0 +1,18 @@
+-- prices are stored in minor units (cents) next to their currency, floats lose cents
+ALTER TABLE products ADD COLUMN price_minor INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE products ADD COLUMN currency TEXT NOT NULL DEFAULT 'EUR';
+UPDATE products SET price_minor = CAST(ROUND(price * 100) AS INTEGER);
+ALTER TABLE products DROP COLUMN price;
+ALTER TABLE products RENAME COLUMN price_minor TO price;
+
+ALTER TABLE orders ADD COLUMN total_minor INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE orders ADD COLUMN currency TEXT NOT NULL DEFAULT 'EUR';
+UPDATE orders SET total_minor = CAST(ROUND(total * 100) AS INTEGER);
+ALTER TABLE orders DROP COLUMN total;
+ALTER TABLE orders RENAME COLUMN total_minor TO total;
+
+ALTER TABLE order_items ADD COLUMN unit_price_minor INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE order_items ADD COLUMN currency TEXT NOT NULL DEFAULT 'EUR';
+UPDATE order_items SET unit_price_minor = CAST(ROUND(unit_price * 100) AS INTEGER);
+ALTER TABLE order_items DROP COLUMN unit_price;
+ALTER TABLE order_items RENAME COLUMN unit_price_minor TO unit_price;
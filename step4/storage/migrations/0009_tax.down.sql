@@ -0,0 +1,10 @@
+DROP TABLE order_taxes;
+
+ALTER TABLE orders DROP COLUMN tax;
+ALTER TABLE orders DROP COLUMN prices_include_tax;
+ALTER TABLE orders DROP COLUMN country;
+ALTER TABLE orders DROP COLUMN subtotal;
+
+ALTER TABLE order_items DROP COLUMN tax_class;
+
+ALTER TABLE products DROP COLUMN tax_class;
@@ -0,0 +1,20 @@
+ALTER TABLE products ADD COLUMN tax_class TEXT NOT NULL DEFAULT 'standard';
+
+ALTER TABLE order_items ADD COLUMN tax_class TEXT NOT NULL DEFAULT 'standard';
+
+-- existing orders were placed with tax-inclusive prices and no tax breakdown
+ALTER TABLE orders ADD COLUMN subtotal INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE orders ADD COLUMN country TEXT NOT NULL DEFAULT '';
+ALTER TABLE orders ADD COLUMN prices_include_tax BOOLEAN NOT NULL DEFAULT TRUE;
+ALTER TABLE orders ADD COLUMN tax INTEGER NOT NULL DEFAULT 0;
+UPDATE orders SET subtotal = total;
+
+CREATE TABLE order_taxes (
+    order_id INTEGER NOT NULL REFERENCES orders (id) ON DELETE CASCADE,
+    class    TEXT    NOT NULL,
+    rate     INTEGER NOT NULL,
+    base     INTEGER NOT NULL,
+    amount   INTEGER NOT NULL,
+    currency TEXT    NOT NULL,
+    PRIMARY KEY (order_id, rate)
+);
@@ -0,0 +1,10 @@
+DROP TABLE order_discounts;
+ALTER TABLE orders DROP COLUMN discount;
+ALTER TABLE orders DROP COLUMN shipping;
+
+DROP TABLE promotion_categories;
+DROP TABLE promotion_products;
+DROP TABLE promotions;
+
+ALTER TABLE baskets DROP COLUMN coupon;
+ALTER TABLE products DROP COLUMN category_id;
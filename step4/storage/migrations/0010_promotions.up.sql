@@ -0,0 +1,59 @@
+-- promotions can target categories, products get theirs later on
+ALTER TABLE products ADD COLUMN category_id INTEGER NOT NULL DEFAULT 0;
+
+ALTER TABLE baskets ADD COLUMN coupon TEXT NOT NULL DEFAULT '';
+
+CREATE TABLE promotions (
+    id                    INTEGER PRIMARY KEY AUTOINCREMENT,
+    code                  TEXT     NOT NULL DEFAULT '',
+    name                  TEXT     NOT NULL,
+    type                  TEXT     NOT NULL,
+    percentage            INTEGER  NOT NULL DEFAULT 0,
+    amount                INTEGER  NOT NULL DEFAULT 0,
+    buy_quantity          INTEGER  NOT NULL DEFAULT 0,
+    get_quantity          INTEGER  NOT NULL DEFAULT 0,
+    minimum_subtotal      INTEGER  NOT NULL DEFAULT 0,
+    currency              TEXT     NOT NULL DEFAULT 'EUR',
+    starts_at             DATETIME,
+    ends_at               DATETIME,
+    max_uses              INTEGER  NOT NULL DEFAULT 0,
+    max_uses_per_customer INTEGER  NOT NULL DEFAULT 0
+);
+
+-- automatic promotions don't have a code
+CREATE UNIQUE INDEX promotions_code ON promotions (code COLLATE NOCASE) WHERE code != '';
+
+CREATE TABLE promotion_products (
+    promotion_id INTEGER NOT NULL REFERENCES promotions (id) ON DELETE CASCADE,
+    product_id   INTEGER NOT NULL,
+    PRIMARY KEY (promotion_id, product_id)
+);
+
+CREATE TABLE promotion_categories (
+    promotion_id INTEGER NOT NULL REFERENCES promotions (id) ON DELETE CASCADE,
+    category_id  INTEGER NOT NULL,
+    PRIMARY KEY (promotion_id, category_id)
+);
+
+ALTER TABLE orders ADD COLUMN shipping INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE orders ADD COLUMN discount INTEGER NOT NULL DEFAULT 0;
+
+CREATE TABLE order_discounts (
+    order_id     INTEGER NOT NULL REFERENCES orders (id) ON DELETE CASCADE,
+    promotion_id INTEGER NOT NULL,
+    code         TEXT    NOT NULL,
+    name         TEXT    NOT NULL,
+    amount       INTEGER NOT NULL,
+    currency     TEXT    NOT NULL,
+    PRIMARY KEY (order_id, promotion_id)
+);
+
+CREATE INDEX order_discounts_promotion_id ON order_discounts (promotion_id);
+
+INSERT INTO promotions (id, code, name, type, percentage, amount, buy_quantity, get_quantity, minimum_subtotal, max_uses_per_customer) VALUES
+    (1, 'WELCOME10', '10% off your first order', 'percentage', 10, 0, 0, 0, 0, 1),
+    (2, 'FIVEOFF', '€5 off when you spend €25', 'fixed_amount', 0, 500, 0, 0, 2500, 0),
+    (3, 'GOPHER3FOR2', '3 Gophers for the price of 2', 'buy_x_get_y', 0, 0, 2, 1, 0, 0),
+    (4, '', 'Free shipping from €50', 'free_shipping', 0, 0, 0, 0, 5000, 0);
+
+INSERT INTO promotion_products (promotion_id, product_id) VALUES (3, 1);
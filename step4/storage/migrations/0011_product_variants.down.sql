@@ -0,0 +1,41 @@
+-- lines of different variants of the same product are added together again
+CREATE TABLE order_items_old (
+    order_id   INTEGER NOT NULL REFERENCES orders (id) ON DELETE CASCADE,
+    product_id INTEGER NOT NULL,
+    name       TEXT    NOT NULL,
+    quantity   INTEGER NOT NULL,
+    unit_price INTEGER NOT NULL DEFAULT 0,
+    currency   TEXT    NOT NULL DEFAULT 'EUR',
+    tax_class  TEXT    NOT NULL DEFAULT 'standard',
+    PRIMARY KEY (order_id, product_id)
+);
+
+INSERT INTO order_items_old (order_id, product_id, name, quantity, unit_price, currency, tax_class)
+SELECT order_id, product_id, name, SUM(quantity), MIN(unit_price), currency, tax_class
+FROM order_items
+GROUP BY order_id, product_id
+ORDER BY MIN(rowid);
+
+DROP TABLE order_items;
+ALTER TABLE order_items_old RENAME TO order_items;
+
+CREATE TABLE basket_items_old (
+    user_id    INTEGER NOT NULL REFERENCES baskets (user_id) ON DELETE CASCADE,
+    product_id INTEGER NOT NULL,
+    quantity   INTEGER NOT NULL CHECK (quantity > 0),
+    PRIMARY KEY (user_id, product_id)
+);
+
+INSERT INTO basket_items_old (user_id, product_id, quantity)
+SELECT user_id, product_id, SUM(quantity)
+FROM basket_items
+GROUP BY user_id, product_id
+ORDER BY MIN(rowid);
+
+DROP TABLE basket_items;
+ALTER TABLE basket_items_old RENAME TO basket_items;
+
+DROP TABLE variant_options;
+DROP TABLE product_variants;
+DROP TABLE product_option_values;
+DROP TABLE product_options;
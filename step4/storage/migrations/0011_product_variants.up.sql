@@ -0,0 +1,84 @@
+-- options are the axes a product comes in, their values are listed in display order
+CREATE TABLE product_options (
+    product_id INTEGER NOT NULL REFERENCES products (id) ON DELETE CASCADE,
+    position   INTEGER NOT NULL,
+    name       TEXT    NOT NULL,
+    PRIMARY KEY (product_id, position)
+);
+
+CREATE TABLE product_option_values (
+    product_id      INTEGER NOT NULL,
+    option_position INTEGER NOT NULL,
+    position        INTEGER NOT NULL,
+    value           TEXT    NOT NULL,
+    PRIMARY KEY (product_id, option_position, position),
+    FOREIGN KEY (product_id, option_position) REFERENCES product_options (product_id, position) ON DELETE CASCADE
+);
+
+-- a NULL price means the variant costs the same as its product
+CREATE TABLE product_variants (
+    id         INTEGER PRIMARY KEY AUTOINCREMENT,
+    product_id INTEGER NOT NULL REFERENCES products (id) ON DELETE CASCADE,
+    sku        TEXT    NOT NULL UNIQUE,
+    price      INTEGER,
+    stock      INTEGER NOT NULL DEFAULT 0,
+    image      TEXT    NOT NULL DEFAULT ''
+);
+
+CREATE INDEX product_variants_product_id ON product_variants (product_id);
+
+CREATE TABLE variant_options (
+    variant_id INTEGER NOT NULL REFERENCES product_variants (id) ON DELETE CASCADE,
+    name       TEXT    NOT NULL,
+    value      TEXT    NOT NULL,
+    PRIMARY KEY (variant_id, name)
+);
+
+-- basket and order lines are a product and its variant, 0 for products without variants
+CREATE TABLE basket_items_new (
+    user_id    INTEGER NOT NULL REFERENCES baskets (user_id) ON DELETE CASCADE,
+    product_id INTEGER NOT NULL,
+    variant_id INTEGER NOT NULL DEFAULT 0,
+    quantity   INTEGER NOT NULL CHECK (quantity > 0),
+    PRIMARY KEY (user_id, product_id, variant_id)
+);
+
+INSERT INTO basket_items_new (user_id, product_id, quantity)
+SELECT user_id, product_id, quantity FROM basket_items ORDER BY rowid;
+
+DROP TABLE basket_items;
+ALTER TABLE basket_items_new RENAME TO basket_items;
+
+CREATE TABLE order_items_new (
+    order_id   INTEGER NOT NULL REFERENCES orders (id) ON DELETE CASCADE,
+    product_id INTEGER NOT NULL,
+    variant_id INTEGER NOT NULL DEFAULT 0,
+    sku        TEXT    NOT NULL DEFAULT '',
+    name       TEXT    NOT NULL,
+    variant    TEXT    NOT NULL DEFAULT '',
+    unit_price INTEGER NOT NULL,
+    currency   TEXT    NOT NULL,
+    tax_class  TEXT    NOT NULL,
+    quantity   INTEGER NOT NULL,
+    PRIMARY KEY (order_id, product_id, variant_id)
+);
+
+INSERT INTO order_items_new (order_id, product_id, name, unit_price, currency, tax_class, quantity)
+SELECT order_id, product_id, name, unit_price, currency, tax_class, quantity FROM order_items ORDER BY rowid;
+
+DROP TABLE order_items;
+ALTER TABLE order_items_new RENAME TO order_items;
+
+-- the elephant comes in blue and pink, pink is a limited edition
+INSERT INTO product_options (product_id, position, name) VALUES (2, 0, 'Colour');
+INSERT INTO product_option_values (product_id, option_position, position, value) VALUES
+    (2, 0, 0, 'Blue'),
+    (2, 0, 1, 'Pink');
+
+INSERT INTO product_variants (id, product_id, sku, price, stock, image) VALUES
+    (1, 2, 'PHP-ELEPHANT-BLUE', NULL, 25, ''),
+    (2, 2, 'PHP-ELEPHANT-PINK', 2200, 10, '');
+
+INSERT INTO variant_options (variant_id, name, value) VALUES
+    (1, 'Colour', 'Blue'),
+    (2, 'Colour', 'Pink');
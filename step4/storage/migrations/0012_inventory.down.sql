@@ -0,0 +1,4 @@
+DROP TABLE stock_reservations;
+
+ALTER TABLE orders DROP COLUMN reserved_until;
+ALTER TABLE products DROP COLUMN stock;
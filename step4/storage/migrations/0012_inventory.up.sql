@@ -0,0 +1,17 @@
+-- products without variants keep their stock on the product itself
+ALTER TABLE products ADD COLUMN stock INTEGER NOT NULL DEFAULT 0;
+UPDATE products SET stock = 50 WHERE id = 1;
+
+-- reservation times are unix seconds, so they compare as numbers
+ALTER TABLE orders ADD COLUMN reserved_until INTEGER NOT NULL DEFAULT 0;
+
+CREATE TABLE stock_reservations (
+    order_id   INTEGER NOT NULL REFERENCES orders (id) ON DELETE CASCADE,
+    product_id INTEGER NOT NULL,
+    variant_id INTEGER NOT NULL DEFAULT 0,
+    quantity   INTEGER NOT NULL CHECK (quantity > 0),
+    expires_at INTEGER NOT NULL,
+    PRIMARY KEY (order_id, product_id, variant_id)
+);
+
+CREATE INDEX stock_reservations_product ON stock_reservations (product_id, variant_id, expires_at);
@@ -0,0 +1,2 @@
+-- everyone is a customer, admins are appointed with the -admins flag
+ALTER TABLE users ADD COLUMN role TEXT NOT NULL DEFAULT 'customer';
@@ -0,0 +1,7 @@
+ALTER TABLE products ADD COLUMN category_id INTEGER NOT NULL DEFAULT 0;
+UPDATE products SET category_id = COALESCE((SELECT MIN(category_id) FROM product_categories WHERE product_id = products.id), 0);
+
+DROP TABLE collection_products;
+DROP TABLE collections;
+DROP TABLE product_categories;
+DROP TABLE categories;
@@ -0,0 +1,54 @@
+-- a tree of categories, top-level categories have no parent
+CREATE TABLE categories (
+    id          INTEGER PRIMARY KEY AUTOINCREMENT,
+    parent_id   INTEGER REFERENCES categories (id),
+    slug        TEXT    NOT NULL UNIQUE,
+    name        TEXT    NOT NULL,
+    description TEXT    NOT NULL DEFAULT '',
+    position    INTEGER NOT NULL DEFAULT 0
+);
+
+CREATE INDEX categories_parent_id ON categories (parent_id);
+
+-- products can be listed in more than one category
+CREATE TABLE product_categories (
+    product_id  INTEGER NOT NULL REFERENCES products (id) ON DELETE CASCADE,
+    category_id INTEGER NOT NULL REFERENCES categories (id) ON DELETE CASCADE,
+    PRIMARY KEY (product_id, category_id)
+);
+
+CREATE INDEX product_categories_category_id ON product_categories (category_id);
+
+CREATE TABLE collections (
+    id          INTEGER PRIMARY KEY AUTOINCREMENT,
+    slug        TEXT    NOT NULL UNIQUE,
+    name        TEXT    NOT NULL,
+    description TEXT    NOT NULL DEFAULT ''
+);
+
+-- collections are curated, position keeps the order they were put in
+CREATE TABLE collection_products (
+    collection_id INTEGER NOT NULL REFERENCES collections (id) ON DELETE CASCADE,
+    product_id    INTEGER NOT NULL REFERENCES products (id) ON DELETE CASCADE,
+    position      INTEGER NOT NULL,
+    PRIMARY KEY (collection_id, product_id)
+);
+
+INSERT INTO categories (id, parent_id, slug, name, description, position) VALUES
+    (1, NULL, 'plushies', 'Plushies', 'Soft toys for developers of all ages.', 0),
+    (2, 1, 'mascots', 'Language mascots', 'The mascots of your favourite programming languages.', 0);
+
+INSERT INTO product_categories (product_id, category_id)
+SELECT id, 2 FROM products WHERE id IN (1, 2);
+
+-- products could already have a category for promotions, keep the ones that exist
+INSERT OR IGNORE INTO product_categories (product_id, category_id)
+SELECT id, category_id FROM products WHERE category_id IN (SELECT id FROM categories);
+
+ALTER TABLE products DROP COLUMN category_id;
+
+INSERT INTO collections (id, slug, name, description) VALUES
+    (1, 'staff-picks', 'Staff picks', 'The plushies on our own desks.');
+
+INSERT INTO collection_products (collection_id, product_id, position)
+SELECT 1, id, CASE id WHEN 2 THEN 0 ELSE 1 END FROM products WHERE id IN (1, 2);
@@ -0,0 +1 @@
+ALTER TABLE baskets DROP COLUMN created_at;
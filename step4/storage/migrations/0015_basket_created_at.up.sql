@@ -0,0 +1,4 @@
+-- the baskets of guests are cleaned up once their cookie has expired,
+-- we don't know how old the existing ones are so they get a fresh start
+ALTER TABLE baskets ADD COLUMN created_at INTEGER NOT NULL DEFAULT 0;
+UPDATE baskets SET created_at = CAST(strftime('%s', 'now') AS INTEGER);
@@ -3,47 +3,307 @@ package storage
 import (
 	"context"
 	"fmt"
+	"maps"
+	"slices"
+	"sync"
+	"time"
 
 	app "github.com/gerbenjacobs/go-webshop-course"
 )
 
+// ProductRepo is safe for concurrent use
 type ProductRepo struct {
+	mu       sync.RWMutex
 	products map[int]app.Product
+	// nextID and nextVariantID are the IDs the next new product and variant get
+	nextID        int
+	nextVariantID int
+	// reservations holds stock for unpaid orders, by order ID
+	reservations map[int][]app.Reservation
 }
 
 func NewProductRepo() *ProductRepo {
 	return &ProductRepo{
+		nextID:        3,
+		nextVariantID: 3,
+		reservations:  make(map[int][]app.Reservation),
 		products: map[int]app.Product{
 			1: {
 				ID:          1,
 				Name:        "Gopher plushie",
 				Description: "A small purple Gophier plushie, perfect for kids and adults alike.",
 				Image:       "",
-				Price:       12.99,
+				Price:       app.EUR(1299),
+				TaxClass:    app.TaxClassStandard,
+				CategoryIDs: []int{2},
+				Stock:       50,
 			},
 			2: {
 				ID:          2,
 				Name:        "PHP Elephant plushie",
 				Description: "An elephant with the PHP logo, available in blue and pink",
 				Image:       "",
-				Price:       20,
+				Price:       app.EUR(2000),
+				TaxClass:    app.TaxClassStandard,
+				CategoryIDs: []int{2},
+				Options: []app.ProductOption{
+					{Name: "Colour", Values: []string{"Blue", "Pink"}},
+				},
+				Variants: []app.Variant{
+					{ID: 1, ProductID: 2, SKU: "PHP-ELEPHANT-BLUE", Options: map[string]string{"Colour": "Blue"}, Stock: 25},
+					// pink is a limited edition
+					{ID: 2, ProductID: 2, SKU: "PHP-ELEPHANT-PINK", Options: map[string]string{"Colour": "Pink"}, Price: ptr(app.EUR(2200)), Stock: 10},
+				},
 			},
 		},
 	}
 }
 
 func (p *ProductRepo) GetAllProducts(_ context.Context) ([]app.Product, error) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	reserved := p.reserved(time.Now())
 	var products []app.Product
 	for _, product := range p.products {
-		products = append(products, product)
+		products = append(products, withReserved(cloneProduct(product), reserved))
 	}
+	// maps don't keep an order, so we list the products like the database does
+	slices.SortFunc(products, func(a, b app.Product) int { return a.ID - b.ID })
 	return products, nil
 }
 
 func (p *ProductRepo) GetProduct(ctx context.Context, productID int) (app.Product, error) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
 	v, ok := p.products[productID]
 	if !ok {
 		return app.Product{}, fmt.Errorf("%w: for ID: %d", app.ErrProductNotFound, productID)
 	}
-	return v, nil
+	return withReserved(cloneProduct(v), p.reserved(time.Now())), nil
+}
+
+func (p *ProductRepo) CreateProduct(_ context.Context, product app.Product) (app.Product, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	product = cloneProduct(product)
+	product.ID = p.nextID
+	for i := range product.Variants {
+		product.Variants[i].ID = 0
+	}
+	if err := p.store(product); err != nil {
+		return app.Product{}, err
+	}
+	p.nextID++
+	return withReserved(cloneProduct(p.products[product.ID]), p.reserved(time.Now())), nil
+}
+
+func (p *ProductRepo) UpdateProduct(_ context.Context, product app.Product) (app.Product, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	current, ok := p.products[product.ID]
+	if !ok {
+		return app.Product{}, fmt.Errorf("%w: for ID: %d", app.ErrProductNotFound, product.ID)
+	}
+	for _, v := range product.Variants {
+		if v.ID == 0 {
+			continue
+		}
+		if !slices.ContainsFunc(current.Variants, func(cv app.Variant) bool { return cv.ID == v.ID }) {
+			return app.Product{}, fmt.Errorf("%w: product %d has no variant %d", app.ErrVariantNotFound, product.ID, v.ID)
+		}
+	}
+	if err := p.store(product); err != nil {
+		return app.Product{}, err
+	}
+	return withReserved(cloneProduct(p.products[product.ID]), p.reserved(time.Now())), nil
+}
+
+func (p *ProductRepo) DeleteProduct(_ context.Context, productID int) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if _, ok := p.products[productID]; !ok {
+		return fmt.Errorf("%w: for ID: %d", app.ErrProductNotFound, productID)
+	}
+	delete(p.products, productID)
+	return nil
+}
+
+// store saves a copy of the product after checking its SKUs are free,
+// new variants get an ID. The caller needs to hold the lock.
+func (p *ProductRepo) store(product app.Product) error {
+	for _, other := range p.products {
+		if other.ID == product.ID {
+			continue
+		}
+		for _, ov := range other.Variants {
+			for _, v := range product.Variants {
+				if v.SKU == ov.SKU {
+					return fmt.Errorf("%w: %s", app.ErrSKUExists, v.SKU)
+				}
+			}
+		}
+	}
+
+	product = cloneProduct(product)
+	product.Reserved = 0
+	for i := range product.Variants {
+		v := &product.Variants[i]
+		if v.ID == 0 {
+			v.ID = p.nextVariantID
+			p.nextVariantID++
+		}
+		v.ProductID = product.ID
+		v.Reserved = 0
+	}
+	p.products[product.ID] = product
+	return nil
+}
+
+// removeCategory takes the category off all products, it's used by CategoryRepo when a category is deleted
+func (p *ProductRepo) removeCategory(categoryID int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for id, product := range p.products {
+		if slices.Contains(product.CategoryIDs, categoryID) {
+			product.CategoryIDs = slices.DeleteFunc(slices.Clone(product.CategoryIDs), func(c int) bool { return c == categoryID })
+			p.products[id] = product
+		}
+	}
+}
+
+// reserve holds stock for an order until the given time, replacing its earlier reservation.
+// It's used by OrderRepo, so orders and their reservations are created in one go.
+func (p *ProductRepo) reserve(orderID int, items []app.OrderItem, until time.Time) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	now := time.Now()
+	// expired reservations don't hold anything anymore
+	for id, reservations := range p.reservations {
+		p.reservations[id] = slices.DeleteFunc(reservations, func(r app.Reservation) bool {
+			return !r.Active(now)
+		})
+	}
+	// the order's earlier reservation is replaced, but only once the new one fits
+	reserved := p.reserved(now)
+	for _, r := range p.reservations[orderID] {
+		reserved[lineKey{r.ProductID, r.VariantID}] -= r.Quantity
+	}
+	reservations := make([]app.Reservation, 0, len(items))
+	for _, item := range items {
+		stock, err := p.stock(item.ProductID, item.VariantID)
+		if err != nil {
+			return err
+		}
+		if available := stock - reserved[lineKey{item.ProductID, item.VariantID}]; available < item.Quantity {
+			return fmt.Errorf("%w: %d available for product ID: %d", app.ErrInsufficientStock, max(available, 0), item.ProductID)
+		}
+		reservations = append(reservations, app.Reservation{
+			OrderID:   orderID,
+			ProductID: item.ProductID,
+			VariantID: item.VariantID,
+			Quantity:  item.Quantity,
+			ExpiresAt: until,
+		})
+	}
+	p.reservations[orderID] = reservations
+	return nil
+}
+
+// release lets go of the stock held for an order
+func (p *ProductRepo) release(orderID int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	delete(p.reservations, orderID)
+}
+
+// commit takes the items of a paid order out of the stock and releases its reservation.
+// Stock can drop below zero when the reservation expired before the payment came in,
+// those items are oversold and need restocking.
+func (p *ProductRepo) commit(orderID int, items []app.OrderItem) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for _, item := range items {
+		product, ok := p.products[item.ProductID]
+		if !ok {
+			continue
+		}
+		if item.VariantID == 0 {
+			product.Stock -= item.Quantity
+		}
+		for i, v := range product.Variants {
+			if v.ID == item.VariantID {
+				product.Variants[i].Stock -= item.Quantity
+			}
+		}
+		p.products[item.ProductID] = product
+	}
+	delete(p.reservations, orderID)
+}
+
+// stock returns the stock on hand of a product or one of its variants, the caller needs to hold the lock
+func (p *ProductRepo) stock(productID, variantID int) (int, error) {
+	product, ok := p.products[productID]
+	if !ok {
+		return 0, fmt.Errorf("%w: for ID: %d", app.ErrProductNotFound, productID)
+	}
+	v, err := product.Variant(variantID)
+	if err != nil {
+		return 0, err
+	}
+	if v.ID == 0 {
+		return product.Stock, nil
+	}
+	return v.Stock, nil
+}
+
+// reserved adds up the active reservations per product and variant, the caller needs to hold the lock
+func (p *ProductRepo) reserved(now time.Time) map[lineKey]int {
+	reserved := make(map[lineKey]int)
+	for _, reservations := range p.reservations {
+		for _, r := range reservations {
+			if r.Active(now) {
+				reserved[lineKey{r.ProductID, r.VariantID}] += r.Quantity
+			}
+		}
+	}
+	return reserved
+}
+
+// withReserved fills in what's reserved of the product and its variants
+func withReserved(p app.Product, reserved map[lineKey]int) app.Product {
+	p.Reserved = reserved[lineKey{p.ID, 0}]
+	for i, v := range p.Variants {
+		p.Variants[i].Reserved = reserved[lineKey{p.ID, v.ID}]
+	}
+	return p
+}
+
+// cloneProduct copies the categories, options and variants, so callers can't change our products behind our back
+func cloneProduct(p app.Product) app.Product {
+	p.CategoryIDs = slices.Clone(p.CategoryIDs)
+	p.Options = slices.Clone(p.Options)
+	for i, o := range p.Options {
+		p.Options[i].Values = slices.Clone(o.Values)
+	}
+	p.Variants = slices.Clone(p.Variants)
+	for i, v := range p.Variants {
+		p.Variants[i].Options = maps.Clone(v.Options)
+		if v.Price != nil {
+			p.Variants[i].Price = ptr(*v.Price)
+		}
+	}
+	return p
+}
+
+func ptr[T any](v T) *T {
+	return &v
 }
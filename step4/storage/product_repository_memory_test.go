@@ -0,0 +1,23 @@
+package storage_test
+
+import (
+	"testing"
+
+	"github.com/gerbenjacobs/go-webshop-course/storage"
+	"github.com/gerbenjacobs/go-webshop-course/storage/storagetest"
+)
+
+func TestProductRepo(t *testing.T) {
+	storagetest.TestProductRepository(t, func(t *testing.T) storage.ProductRepository {
+		return storage.NewProductRepo()
+	})
+}
+
+func TestProductRepoStress(t *testing.T) {
+	baskets, products := storage.NewBasketRepo(), storage.NewProductRepo()
+	storagetest.StressProductRepository(t, storagetest.OrderRepos{
+		Orders:   storage.NewOrderRepo(baskets, products, storage.NewPromotionRepo()),
+		Baskets:  baskets,
+		Products: products,
+	})
+}
@@ -0,0 +1,211 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	app "github.com/gerbenjacobs/go-webshop-course"
+)
+
+type SQLiteBasketRepo struct {
+	db *sql.DB
+}
+
+func NewSQLiteBasketRepo(db *sql.DB) *SQLiteBasketRepo {
+	return &SQLiteBasketRepo{db: db}
+}
+
+func (r *SQLiteBasketRepo) GetBasket(ctx context.Context, userID int) (app.Basket, error) {
+	// like our in-memory version, we create the basket if it doesn't exist yet
+	_, err := r.db.ExecContext(ctx,
+		"INSERT OR IGNORE INTO baskets (user_id, created_at) VALUES (?, ?)", userID, time.Now().Unix(),
+	)
+	if err != nil {
+		return app.Basket{}, fmt.Errorf("failed to create basket: %w", err)
+	}
+
+	basket := app.Basket{UserID: userID, Items: []app.BasketItem{}}
+	if err := r.db.QueryRowContext(ctx, "SELECT coupon FROM baskets WHERE user_id = ?", userID).Scan(&basket.Coupon); err != nil {
+		return app.Basket{}, fmt.Errorf("failed to query basket: %w", err)
+	}
+
+	rows, err := r.db.QueryContext(ctx,
+		"SELECT product_id, variant_id, quantity FROM basket_items WHERE user_id = ? ORDER BY rowid", userID,
+	)
+	if err != nil {
+		return app.Basket{}, fmt.Errorf("failed to query basket items: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var item app.BasketItem
+		if err := rows.Scan(&item.ProductID, &item.VariantID, &item.Quantity); err != nil {
+			return app.Basket{}, fmt.Errorf("failed to scan basket item: %w", err)
+		}
+		basket.Items = append(basket.Items, item)
+	}
+	return basket, rows.Err()
+}
+
+func (r *SQLiteBasketRepo) AddToBasket(ctx context.Context, userID, productID, variantID, quantity int) error {
+	if quantity <= 0 {
+		return app.ErrInvalidQuantity
+	}
+	if err := r.basketExists(ctx, userID); err != nil {
+		return err
+	}
+	_, err := r.db.ExecContext(ctx,
+		`INSERT INTO basket_items (user_id, product_id, variant_id, quantity) VALUES (?, ?, ?, ?)
+		ON CONFLICT (user_id, product_id, variant_id) DO UPDATE SET quantity = quantity + excluded.quantity`,
+		userID, productID, variantID, quantity,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to add to basket: %w", err)
+	}
+	return nil
+}
+
+func (r *SQLiteBasketRepo) RemoveFromBasket(ctx context.Context, userID, productID, variantID, quantity int) error {
+	if quantity <= 0 {
+		return app.ErrInvalidQuantity
+	}
+	if err := r.basketExists(ctx, userID); err != nil {
+		return err
+	}
+	// removing a product that's not in the basket is not an error, same as our in-memory version
+	err := inTx(ctx, r.db, func(tx *sql.Tx) error {
+		_, err := tx.ExecContext(ctx,
+			"DELETE FROM basket_items WHERE user_id = ? AND product_id = ? AND variant_id = ? AND quantity <= ?",
+			userID, productID, variantID, quantity,
+		)
+		if err != nil {
+			return err
+		}
+		_, err = tx.ExecContext(ctx,
+			"UPDATE basket_items SET quantity = quantity - ? WHERE user_id = ? AND product_id = ? AND variant_id = ?",
+			quantity, userID, productID, variantID,
+		)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("failed to remove from basket: %w", err)
+	}
+	return nil
+}
+
+func (r *SQLiteBasketRepo) SetQuantity(ctx context.Context, userID, productID, variantID, quantity int) error {
+	if quantity < 0 {
+		return app.ErrInvalidQuantity
+	}
+	if err := r.basketExists(ctx, userID); err != nil {
+		return err
+	}
+
+	var err error
+	if quantity == 0 {
+		_, err = r.db.ExecContext(ctx,
+			"DELETE FROM basket_items WHERE user_id = ? AND product_id = ? AND variant_id = ?", userID, productID, variantID,
+		)
+	} else {
+		_, err = r.db.ExecContext(ctx,
+			`INSERT INTO basket_items (user_id, product_id, variant_id, quantity) VALUES (?, ?, ?, ?)
+			ON CONFLICT (user_id, product_id, variant_id) DO UPDATE SET quantity = excluded.quantity`,
+			userID, productID, variantID, quantity,
+		)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to set quantity: %w", err)
+	}
+	return nil
+}
+
+func (r *SQLiteBasketRepo) MergeBaskets(ctx context.Context, fromUserID, toUserID int) error {
+	return inTx(ctx, r.db, func(tx *sql.Tx) error {
+		_, err := tx.ExecContext(ctx,
+			"INSERT OR IGNORE INTO baskets (user_id, created_at) VALUES (?, ?)", toUserID, time.Now().Unix(),
+		)
+		if err != nil {
+			return fmt.Errorf("failed to create basket: %w", err)
+		}
+		_, err = tx.ExecContext(ctx,
+			`INSERT INTO basket_items (user_id, product_id, variant_id, quantity)
+			SELECT ?, product_id, variant_id, quantity FROM basket_items WHERE user_id = ? ORDER BY rowid
+			ON CONFLICT (user_id, product_id, variant_id) DO UPDATE SET quantity = MIN(quantity + excluded.quantity, ?)`,
+			toUserID, fromUserID, app.MaxLineQuantity,
+		)
+		if err != nil {
+			return fmt.Errorf("failed to move basket items: %w", err)
+		}
+		// the coupon of the guest is only used if the user didn't enter one yet
+		_, err = tx.ExecContext(ctx,
+			`UPDATE baskets SET coupon = (SELECT coupon FROM baskets WHERE user_id = ?)
+			WHERE user_id = ? AND coupon = '' AND EXISTS (SELECT 1 FROM baskets WHERE user_id = ?)`,
+			fromUserID, toUserID, fromUserID,
+		)
+		if err != nil {
+			return fmt.Errorf("failed to move coupon: %w", err)
+		}
+		if _, err := tx.ExecContext(ctx, "DELETE FROM baskets WHERE user_id = ?", fromUserID); err != nil {
+			return fmt.Errorf("failed to delete basket: %w", err)
+		}
+		return nil
+	})
+}
+
+func (r *SQLiteBasketRepo) SetCoupon(ctx context.Context, userID int, code string) error {
+	res, err := r.db.ExecContext(ctx, "UPDATE baskets SET coupon = ? WHERE user_id = ?", code, userID)
+	if err != nil {
+		return fmt.Errorf("failed to set coupon: %w", err)
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return app.ErrBasketNotFound
+	}
+	return nil
+}
+
+func (r *SQLiteBasketRepo) ClearBasket(ctx context.Context, userID int) error {
+	return inTx(ctx, r.db, func(tx *sql.Tx) error {
+		res, err := tx.ExecContext(ctx, "UPDATE baskets SET coupon = '' WHERE user_id = ?", userID)
+		if err != nil {
+			return fmt.Errorf("failed to remove coupon: %w", err)
+		}
+		if n, err := res.RowsAffected(); err == nil && n == 0 {
+			return app.ErrBasketNotFound
+		}
+		if _, err := tx.ExecContext(ctx, "DELETE FROM basket_items WHERE user_id = ?", userID); err != nil {
+			return fmt.Errorf("failed to delete basket items: %w", err)
+		}
+		return nil
+	})
+}
+
+func (r *SQLiteBasketRepo) DeleteGuestBaskets(ctx context.Context, createdBefore time.Time) (int, error) {
+	// the items go along through ON DELETE CASCADE
+	res, err := r.db.ExecContext(ctx,
+		"DELETE FROM baskets WHERE user_id < 0 AND created_at < ?", createdBefore.Unix(),
+	)
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete guest baskets: %w", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to count deleted guest baskets: %w", err)
+	}
+	return int(n), nil
+}
+
+func (r *SQLiteBasketRepo) basketExists(ctx context.Context, userID int) error {
+	var exists bool
+	err := r.db.QueryRowContext(ctx,
+		"SELECT EXISTS (SELECT 1 FROM baskets WHERE user_id = ?)", userID,
+	).Scan(&exists)
+	if err != nil {
+		return fmt.Errorf("failed to query basket: %w", err)
+	}
+	if !exists {
+		return app.ErrBasketNotFound
+	}
+	return nil
+}
@@ -0,0 +1,135 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+
+	app "github.com/gerbenjacobs/go-webshop-course"
+)
+
+type SQLiteCategoryRepo struct {
+	db *sql.DB
+}
+
+func NewSQLiteCategoryRepo(db *sql.DB) *SQLiteCategoryRepo {
+	return &SQLiteCategoryRepo{db: db}
+}
+
+const categoryColumns = "id, COALESCE(parent_id, 0), slug, name, description, position"
+
+func (r *SQLiteCategoryRepo) GetAllCategories(ctx context.Context) ([]app.Category, error) {
+	rows, err := r.db.QueryContext(ctx, "SELECT "+categoryColumns+" FROM categories ORDER BY id")
+	if err != nil {
+		return nil, fmt.Errorf("failed to query categories: %w", err)
+	}
+	defer rows.Close()
+
+	categories := []app.Category{}
+	for rows.Next() {
+		var c app.Category
+		if err := rows.Scan(&c.ID, &c.ParentID, &c.Slug, &c.Name, &c.Description, &c.Position); err != nil {
+			return nil, fmt.Errorf("failed to scan category: %w", err)
+		}
+		categories = append(categories, c)
+	}
+	return categories, rows.Err()
+}
+
+func (r *SQLiteCategoryRepo) GetCategory(ctx context.Context, categoryID int) (app.Category, error) {
+	c, err := r.scanCategory(r.db.QueryRowContext(ctx,
+		"SELECT "+categoryColumns+" FROM categories WHERE id = ?", categoryID,
+	))
+	if errors.Is(err, sql.ErrNoRows) {
+		return app.Category{}, fmt.Errorf("%w: for ID: %d", app.ErrCategoryNotFound, categoryID)
+	}
+	return c, err
+}
+
+func (r *SQLiteCategoryRepo) GetCategoryBySlug(ctx context.Context, slug string) (app.Category, error) {
+	c, err := r.scanCategory(r.db.QueryRowContext(ctx,
+		"SELECT "+categoryColumns+" FROM categories WHERE slug = ?", slug,
+	))
+	if errors.Is(err, sql.ErrNoRows) {
+		return app.Category{}, fmt.Errorf("%w: for slug: %s", app.ErrCategoryNotFound, slug)
+	}
+	return c, err
+}
+
+func (r *SQLiteCategoryRepo) CreateCategory(ctx context.Context, category app.Category) (app.Category, error) {
+	res, err := r.db.ExecContext(ctx,
+		"INSERT INTO categories (parent_id, slug, name, description, position) VALUES (?, ?, ?, ?, ?)",
+		nullID(category.ParentID), category.Slug, category.Name, category.Description, category.Position,
+	)
+	if err != nil {
+		return app.Category{}, categoryError(err, category)
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return app.Category{}, fmt.Errorf("failed to fetch category ID: %w", err)
+	}
+	category.ID = int(id)
+	return category, nil
+}
+
+func (r *SQLiteCategoryRepo) UpdateCategory(ctx context.Context, category app.Category) (app.Category, error) {
+	res, err := r.db.ExecContext(ctx,
+		"UPDATE categories SET parent_id = ?, slug = ?, name = ?, description = ?, position = ? WHERE id = ?",
+		nullID(category.ParentID), category.Slug, category.Name, category.Description, category.Position, category.ID,
+	)
+	if err != nil {
+		return app.Category{}, categoryError(err, category)
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return app.Category{}, fmt.Errorf("%w: for ID: %d", app.ErrCategoryNotFound, category.ID)
+	}
+	return category, nil
+}
+
+func (r *SQLiteCategoryRepo) DeleteCategory(ctx context.Context, categoryID int) error {
+	var children int
+	if err := r.db.QueryRowContext(ctx,
+		"SELECT COUNT(*) FROM categories WHERE parent_id = ?", categoryID,
+	).Scan(&children); err != nil {
+		return fmt.Errorf("failed to count subcategories: %w", err)
+	}
+	if children > 0 {
+		return fmt.Errorf("%w: for ID: %d", app.ErrCategoryHasChildren, categoryID)
+	}
+
+	// the products lose the category, see the ON DELETE CASCADE of product_categories
+	res, err := r.db.ExecContext(ctx, "DELETE FROM categories WHERE id = ?", categoryID)
+	if err != nil {
+		return fmt.Errorf("failed to delete category %d: %w", categoryID, err)
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return fmt.Errorf("%w: for ID: %d", app.ErrCategoryNotFound, categoryID)
+	}
+	return nil
+}
+
+func (r *SQLiteCategoryRepo) scanCategory(row *sql.Row) (app.Category, error) {
+	var c app.Category
+	err := row.Scan(&c.ID, &c.ParentID, &c.Slug, &c.Name, &c.Description, &c.Position)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return app.Category{}, fmt.Errorf("failed to query category: %w", err)
+	}
+	return c, err
+}
+
+func categoryError(err error, category app.Category) error {
+	switch {
+	case strings.Contains(err.Error(), "UNIQUE constraint failed"):
+		return fmt.Errorf("%w: %s", app.ErrSlugExists, category.Slug)
+	case strings.Contains(err.Error(), "FOREIGN KEY constraint failed"):
+		return fmt.Errorf("%w: for ID: %d", app.ErrCategoryNotFound, category.ParentID)
+	}
+	return fmt.Errorf("failed to save category %s: %w", category.Slug, err)
+}
+
+// nullID stores an ID of 0 as NULL, for optional foreign keys
+func nullID(id int) sql.NullInt64 {
+	return sql.NullInt64{Int64: int64(id), Valid: id != 0}
+}
@@ -0,0 +1,18 @@
+package storage_test
+
+import (
+	"testing"
+
+	"github.com/gerbenjacobs/go-webshop-course/storage"
+	"github.com/gerbenjacobs/go-webshop-course/storage/storagetest"
+)
+
+func TestSQLiteCategoryRepo(t *testing.T) {
+	storagetest.TestCategoryRepository(t, func(t *testing.T) storagetest.CategoryRepos {
+		db := openTestDB(t)
+		return storagetest.CategoryRepos{
+			Categories: storage.NewSQLiteCategoryRepo(db),
+			Products:   storage.NewSQLiteProductRepo(db),
+		}
+	})
+}
@@ -0,0 +1,174 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+
+	app "github.com/gerbenjacobs/go-webshop-course"
+)
+
+type SQLiteCollectionRepo struct {
+	db *sql.DB
+}
+
+func NewSQLiteCollectionRepo(db *sql.DB) *SQLiteCollectionRepo {
+	return &SQLiteCollectionRepo{db: db}
+}
+
+func (r *SQLiteCollectionRepo) GetAllCollections(ctx context.Context) ([]app.Collection, error) {
+	rows, err := r.db.QueryContext(ctx, "SELECT id, slug, name, description FROM collections ORDER BY id")
+	if err != nil {
+		return nil, fmt.Errorf("failed to query collections: %w", err)
+	}
+	defer rows.Close()
+
+	collections := []app.Collection{}
+	for rows.Next() {
+		var c app.Collection
+		if err := rows.Scan(&c.ID, &c.Slug, &c.Name, &c.Description); err != nil {
+			return nil, fmt.Errorf("failed to scan collection: %w", err)
+		}
+		collections = append(collections, c)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	// we only have a single connection, so the rows need to be closed before loading the products
+	rows.Close()
+
+	for i := range collections {
+		if err := r.loadProducts(ctx, &collections[i]); err != nil {
+			return nil, err
+		}
+	}
+	return collections, nil
+}
+
+func (r *SQLiteCollectionRepo) GetCollection(ctx context.Context, collectionID int) (app.Collection, error) {
+	return r.getCollection(ctx, "id", "ID", collectionID)
+}
+
+func (r *SQLiteCollectionRepo) GetCollectionBySlug(ctx context.Context, slug string) (app.Collection, error) {
+	return r.getCollection(ctx, "slug", "slug", slug)
+}
+
+func (r *SQLiteCollectionRepo) CreateCollection(ctx context.Context, collection app.Collection) (app.Collection, error) {
+	err := inTx(ctx, r.db, func(tx *sql.Tx) error {
+		res, err := tx.ExecContext(ctx,
+			"INSERT INTO collections (slug, name, description) VALUES (?, ?, ?)",
+			collection.Slug, collection.Name, collection.Description,
+		)
+		if err != nil {
+			return collectionError(err, collection)
+		}
+		id, err := res.LastInsertId()
+		if err != nil {
+			return fmt.Errorf("failed to fetch collection ID: %w", err)
+		}
+		collection.ID = int(id)
+		return saveCollectionProducts(ctx, tx, collection)
+	})
+	if err != nil {
+		return app.Collection{}, err
+	}
+	return collection, nil
+}
+
+func (r *SQLiteCollectionRepo) UpdateCollection(ctx context.Context, collection app.Collection) (app.Collection, error) {
+	err := inTx(ctx, r.db, func(tx *sql.Tx) error {
+		res, err := tx.ExecContext(ctx,
+			"UPDATE collections SET slug = ?, name = ?, description = ? WHERE id = ?",
+			collection.Slug, collection.Name, collection.Description, collection.ID,
+		)
+		if err != nil {
+			return collectionError(err, collection)
+		}
+		if n, err := res.RowsAffected(); err == nil && n == 0 {
+			return fmt.Errorf("%w: for ID: %d", app.ErrCollectionNotFound, collection.ID)
+		}
+		return saveCollectionProducts(ctx, tx, collection)
+	})
+	if err != nil {
+		return app.Collection{}, err
+	}
+	return collection, nil
+}
+
+func (r *SQLiteCollectionRepo) DeleteCollection(ctx context.Context, collectionID int) error {
+	res, err := r.db.ExecContext(ctx, "DELETE FROM collections WHERE id = ?", collectionID)
+	if err != nil {
+		return fmt.Errorf("failed to delete collection %d: %w", collectionID, err)
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return fmt.Errorf("%w: for ID: %d", app.ErrCollectionNotFound, collectionID)
+	}
+	return nil
+}
+
+// getCollection finds a collection by one of its unique columns, label names the column in errors
+func (r *SQLiteCollectionRepo) getCollection(ctx context.Context, column, label string, value any) (app.Collection, error) {
+	var c app.Collection
+	err := r.db.QueryRowContext(ctx,
+		"SELECT id, slug, name, description FROM collections WHERE "+column+" = ?", value,
+	).Scan(&c.ID, &c.Slug, &c.Name, &c.Description)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		return app.Collection{}, fmt.Errorf("%w: for %s: %v", app.ErrCollectionNotFound, label, value)
+	case err != nil:
+		return app.Collection{}, fmt.Errorf("failed to query collection: %w", err)
+	}
+	if err := r.loadProducts(ctx, &c); err != nil {
+		return app.Collection{}, err
+	}
+	return c, nil
+}
+
+// loadProducts adds the IDs of the collection's products in their curated order
+func (r *SQLiteCollectionRepo) loadProducts(ctx context.Context, collection *app.Collection) error {
+	rows, err := r.db.QueryContext(ctx,
+		"SELECT product_id FROM collection_products WHERE collection_id = ? ORDER BY position", collection.ID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to query collection products: %w", err)
+	}
+	defer rows.Close()
+
+	collection.ProductIDs = []int{}
+	for rows.Next() {
+		var productID int
+		if err := rows.Scan(&productID); err != nil {
+			return fmt.Errorf("failed to scan collection product: %w", err)
+		}
+		collection.ProductIDs = append(collection.ProductIDs, productID)
+	}
+	return rows.Err()
+}
+
+// saveCollectionProducts replaces the products of the collection, keeping their order
+func saveCollectionProducts(ctx context.Context, tx *sql.Tx, collection app.Collection) error {
+	if _, err := tx.ExecContext(ctx, "DELETE FROM collection_products WHERE collection_id = ?", collection.ID); err != nil {
+		return fmt.Errorf("failed to delete collection products: %w", err)
+	}
+	for i, productID := range collection.ProductIDs {
+		if _, err := tx.ExecContext(ctx,
+			"INSERT INTO collection_products (collection_id, product_id, position) VALUES (?, ?, ?)",
+			collection.ID, productID, i,
+		); err != nil {
+			if strings.Contains(err.Error(), "FOREIGN KEY constraint failed") {
+				return fmt.Errorf("%w: for ID: %d", app.ErrProductNotFound, productID)
+			}
+			return fmt.Errorf("failed to insert collection product: %w", err)
+		}
+	}
+	return nil
+}
+
+func collectionError(err error, collection app.Collection) error {
+	if strings.Contains(err.Error(), "UNIQUE constraint failed") {
+		return fmt.Errorf("%w: %s", app.ErrSlugExists, collection.Slug)
+	}
+	return fmt.Errorf("failed to save collection %s: %w", collection.Slug, err)
+}
@@ -0,0 +1,14 @@
+package storage_test
+
+import (
+	"testing"
+
+	"github.com/gerbenjacobs/go-webshop-course/storage"
+	"github.com/gerbenjacobs/go-webshop-course/storage/storagetest"
+)
+
+func TestSQLiteCollectionRepo(t *testing.T) {
+	storagetest.TestCollectionRepository(t, func(t *testing.T) storage.CollectionRepository {
+		return storage.NewSQLiteCollectionRepo(openTestDB(t))
+	})
+}
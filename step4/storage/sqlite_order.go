@@ -0,0 +1,482 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	app "github.com/gerbenjacobs/go-webshop-course"
+)
+
+type SQLiteOrderRepo struct {
+	db *sql.DB
+}
+
+func NewSQLiteOrderRepo(db *sql.DB) *SQLiteOrderRepo {
+	return &SQLiteOrderRepo{db: db}
+}
+
+func (r *SQLiteOrderRepo) CreateOrder(ctx context.Context, order app.Order) (app.Order, error) {
+	err := inTx(ctx, r.db, func(tx *sql.Tx) error {
+		items, err := basketItemsTx(ctx, tx, order.UserID)
+		if err != nil {
+			return err
+		}
+		if !basketMatchesOrder(items, order.Items) {
+			return app.ErrBasketChanged
+		}
+		// the basket's promotions were checked before, but other orders may have used them up since
+		for _, d := range order.Discounts {
+			if err := checkPromotionUsesTx(ctx, tx, d.PromotionID, order.UserID, 0); err != nil {
+				return err
+			}
+		}
+
+		res, err := tx.ExecContext(ctx,
+			`INSERT INTO orders (user_id, status, subtotal, shipping, discount, country, prices_include_tax, tax, total, currency, payment_id, created_at, reserved_until)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+			order.UserID, order.Status, order.Subtotal, order.Shipping, order.Discount, order.Country, order.PricesIncludeTax, order.Tax,
+			order.Total, order.Total.Currency, order.PaymentID, order.CreatedAt, order.ReservedUntil.Unix(),
+		)
+		if err != nil {
+			return fmt.Errorf("failed to insert order: %w", err)
+		}
+		id, err := res.LastInsertId()
+		if err != nil {
+			return fmt.Errorf("failed to fetch order ID: %w", err)
+		}
+		order.ID = int(id)
+
+		for _, item := range order.Items {
+			_, err := tx.ExecContext(ctx,
+				`INSERT INTO order_items (order_id, product_id, variant_id, sku, name, variant, unit_price, currency, tax_class, quantity)
+				VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+				order.ID, item.ProductID, item.VariantID, item.SKU, item.Name, item.Variant, item.UnitPrice, item.UnitPrice.Currency, item.TaxClass, item.Quantity,
+			)
+			if err != nil {
+				return fmt.Errorf("failed to insert order item: %w", err)
+			}
+		}
+		if err := reserveTx(ctx, tx, order.ID, order.Items, order.ReservedUntil); err != nil {
+			return err
+		}
+		for _, d := range order.Discounts {
+			_, err := tx.ExecContext(ctx,
+				"INSERT INTO order_discounts (order_id, promotion_id, code, name, amount, currency) VALUES (?, ?, ?, ?, ?, ?)",
+				order.ID, d.PromotionID, d.Code, d.Name, d.Amount, d.Amount.Currency,
+			)
+			if err != nil {
+				return fmt.Errorf("failed to insert order discount: %w", err)
+			}
+		}
+		for _, tl := range order.Taxes {
+			_, err := tx.ExecContext(ctx,
+				"INSERT INTO order_taxes (order_id, class, rate, base, amount, currency) VALUES (?, ?, ?, ?, ?, ?)",
+				order.ID, tl.Class, tl.Rate, tl.Base, tl.Amount, tl.Amount.Currency,
+			)
+			if err != nil {
+				return fmt.Errorf("failed to insert order tax: %w", err)
+			}
+		}
+
+		if _, err := tx.ExecContext(ctx, "DELETE FROM basket_items WHERE user_id = ?", order.UserID); err != nil {
+			return fmt.Errorf("failed to empty basket: %w", err)
+		}
+		if _, err := tx.ExecContext(ctx, "UPDATE baskets SET coupon = '' WHERE user_id = ?", order.UserID); err != nil {
+			return fmt.Errorf("failed to remove coupon: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return app.Order{}, err
+	}
+	return order, nil
+}
+
+func (r *SQLiteOrderRepo) GetOrder(ctx context.Context, orderID int) (app.Order, error) {
+	var (
+		order         app.Order
+		reservedUntil int64
+	)
+	err := r.db.QueryRowContext(ctx,
+		"SELECT id, user_id, status, subtotal, shipping, discount, country, prices_include_tax, tax, total, currency, payment_id, created_at, reserved_until FROM orders WHERE id = ?", orderID,
+	).Scan(&order.ID, &order.UserID, &order.Status, &order.Subtotal, &order.Shipping, &order.Discount, &order.Country, &order.PricesIncludeTax, &order.Tax, &order.Total, &order.Total.Currency, &order.PaymentID, &order.CreatedAt, &reservedUntil)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		return app.Order{}, fmt.Errorf("%w: for ID: %d", app.ErrOrderNotFound, orderID)
+	case err != nil:
+		return app.Order{}, fmt.Errorf("failed to query order %d: %w", orderID, err)
+	}
+	order.ReservedUntil = unixTime(reservedUntil)
+
+	if err := r.loadLines(ctx, &order); err != nil {
+		return app.Order{}, err
+	}
+	return order, nil
+}
+
+func (r *SQLiteOrderRepo) GetOrdersByUser(ctx context.Context, userID int) ([]app.Order, error) {
+	return r.queryOrders(ctx, "WHERE user_id = ?", userID)
+}
+
+func (r *SQLiteOrderRepo) GetAllOrders(ctx context.Context, status app.OrderStatus) ([]app.Order, error) {
+	if status == "" {
+		return r.queryOrders(ctx, "")
+	}
+	return r.queryOrders(ctx, "WHERE status = ?", status)
+}
+
+// queryOrders fetches the orders matching the where clause with their lines, newest first
+func (r *SQLiteOrderRepo) queryOrders(ctx context.Context, where string, args ...any) ([]app.Order, error) {
+	rows, err := r.db.QueryContext(ctx,
+		"SELECT id, user_id, status, subtotal, shipping, discount, country, prices_include_tax, tax, total, currency, payment_id, created_at, reserved_until FROM orders "+where+" ORDER BY id DESC", args...,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query orders: %w", err)
+	}
+	defer rows.Close()
+
+	orders := []app.Order{}
+	for rows.Next() {
+		var (
+			order         app.Order
+			reservedUntil int64
+		)
+		if err := rows.Scan(&order.ID, &order.UserID, &order.Status, &order.Subtotal, &order.Shipping, &order.Discount, &order.Country, &order.PricesIncludeTax, &order.Tax, &order.Total, &order.Total.Currency, &order.PaymentID, &order.CreatedAt, &reservedUntil); err != nil {
+			return nil, fmt.Errorf("failed to scan order: %w", err)
+		}
+		order.ReservedUntil = unixTime(reservedUntil)
+		orders = append(orders, order)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for i := range orders {
+		if err := r.loadLines(ctx, &orders[i]); err != nil {
+			return nil, err
+		}
+	}
+	return orders, nil
+}
+
+func (r *SQLiteOrderRepo) UpdateOrder(ctx context.Context, order app.Order, from app.OrderStatus) error {
+	return inTx(ctx, r.db, func(tx *sql.Tx) error {
+		res, err := tx.ExecContext(ctx,
+			"UPDATE orders SET status = ?, payment_id = ? WHERE id = ? AND status = ?",
+			order.Status, order.PaymentID, order.ID, from,
+		)
+		if err != nil {
+			return fmt.Errorf("failed to update order: %w", err)
+		}
+		if n, err := res.RowsAffected(); err == nil && n == 0 {
+			// find out whether it's gone or another request got there first
+			var status app.OrderStatus
+			err := tx.QueryRowContext(ctx, "SELECT status FROM orders WHERE id = ?", order.ID).Scan(&status)
+			switch {
+			case errors.Is(err, sql.ErrNoRows):
+				return fmt.Errorf("%w: for ID: %d", app.ErrOrderNotFound, order.ID)
+			case err != nil:
+				return fmt.Errorf("failed to query order %d: %w", order.ID, err)
+			}
+			return fmt.Errorf("%w: order %d is %s, not %s", app.ErrOrderChanged, order.ID, status, from)
+		}
+		if order.Status == app.OrderStatusPaid && from.Payable() {
+			// only paid orders count as uses, so pending orders with the same promotion can't all be paid
+			if err := checkOrderPromotionsTx(ctx, tx, order.ID); err != nil {
+				return err
+			}
+			return commitStockTx(ctx, tx, order.ID)
+		}
+		return nil
+	})
+}
+
+func (r *SQLiteOrderRepo) ReserveStock(ctx context.Context, orderID int, until time.Time) error {
+	return inTx(ctx, r.db, func(tx *sql.Tx) error {
+		res, err := tx.ExecContext(ctx, "UPDATE orders SET reserved_until = ? WHERE id = ?", until.Unix(), orderID)
+		if err != nil {
+			return fmt.Errorf("failed to update order: %w", err)
+		}
+		if n, err := res.RowsAffected(); err == nil && n == 0 {
+			return fmt.Errorf("%w: for ID: %d", app.ErrOrderNotFound, orderID)
+		}
+
+		rows, err := tx.QueryContext(ctx,
+			"SELECT product_id, variant_id, quantity FROM order_items WHERE order_id = ? ORDER BY rowid", orderID,
+		)
+		if err != nil {
+			return fmt.Errorf("failed to query order items: %w", err)
+		}
+		var items []app.OrderItem
+		for rows.Next() {
+			var item app.OrderItem
+			if err := rows.Scan(&item.ProductID, &item.VariantID, &item.Quantity); err != nil {
+				rows.Close()
+				return fmt.Errorf("failed to scan order item: %w", err)
+			}
+			items = append(items, item)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return err
+		}
+		return reserveTx(ctx, tx, orderID, items, until)
+	})
+}
+
+// loadLines fetches the items and tax lines of an order
+func (r *SQLiteOrderRepo) loadLines(ctx context.Context, order *app.Order) error {
+	var err error
+	order.Items, err = r.orderItems(ctx, order.ID)
+	if err != nil {
+		return err
+	}
+	order.Taxes, err = r.orderTaxes(ctx, order.ID)
+	if err != nil {
+		return err
+	}
+	order.Discounts, err = r.orderDiscounts(ctx, order.ID)
+	if err != nil {
+		return err
+	}
+	// all amounts share the currency of the total
+	order.Subtotal.Currency = order.Total.Currency
+	order.Shipping.Currency = order.Total.Currency
+	order.Discount.Currency = order.Total.Currency
+	order.Tax.Currency = order.Total.Currency
+	return nil
+}
+
+func (r *SQLiteOrderRepo) orderDiscounts(ctx context.Context, orderID int) ([]app.AppliedDiscount, error) {
+	rows, err := r.db.QueryContext(ctx,
+		"SELECT promotion_id, code, name, amount, currency FROM order_discounts WHERE order_id = ? ORDER BY rowid", orderID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query order discounts: %w", err)
+	}
+	defer rows.Close()
+
+	discounts := []app.AppliedDiscount{}
+	for rows.Next() {
+		var d app.AppliedDiscount
+		if err := rows.Scan(&d.PromotionID, &d.Code, &d.Name, &d.Amount, &d.Amount.Currency); err != nil {
+			return nil, fmt.Errorf("failed to scan order discount: %w", err)
+		}
+		discounts = append(discounts, d)
+	}
+	return discounts, rows.Err()
+}
+
+func (r *SQLiteOrderRepo) CountPromotionUses(ctx context.Context, promotionID, userID int) (total, byUser int, err error) {
+	err = r.db.QueryRowContext(ctx, countPromotionUses, userID, promotionID, app.OrderStatusPaid, 0).Scan(&total, &byUser)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to count promotion uses: %w", err)
+	}
+	return total, byUser, nil
+}
+
+// countPromotionUses counts the paid orders with a discount of the promotion, in total and of the user,
+// it needs the user ID, promotion ID, paid status and the ID of an order to leave out as parameters
+const countPromotionUses = `SELECT COUNT(*), COALESCE(SUM(o.user_id = ?), 0)
+	FROM order_discounts d JOIN orders o ON o.id = d.order_id
+	WHERE d.promotion_id = ? AND o.status = ? AND o.id != ?`
+
+// checkPromotionUsesTx makes sure the promotion can be used once more by the user for the order,
+// orderID is 0 for an order that isn't stored yet
+func checkPromotionUsesTx(ctx context.Context, tx *sql.Tx, promotionID, userID, orderID int) error {
+	var p app.Promotion
+	err := tx.QueryRowContext(ctx,
+		"SELECT id, name, max_uses, max_uses_per_customer FROM promotions WHERE id = ?", promotionID,
+	).Scan(&p.ID, &p.Name, &p.MaxUses, &p.MaxUsesPerCustomer)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		return fmt.Errorf("%w: for ID: %d", app.ErrPromotionNotFound, promotionID)
+	case err != nil:
+		return fmt.Errorf("failed to query promotion %d: %w", promotionID, err)
+	}
+
+	var total, byUser int
+	if err := tx.QueryRowContext(ctx, countPromotionUses, userID, promotionID, app.OrderStatusPaid, orderID).Scan(&total, &byUser); err != nil {
+		return fmt.Errorf("failed to count promotion uses: %w", err)
+	}
+	return p.CheckUses(total, byUser)
+}
+
+// checkOrderPromotionsTx makes sure the promotions of an order that becomes paid haven't
+// been used up by its user or others in the meantime
+func checkOrderPromotionsTx(ctx context.Context, tx *sql.Tx, orderID int) error {
+	var userID int
+	if err := tx.QueryRowContext(ctx, "SELECT user_id FROM orders WHERE id = ?", orderID).Scan(&userID); err != nil {
+		return fmt.Errorf("failed to query order %d: %w", orderID, err)
+	}
+	rows, err := tx.QueryContext(ctx, "SELECT promotion_id FROM order_discounts WHERE order_id = ? ORDER BY rowid", orderID)
+	if err != nil {
+		return fmt.Errorf("failed to query order discounts: %w", err)
+	}
+	var promotionIDs []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return fmt.Errorf("failed to scan order discount: %w", err)
+		}
+		promotionIDs = append(promotionIDs, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, id := range promotionIDs {
+		if err := checkPromotionUsesTx(ctx, tx, id, userID, orderID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *SQLiteOrderRepo) orderTaxes(ctx context.Context, orderID int) ([]app.TaxLine, error) {
+	rows, err := r.db.QueryContext(ctx,
+		"SELECT class, rate, base, amount, currency FROM order_taxes WHERE order_id = ? ORDER BY rate DESC", orderID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query order taxes: %w", err)
+	}
+	defer rows.Close()
+
+	taxes := []app.TaxLine{}
+	for rows.Next() {
+		var tl app.TaxLine
+		if err := rows.Scan(&tl.Class, &tl.Rate, &tl.Base, &tl.Amount, &tl.Amount.Currency); err != nil {
+			return nil, fmt.Errorf("failed to scan order tax: %w", err)
+		}
+		tl.Base.Currency = tl.Amount.Currency
+		taxes = append(taxes, tl)
+	}
+	return taxes, rows.Err()
+}
+
+func (r *SQLiteOrderRepo) orderItems(ctx context.Context, orderID int) ([]app.OrderItem, error) {
+	rows, err := r.db.QueryContext(ctx,
+		"SELECT product_id, variant_id, sku, name, variant, unit_price, currency, tax_class, quantity FROM order_items WHERE order_id = ? ORDER BY rowid", orderID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query order items: %w", err)
+	}
+	defer rows.Close()
+
+	items := []app.OrderItem{}
+	for rows.Next() {
+		var item app.OrderItem
+		if err := rows.Scan(&item.ProductID, &item.VariantID, &item.SKU, &item.Name, &item.Variant, &item.UnitPrice, &item.UnitPrice.Currency, &item.TaxClass, &item.Quantity); err != nil {
+			return nil, fmt.Errorf("failed to scan order item: %w", err)
+		}
+		items = append(items, item)
+	}
+	return items, rows.Err()
+}
+
+func basketItemsTx(ctx context.Context, tx *sql.Tx, userID int) ([]app.BasketItem, error) {
+	rows, err := tx.QueryContext(ctx,
+		"SELECT product_id, variant_id, quantity FROM basket_items WHERE user_id = ? ORDER BY rowid", userID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query basket items: %w", err)
+	}
+	defer rows.Close()
+
+	var items []app.BasketItem
+	for rows.Next() {
+		var item app.BasketItem
+		if err := rows.Scan(&item.ProductID, &item.VariantID, &item.Quantity); err != nil {
+			return nil, fmt.Errorf("failed to scan basket item: %w", err)
+		}
+		items = append(items, item)
+	}
+	return items, rows.Err()
+}
+
+// reserveTx holds the stock of the items for the order until the given time,
+// replacing the order's earlier reservation. Expired reservations are cleaned up on the way.
+func reserveTx(ctx context.Context, tx *sql.Tx, orderID int, items []app.OrderItem, until time.Time) error {
+	_, err := tx.ExecContext(ctx,
+		"DELETE FROM stock_reservations WHERE order_id = ? OR expires_at <= ?", orderID, time.Now().Unix(),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to clean up reservations: %w", err)
+	}
+
+	for _, item := range items {
+		var available int
+		if item.VariantID == 0 {
+			err = tx.QueryRowContext(ctx,
+				`SELECT stock - (SELECT COALESCE(SUM(quantity), 0) FROM stock_reservations WHERE product_id = p.id AND variant_id = 0)
+				FROM products p WHERE id = ?`, item.ProductID,
+			).Scan(&available)
+		} else {
+			err = tx.QueryRowContext(ctx,
+				`SELECT stock - (SELECT COALESCE(SUM(quantity), 0) FROM stock_reservations WHERE variant_id = v.id)
+				FROM product_variants v WHERE id = ? AND product_id = ?`, item.VariantID, item.ProductID,
+			).Scan(&available)
+		}
+		switch {
+		case errors.Is(err, sql.ErrNoRows) && item.VariantID == 0:
+			return fmt.Errorf("%w: for ID: %d", app.ErrProductNotFound, item.ProductID)
+		case errors.Is(err, sql.ErrNoRows):
+			return fmt.Errorf("%w: product %d has no variant %d", app.ErrVariantNotFound, item.ProductID, item.VariantID)
+		case err != nil:
+			return fmt.Errorf("failed to query stock: %w", err)
+		}
+		if available < item.Quantity {
+			return fmt.Errorf("%w: %d available for product ID: %d", app.ErrInsufficientStock, max(available, 0), item.ProductID)
+		}
+
+		_, err = tx.ExecContext(ctx,
+			"INSERT INTO stock_reservations (order_id, product_id, variant_id, quantity, expires_at) VALUES (?, ?, ?, ?, ?)",
+			orderID, item.ProductID, item.VariantID, item.Quantity, until.Unix(),
+		)
+		if err != nil {
+			return fmt.Errorf("failed to reserve stock: %w", err)
+		}
+	}
+	return nil
+}
+
+// commitStockTx takes the items of a paid order out of the stock and releases its reservation.
+// Stock can drop below zero when the reservation expired before the payment came in,
+// those items are oversold and need restocking.
+func commitStockTx(ctx context.Context, tx *sql.Tx, orderID int) error {
+	_, err := tx.ExecContext(ctx,
+		`UPDATE products SET stock = stock - (
+			SELECT SUM(quantity) FROM order_items WHERE order_id = ? AND product_id = products.id AND variant_id = 0
+		) WHERE id IN (SELECT product_id FROM order_items WHERE order_id = ? AND variant_id = 0)`,
+		orderID, orderID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to update product stock: %w", err)
+	}
+	_, err = tx.ExecContext(ctx,
+		`UPDATE product_variants SET stock = stock - (
+			SELECT SUM(quantity) FROM order_items WHERE order_id = ? AND variant_id = product_variants.id
+		) WHERE id IN (SELECT variant_id FROM order_items WHERE order_id = ?)`,
+		orderID, orderID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to update variant stock: %w", err)
+	}
+	if _, err := tx.ExecContext(ctx, "DELETE FROM stock_reservations WHERE order_id = ?", orderID); err != nil {
+		return fmt.Errorf("failed to release reservation: %w", err)
+	}
+	return nil
+}
+
+// unixTime turns the unix seconds we store back into a time, 0 is the zero time
+func unixTime(sec int64) time.Time {
+	if sec == 0 {
+		return time.Time{}
+	}
+	return time.Unix(sec, 0).UTC()
+}
@@ -0,0 +1,19 @@
+package storage_test
+
+import (
+	"testing"
+
+	"github.com/gerbenjacobs/go-webshop-course/storage"
+	"github.com/gerbenjacobs/go-webshop-course/storage/storagetest"
+)
+
+func TestSQLiteOrderRepo(t *testing.T) {
+	storagetest.TestOrderRepository(t, func(t *testing.T) storagetest.OrderRepos {
+		db := openTestDB(t)
+		return storagetest.OrderRepos{
+			Orders:   storage.NewSQLiteOrderRepo(db),
+			Baskets:  storage.NewSQLiteBasketRepo(db),
+			Products: storage.NewSQLiteProductRepo(db),
+		}
+	})
+}
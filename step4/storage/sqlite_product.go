@@ -0,0 +1,347 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	app "github.com/gerbenjacobs/go-webshop-course"
+)
+
+type SQLiteProductRepo struct {
+	db *sql.DB
+}
+
+func NewSQLiteProductRepo(db *sql.DB) *SQLiteProductRepo {
+	return &SQLiteProductRepo{db: db}
+}
+
+// productColumns selects a product with its active reservations, it needs the current unix time as parameter
+const productColumns = `id, name, description, image, price, currency, tax_class, stock,
+	(SELECT COALESCE(SUM(quantity), 0) FROM stock_reservations r WHERE r.product_id = products.id AND r.variant_id = 0 AND r.expires_at > ?)`
+
+func (p *SQLiteProductRepo) GetAllProducts(ctx context.Context) ([]app.Product, error) {
+	rows, err := p.db.QueryContext(ctx, "SELECT "+productColumns+" FROM products ORDER BY id", time.Now().Unix())
+	if err != nil {
+		return nil, fmt.Errorf("failed to query products: %w", err)
+	}
+	defer rows.Close()
+
+	var products []app.Product
+	for rows.Next() {
+		var product app.Product
+		if err := rows.Scan(&product.ID, &product.Name, &product.Description, &product.Image, &product.Price, &product.Price.Currency, &product.TaxClass, &product.Stock, &product.Reserved); err != nil {
+			return nil, fmt.Errorf("failed to scan product: %w", err)
+		}
+		products = append(products, product)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	// we only have a single connection, so the rows need to be closed before loading the variants
+	rows.Close()
+
+	for i := range products {
+		if err := p.loadVariants(ctx, &products[i]); err != nil {
+			return nil, err
+		}
+		if err := p.loadCategories(ctx, &products[i]); err != nil {
+			return nil, err
+		}
+	}
+	return products, nil
+}
+
+func (p *SQLiteProductRepo) GetProduct(ctx context.Context, productID int) (app.Product, error) {
+	var product app.Product
+	err := p.db.QueryRowContext(ctx,
+		"SELECT "+productColumns+" FROM products WHERE id = ?", time.Now().Unix(), productID,
+	).Scan(&product.ID, &product.Name, &product.Description, &product.Image, &product.Price, &product.Price.Currency, &product.TaxClass, &product.Stock, &product.Reserved)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		return app.Product{}, fmt.Errorf("%w: for ID: %d", app.ErrProductNotFound, productID)
+	case err != nil:
+		return app.Product{}, fmt.Errorf("failed to query product %d: %w", productID, err)
+	}
+	if err := p.loadVariants(ctx, &product); err != nil {
+		return app.Product{}, err
+	}
+	if err := p.loadCategories(ctx, &product); err != nil {
+		return app.Product{}, err
+	}
+	return product, nil
+}
+
+func (p *SQLiteProductRepo) CreateProduct(ctx context.Context, product app.Product) (app.Product, error) {
+	// new products only have new variants
+	product.Variants = slices.Clone(product.Variants)
+	for i := range product.Variants {
+		product.Variants[i].ID = 0
+	}
+	err := inTx(ctx, p.db, func(tx *sql.Tx) error {
+		res, err := tx.ExecContext(ctx,
+			`INSERT INTO products (name, description, image, price, currency, tax_class, stock)
+			VALUES (?, ?, ?, ?, ?, ?, ?)`,
+			product.Name, product.Description, product.Image, product.Price, product.Price.Currency, product.TaxClass, product.Stock,
+		)
+		if err != nil {
+			return fmt.Errorf("failed to create product: %w", err)
+		}
+		id, err := res.LastInsertId()
+		if err != nil {
+			return fmt.Errorf("failed to fetch product ID: %w", err)
+		}
+		product.ID = int(id)
+		if err := saveCategories(ctx, tx, product); err != nil {
+			return err
+		}
+		return saveVariants(ctx, tx, product)
+	})
+	if err != nil {
+		return app.Product{}, err
+	}
+	return p.GetProduct(ctx, product.ID)
+}
+
+func (p *SQLiteProductRepo) UpdateProduct(ctx context.Context, product app.Product) (app.Product, error) {
+	err := inTx(ctx, p.db, func(tx *sql.Tx) error {
+		res, err := tx.ExecContext(ctx,
+			`UPDATE products SET name = ?, description = ?, image = ?, price = ?, currency = ?, tax_class = ?, stock = ?
+			WHERE id = ?`,
+			product.Name, product.Description, product.Image, product.Price, product.Price.Currency, product.TaxClass, product.Stock, product.ID,
+		)
+		if err != nil {
+			return fmt.Errorf("failed to update product %d: %w", product.ID, err)
+		}
+		if n, err := res.RowsAffected(); err == nil && n == 0 {
+			return fmt.Errorf("%w: for ID: %d", app.ErrProductNotFound, product.ID)
+		}
+		if err := saveCategories(ctx, tx, product); err != nil {
+			return err
+		}
+		return saveVariants(ctx, tx, product)
+	})
+	if err != nil {
+		return app.Product{}, err
+	}
+	return p.GetProduct(ctx, product.ID)
+}
+
+func (p *SQLiteProductRepo) DeleteProduct(ctx context.Context, productID int) error {
+	// options and variants go with it, see the ON DELETE CASCADE of their tables
+	res, err := p.db.ExecContext(ctx, "DELETE FROM products WHERE id = ?", productID)
+	if err != nil {
+		return fmt.Errorf("failed to delete product %d: %w", productID, err)
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return fmt.Errorf("%w: for ID: %d", app.ErrProductNotFound, productID)
+	}
+	return nil
+}
+
+// saveCategories replaces the categories the product is listed in
+func saveCategories(ctx context.Context, tx *sql.Tx, product app.Product) error {
+	if _, err := tx.ExecContext(ctx, "DELETE FROM product_categories WHERE product_id = ?", product.ID); err != nil {
+		return fmt.Errorf("failed to delete product categories: %w", err)
+	}
+	for _, categoryID := range product.CategoryIDs {
+		if _, err := tx.ExecContext(ctx,
+			"INSERT INTO product_categories (product_id, category_id) VALUES (?, ?)", product.ID, categoryID,
+		); err != nil {
+			if strings.Contains(err.Error(), "FOREIGN KEY constraint failed") {
+				return fmt.Errorf("%w: for ID: %d", app.ErrCategoryNotFound, categoryID)
+			}
+			return fmt.Errorf("failed to insert product category: %w", err)
+		}
+	}
+	return nil
+}
+
+// saveVariants replaces the options of the product and brings its variants in line, see UpdateProduct
+func saveVariants(ctx context.Context, tx *sql.Tx, product app.Product) error {
+	if _, err := tx.ExecContext(ctx, "DELETE FROM product_options WHERE product_id = ?", product.ID); err != nil {
+		return fmt.Errorf("failed to delete product options: %w", err)
+	}
+	for i, o := range product.Options {
+		if _, err := tx.ExecContext(ctx,
+			"INSERT INTO product_options (product_id, position, name) VALUES (?, ?, ?)", product.ID, i, o.Name,
+		); err != nil {
+			return fmt.Errorf("failed to insert product option: %w", err)
+		}
+		for j, value := range o.Values {
+			if _, err := tx.ExecContext(ctx,
+				"INSERT INTO product_option_values (product_id, option_position, position, value) VALUES (?, ?, ?, ?)",
+				product.ID, i, j, value,
+			); err != nil {
+				return fmt.Errorf("failed to insert product option value: %w", err)
+			}
+		}
+	}
+
+	rows, err := tx.QueryContext(ctx, "SELECT id FROM product_variants WHERE product_id = ?", product.ID)
+	if err != nil {
+		return fmt.Errorf("failed to query product variants: %w", err)
+	}
+	existing := map[int]bool{}
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return fmt.Errorf("failed to scan product variant: %w", err)
+		}
+		existing[id] = true
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, v := range product.Variants {
+		var price sql.NullInt64
+		if v.Price != nil {
+			price = sql.NullInt64{Int64: v.Price.Amount, Valid: true}
+		}
+		variantID := v.ID
+		if variantID == 0 {
+			res, err := tx.ExecContext(ctx,
+				"INSERT INTO product_variants (product_id, sku, price, stock, image) VALUES (?, ?, ?, ?, ?)",
+				product.ID, v.SKU, price, v.Stock, v.Image,
+			)
+			if err != nil {
+				return variantError(err, v.SKU)
+			}
+			id, err := res.LastInsertId()
+			if err != nil {
+				return fmt.Errorf("failed to fetch variant ID: %w", err)
+			}
+			variantID = int(id)
+		} else {
+			if !existing[variantID] {
+				return fmt.Errorf("%w: product %d has no variant %d", app.ErrVariantNotFound, product.ID, variantID)
+			}
+			delete(existing, variantID)
+			if _, err := tx.ExecContext(ctx,
+				"UPDATE product_variants SET sku = ?, price = ?, stock = ?, image = ? WHERE id = ?",
+				v.SKU, price, v.Stock, v.Image, variantID,
+			); err != nil {
+				return variantError(err, v.SKU)
+			}
+			if _, err := tx.ExecContext(ctx, "DELETE FROM variant_options WHERE variant_id = ?", variantID); err != nil {
+				return fmt.Errorf("failed to delete variant options: %w", err)
+			}
+		}
+		for name, value := range v.Options {
+			if _, err := tx.ExecContext(ctx,
+				"INSERT INTO variant_options (variant_id, name, value) VALUES (?, ?, ?)", variantID, name, value,
+			); err != nil {
+				return fmt.Errorf("failed to insert variant option: %w", err)
+			}
+		}
+	}
+
+	// the variants that are left weren't part of the product anymore
+	for id := range existing {
+		if _, err := tx.ExecContext(ctx, "DELETE FROM product_variants WHERE id = ?", id); err != nil {
+			return fmt.Errorf("failed to delete variant %d: %w", id, err)
+		}
+	}
+	return nil
+}
+
+func variantError(err error, sku string) error {
+	if strings.Contains(err.Error(), "UNIQUE constraint failed") {
+		return fmt.Errorf("%w: %s", app.ErrSKUExists, sku)
+	}
+	return fmt.Errorf("failed to save variant %s: %w", sku, err)
+}
+
+// loadVariants adds the options and variants to the product
+func (p *SQLiteProductRepo) loadVariants(ctx context.Context, product *app.Product) error {
+	rows, err := p.db.QueryContext(ctx,
+		`SELECT o.name, v.value
+		FROM product_options o JOIN product_option_values v ON v.product_id = o.product_id AND v.option_position = o.position
+		WHERE o.product_id = ? ORDER BY o.position, v.position`, product.ID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to query product options: %w", err)
+	}
+	product.Options = nil
+	for rows.Next() {
+		var name, value string
+		if err := rows.Scan(&name, &value); err != nil {
+			rows.Close()
+			return fmt.Errorf("failed to scan product option: %w", err)
+		}
+		if n := len(product.Options); n == 0 || product.Options[n-1].Name != name {
+			product.Options = append(product.Options, app.ProductOption{Name: name})
+		}
+		last := &product.Options[len(product.Options)-1]
+		last.Values = append(last.Values, value)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	rows, err = p.db.QueryContext(ctx,
+		`SELECT v.id, v.sku, v.price, v.stock, v.image, o.name, o.value,
+			(SELECT COALESCE(SUM(quantity), 0) FROM stock_reservations r WHERE r.variant_id = v.id AND r.expires_at > ?)
+		FROM product_variants v LEFT JOIN variant_options o ON o.variant_id = v.id
+		WHERE v.product_id = ? ORDER BY v.id`, time.Now().Unix(), product.ID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to query product variants: %w", err)
+	}
+	defer rows.Close()
+
+	product.Variants = nil
+	for rows.Next() {
+		var (
+			v           app.Variant
+			price       sql.NullInt64
+			name, value sql.NullString
+		)
+		if err := rows.Scan(&v.ID, &v.SKU, &price, &v.Stock, &v.Image, &name, &value, &v.Reserved); err != nil {
+			return fmt.Errorf("failed to scan product variant: %w", err)
+		}
+		// every option of a variant is a row of its own
+		if n := len(product.Variants); n == 0 || product.Variants[n-1].ID != v.ID {
+			v.ProductID = product.ID
+			v.Options = map[string]string{}
+			if price.Valid {
+				override := app.NewMoney(price.Int64, product.Price.Currency)
+				v.Price = &override
+			}
+			product.Variants = append(product.Variants, v)
+		}
+		if name.Valid {
+			product.Variants[len(product.Variants)-1].Options[name.String] = value.String
+		}
+	}
+	return rows.Err()
+}
+
+// loadCategories adds the IDs of the categories the product is listed in
+func (p *SQLiteProductRepo) loadCategories(ctx context.Context, product *app.Product) error {
+	rows, err := p.db.QueryContext(ctx,
+		"SELECT category_id FROM product_categories WHERE product_id = ? ORDER BY category_id", product.ID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to query product categories: %w", err)
+	}
+	defer rows.Close()
+
+	product.CategoryIDs = nil
+	for rows.Next() {
+		var categoryID int
+		if err := rows.Scan(&categoryID); err != nil {
+			return fmt.Errorf("failed to scan product category: %w", err)
+		}
+		product.CategoryIDs = append(product.CategoryIDs, categoryID)
+	}
+	return rows.Err()
+}
@@ -0,0 +1,23 @@
+package storage_test
+
+import (
+	"testing"
+
+	"github.com/gerbenjacobs/go-webshop-course/storage"
+	"github.com/gerbenjacobs/go-webshop-course/storage/storagetest"
+)
+
+func TestSQLiteProductRepo(t *testing.T) {
+	storagetest.TestProductRepository(t, func(t *testing.T) storage.ProductRepository {
+		return storage.NewSQLiteProductRepo(openTestDB(t))
+	})
+}
+
+func TestSQLiteProductRepoStress(t *testing.T) {
+	db := openTestDB(t)
+	storagetest.StressProductRepository(t, storagetest.OrderRepos{
+		Orders:   storage.NewSQLiteOrderRepo(db),
+		Baskets:  storage.NewSQLiteBasketRepo(db),
+		Products: storage.NewSQLiteProductRepo(db),
+	})
+}
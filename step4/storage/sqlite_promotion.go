@@ -0,0 +1,117 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	app "github.com/gerbenjacobs/go-webshop-course"
+)
+
+type SQLitePromotionRepo struct {
+	db *sql.DB
+}
+
+func NewSQLitePromotionRepo(db *sql.DB) *SQLitePromotionRepo {
+	return &SQLitePromotionRepo{db: db}
+}
+
+const promotionColumns = `id, code, name, type, percentage, amount, buy_quantity, get_quantity,
+	minimum_subtotal, currency, starts_at, ends_at, max_uses, max_uses_per_customer`
+
+func (r *SQLitePromotionRepo) GetPromotion(ctx context.Context, promotionID int) (app.Promotion, error) {
+	p, err := r.scanPromotion(ctx, r.db.QueryRowContext(ctx,
+		"SELECT "+promotionColumns+" FROM promotions WHERE id = ?", promotionID,
+	))
+	if errors.Is(err, sql.ErrNoRows) {
+		return app.Promotion{}, fmt.Errorf("%w: for ID: %d", app.ErrPromotionNotFound, promotionID)
+	}
+	return p, err
+}
+
+func (r *SQLitePromotionRepo) GetPromotionByCode(ctx context.Context, code string) (app.Promotion, error) {
+	p, err := r.scanPromotion(ctx, r.db.QueryRowContext(ctx,
+		"SELECT "+promotionColumns+" FROM promotions WHERE code = ? COLLATE NOCASE AND code != ''", code,
+	))
+	if errors.Is(err, sql.ErrNoRows) {
+		return app.Promotion{}, fmt.Errorf("%w: for code: %s", app.ErrPromotionNotFound, code)
+	}
+	return p, err
+}
+
+func (r *SQLitePromotionRepo) GetAutomaticPromotions(ctx context.Context) ([]app.Promotion, error) {
+	rows, err := r.db.QueryContext(ctx, "SELECT id FROM promotions WHERE code = '' ORDER BY id")
+	if err != nil {
+		return nil, fmt.Errorf("failed to query promotions: %w", err)
+	}
+	var ids []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to scan promotion: %w", err)
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// we only have a single connection, so the rows need to be closed before loading the targets
+	promotions := make([]app.Promotion, 0, len(ids))
+	for _, id := range ids {
+		p, err := r.GetPromotion(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		promotions = append(promotions, p)
+	}
+	return promotions, nil
+}
+
+func (r *SQLitePromotionRepo) scanPromotion(ctx context.Context, row *sql.Row) (app.Promotion, error) {
+	var (
+		p                app.Promotion
+		currency         string
+		startsAt, endsAt sql.NullTime
+	)
+	err := row.Scan(&p.ID, &p.Code, &p.Name, &p.Type, &p.Percentage, &p.Amount, &p.BuyQuantity, &p.GetQuantity,
+		&p.MinimumSubtotal, &currency, &startsAt, &endsAt, &p.MaxUses, &p.MaxUsesPerCustomer)
+	if err != nil {
+		return app.Promotion{}, err
+	}
+	p.Amount.Currency = currency
+	p.MinimumSubtotal.Currency = currency
+	p.StartsAt = startsAt.Time
+	p.EndsAt = endsAt.Time
+
+	p.ProductIDs, err = r.targets(ctx, "SELECT product_id FROM promotion_products WHERE promotion_id = ? ORDER BY product_id", p.ID)
+	if err != nil {
+		return app.Promotion{}, err
+	}
+	p.CategoryIDs, err = r.targets(ctx, "SELECT category_id FROM promotion_categories WHERE promotion_id = ? ORDER BY category_id", p.ID)
+	if err != nil {
+		return app.Promotion{}, err
+	}
+	return p, nil
+}
+
+func (r *SQLitePromotionRepo) targets(ctx context.Context, query string, promotionID int) ([]int, error) {
+	rows, err := r.db.QueryContext(ctx, query, promotionID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query promotion targets: %w", err)
+	}
+	defer rows.Close()
+
+	var ids []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("failed to scan promotion target: %w", err)
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
@@ -0,0 +1,29 @@
+package storage_test
+
+import (
+	"context"
+	"database/sql"
+	"path/filepath"
+	"testing"
+
+	"github.com/gerbenjacobs/go-webshop-course/storage"
+)
+
+// openTestDB opens a migrated database of its own in the test's temp directory
+func openTestDB(t *testing.T) *sql.DB {
+	t.Helper()
+	ctx := context.Background()
+	db, err := storage.OpenSQLite(ctx, filepath.Join(t.TempDir(), "webshop.db"))
+	if err != nil {
+		t.Fatalf("OpenSQLite: %v", err)
+	}
+	t.Cleanup(func() { _ = db.Close() })
+	// the database is thrown away afterwards, waiting for the disk only slows the tests down
+	if _, err := db.ExecContext(ctx, "PRAGMA synchronous = OFF"); err != nil {
+		t.Fatalf("PRAGMA synchronous: %v", err)
+	}
+	if _, err := storage.Migrate(ctx, db); err != nil {
+		t.Fatalf("Migrate: %v", err)
+	}
+	return db
+}
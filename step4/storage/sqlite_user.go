@@ -0,0 +1,105 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+
+	app "github.com/gerbenjacobs/go-webshop-course"
+)
+
+type SQLiteUserRepo struct {
+	db *sql.DB
+}
+
+func NewSQLiteUserRepo(db *sql.DB) *SQLiteUserRepo {
+	return &SQLiteUserRepo{db: db}
+}
+
+func (r *SQLiteUserRepo) CreateUser(ctx context.Context, user app.User) (app.User, error) {
+	res, err := r.db.ExecContext(ctx,
+		"INSERT INTO users (name, email, password_hash, role, created_at) VALUES (?, ?, ?, ?, ?)",
+		user.Name, user.Email, user.PasswordHash, user.Role, user.CreatedAt,
+	)
+	if err != nil {
+		if strings.Contains(err.Error(), "UNIQUE constraint failed") {
+			return app.User{}, fmt.Errorf("%w: %s", app.ErrUserExists, user.Email)
+		}
+		return app.User{}, fmt.Errorf("failed to create user: %w", err)
+	}
+
+	id, err := res.LastInsertId()
+	if err != nil {
+		return app.User{}, fmt.Errorf("failed to fetch user ID: %w", err)
+	}
+	user.ID = int(id)
+	return user, nil
+}
+
+func (r *SQLiteUserRepo) GetUser(ctx context.Context, userID int) (app.User, error) {
+	user, err := r.scanUser(r.db.QueryRowContext(ctx,
+		"SELECT id, name, email, password_hash, role, created_at FROM users WHERE id = ?", userID,
+	))
+	if errors.Is(err, sql.ErrNoRows) {
+		return app.User{}, fmt.Errorf("%w: for ID: %d", app.ErrUserNotFound, userID)
+	}
+	return user, err
+}
+
+func (r *SQLiteUserRepo) GetUserByEmail(ctx context.Context, email string) (app.User, error) {
+	user, err := r.scanUser(r.db.QueryRowContext(ctx,
+		"SELECT id, name, email, password_hash, role, created_at FROM users WHERE email = ?", email,
+	))
+	if errors.Is(err, sql.ErrNoRows) {
+		return app.User{}, fmt.Errorf("%w: for email: %s", app.ErrUserNotFound, email)
+	}
+	return user, err
+}
+
+func (r *SQLiteUserRepo) UpdateUser(ctx context.Context, user app.User) error {
+	res, err := r.db.ExecContext(ctx,
+		"UPDATE users SET name = ?, email = ?, password_hash = ?, role = ? WHERE id = ?",
+		user.Name, user.Email, user.PasswordHash, user.Role, user.ID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to update user: %w", err)
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return fmt.Errorf("%w: for ID: %d", app.ErrUserNotFound, user.ID)
+	}
+	return nil
+}
+
+func (r *SQLiteUserRepo) SearchUsers(ctx context.Context, query string) ([]app.User, error) {
+	// escape the LIKE wildcards, so searching for "_" doesn't match everyone
+	pattern := "%" + strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(strings.ToLower(query)) + "%"
+	rows, err := r.db.QueryContext(ctx,
+		`SELECT id, name, email, password_hash, role, created_at FROM users
+		WHERE lower(name) LIKE ? ESCAPE '\' OR lower(email) LIKE ? ESCAPE '\' ORDER BY id`, pattern, pattern,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query users: %w", err)
+	}
+	defer rows.Close()
+
+	users := []app.User{}
+	for rows.Next() {
+		var user app.User
+		if err := rows.Scan(&user.ID, &user.Name, &user.Email, &user.PasswordHash, &user.Role, &user.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan user: %w", err)
+		}
+		users = append(users, user)
+	}
+	return users, rows.Err()
+}
+
+func (r *SQLiteUserRepo) scanUser(row *sql.Row) (app.User, error) {
+	var user app.User
+	err := row.Scan(&user.ID, &user.Name, &user.Email, &user.PasswordHash, &user.Role, &user.CreatedAt)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return app.User{}, fmt.Errorf("failed to query user: %w", err)
+	}
+	return user, err
+}
@@ -0,0 +1,14 @@
+package storage_test
+
+import (
+	"testing"
+
+	"github.com/gerbenjacobs/go-webshop-course/storage"
+	"github.com/gerbenjacobs/go-webshop-course/storage/storagetest"
+)
+
+func TestSQLiteUserRepo(t *testing.T) {
+	storagetest.TestUserRepository(t, func(t *testing.T) storage.UserRepository {
+		return storage.NewSQLiteUserRepo(openTestDB(t))
+	})
+}
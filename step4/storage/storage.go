@@ -2,6 +2,7 @@ package storage
 
 import (
 	"context"
+	"time"
 
 	app "github.com/gerbenjacobs/go-webshop-course"
 )
@@ -9,10 +10,115 @@ import (
 type ProductRepository interface {
 	GetAllProducts(context.Context) ([]app.Product, error)
 	GetProduct(ctx context.Context, productID int) (app.Product, error)
+	// CreateProduct stores a new product with its options and variants, and returns it with the IDs it got
+	CreateProduct(ctx context.Context, product app.Product) (app.Product, error)
+	// UpdateProduct replaces the product's fields, options and variants. Variants with an ID
+	// are updated, the ones without are added and the ones left out are removed.
+	// SKUs are unique across all products, a taken SKU fails with ErrSKUExists.
+	UpdateProduct(ctx context.Context, product app.Product) (app.Product, error)
+	// DeleteProduct removes the product and its variants, orders keep their own copy of the items
+	DeleteProduct(ctx context.Context, productID int) error
+}
+
+type CategoryRepository interface {
+	GetAllCategories(ctx context.Context) ([]app.Category, error)
+	GetCategory(ctx context.Context, categoryID int) (app.Category, error)
+	GetCategoryBySlug(ctx context.Context, slug string) (app.Category, error)
+	// CreateCategory and UpdateCategory fail with ErrSlugExists when another category has the slug
+	CreateCategory(ctx context.Context, category app.Category) (app.Category, error)
+	UpdateCategory(ctx context.Context, category app.Category) (app.Category, error)
+	// DeleteCategory removes the category from the products listed in it,
+	// a category with subcategories can't be deleted and fails with ErrCategoryHasChildren
+	DeleteCategory(ctx context.Context, categoryID int) error
+}
+
+type CollectionRepository interface {
+	GetAllCollections(ctx context.Context) ([]app.Collection, error)
+	GetCollection(ctx context.Context, collectionID int) (app.Collection, error)
+	GetCollectionBySlug(ctx context.Context, slug string) (app.Collection, error)
+	// CreateCollection and UpdateCollection store the products in the given order,
+	// they fail with ErrSlugExists when another collection has the slug
+	CreateCollection(ctx context.Context, collection app.Collection) (app.Collection, error)
+	UpdateCollection(ctx context.Context, collection app.Collection) (app.Collection, error)
+	DeleteCollection(ctx context.Context, collectionID int) error
 }
 
 type BasketRepository interface {
 	GetBasket(ctx context.Context, userID int) (app.Basket, error)
-	AddToBasket(ctx context.Context, userID, productID, quantity int) error
-	RemoveFromBasket(ctx context.Context, userID, productID, quantity int) error
+	// AddToBasket, RemoveFromBasket and SetQuantity work on a basket line,
+	// that's a product and its variant, variantID is 0 for products without variants
+	AddToBasket(ctx context.Context, userID, productID, variantID, quantity int) error
+	RemoveFromBasket(ctx context.Context, userID, productID, variantID, quantity int) error
+	SetQuantity(ctx context.Context, userID, productID, variantID, quantity int) error
+	// MergeBaskets moves the items to the other basket, adding up the quantities
+	// of the same lines up to app.MaxLineQuantity
+	MergeBaskets(ctx context.Context, fromUserID, toUserID int) error
+	// SetCoupon stores the coupon code on the basket, an empty code removes it
+	SetCoupon(ctx context.Context, userID int, code string) error
+	// ClearBasket removes all items and the coupon, the basket itself stays
+	ClearBasket(ctx context.Context, userID int) error
+	// DeleteGuestBaskets removes the baskets of guests that were created before the given time
+	// and returns how many there were, the baskets of users stay
+	DeleteGuestBaskets(ctx context.Context, createdBefore time.Time) (int, error)
+}
+
+type UserRepository interface {
+	CreateUser(ctx context.Context, user app.User) (app.User, error)
+	GetUser(ctx context.Context, userID int) (app.User, error)
+	GetUserByEmail(ctx context.Context, email string) (app.User, error)
+	UpdateUser(ctx context.Context, user app.User) error
+	// SearchUsers finds the users whose name or email contains the query, ignoring case,
+	// an empty query returns all users. Users are ordered by ID.
+	SearchUsers(ctx context.Context, query string) ([]app.User, error)
+}
+
+type OrderRepository interface {
+	// CreateOrder stores the order, reserves its stock until ReservedUntil and empties the user's basket in one go.
+	// If the basket no longer matches the order items, there's not enough stock or one of its
+	// promotions got used up in the meantime, nothing is stored.
+	CreateOrder(ctx context.Context, order app.Order) (app.Order, error)
+	GetOrder(ctx context.Context, orderID int) (app.Order, error)
+	GetOrdersByUser(ctx context.Context, userID int) ([]app.Order, error)
+	// GetAllOrders returns the orders of all users with the given status, newest first,
+	// an empty status returns every order
+	GetAllOrders(ctx context.Context, status app.OrderStatus) ([]app.Order, error)
+	// UpdateOrder saves the order's status and payment ID, but only while its stored status is
+	// still from, otherwise it fails with ErrOrderChanged. When an unpaid order becomes paid, its items
+	// are taken out of the stock and the reservation is released in the same go. An order whose
+	// promotions got used up by other paid orders fails with ErrPromotionUsedUp and stays as it was.
+	UpdateOrder(ctx context.Context, order app.Order, from app.OrderStatus) error
+	// ReserveStock holds the stock of the order's items until the given time, replacing its earlier reservation.
+	// It fails with ErrInsufficientStock when there's not enough left for sale.
+	ReserveStock(ctx context.Context, orderID int, until time.Time) error
+	// CountPromotionUses counts the paid orders that got a discount from the promotion,
+	// in total and for the given user
+	CountPromotionUses(ctx context.Context, promotionID, userID int) (total, byUser int, err error)
+}
+
+type PromotionRepository interface {
+	GetPromotion(ctx context.Context, promotionID int) (app.Promotion, error)
+	// GetPromotionByCode finds a coupon, codes are case-insensitive
+	GetPromotionByCode(ctx context.Context, code string) (app.Promotion, error)
+	// GetAutomaticPromotions returns the promotions without a code
+	GetAutomaticPromotions(ctx context.Context) ([]app.Promotion, error)
+}
+
+// lineKey identifies a basket or order line, a product and its variant
+type lineKey struct{ productID, variantID int }
+
+// basketMatchesOrder checks whether the basket still holds exactly the ordered items
+func basketMatchesOrder(items []app.BasketItem, ordered []app.OrderItem) bool {
+	if len(items) != len(ordered) {
+		return false
+	}
+	quantities := make(map[lineKey]int, len(items))
+	for _, item := range items {
+		quantities[lineKey{item.ProductID, item.VariantID}] += item.Quantity
+	}
+	for _, item := range ordered {
+		if quantities[lineKey{item.ProductID, item.VariantID}] != item.Quantity {
+			return false
+		}
+	}
+	return true
 }
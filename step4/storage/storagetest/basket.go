@@ -0,0 +1,371 @@
+// Package storagetest contains conformance tests that every
+// storage implementation has to pass, whether it's in-memory or SQLite.
+package storagetest
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	app "github.com/gerbenjacobs/go-webshop-course"
+	"github.com/gerbenjacobs/go-webshop-course/storage"
+)
+
+// TestBasketRepository runs the basket conformance suite,
+// newRepo is called for every subtest and should return an empty repository.
+//
+//	func TestBasketRepo(t *testing.T) {
+//		storagetest.TestBasketRepository(t, func(t *testing.T) storage.BasketRepository {
+//			return storage.NewBasketRepo()
+//		})
+//	}
+func TestBasketRepository(t *testing.T, newRepo func(t *testing.T) storage.BasketRepository) {
+	ctx := context.Background()
+	const userID, otherUserID = 1, 2
+
+	t.Run("GetBasket creates an empty basket", func(t *testing.T) {
+		repo := newRepo(t)
+		basket, err := repo.GetBasket(ctx, userID)
+		if err != nil {
+			t.Fatalf("GetBasket: %v", err)
+		}
+		if basket.UserID != userID {
+			t.Errorf("UserID = %d, want %d", basket.UserID, userID)
+		}
+		if basket.Items == nil || len(basket.Items) != 0 {
+			t.Errorf("Items = %#v, want empty non-nil slice", basket.Items)
+		}
+	})
+
+	t.Run("GetBasket returns a copy", func(t *testing.T) {
+		repo := newBasket(t, newRepo, userID)
+		mustAdd(t, repo, userID, 1, 1)
+
+		basket, err := repo.GetBasket(ctx, userID)
+		if err != nil {
+			t.Fatalf("GetBasket: %v", err)
+		}
+		basket.Items[0].Quantity = 100
+		basket.Items = append(basket.Items, app.BasketItem{ProductID: 2, Quantity: 1})
+
+		assertItems(t, repo, userID, []app.BasketItem{{ProductID: 1, Quantity: 1}})
+	})
+
+	t.Run("changing a basket that doesn't exist fails", func(t *testing.T) {
+		repo := newRepo(t)
+		if err := repo.AddToBasket(ctx, userID, 1, 0, 1); !errors.Is(err, app.ErrBasketNotFound) {
+			t.Errorf("AddToBasket error = %v, want %v", err, app.ErrBasketNotFound)
+		}
+		if err := repo.RemoveFromBasket(ctx, userID, 1, 0, 1); !errors.Is(err, app.ErrBasketNotFound) {
+			t.Errorf("RemoveFromBasket error = %v, want %v", err, app.ErrBasketNotFound)
+		}
+		if err := repo.SetQuantity(ctx, userID, 1, 0, 1); !errors.Is(err, app.ErrBasketNotFound) {
+			t.Errorf("SetQuantity error = %v, want %v", err, app.ErrBasketNotFound)
+		}
+	})
+
+	t.Run("adding the same product increments its quantity", func(t *testing.T) {
+		repo := newBasket(t, newRepo, userID)
+		mustAdd(t, repo, userID, 1, 1)
+		mustAdd(t, repo, userID, 2, 1)
+		mustAdd(t, repo, userID, 1, 2)
+		assertItems(t, repo, userID, []app.BasketItem{
+			{ProductID: 1, Quantity: 3},
+			{ProductID: 2, Quantity: 1},
+		})
+	})
+
+	t.Run("removing decrements and deletes the line at zero", func(t *testing.T) {
+		repo := newBasket(t, newRepo, userID)
+		mustAdd(t, repo, userID, 1, 3)
+		mustAdd(t, repo, userID, 2, 1)
+
+		if err := repo.RemoveFromBasket(ctx, userID, 1, 0, 2); err != nil {
+			t.Fatalf("RemoveFromBasket: %v", err)
+		}
+		assertItems(t, repo, userID, []app.BasketItem{
+			{ProductID: 1, Quantity: 1},
+			{ProductID: 2, Quantity: 1},
+		})
+
+		if err := repo.RemoveFromBasket(ctx, userID, 1, 0, 1); err != nil {
+			t.Fatalf("RemoveFromBasket: %v", err)
+		}
+		assertItems(t, repo, userID, []app.BasketItem{
+			{ProductID: 2, Quantity: 1},
+		})
+	})
+
+	t.Run("removing more than is in the basket deletes the line", func(t *testing.T) {
+		repo := newBasket(t, newRepo, userID)
+		mustAdd(t, repo, userID, 1, 2)
+		if err := repo.RemoveFromBasket(ctx, userID, 1, 0, 5); err != nil {
+			t.Fatalf("RemoveFromBasket: %v", err)
+		}
+		assertItems(t, repo, userID, []app.BasketItem{})
+	})
+
+	t.Run("removing a product that's not in the basket is not an error", func(t *testing.T) {
+		repo := newBasket(t, newRepo, userID)
+		if err := repo.RemoveFromBasket(ctx, userID, 1, 0, 1); err != nil {
+			t.Fatalf("RemoveFromBasket: %v", err)
+		}
+		assertItems(t, repo, userID, []app.BasketItem{})
+	})
+
+	t.Run("SetQuantity sets, adds and deletes lines", func(t *testing.T) {
+		repo := newBasket(t, newRepo, userID)
+		mustAdd(t, repo, userID, 1, 3)
+
+		if err := repo.SetQuantity(ctx, userID, 1, 0, 5); err != nil {
+			t.Fatalf("SetQuantity: %v", err)
+		}
+		if err := repo.SetQuantity(ctx, userID, 2, 0, 2); err != nil {
+			t.Fatalf("SetQuantity: %v", err)
+		}
+		assertItems(t, repo, userID, []app.BasketItem{
+			{ProductID: 1, Quantity: 5},
+			{ProductID: 2, Quantity: 2},
+		})
+
+		if err := repo.SetQuantity(ctx, userID, 1, 0, 0); err != nil {
+			t.Fatalf("SetQuantity: %v", err)
+		}
+		if err := repo.SetQuantity(ctx, userID, 3, 0, 0); err != nil {
+			t.Fatalf("SetQuantity: %v", err)
+		}
+		assertItems(t, repo, userID, []app.BasketItem{
+			{ProductID: 2, Quantity: 2},
+		})
+	})
+
+	t.Run("invalid quantities are rejected", func(t *testing.T) {
+		repo := newBasket(t, newRepo, userID)
+		mustAdd(t, repo, userID, 1, 1)
+		for name, err := range map[string]error{
+			"AddToBasket(0)":       repo.AddToBasket(ctx, userID, 1, 0, 0),
+			"AddToBasket(-1)":      repo.AddToBasket(ctx, userID, 1, 0, -1),
+			"RemoveFromBasket(0)":  repo.RemoveFromBasket(ctx, userID, 1, 0, 0),
+			"RemoveFromBasket(-1)": repo.RemoveFromBasket(ctx, userID, 1, 0, -1),
+			"SetQuantity(-1)":      repo.SetQuantity(ctx, userID, 1, 0, -1),
+		} {
+			if !errors.Is(err, app.ErrInvalidQuantity) {
+				t.Errorf("%s error = %v, want %v", name, err, app.ErrInvalidQuantity)
+			}
+		}
+		assertItems(t, repo, userID, []app.BasketItem{
+			{ProductID: 1, Quantity: 1},
+		})
+	})
+
+	t.Run("baskets are kept per user", func(t *testing.T) {
+		repo := newBasket(t, newRepo, userID)
+		if _, err := repo.GetBasket(ctx, otherUserID); err != nil {
+			t.Fatalf("GetBasket: %v", err)
+		}
+		mustAdd(t, repo, userID, 1, 1)
+		mustAdd(t, repo, otherUserID, 2, 4)
+		assertItems(t, repo, userID, []app.BasketItem{{ProductID: 1, Quantity: 1}})
+		assertItems(t, repo, otherUserID, []app.BasketItem{{ProductID: 2, Quantity: 4}})
+	})
+
+	t.Run("MergeBaskets adds quantities and removes the source basket", func(t *testing.T) {
+		repo := newBasket(t, newRepo, userID)
+		guestID := -1
+		if _, err := repo.GetBasket(ctx, guestID); err != nil {
+			t.Fatalf("GetBasket: %v", err)
+		}
+		mustAdd(t, repo, userID, 1, 1)
+		mustAdd(t, repo, guestID, 1, 2)
+		mustAdd(t, repo, guestID, 2, 1)
+
+		if err := repo.MergeBaskets(ctx, guestID, userID); err != nil {
+			t.Fatalf("MergeBaskets: %v", err)
+		}
+		assertItems(t, repo, userID, []app.BasketItem{
+			{ProductID: 1, Quantity: 3},
+			{ProductID: 2, Quantity: 1},
+		})
+		if err := repo.AddToBasket(ctx, guestID, 1, 0, 1); !errors.Is(err, app.ErrBasketNotFound) {
+			t.Errorf("guest basket still exists after merge, AddToBasket error = %v", err)
+		}
+	})
+
+	t.Run("MergeBaskets caps the quantity of a line", func(t *testing.T) {
+		repo := newBasket(t, newRepo, userID)
+		guestID := -1
+		if _, err := repo.GetBasket(ctx, guestID); err != nil {
+			t.Fatalf("GetBasket: %v", err)
+		}
+		mustAdd(t, repo, userID, 1, app.MaxLineQuantity-1)
+		mustAdd(t, repo, guestID, 1, 2)
+
+		if err := repo.MergeBaskets(ctx, guestID, userID); err != nil {
+			t.Fatalf("MergeBaskets: %v", err)
+		}
+		assertItems(t, repo, userID, []app.BasketItem{{ProductID: 1, Quantity: app.MaxLineQuantity}})
+	})
+
+	t.Run("variants of a product are separate lines", func(t *testing.T) {
+		repo := newBasket(t, newRepo, userID)
+		guestID := -1
+		if _, err := repo.GetBasket(ctx, guestID); err != nil {
+			t.Fatalf("GetBasket: %v", err)
+		}
+		for _, add := range []struct{ userID, variantID, quantity int }{
+			{userID, 1, 1}, {userID, 2, 2}, {userID, 1, 1}, {guestID, 2, 1}, {guestID, 3, 1},
+		} {
+			if err := repo.AddToBasket(ctx, add.userID, 2, add.variantID, add.quantity); err != nil {
+				t.Fatalf("AddToBasket: %v", err)
+			}
+		}
+		if err := repo.RemoveFromBasket(ctx, userID, 2, 2, 1); err != nil {
+			t.Fatalf("RemoveFromBasket: %v", err)
+		}
+		if err := repo.SetQuantity(ctx, userID, 2, 1, 5); err != nil {
+			t.Fatalf("SetQuantity: %v", err)
+		}
+		assertItems(t, repo, userID, []app.BasketItem{
+			{ProductID: 2, VariantID: 1, Quantity: 5},
+			{ProductID: 2, VariantID: 2, Quantity: 1},
+		})
+
+		if err := repo.MergeBaskets(ctx, guestID, userID); err != nil {
+			t.Fatalf("MergeBaskets: %v", err)
+		}
+		assertItems(t, repo, userID, []app.BasketItem{
+			{ProductID: 2, VariantID: 1, Quantity: 5},
+			{ProductID: 2, VariantID: 2, Quantity: 2},
+			{ProductID: 2, VariantID: 3, Quantity: 1},
+		})
+	})
+
+	t.Run("MergeBaskets without a source basket is a no-op", func(t *testing.T) {
+		repo := newBasket(t, newRepo, userID)
+		mustAdd(t, repo, userID, 1, 1)
+		if err := repo.MergeBaskets(ctx, -1, userID); err != nil {
+			t.Fatalf("MergeBaskets: %v", err)
+		}
+		assertItems(t, repo, userID, []app.BasketItem{{ProductID: 1, Quantity: 1}})
+	})
+
+	t.Run("SetCoupon sets and removes the coupon", func(t *testing.T) {
+		repo := newBasket(t, newRepo, userID)
+		if err := repo.SetCoupon(ctx, userID, "WELCOME10"); err != nil {
+			t.Fatalf("SetCoupon: %v", err)
+		}
+		assertCoupon(t, repo, userID, "WELCOME10")
+		if err := repo.SetCoupon(ctx, userID, ""); err != nil {
+			t.Fatalf("SetCoupon: %v", err)
+		}
+		assertCoupon(t, repo, userID, "")
+
+		if err := repo.SetCoupon(ctx, otherUserID, "WELCOME10"); !errors.Is(err, app.ErrBasketNotFound) {
+			t.Errorf("SetCoupon error = %v, want %v", err, app.ErrBasketNotFound)
+		}
+	})
+
+	t.Run("ClearBasket removes the items and the coupon", func(t *testing.T) {
+		repo := newBasket(t, newRepo, userID)
+		mustAdd(t, repo, userID, 1, 2)
+		mustAdd(t, repo, userID, 2, 1)
+		if err := repo.SetCoupon(ctx, userID, "WELCOME10"); err != nil {
+			t.Fatalf("SetCoupon: %v", err)
+		}
+
+		if err := repo.ClearBasket(ctx, userID); err != nil {
+			t.Fatalf("ClearBasket: %v", err)
+		}
+		assertItems(t, repo, userID, []app.BasketItem{})
+		assertCoupon(t, repo, userID, "")
+
+		if err := repo.ClearBasket(ctx, otherUserID); !errors.Is(err, app.ErrBasketNotFound) {
+			t.Errorf("ClearBasket error = %v, want %v", err, app.ErrBasketNotFound)
+		}
+	})
+
+	t.Run("MergeBaskets keeps the user's coupon over the guest's", func(t *testing.T) {
+		repo := newBasket(t, newRepo, userID)
+		for guestID, coupon := range map[int]string{-1: "GUEST", -2: "SECOND"} {
+			if _, err := repo.GetBasket(ctx, guestID); err != nil {
+				t.Fatalf("GetBasket: %v", err)
+			}
+			if err := repo.SetCoupon(ctx, guestID, coupon); err != nil {
+				t.Fatalf("SetCoupon: %v", err)
+			}
+		}
+
+		if err := repo.MergeBaskets(ctx, -1, userID); err != nil {
+			t.Fatalf("MergeBaskets: %v", err)
+		}
+		assertCoupon(t, repo, userID, "GUEST")
+		if err := repo.MergeBaskets(ctx, -2, userID); err != nil {
+			t.Fatalf("MergeBaskets: %v", err)
+		}
+		assertCoupon(t, repo, userID, "GUEST")
+	})
+
+	t.Run("DeleteGuestBaskets only removes old baskets of guests", func(t *testing.T) {
+		const guestID = -1
+		repo := newBasket(t, newRepo, userID)
+		mustAdd(t, repo, userID, 1, 1)
+		if _, err := repo.GetBasket(ctx, guestID); err != nil {
+			t.Fatalf("GetBasket: %v", err)
+		}
+		mustAdd(t, repo, guestID, 2, 1)
+
+		if n, err := repo.DeleteGuestBaskets(ctx, time.Now().Add(-time.Hour)); err != nil || n != 0 {
+			t.Fatalf("DeleteGuestBaskets of an hour ago = %d, %v, want 0", n, err)
+		}
+		assertItems(t, repo, guestID, []app.BasketItem{{ProductID: 2, Quantity: 1}})
+
+		// the SQLite version stores seconds
+		if n, err := repo.DeleteGuestBaskets(ctx, time.Now().Add(2*time.Second)); err != nil || n != 1 {
+			t.Fatalf("DeleteGuestBaskets = %d, %v, want 1", n, err)
+		}
+		if err := repo.AddToBasket(ctx, guestID, 1, 0, 1); !errors.Is(err, app.ErrBasketNotFound) {
+			t.Errorf("AddToBasket of the deleted basket error = %v, want %v", err, app.ErrBasketNotFound)
+		}
+		assertItems(t, repo, userID, []app.BasketItem{{ProductID: 1, Quantity: 1}})
+	})
+}
+
+func assertCoupon(t *testing.T, repo storage.BasketRepository, userID int, want string) {
+	t.Helper()
+	basket, err := repo.GetBasket(context.Background(), userID)
+	if err != nil {
+		t.Fatalf("GetBasket: %v", err)
+	}
+	if basket.Coupon != want {
+		t.Errorf("Coupon = %q, want %q", basket.Coupon, want)
+	}
+}
+
+func newBasket(t *testing.T, newRepo func(t *testing.T) storage.BasketRepository, userID int) storage.BasketRepository {
+	t.Helper()
+	repo := newRepo(t)
+	if _, err := repo.GetBasket(context.Background(), userID); err != nil {
+		t.Fatalf("GetBasket: %v", err)
+	}
+	return repo
+}
+
+func mustAdd(t *testing.T, repo storage.BasketRepository, userID, productID, quantity int) {
+	t.Helper()
+	if err := repo.AddToBasket(context.Background(), userID, productID, 0, quantity); err != nil {
+		t.Fatalf("AddToBasket(%d, %d, %d): %v", userID, productID, quantity, err)
+	}
+}
+
+func assertItems(t *testing.T, repo storage.BasketRepository, userID int, want []app.BasketItem) {
+	t.Helper()
+	basket, err := repo.GetBasket(context.Background(), userID)
+	if err != nil {
+		t.Fatalf("GetBasket: %v", err)
+	}
+	if !reflect.DeepEqual(basket.Items, want) {
+		t.Errorf("Items = %+v, want %+v", basket.Items, want)
+	}
+}
@@ -0,0 +1,163 @@
+package storagetest
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"slices"
+	"testing"
+
+	app "github.com/gerbenjacobs/go-webshop-course"
+	"github.com/gerbenjacobs/go-webshop-course/storage"
+)
+
+// CategoryRepos are the repositories of the category suite, deleting a category takes it off the products
+type CategoryRepos struct {
+	Categories storage.CategoryRepository
+	Products   storage.ProductRepository
+}
+
+const (
+	// plushiesCategory is the seeded top-level category, mascotsCategory is the one below it
+	// that lists the Gopher and the PHP Elephant
+	plushiesCategory = 1
+	mascotsCategory  = 2
+)
+
+// TestCategoryRepository runs the category conformance suite, newRepos is called for every
+// subtest and should return repositories with the seed categories and products.
+func TestCategoryRepository(t *testing.T, newRepos func(t *testing.T) CategoryRepos) {
+	ctx := context.Background()
+
+	t.Run("GetAllCategories returns the categories by ID", func(t *testing.T) {
+		categories, err := newRepos(t).Categories.GetAllCategories(ctx)
+		if err != nil {
+			t.Fatalf("GetAllCategories: %v", err)
+		}
+		want := []app.Category{
+			{ID: plushiesCategory, Slug: "plushies", Name: "Plushies", Description: "Soft toys for developers of all ages."},
+			{ID: mascotsCategory, ParentID: plushiesCategory, Slug: "mascots", Name: "Language mascots", Description: "The mascots of your favourite programming languages."},
+		}
+		if !reflect.DeepEqual(categories, want) {
+			t.Errorf("categories = %+v, want %+v", categories, want)
+		}
+	})
+
+	t.Run("GetCategory and GetCategoryBySlug of an unknown category fail", func(t *testing.T) {
+		repo := newRepos(t).Categories
+		if _, err := repo.GetCategory(ctx, 99); !errors.Is(err, app.ErrCategoryNotFound) {
+			t.Errorf("GetCategory error = %v, want %v", err, app.ErrCategoryNotFound)
+		}
+		if _, err := repo.GetCategoryBySlug(ctx, "nope"); !errors.Is(err, app.ErrCategoryNotFound) {
+			t.Errorf("GetCategoryBySlug error = %v, want %v", err, app.ErrCategoryNotFound)
+		}
+	})
+
+	t.Run("CreateCategory stores the category under its parent", func(t *testing.T) {
+		repo := newRepos(t).Categories
+		created, err := repo.CreateCategory(ctx, app.Category{ParentID: plushiesCategory, Slug: "animals", Name: "Animals", Position: 1})
+		if err != nil {
+			t.Fatalf("CreateCategory: %v", err)
+		}
+		if created.ID == 0 {
+			t.Fatal("created category has no ID")
+		}
+		got, err := repo.GetCategoryBySlug(ctx, "animals")
+		if err != nil {
+			t.Fatalf("GetCategoryBySlug: %v", err)
+		}
+		if got != created {
+			t.Errorf("stored category = %+v, want %+v", got, created)
+		}
+	})
+
+	t.Run("CreateCategory with a taken slug or an unknown parent fails", func(t *testing.T) {
+		repo := newRepos(t).Categories
+		if _, err := repo.CreateCategory(ctx, app.Category{Slug: "mascots", Name: "Mascots"}); !errors.Is(err, app.ErrSlugExists) {
+			t.Errorf("CreateCategory with a taken slug error = %v, want %v", err, app.ErrSlugExists)
+		}
+		if _, err := repo.CreateCategory(ctx, app.Category{ParentID: 99, Slug: "orphans", Name: "Orphans"}); !errors.Is(err, app.ErrCategoryNotFound) {
+			t.Errorf("CreateCategory with an unknown parent error = %v, want %v", err, app.ErrCategoryNotFound)
+		}
+		assertCategoryCount(t, repo, 2)
+	})
+
+	t.Run("UpdateCategory replaces the fields", func(t *testing.T) {
+		repo := newRepos(t).Categories
+		c, err := repo.GetCategory(ctx, mascotsCategory)
+		if err != nil {
+			t.Fatalf("GetCategory: %v", err)
+		}
+		// keeping its own slug is fine
+		c.Name, c.ParentID, c.Position = "Mascots", 0, 2
+		if _, err := repo.UpdateCategory(ctx, c); err != nil {
+			t.Fatalf("UpdateCategory: %v", err)
+		}
+		got, err := repo.GetCategory(ctx, mascotsCategory)
+		if err != nil {
+			t.Fatalf("GetCategory: %v", err)
+		}
+		if got != c {
+			t.Errorf("updated category = %+v, want %+v", got, c)
+		}
+	})
+
+	t.Run("UpdateCategory with a taken slug or an unknown category fails", func(t *testing.T) {
+		repo := newRepos(t).Categories
+		c, err := repo.GetCategory(ctx, mascotsCategory)
+		if err != nil {
+			t.Fatalf("GetCategory: %v", err)
+		}
+		c.Slug = "plushies"
+		if _, err := repo.UpdateCategory(ctx, c); !errors.Is(err, app.ErrSlugExists) {
+			t.Errorf("UpdateCategory with a taken slug error = %v, want %v", err, app.ErrSlugExists)
+		}
+		if _, err := repo.UpdateCategory(ctx, app.Category{ID: 99, Slug: "new", Name: "New"}); !errors.Is(err, app.ErrCategoryNotFound) {
+			t.Errorf("UpdateCategory of an unknown category error = %v, want %v", err, app.ErrCategoryNotFound)
+		}
+		if got, err := repo.GetCategory(ctx, mascotsCategory); err != nil || got.Slug != "mascots" {
+			t.Errorf("GetCategory = %+v, %v, want it unchanged", got, err)
+		}
+	})
+
+	t.Run("DeleteCategory takes the category off its products", func(t *testing.T) {
+		repos := newRepos(t)
+		if err := repos.Categories.DeleteCategory(ctx, mascotsCategory); err != nil {
+			t.Fatalf("DeleteCategory: %v", err)
+		}
+		if _, err := repos.Categories.GetCategory(ctx, mascotsCategory); !errors.Is(err, app.ErrCategoryNotFound) {
+			t.Errorf("GetCategory of the deleted category error = %v, want %v", err, app.ErrCategoryNotFound)
+		}
+		products, err := repos.Products.GetAllProducts(ctx)
+		if err != nil {
+			t.Fatalf("GetAllProducts: %v", err)
+		}
+		for _, p := range products {
+			if slices.Contains(p.CategoryIDs, mascotsCategory) {
+				t.Errorf("product %d is still listed in the deleted category", p.ID)
+			}
+		}
+	})
+
+	t.Run("DeleteCategory with subcategories or of an unknown category fails", func(t *testing.T) {
+		repo := newRepos(t).Categories
+		if err := repo.DeleteCategory(ctx, plushiesCategory); !errors.Is(err, app.ErrCategoryHasChildren) {
+			t.Errorf("DeleteCategory with subcategories error = %v, want %v", err, app.ErrCategoryHasChildren)
+		}
+		if err := repo.DeleteCategory(ctx, 99); !errors.Is(err, app.ErrCategoryNotFound) {
+			t.Errorf("DeleteCategory of an unknown category error = %v, want %v", err, app.ErrCategoryNotFound)
+		}
+		assertCategoryCount(t, repo, 2)
+	})
+}
+
+func assertCategoryCount(t *testing.T, repo storage.CategoryRepository, want int) {
+	t.Helper()
+	categories, err := repo.GetAllCategories(context.Background())
+	if err != nil {
+		t.Fatalf("GetAllCategories: %v", err)
+	}
+	if len(categories) != want {
+		t.Errorf("got %d categories, want %d", len(categories), want)
+	}
+}
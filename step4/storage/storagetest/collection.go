@@ -0,0 +1,151 @@
+package storagetest
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	app "github.com/gerbenjacobs/go-webshop-course"
+	"github.com/gerbenjacobs/go-webshop-course/storage"
+)
+
+// staffPicks is the seeded collection, it shows the PHP Elephant before the Gopher
+const staffPicks = 1
+
+// TestCollectionRepository runs the collection conformance suite, newRepo is called for every
+// subtest and should return a repository with the seed collection and products.
+func TestCollectionRepository(t *testing.T, newRepo func(t *testing.T) storage.CollectionRepository) {
+	ctx := context.Background()
+
+	t.Run("collections keep the order of their products", func(t *testing.T) {
+		repo := newRepo(t)
+		want := app.Collection{ID: staffPicks, Slug: "staff-picks", Name: "Staff picks", Description: "The plushies on our own desks.", ProductIDs: []int{2, 1}}
+		c, err := repo.GetCollection(ctx, staffPicks)
+		if err != nil {
+			t.Fatalf("GetCollection: %v", err)
+		}
+		if !reflect.DeepEqual(c, want) {
+			t.Errorf("GetCollection = %+v, want %+v", c, want)
+		}
+		c, err = repo.GetCollectionBySlug(ctx, "staff-picks")
+		if err != nil {
+			t.Fatalf("GetCollectionBySlug: %v", err)
+		}
+		if !reflect.DeepEqual(c, want) {
+			t.Errorf("GetCollectionBySlug = %+v, want %+v", c, want)
+		}
+		all, err := repo.GetAllCollections(ctx)
+		if err != nil {
+			t.Fatalf("GetAllCollections: %v", err)
+		}
+		if !reflect.DeepEqual(all, []app.Collection{want}) {
+			t.Errorf("GetAllCollections = %+v, want %+v", all, []app.Collection{want})
+		}
+	})
+
+	t.Run("GetCollection and GetCollectionBySlug of an unknown collection fail", func(t *testing.T) {
+		repo := newRepo(t)
+		if _, err := repo.GetCollection(ctx, 99); !errors.Is(err, app.ErrCollectionNotFound) {
+			t.Errorf("GetCollection error = %v, want %v", err, app.ErrCollectionNotFound)
+		}
+		if _, err := repo.GetCollectionBySlug(ctx, "nope"); !errors.Is(err, app.ErrCollectionNotFound) {
+			t.Errorf("GetCollectionBySlug error = %v, want %v", err, app.ErrCollectionNotFound)
+		}
+	})
+
+	t.Run("CreateCollection stores the products in the given order", func(t *testing.T) {
+		repo := newRepo(t)
+		created, err := repo.CreateCollection(ctx, app.Collection{Slug: "gophers-first", Name: "Gophers first", ProductIDs: []int{1, 2}})
+		if err != nil {
+			t.Fatalf("CreateCollection: %v", err)
+		}
+		if created.ID == 0 || created.ID == staffPicks {
+			t.Fatalf("created collection has ID %d, want a new one", created.ID)
+		}
+		got, err := repo.GetCollection(ctx, created.ID)
+		if err != nil {
+			t.Fatalf("GetCollection: %v", err)
+		}
+		if !reflect.DeepEqual(got.ProductIDs, []int{1, 2}) || got.Slug != "gophers-first" {
+			t.Errorf("stored collection = %+v, want gophers-first with [1 2]", got)
+		}
+
+		if _, err := repo.CreateCollection(ctx, app.Collection{Slug: "staff-picks", Name: "Staff picks again"}); !errors.Is(err, app.ErrSlugExists) {
+			t.Errorf("CreateCollection with a taken slug error = %v, want %v", err, app.ErrSlugExists)
+		}
+	})
+
+	t.Run("UpdateCollection reorders the products", func(t *testing.T) {
+		repo := newRepo(t)
+		c, err := repo.GetCollection(ctx, staffPicks)
+		if err != nil {
+			t.Fatalf("GetCollection: %v", err)
+		}
+		c.Name, c.ProductIDs = "Our picks", []int{1, 2}
+		if _, err := repo.UpdateCollection(ctx, c); err != nil {
+			t.Fatalf("UpdateCollection: %v", err)
+		}
+		got, err := repo.GetCollection(ctx, staffPicks)
+		if err != nil {
+			t.Fatalf("GetCollection: %v", err)
+		}
+		if !reflect.DeepEqual(got, c) {
+			t.Errorf("updated collection = %+v, want %+v", got, c)
+		}
+
+		c.ProductIDs = []int{2}
+		if _, err := repo.UpdateCollection(ctx, c); err != nil {
+			t.Fatalf("UpdateCollection with fewer products: %v", err)
+		}
+		if got, err := repo.GetCollection(ctx, staffPicks); err != nil || !reflect.DeepEqual(got.ProductIDs, []int{2}) {
+			t.Errorf("GetCollection = %+v, %v, want only product 2", got, err)
+		}
+	})
+
+	t.Run("UpdateCollection with a taken slug or of an unknown collection fails", func(t *testing.T) {
+		repo := newRepo(t)
+		if _, err := repo.CreateCollection(ctx, app.Collection{Slug: "new", Name: "New"}); err != nil {
+			t.Fatalf("CreateCollection: %v", err)
+		}
+		c, err := repo.GetCollection(ctx, staffPicks)
+		if err != nil {
+			t.Fatalf("GetCollection: %v", err)
+		}
+		c.Slug, c.ProductIDs = "new", []int{1}
+		if _, err := repo.UpdateCollection(ctx, c); !errors.Is(err, app.ErrSlugExists) {
+			t.Errorf("UpdateCollection with a taken slug error = %v, want %v", err, app.ErrSlugExists)
+		}
+		if got, err := repo.GetCollection(ctx, staffPicks); err != nil || got.Slug != "staff-picks" || !reflect.DeepEqual(got.ProductIDs, []int{2, 1}) {
+			t.Errorf("GetCollection = %+v, %v, want it unchanged", got, err)
+		}
+		if _, err := repo.UpdateCollection(ctx, app.Collection{ID: 99, Slug: "other", Name: "Other"}); !errors.Is(err, app.ErrCollectionNotFound) {
+			t.Errorf("UpdateCollection of an unknown collection error = %v, want %v", err, app.ErrCollectionNotFound)
+		}
+	})
+
+	t.Run("DeleteCollection", func(t *testing.T) {
+		repo := newRepo(t)
+		if err := repo.DeleteCollection(ctx, staffPicks); err != nil {
+			t.Fatalf("DeleteCollection: %v", err)
+		}
+		if _, err := repo.GetCollection(ctx, staffPicks); !errors.Is(err, app.ErrCollectionNotFound) {
+			t.Errorf("GetCollection of the deleted collection error = %v, want %v", err, app.ErrCollectionNotFound)
+		}
+		if err := repo.DeleteCollection(ctx, staffPicks); !errors.Is(err, app.ErrCollectionNotFound) {
+			t.Errorf("DeleteCollection once more error = %v, want %v", err, app.ErrCollectionNotFound)
+		}
+	})
+
+	t.Run("returned collections are copies", func(t *testing.T) {
+		repo := newRepo(t)
+		c, err := repo.GetCollection(ctx, staffPicks)
+		if err != nil {
+			t.Fatalf("GetCollection: %v", err)
+		}
+		c.ProductIDs[0] = 1
+		if got, err := repo.GetCollection(ctx, staffPicks); err != nil || !reflect.DeepEqual(got.ProductIDs, []int{2, 1}) {
+			t.Errorf("GetCollection = %+v, %v after changing a copy, want [2 1]", got, err)
+		}
+	})
+}
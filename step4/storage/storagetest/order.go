@@ -0,0 +1,399 @@
+package storagetest
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	app "github.com/gerbenjacobs/go-webshop-course"
+	"github.com/gerbenjacobs/go-webshop-course/storage"
+)
+
+// OrderRepos are the repositories of the order suite, they share their data like they do
+// in the shop: an order empties the basket it was created from and reserves product stock
+type OrderRepos struct {
+	Orders   storage.OrderRepository
+	Baskets  storage.BasketRepository
+	Products storage.ProductRepository
+}
+
+const (
+	// welcomePromotion is the seeded WELCOME10 coupon, a customer can use it once
+	welcomePromotion = 1
+	// gopherStock is the seeded stock of the Gopher plushie, the product of newOrder
+	gopherStock = 50
+	// the PHP Elephant plushie comes in blue and in pink, of which there are only a few
+	blueVariant, blueStock = 1, 25
+	pinkVariant, pinkStock = 2, 10
+)
+
+// TestOrderRepository runs the order conformance suite, newRepos is called for every
+// subtest and should return repositories with the seed products and promotions.
+func TestOrderRepository(t *testing.T, newRepos func(t *testing.T) OrderRepos) {
+	ctx := context.Background()
+	const userID, otherUserID = 1, 2
+	welcome := []app.AppliedDiscount{{PromotionID: welcomePromotion, Code: "WELCOME10", Name: "10% off", Amount: app.EUR(130)}}
+
+	t.Run("only paid orders count as promotion uses", func(t *testing.T) {
+		repos := newRepos(t)
+		order := placeOrder(t, repos, userID, welcome)
+		assertPromotionUses(t, repos.Orders, userID, 0, 0)
+
+		order.Status = app.OrderStatusPaid
+		if err := repos.Orders.UpdateOrder(ctx, order, app.OrderStatusPendingPayment); err != nil {
+			t.Fatalf("UpdateOrder: %v", err)
+		}
+		assertPromotionUses(t, repos.Orders, userID, 1, 1)
+		assertPromotionUses(t, repos.Orders, otherUserID, 1, 0)
+	})
+
+	t.Run("paying two orders with a one-use promotion fails", func(t *testing.T) {
+		repos := newRepos(t)
+		first := placeOrder(t, repos, userID, welcome)
+		second := placeOrder(t, repos, userID, welcome)
+
+		first.Status = app.OrderStatusPaid
+		if err := repos.Orders.UpdateOrder(ctx, first, app.OrderStatusPendingPayment); err != nil {
+			t.Fatalf("UpdateOrder of the first order: %v", err)
+		}
+		second.Status = app.OrderStatusPaid
+		if err := repos.Orders.UpdateOrder(ctx, second, app.OrderStatusPendingPayment); !errors.Is(err, app.ErrPromotionUsedUp) {
+			t.Fatalf("UpdateOrder of the second order error = %v, want %v", err, app.ErrPromotionUsedUp)
+		}
+		assertOrderStatus(t, repos.Orders, second.ID, app.OrderStatusPendingPayment)
+		assertPromotionUses(t, repos.Orders, userID, 1, 1)
+
+		// another customer can still use it
+		other := placeOrder(t, repos, otherUserID, welcome)
+		other.Status = app.OrderStatusPaid
+		if err := repos.Orders.UpdateOrder(ctx, other, app.OrderStatusPendingPayment); err != nil {
+			t.Errorf("UpdateOrder of another customer's order: %v", err)
+		}
+	})
+
+	t.Run("a refund that is undone doesn't take the stock twice", func(t *testing.T) {
+		repos := newRepos(t)
+		order := placeOrder(t, repos, userID, nil)
+		for _, step := range []struct{ from, to app.OrderStatus }{
+			{app.OrderStatusPendingPayment, app.OrderStatusPaid},
+			{app.OrderStatusPaid, app.OrderStatusRefunded},
+			{app.OrderStatusRefunded, app.OrderStatusPaid},
+		} {
+			order.Status = step.to
+			if err := repos.Orders.UpdateOrder(ctx, order, step.from); err != nil {
+				t.Fatalf("UpdateOrder from %s to %s: %v", step.from, step.to, err)
+			}
+		}
+		assertStock(t, repos.Products, 1, 0, gopherStock-1, 0)
+	})
+
+	t.Run("CreateOrder reserves the stock", func(t *testing.T) {
+		repos := newRepos(t)
+		placeOrder(t, repos, userID, nil)
+		assertStock(t, repos.Products, 1, 0, gopherStock, 1)
+	})
+
+	t.Run("CreateOrder without enough stock stores nothing", func(t *testing.T) {
+		repos := newRepos(t)
+		_, err := orderItems(t, repos, userID, inAnHour(), gopher(gopherStock+1))
+		if !errors.Is(err, app.ErrInsufficientStock) {
+			t.Fatalf("CreateOrder error = %v, want %v", err, app.ErrInsufficientStock)
+		}
+		assertStock(t, repos.Products, 1, 0, gopherStock, 0)
+		assertItems(t, repos.Baskets, userID, []app.BasketItem{{ProductID: 1, Quantity: gopherStock + 1}})
+		assertOrderCount(t, repos.Orders, userID, 0)
+	})
+
+	t.Run("CreateOrder fails when the basket changed", func(t *testing.T) {
+		repos := newRepos(t)
+		fillBasket(t, repos.Baskets, userID)
+		mustAdd(t, repos.Baskets, userID, 1, 1)
+		if _, err := repos.Orders.CreateOrder(ctx, newOrder(userID, nil)); !errors.Is(err, app.ErrBasketChanged) {
+			t.Fatalf("CreateOrder error = %v, want %v", err, app.ErrBasketChanged)
+		}
+		assertStock(t, repos.Products, 1, 0, gopherStock, 0)
+		assertOrderCount(t, repos.Orders, userID, 0)
+	})
+
+	t.Run("an expired reservation doesn't hold the stock", func(t *testing.T) {
+		repos := newRepos(t)
+		if _, err := orderItems(t, repos, userID, time.Now().Add(-time.Minute), gopher(gopherStock)); err != nil {
+			t.Fatalf("CreateOrder of an expired order: %v", err)
+		}
+		assertStock(t, repos.Products, 1, 0, gopherStock, 0)
+
+		if _, err := orderItems(t, repos, otherUserID, inAnHour(), gopher(gopherStock)); err != nil {
+			t.Fatalf("CreateOrder of the stock that was held: %v", err)
+		}
+		assertStock(t, repos.Products, 1, 0, gopherStock, gopherStock)
+	})
+
+	t.Run("ReserveStock renews the reservation", func(t *testing.T) {
+		repos := newRepos(t)
+		order, err := orderItems(t, repos, userID, time.Now().Add(-time.Minute), gopher(2))
+		if err != nil {
+			t.Fatalf("CreateOrder: %v", err)
+		}
+		until := inAnHour()
+		if err := repos.Orders.ReserveStock(ctx, order.ID, until); err != nil {
+			t.Fatalf("ReserveStock: %v", err)
+		}
+		assertStock(t, repos.Products, 1, 0, gopherStock, 2)
+		got, err := repos.Orders.GetOrder(ctx, order.ID)
+		if err != nil {
+			t.Fatalf("GetOrder: %v", err)
+		}
+		if got.ReservedUntil.Unix() != until.Unix() {
+			t.Errorf("ReservedUntil = %v, want %v", got.ReservedUntil, until)
+		}
+
+		// renewing replaces the reservation, it doesn't add to it
+		if err := repos.Orders.ReserveStock(ctx, order.ID, until.Add(time.Hour)); err != nil {
+			t.Fatalf("ReserveStock once more: %v", err)
+		}
+		assertStock(t, repos.Products, 1, 0, gopherStock, 2)
+
+		if err := repos.Orders.ReserveStock(ctx, order.ID+1, until); !errors.Is(err, app.ErrOrderNotFound) {
+			t.Errorf("ReserveStock of an unknown order error = %v, want %v", err, app.ErrOrderNotFound)
+		}
+	})
+
+	t.Run("ReserveStock fails when the stock was sold in the meantime", func(t *testing.T) {
+		repos := newRepos(t)
+		order, err := orderItems(t, repos, userID, time.Now().Add(-time.Minute), gopher(2))
+		if err != nil {
+			t.Fatalf("CreateOrder: %v", err)
+		}
+		if _, err := orderItems(t, repos, otherUserID, inAnHour(), gopher(gopherStock-1)); err != nil {
+			t.Fatalf("CreateOrder of another customer: %v", err)
+		}
+		if err := repos.Orders.ReserveStock(ctx, order.ID, inAnHour()); !errors.Is(err, app.ErrInsufficientStock) {
+			t.Fatalf("ReserveStock error = %v, want %v", err, app.ErrInsufficientStock)
+		}
+		assertStock(t, repos.Products, 1, 0, gopherStock, gopherStock-1)
+	})
+
+	t.Run("a failed ReserveStock keeps the earlier reservation", func(t *testing.T) {
+		repos := newRepos(t)
+		order := placeOrder(t, repos, userID, nil)
+		if _, err := orderItems(t, repos, otherUserID, inAnHour(), gopher(gopherStock-1)); err != nil {
+			t.Fatalf("CreateOrder of another customer: %v", err)
+		}
+		product, err := repos.Products.GetProduct(ctx, 1)
+		if err != nil {
+			t.Fatalf("GetProduct: %v", err)
+		}
+		product.Stock = gopherStock - 1
+		if _, err := repos.Products.UpdateProduct(ctx, product); err != nil {
+			t.Fatalf("UpdateProduct: %v", err)
+		}
+
+		if err := repos.Orders.ReserveStock(ctx, order.ID, inAnHour()); !errors.Is(err, app.ErrInsufficientStock) {
+			t.Fatalf("ReserveStock error = %v, want %v", err, app.ErrInsufficientStock)
+		}
+		assertStock(t, repos.Products, 1, 0, gopherStock-1, gopherStock)
+	})
+
+	t.Run("paying takes the items out of the stock and releases the reservation", func(t *testing.T) {
+		repos := newRepos(t)
+		order := placeOrder(t, repos, userID, nil)
+		order.Status = app.OrderStatusPaid
+		order.PaymentID = "pay_1"
+		if err := repos.Orders.UpdateOrder(ctx, order, app.OrderStatusPendingPayment); err != nil {
+			t.Fatalf("UpdateOrder: %v", err)
+		}
+		assertStock(t, repos.Products, 1, 0, gopherStock-1, 0)
+		got, err := repos.Orders.GetOrder(ctx, order.ID)
+		if err != nil {
+			t.Fatalf("GetOrder: %v", err)
+		}
+		if got.Status != app.OrderStatusPaid || got.PaymentID != "pay_1" {
+			t.Errorf("order is %s with payment %q, want paid with pay_1", got.Status, got.PaymentID)
+		}
+	})
+
+	t.Run("UpdateOrder from a stale status fails", func(t *testing.T) {
+		repos := newRepos(t)
+		order := placeOrder(t, repos, userID, nil)
+		order.Status = app.OrderStatusPaid
+		if err := repos.Orders.UpdateOrder(ctx, order, app.OrderStatusPaymentFailed); !errors.Is(err, app.ErrOrderChanged) {
+			t.Fatalf("UpdateOrder error = %v, want %v", err, app.ErrOrderChanged)
+		}
+		assertOrderStatus(t, repos.Orders, order.ID, app.OrderStatusPendingPayment)
+		assertStock(t, repos.Products, 1, 0, gopherStock, 1)
+
+		order.ID++
+		if err := repos.Orders.UpdateOrder(ctx, order, app.OrderStatusPendingPayment); !errors.Is(err, app.ErrOrderNotFound) {
+			t.Errorf("UpdateOrder of an unknown order error = %v, want %v", err, app.ErrOrderNotFound)
+		}
+	})
+
+	t.Run("variants have stock of their own", func(t *testing.T) {
+		repos := newRepos(t)
+		order, err := orderItems(t, repos, userID, inAnHour(), elephant(pinkVariant, pinkStock))
+		if err != nil {
+			t.Fatalf("CreateOrder of all pink elephants: %v", err)
+		}
+		assertStock(t, repos.Products, 2, pinkVariant, pinkStock, pinkStock)
+		assertStock(t, repos.Products, 2, blueVariant, blueStock, 0)
+
+		if _, err := orderItems(t, repos, otherUserID, inAnHour(), elephant(pinkVariant, 1)); !errors.Is(err, app.ErrInsufficientStock) {
+			t.Fatalf("CreateOrder of a sold out variant error = %v, want %v", err, app.ErrInsufficientStock)
+		}
+		if err := repos.Baskets.ClearBasket(ctx, otherUserID); err != nil {
+			t.Fatalf("ClearBasket: %v", err)
+		}
+		if _, err := orderItems(t, repos, otherUserID, inAnHour(), elephant(blueVariant, 1)); err != nil {
+			t.Fatalf("CreateOrder of another variant: %v", err)
+		}
+
+		order.Status = app.OrderStatusPaid
+		if err := repos.Orders.UpdateOrder(ctx, order, app.OrderStatusPendingPayment); err != nil {
+			t.Fatalf("UpdateOrder: %v", err)
+		}
+		assertStock(t, repos.Products, 2, pinkVariant, 0, 0)
+		assertStock(t, repos.Products, 2, blueVariant, blueStock, 1)
+
+		if _, err := orderItems(t, repos, userID, inAnHour(), elephant(3, 1)); !errors.Is(err, app.ErrVariantNotFound) {
+			t.Errorf("CreateOrder of an unknown variant error = %v, want %v", err, app.ErrVariantNotFound)
+		}
+	})
+
+	t.Run("CreateOrder with a used up promotion fails", func(t *testing.T) {
+		repos := newRepos(t)
+		order := placeOrder(t, repos, userID, welcome)
+		order.Status = app.OrderStatusPaid
+		if err := repos.Orders.UpdateOrder(ctx, order, app.OrderStatusPendingPayment); err != nil {
+			t.Fatalf("UpdateOrder: %v", err)
+		}
+
+		fillBasket(t, repos.Baskets, userID)
+		if _, err := repos.Orders.CreateOrder(ctx, newOrder(userID, welcome)); !errors.Is(err, app.ErrPromotionUsedUp) {
+			t.Errorf("CreateOrder error = %v, want %v", err, app.ErrPromotionUsedUp)
+		}
+	})
+}
+
+// placeOrder orders one Gopher plushie for the user with the discounts
+func placeOrder(t *testing.T, repos OrderRepos, userID int, discounts []app.AppliedDiscount) app.Order {
+	t.Helper()
+	fillBasket(t, repos.Baskets, userID)
+	order, err := repos.Orders.CreateOrder(context.Background(), newOrder(userID, discounts))
+	if err != nil {
+		t.Fatalf("CreateOrder: %v", err)
+	}
+	return order
+}
+
+// fillBasket puts the Gopher plushie of newOrder in the user's basket
+func fillBasket(t *testing.T, repo storage.BasketRepository, userID int) {
+	t.Helper()
+	if _, err := repo.GetBasket(context.Background(), userID); err != nil {
+		t.Fatalf("GetBasket: %v", err)
+	}
+	mustAdd(t, repo, userID, 1, 1)
+}
+
+// orderItems puts the items in the user's basket and orders them, reserved until the given time
+func orderItems(t *testing.T, repos OrderRepos, userID int, until time.Time, items ...app.OrderItem) (app.Order, error) {
+	t.Helper()
+	if _, err := repos.Baskets.GetBasket(context.Background(), userID); err != nil {
+		t.Fatalf("GetBasket: %v", err)
+	}
+	for _, item := range items {
+		if err := repos.Baskets.AddToBasket(context.Background(), userID, item.ProductID, item.VariantID, item.Quantity); err != nil {
+			t.Fatalf("AddToBasket: %v", err)
+		}
+	}
+	order := newOrder(userID, nil)
+	order.Items = items
+	order.ReservedUntil = until
+	return repos.Orders.CreateOrder(context.Background(), order)
+}
+
+func gopher(quantity int) app.OrderItem {
+	return app.OrderItem{ProductID: 1, Name: "Gopher plushie", UnitPrice: app.EUR(1299), TaxClass: app.TaxClassStandard, Quantity: quantity}
+}
+
+func elephant(variantID, quantity int) app.OrderItem {
+	price := app.EUR(2000)
+	if variantID == pinkVariant {
+		price = app.EUR(2200)
+	}
+	return app.OrderItem{ProductID: 2, VariantID: variantID, Name: "PHP Elephant plushie", UnitPrice: price, TaxClass: app.TaxClassStandard, Quantity: quantity}
+}
+
+func inAnHour() time.Time {
+	return time.Now().UTC().Add(time.Hour)
+}
+
+func newOrder(userID int, discounts []app.AppliedDiscount) app.Order {
+	return app.Order{
+		UserID:        userID,
+		Status:        app.OrderStatusPendingPayment,
+		Items:         []app.OrderItem{gopher(1)},
+		Subtotal:      app.EUR(1299),
+		Shipping:      app.EUR(0),
+		Discounts:     discounts,
+		Discount:      app.EUR(0),
+		Country:       "NL",
+		Tax:           app.EUR(0),
+		Total:         app.EUR(1299),
+		CreatedAt:     time.Now().UTC(),
+		ReservedUntil: time.Now().UTC().Add(30 * time.Minute),
+	}
+}
+
+func assertOrderStatus(t *testing.T, repo storage.OrderRepository, orderID int, want app.OrderStatus) {
+	t.Helper()
+	order, err := repo.GetOrder(context.Background(), orderID)
+	if err != nil {
+		t.Fatalf("GetOrder: %v", err)
+	}
+	if order.Status != want {
+		t.Errorf("order %d is %s, want %s", orderID, order.Status, want)
+	}
+}
+
+// assertStock checks the stock on hand and what's reserved of a product or, with a variantID, one of its variants
+func assertStock(t *testing.T, repo storage.ProductRepository, productID, variantID, wantStock, wantReserved int) {
+	t.Helper()
+	product, err := repo.GetProduct(context.Background(), productID)
+	if err != nil {
+		t.Fatalf("GetProduct: %v", err)
+	}
+	stock, reserved := product.Stock, product.Reserved
+	if variantID != 0 {
+		v, err := product.Variant(variantID)
+		if err != nil {
+			t.Fatalf("Variant: %v", err)
+		}
+		stock, reserved = v.Stock, v.Reserved
+	}
+	if stock != wantStock || reserved != wantReserved {
+		t.Errorf("product %d variant %d has %d in stock and %d reserved, want %d and %d", productID, variantID, stock, reserved, wantStock, wantReserved)
+	}
+}
+
+func assertOrderCount(t *testing.T, repo storage.OrderRepository, userID, want int) {
+	t.Helper()
+	orders, err := repo.GetOrdersByUser(context.Background(), userID)
+	if err != nil {
+		t.Fatalf("GetOrdersByUser: %v", err)
+	}
+	if len(orders) != want {
+		t.Errorf("user %d has %d orders, want %d", userID, len(orders), want)
+	}
+}
+
+func assertPromotionUses(t *testing.T, repo storage.OrderRepository, userID, wantTotal, wantByUser int) {
+	t.Helper()
+	total, byUser, err := repo.CountPromotionUses(context.Background(), welcomePromotion, userID)
+	if err != nil {
+		t.Fatalf("CountPromotionUses: %v", err)
+	}
+	if total != wantTotal || byUser != wantByUser {
+		t.Errorf("promotion uses = %d in total and %d by user %d, want %d and %d", total, byUser, userID, wantTotal, wantByUser)
+	}
+}
@@ -0,0 +1,150 @@
+package storagetest
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	app "github.com/gerbenjacobs/go-webshop-course"
+	"github.com/gerbenjacobs/go-webshop-course/storage"
+)
+
+// TestProductRepository runs the product conformance suite, newRepo is called for every
+// subtest and should return a repository with its seed products, it doesn't rely on them.
+func TestProductRepository(t *testing.T, newRepo func(t *testing.T) storage.ProductRepository) {
+	ctx := context.Background()
+
+	crab := func() app.Product {
+		return app.Product{
+			Name:     "Rust crab",
+			Price:    app.EUR(1500),
+			TaxClass: app.TaxClassStandard,
+			Options:  []app.ProductOption{{Name: "Size", Values: []string{"S", "L"}}},
+			Variants: []app.Variant{
+				{SKU: "TEST-CRAB-S", Options: map[string]string{"Size": "S"}, Stock: 3},
+				{SKU: "TEST-CRAB-L", Options: map[string]string{"Size": "L"}, Stock: 7, Price: ptr(app.EUR(1800))},
+			},
+		}
+	}
+
+	t.Run("CreateProduct hands out IDs", func(t *testing.T) {
+		repo := newRepo(t)
+		created, err := repo.CreateProduct(ctx, crab())
+		if err != nil {
+			t.Fatalf("CreateProduct: %v", err)
+		}
+		if created.ID == 0 {
+			t.Fatal("created product has no ID")
+		}
+
+		product, err := repo.GetProduct(ctx, created.ID)
+		if err != nil {
+			t.Fatalf("GetProduct: %v", err)
+		}
+		if product.Name != "Rust crab" || product.Price != app.EUR(1500) {
+			t.Errorf("product = %s, want the crab for €15.00", product)
+		}
+		if len(product.Options) != 1 || len(product.Options[0].Values) != 2 {
+			t.Errorf("Options = %v, want Size with S and L", product.Options)
+		}
+		if len(product.Variants) != 2 {
+			t.Fatalf("got %d variants, want 2", len(product.Variants))
+		}
+		for _, v := range product.Variants {
+			if v.ID == 0 || v.ProductID != created.ID {
+				t.Errorf("variant %s has ID %d and product ID %d", v.SKU, v.ID, v.ProductID)
+			}
+		}
+		large := product.Variants[1]
+		if large.SKU != "TEST-CRAB-L" || large.Stock != 7 || large.Price == nil || *large.Price != app.EUR(1800) {
+			t.Errorf("variant = %+v, want TEST-CRAB-L with 7 in stock for €18.00", large)
+		}
+	})
+
+	t.Run("UpdateProduct keeps, adds and removes variants", func(t *testing.T) {
+		repo := newRepo(t)
+		created, err := repo.CreateProduct(ctx, crab())
+		if err != nil {
+			t.Fatalf("CreateProduct: %v", err)
+		}
+		small, large := created.Variants[0], created.Variants[1]
+
+		update := created
+		update.Name = "Ferris"
+		update.Options = []app.ProductOption{{Name: "Size", Values: []string{"M", "L"}}}
+		large.Stock = 9
+		large.Price = nil
+		update.Variants = []app.Variant{
+			large,
+			{SKU: "TEST-CRAB-M", Options: map[string]string{"Size": "M"}, Stock: 2},
+		}
+		updated, err := repo.UpdateProduct(ctx, update)
+		if err != nil {
+			t.Fatalf("UpdateProduct: %v", err)
+		}
+
+		if updated.Name != "Ferris" {
+			t.Errorf("Name = %q, want Ferris", updated.Name)
+		}
+		if len(updated.Variants) != 2 {
+			t.Fatalf("got %d variants, want 2", len(updated.Variants))
+		}
+		if _, err := updated.Variant(small.ID); !errors.Is(err, app.ErrVariantNotFound) {
+			t.Errorf("removed variant is still there, error = %v", err)
+		}
+		kept, err := updated.Variant(large.ID)
+		if err != nil {
+			t.Fatalf("kept variant: %v", err)
+		}
+		if kept.Stock != 9 || kept.Price != nil {
+			t.Errorf("kept variant = %+v, want 9 in stock for the product's price", kept)
+		}
+		if updated.Variants[1].ID == 0 || updated.Variants[1].SKU != "TEST-CRAB-M" {
+			t.Errorf("added variant = %+v, want TEST-CRAB-M with an ID", updated.Variants[1])
+		}
+	})
+
+	t.Run("UpdateProduct of an unknown product fails", func(t *testing.T) {
+		repo := newRepo(t)
+		product := crab()
+		product.ID = 999
+		if _, err := repo.UpdateProduct(ctx, product); !errors.Is(err, app.ErrProductNotFound) {
+			t.Errorf("UpdateProduct error = %v, want %v", err, app.ErrProductNotFound)
+		}
+	})
+
+	t.Run("SKUs are unique across products", func(t *testing.T) {
+		repo := newRepo(t)
+		if _, err := repo.CreateProduct(ctx, crab()); err != nil {
+			t.Fatalf("CreateProduct: %v", err)
+		}
+		if _, err := repo.CreateProduct(ctx, crab()); !errors.Is(err, app.ErrSKUExists) {
+			t.Errorf("CreateProduct error = %v, want %v", err, app.ErrSKUExists)
+		}
+	})
+
+	t.Run("DeleteProduct removes the product", func(t *testing.T) {
+		repo := newRepo(t)
+		created, err := repo.CreateProduct(ctx, crab())
+		if err != nil {
+			t.Fatalf("CreateProduct: %v", err)
+		}
+		if err := repo.DeleteProduct(ctx, created.ID); err != nil {
+			t.Fatalf("DeleteProduct: %v", err)
+		}
+		if _, err := repo.GetProduct(ctx, created.ID); !errors.Is(err, app.ErrProductNotFound) {
+			t.Errorf("GetProduct error = %v, want %v", err, app.ErrProductNotFound)
+		}
+		if err := repo.DeleteProduct(ctx, created.ID); !errors.Is(err, app.ErrProductNotFound) {
+			t.Errorf("second DeleteProduct error = %v, want %v", err, app.ErrProductNotFound)
+		}
+		// its SKUs are free again
+		if _, err := repo.CreateProduct(ctx, crab()); err != nil {
+			t.Errorf("CreateProduct after delete: %v", err)
+		}
+	})
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
@@ -0,0 +1,88 @@
+package storagetest
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	app "github.com/gerbenjacobs/go-webshop-course"
+	"github.com/gerbenjacobs/go-webshop-course/storage"
+)
+
+// TestPromotionRepository runs the promotion conformance suite against the seed promotions,
+// newRepo is called for every subtest.
+func TestPromotionRepository(t *testing.T, newRepo func(t *testing.T) storage.PromotionRepository) {
+	ctx := context.Background()
+
+	t.Run("GetPromotion returns the promotion with its targets", func(t *testing.T) {
+		p, err := newRepo(t).GetPromotion(ctx, 3)
+		if err != nil {
+			t.Fatalf("GetPromotion: %v", err)
+		}
+		if p.Code != "GOPHER3FOR2" || p.Type != app.PromotionBuyXGetY || p.BuyQuantity != 2 || p.GetQuantity != 1 {
+			t.Errorf("promotion 3 = %+v, want GOPHER3FOR2, buy 2 get 1", p)
+		}
+		if !reflect.DeepEqual(p.ProductIDs, []int{1}) || len(p.CategoryIDs) != 0 {
+			t.Errorf("promotion 3 targets products %v and categories %v, want product 1", p.ProductIDs, p.CategoryIDs)
+		}
+	})
+
+	t.Run("GetPromotion of an unknown promotion fails", func(t *testing.T) {
+		if _, err := newRepo(t).GetPromotion(ctx, 99); !errors.Is(err, app.ErrPromotionNotFound) {
+			t.Errorf("GetPromotion error = %v, want %v", err, app.ErrPromotionNotFound)
+		}
+	})
+
+	t.Run("GetPromotionByCode ignores case", func(t *testing.T) {
+		repo := newRepo(t)
+		for _, code := range []string{"WELCOME10", "welcome10", "Welcome10"} {
+			p, err := repo.GetPromotionByCode(ctx, code)
+			if err != nil {
+				t.Fatalf("GetPromotionByCode(%s): %v", code, err)
+			}
+			if p.ID != welcomePromotion || p.MaxUsesPerCustomer != 1 {
+				t.Errorf("GetPromotionByCode(%s) = %+v, want WELCOME10 for one use per customer", code, p)
+			}
+		}
+	})
+
+	t.Run("GetPromotionByCode doesn't find automatic promotions", func(t *testing.T) {
+		repo := newRepo(t)
+		for _, code := range []string{"", "NOPE"} {
+			if _, err := repo.GetPromotionByCode(ctx, code); !errors.Is(err, app.ErrPromotionNotFound) {
+				t.Errorf("GetPromotionByCode(%q) error = %v, want %v", code, err, app.ErrPromotionNotFound)
+			}
+		}
+	})
+
+	t.Run("GetAutomaticPromotions returns the promotions without a code", func(t *testing.T) {
+		promotions, err := newRepo(t).GetAutomaticPromotions(ctx)
+		if err != nil {
+			t.Fatalf("GetAutomaticPromotions: %v", err)
+		}
+		if len(promotions) != 1 || promotions[0].ID != 4 || promotions[0].Type != app.PromotionFreeShipping {
+			t.Fatalf("automatic promotions = %+v, want free shipping", promotions)
+		}
+		if promotions[0].MinimumSubtotal != app.EUR(5000) {
+			t.Errorf("MinimumSubtotal = %v, want %v", promotions[0].MinimumSubtotal, app.EUR(5000))
+		}
+	})
+
+	t.Run("returned promotions are copies", func(t *testing.T) {
+		repo := newRepo(t)
+		p, err := repo.GetPromotion(ctx, 3)
+		if err != nil {
+			t.Fatalf("GetPromotion: %v", err)
+		}
+		p.ProductIDs[0] = 2
+
+		p, err = repo.GetPromotion(ctx, 3)
+		if err != nil {
+			t.Fatalf("GetPromotion: %v", err)
+		}
+		if !reflect.DeepEqual(p.ProductIDs, []int{1}) {
+			t.Errorf("ProductIDs = %v after changing a copy, want [1]", p.ProductIDs)
+		}
+	})
+}
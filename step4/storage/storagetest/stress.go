@@ -0,0 +1,262 @@
+package storagetest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	app "github.com/gerbenjacobs/go-webshop-course"
+	"github.com/gerbenjacobs/go-webshop-course/storage"
+)
+
+const stressWorkers = 32
+
+// stressIterations is the number of rounds every worker does, with -short
+// there are fewer so the SQLite repositories are done quickly under -race
+func stressIterations() int {
+	if testing.Short() {
+		return 20
+	}
+	return 200
+}
+
+// StressBasketRepository hammers a single BasketRepository from many
+// goroutines at once, run it with `go test -race` to catch data races.
+// It also checks that no additions got lost along the way.
+func StressBasketRepository(t *testing.T, repo storage.BasketRepository) {
+	ctx := context.Background()
+	const userID, productID = 1, 1
+	iterations := stressIterations()
+
+	if _, err := repo.GetBasket(ctx, userID); err != nil {
+		t.Fatalf("GetBasket: %v", err)
+	}
+
+	var wg sync.WaitGroup
+	for w := 0; w < stressWorkers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			otherUserID := 100 + w
+			for i := 0; i < iterations; i++ {
+				if err := repo.AddToBasket(ctx, userID, productID, 0, 1); err != nil {
+					t.Errorf("AddToBasket: %v", err)
+					return
+				}
+
+				// mix in some traffic on other baskets and products
+				basket, err := repo.GetBasket(ctx, otherUserID)
+				if err != nil {
+					t.Errorf("GetBasket: %v", err)
+					return
+				}
+				for j := range basket.Items {
+					basket.Items[j].Quantity = -1 // must not leak into the repository
+				}
+				if err := repo.AddToBasket(ctx, otherUserID, 2, 0, 2); err != nil {
+					t.Errorf("AddToBasket: %v", err)
+					return
+				}
+				if err := repo.RemoveFromBasket(ctx, otherUserID, 2, 0, 1); err != nil {
+					t.Errorf("RemoveFromBasket: %v", err)
+					return
+				}
+				if err := repo.SetQuantity(ctx, otherUserID, 3, 0, i+1); err != nil {
+					t.Errorf("SetQuantity: %v", err)
+					return
+				}
+				if _, err := repo.GetBasket(ctx, userID); err != nil {
+					t.Errorf("GetBasket: %v", err)
+					return
+				}
+			}
+		}(w)
+	}
+	wg.Wait()
+
+	assertItems(t, repo, userID, []app.BasketItem{
+		{ProductID: productID, Quantity: stressWorkers * iterations},
+	})
+	for w := 0; w < stressWorkers; w++ {
+		assertItems(t, repo, 100+w, []app.BasketItem{
+			{ProductID: 2, Quantity: iterations},
+			{ProductID: 3, Quantity: iterations},
+		})
+	}
+}
+
+// StressProductRepository hammers the repositories with readers, admins who change and
+// delete products and customers who race for the last pink elephants, run it with
+// `go test -race` to catch data races. The repositories need the seed products.
+// It checks that the stock is never oversold: nothing is reserved that isn't there
+// and in the end exactly the paid items are gone.
+func StressProductRepository(t *testing.T, repos OrderRepos) {
+	ctx := context.Background()
+	iterations := stressIterations()
+
+	var (
+		wg   sync.WaitGroup
+		mu   sync.Mutex
+		paid int
+	)
+	for w := 0; w < stressWorkers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			userID := 100 + w
+			for i := 0; i < iterations; i++ {
+				var ok bool
+				switch w % 4 {
+				case 0:
+					ok = stressRead(t, repos.Products)
+				case 1:
+					ok = stressUpdate(t, repos.Products, w, i)
+				default:
+					var sold int
+					sold, ok = stressOrder(t, repos, userID, i)
+					mu.Lock()
+					paid += sold
+					mu.Unlock()
+				}
+				if !ok {
+					return
+				}
+			}
+		}(w)
+	}
+	wg.Wait()
+
+	if paid > pinkStock {
+		t.Errorf("%d pink elephants were paid for, there were only %d", paid, pinkStock)
+	}
+	product, err := repos.Products.GetProduct(ctx, 2)
+	if err != nil {
+		t.Fatalf("GetProduct: %v", err)
+	}
+	pink, err := product.Variant(pinkVariant)
+	if err != nil {
+		t.Fatalf("Variant: %v", err)
+	}
+	if pink.Stock != pinkStock-paid || pink.Reserved > pink.Stock {
+		t.Errorf("pink elephants: %d in stock and %d reserved after %d were paid for, want %d in stock and at most that reserved",
+			pink.Stock, pink.Reserved, paid, pinkStock-paid)
+	}
+	assertStock(t, repos.Products, 1, 0, gopherStock, 0)
+}
+
+// stressRead reads the products and reports false after failing the test,
+// also when more is reserved of a product than there is
+func stressRead(t *testing.T, repo storage.ProductRepository) bool {
+	products, err := repo.GetAllProducts(context.Background())
+	if err != nil {
+		t.Errorf("GetAllProducts: %v", err)
+		return false
+	}
+	elephant, err := repo.GetProduct(context.Background(), 2)
+	if err != nil {
+		t.Errorf("GetProduct: %v", err)
+		return false
+	}
+	for _, product := range append(products, elephant) {
+		if product.Stock < 0 || product.Reserved > product.Stock {
+			t.Errorf("product %d is oversold: %d in stock and %d reserved", product.ID, product.Stock, product.Reserved)
+			return false
+		}
+		for _, v := range product.Variants {
+			if v.Stock < 0 || v.Reserved > v.Stock {
+				t.Errorf("variant %d of product %d is oversold: %d in stock and %d reserved", v.ID, product.ID, v.Stock, v.Reserved)
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// stressUpdate renames the Gopher, leaving its stock be, and adds, changes and deletes a product of its own
+func stressUpdate(t *testing.T, repo storage.ProductRepository, w, i int) bool {
+	ctx := context.Background()
+	gopher, err := repo.GetProduct(ctx, 1)
+	if err != nil {
+		t.Errorf("GetProduct: %v", err)
+		return false
+	}
+	gopher.Name = fmt.Sprintf("Gopher plushie %d-%d", w, i)
+	if _, err := repo.UpdateProduct(ctx, gopher); err != nil {
+		t.Errorf("UpdateProduct: %v", err)
+		return false
+	}
+
+	product, err := repo.CreateProduct(ctx, app.Product{
+		Name:     "Limited edition",
+		Price:    app.EUR(999),
+		TaxClass: app.TaxClassStandard,
+		Stock:    1,
+		Variants: []app.Variant{{SKU: fmt.Sprintf("LIMITED-%d-%d", w, i), Stock: 1}},
+	})
+	if err != nil {
+		t.Errorf("CreateProduct: %v", err)
+		return false
+	}
+	product.Stock = 2
+	if _, err := repo.UpdateProduct(ctx, product); err != nil {
+		t.Errorf("UpdateProduct: %v", err)
+		return false
+	}
+	if err := repo.DeleteProduct(ctx, product.ID); err != nil {
+		t.Errorf("DeleteProduct: %v", err)
+		return false
+	}
+	return true
+}
+
+// stressOrder orders a pink elephant and then pays, releases or renews its reservation.
+// It returns how many it sold and reports false after failing the test.
+func stressOrder(t *testing.T, repos OrderRepos, userID, i int) (int, bool) {
+	ctx := context.Background()
+	if _, err := repos.Baskets.GetBasket(ctx, userID); err != nil {
+		t.Errorf("GetBasket: %v", err)
+		return 0, false
+	}
+	if err := repos.Baskets.AddToBasket(ctx, userID, 2, pinkVariant, 1); err != nil {
+		t.Errorf("AddToBasket: %v", err)
+		return 0, false
+	}
+	order := newOrder(userID, nil)
+	order.Items = []app.OrderItem{elephant(pinkVariant, 1)}
+	order, err := repos.Orders.CreateOrder(ctx, order)
+	if errors.Is(err, app.ErrInsufficientStock) {
+		// sold out for now, the basket is left as it was
+		if err := repos.Baskets.ClearBasket(ctx, userID); err != nil {
+			t.Errorf("ClearBasket: %v", err)
+			return 0, false
+		}
+		return 0, true
+	}
+	if err != nil {
+		t.Errorf("CreateOrder: %v", err)
+		return 0, false
+	}
+
+	switch i % 3 {
+	case 0:
+		order.Status = app.OrderStatusPaid
+		if err := repos.Orders.UpdateOrder(ctx, order, app.OrderStatusPendingPayment); err != nil {
+			t.Errorf("UpdateOrder: %v", err)
+			return 0, false
+		}
+		return 1, true
+	case 1:
+		// a reservation that ended is released
+		err = repos.Orders.ReserveStock(ctx, order.ID, time.Now().Add(-time.Minute))
+	default:
+		err = repos.Orders.ReserveStock(ctx, order.ID, inAnHour())
+	}
+	if err != nil && !errors.Is(err, app.ErrInsufficientStock) {
+		t.Errorf("ReserveStock: %v", err)
+		return 0, false
+	}
+	return 0, true
+}
@@ -0,0 +1,141 @@
+package storagetest
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	app "github.com/gerbenjacobs/go-webshop-course"
+	"github.com/gerbenjacobs/go-webshop-course/storage"
+)
+
+// TestUserRepository runs the user conformance suite,
+// newRepo is called for every subtest and should return a repository without users.
+func TestUserRepository(t *testing.T, newRepo func(t *testing.T) storage.UserRepository) {
+	ctx := context.Background()
+
+	t.Run("CreateUser gives every user an ID", func(t *testing.T) {
+		repo := newRepo(t)
+		ada := mustCreateUser(t, repo, "Ada Lovelace", "ada@example.com")
+		grace := mustCreateUser(t, repo, "Grace Hopper", "grace@example.com")
+		if ada.ID == 0 || grace.ID == ada.ID {
+			t.Fatalf("users got IDs %d and %d, want two different ones", ada.ID, grace.ID)
+		}
+
+		got, err := repo.GetUser(ctx, ada.ID)
+		if err != nil {
+			t.Fatalf("GetUser: %v", err)
+		}
+		assertUser(t, got, ada)
+	})
+
+	t.Run("email addresses are unique and ignore case", func(t *testing.T) {
+		repo := newRepo(t)
+		ada := mustCreateUser(t, repo, "Ada Lovelace", "ada@example.com")
+		if _, err := repo.CreateUser(ctx, newUser("Ada again", "ADA@example.com")); !errors.Is(err, app.ErrUserExists) {
+			t.Errorf("CreateUser with a taken email error = %v, want %v", err, app.ErrUserExists)
+		}
+
+		got, err := repo.GetUserByEmail(ctx, "Ada@Example.com")
+		if err != nil {
+			t.Fatalf("GetUserByEmail: %v", err)
+		}
+		assertUser(t, got, ada)
+	})
+
+	t.Run("GetUser and GetUserByEmail of an unknown user fail", func(t *testing.T) {
+		repo := newRepo(t)
+		mustCreateUser(t, repo, "Ada Lovelace", "ada@example.com")
+		if _, err := repo.GetUser(ctx, 99); !errors.Is(err, app.ErrUserNotFound) {
+			t.Errorf("GetUser error = %v, want %v", err, app.ErrUserNotFound)
+		}
+		if _, err := repo.GetUserByEmail(ctx, "grace@example.com"); !errors.Is(err, app.ErrUserNotFound) {
+			t.Errorf("GetUserByEmail error = %v, want %v", err, app.ErrUserNotFound)
+		}
+	})
+
+	t.Run("UpdateUser saves the name, password and role", func(t *testing.T) {
+		repo := newRepo(t)
+		ada := mustCreateUser(t, repo, "Ada Lovelace", "ada@example.com")
+		ada.Name, ada.PasswordHash, ada.Role = "Ada, Countess of Lovelace", "$2a$10$rehashed", app.RoleAdmin
+		if err := repo.UpdateUser(ctx, ada); err != nil {
+			t.Fatalf("UpdateUser: %v", err)
+		}
+		got, err := repo.GetUser(ctx, ada.ID)
+		if err != nil {
+			t.Fatalf("GetUser: %v", err)
+		}
+		assertUser(t, got, ada)
+
+		if err := repo.UpdateUser(ctx, app.User{ID: 99, Name: "Nobody", Email: "nobody@example.com"}); !errors.Is(err, app.ErrUserNotFound) {
+			t.Errorf("UpdateUser of an unknown user error = %v, want %v", err, app.ErrUserNotFound)
+		}
+	})
+
+	t.Run("SearchUsers matches the name or email, ignoring case", func(t *testing.T) {
+		repo := newRepo(t)
+		ada := mustCreateUser(t, repo, "Ada Lovelace", "ada@example.com")
+		grace := mustCreateUser(t, repo, "Grace Hopper", "grace@navy.mil")
+		underscore := mustCreateUser(t, repo, "Linus", "linus_t@example.com")
+
+		tests := []struct {
+			query string
+			want  []int
+		}{
+			{"", []int{ada.ID, grace.ID, underscore.ID}},
+			{"LOVE", []int{ada.ID}},
+			{"navy", []int{grace.ID}},
+			{"example.com", []int{ada.ID, underscore.ID}},
+			// LIKE wildcards are just characters
+			{"_", []int{underscore.ID}},
+			{"%", []int{}},
+			{"turing", []int{}},
+		}
+		for _, tt := range tests {
+			users, err := repo.SearchUsers(ctx, tt.query)
+			if err != nil {
+				t.Fatalf("SearchUsers(%q): %v", tt.query, err)
+			}
+			got := make([]int, len(users))
+			for i, u := range users {
+				got[i] = u.ID
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("SearchUsers(%q) = %v, want %v", tt.query, got, tt.want)
+			}
+		}
+	})
+}
+
+func newUser(name, email string) app.User {
+	return app.User{
+		Name:         name,
+		Email:        email,
+		PasswordHash: "$2a$10$hash",
+		Role:         app.RoleCustomer,
+		CreatedAt:    time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC),
+	}
+}
+
+func mustCreateUser(t *testing.T, repo storage.UserRepository, name, email string) app.User {
+	t.Helper()
+	user, err := repo.CreateUser(context.Background(), newUser(name, email))
+	if err != nil {
+		t.Fatalf("CreateUser(%s): %v", email, err)
+	}
+	return user
+}
+
+func assertUser(t *testing.T, got, want app.User) {
+	t.Helper()
+	// the time zone of CreatedAt depends on the storage, the moment doesn't
+	if !got.CreatedAt.Equal(want.CreatedAt) {
+		t.Errorf("CreatedAt = %v, want %v", got.CreatedAt, want.CreatedAt)
+	}
+	got.CreatedAt = want.CreatedAt
+	if got != want {
+		t.Errorf("user = %+v, want %+v", got, want)
+	}
+}
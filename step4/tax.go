@@ -0,0 +1,37 @@
+package go_webshop_course
+
+import (
+	"fmt"
+	"strings"
+)
+
+// TaxClass decides which of a country's tax rates applies to a product
+type TaxClass string
+
+const (
+	TaxClassStandard TaxClass = "standard"
+	TaxClassReduced  TaxClass = "reduced"
+	TaxClassZero     TaxClass = "zero"
+)
+
+func (c TaxClass) Valid() bool {
+	return c == TaxClassStandard || c == TaxClassReduced || c == TaxClassZero
+}
+
+// TaxRate is a percentage in basis points, so 21% is 2100
+type TaxRate int
+
+func (r TaxRate) String() string {
+	s := fmt.Sprintf("%d.%02d", r/100, r%100)
+	s = strings.TrimRight(strings.TrimRight(s, "0"), ".")
+	return s + "%"
+}
+
+// TaxLine is the tax of everything that's taxed at the same rate
+type TaxLine struct {
+	Class TaxClass `json:"class"`
+	Rate  TaxRate  `json:"rate_bps"`
+	// Base is the amount the tax is calculated over, excluding tax
+	Base   Money `json:"base"`
+	Amount Money `json:"amount"`
+}
@@ -0,0 +1,177 @@
+// Package tax calculates the VAT of a basket or order with per-country rates,
+// the rates are loaded from a JSON config file such as tax.json.
+package tax
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"slices"
+	"strings"
+
+	app "github.com/gerbenjacobs/go-webshop-course"
+)
+
+var (
+	ErrUnknownCountry = errors.New("unknown tax country")
+	ErrInvalidConfig  = errors.New("invalid tax config")
+)
+
+type Config struct {
+	// PricesIncludeTax means our product prices are gross prices, with tax included
+	PricesIncludeTax bool   `json:"prices_include_tax"`
+	DefaultCountry   string `json:"default_country"`
+	// Countries contains the rates in percent per tax class, by ISO 3166 country code.
+	// The zero tax class doesn't have to be configured.
+	Countries map[string]map[app.TaxClass]float64 `json:"countries"`
+}
+
+func LoadConfig(path string) (Config, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return Config{}, fmt.Errorf("failed to read tax config: %w", err)
+	}
+	var cfg Config
+	if err := json.Unmarshal(b, &cfg); err != nil {
+		return Config{}, fmt.Errorf("%w: %w", ErrInvalidConfig, err)
+	}
+	return cfg, nil
+}
+
+// Line is something to tax, its amount follows the PricesIncludeTax setting
+type Line struct {
+	Class  app.TaxClass
+	Amount app.Money
+}
+
+type Result struct {
+	// Lines has one line per tax rate, ordered from highest to lowest rate
+	Lines []app.TaxLine
+	Net   app.Money
+	Tax   app.Money
+	Gross app.Money
+}
+
+type Engine struct {
+	pricesIncludeTax bool
+	defaultCountry   string
+	rates            map[string]map[app.TaxClass]app.TaxRate
+}
+
+func NewEngine(cfg Config) (*Engine, error) {
+	e := &Engine{
+		pricesIncludeTax: cfg.PricesIncludeTax,
+		defaultCountry:   strings.ToUpper(cfg.DefaultCountry),
+		rates:            make(map[string]map[app.TaxClass]app.TaxRate, len(cfg.Countries)),
+	}
+	for country, classes := range cfg.Countries {
+		country = strings.ToUpper(country)
+		rates := map[app.TaxClass]app.TaxRate{app.TaxClassZero: 0}
+		for class, percent := range classes {
+			if class != app.TaxClassStandard && class != app.TaxClassReduced && class != app.TaxClassZero {
+				return nil, fmt.Errorf("%w: unknown tax class %q for %s", ErrInvalidConfig, class, country)
+			}
+			if percent < 0 || percent >= 100 {
+				return nil, fmt.Errorf("%w: %s rate for %s should be between 0 and 100", ErrInvalidConfig, class, country)
+			}
+			rates[class] = app.TaxRate(math.Round(percent * 100))
+		}
+		if _, ok := rates[app.TaxClassStandard]; !ok {
+			return nil, fmt.Errorf("%w: %s has no standard rate", ErrInvalidConfig, country)
+		}
+		if _, ok := rates[app.TaxClassReduced]; !ok {
+			return nil, fmt.Errorf("%w: %s has no reduced rate", ErrInvalidConfig, country)
+		}
+		e.rates[country] = rates
+	}
+	if _, ok := e.rates[e.defaultCountry]; !ok {
+		return nil, fmt.Errorf("%w: default country %q has no rates", ErrInvalidConfig, cfg.DefaultCountry)
+	}
+	return e, nil
+}
+
+func (e *Engine) PricesIncludeTax() bool {
+	return e.pricesIncludeTax
+}
+
+func (e *Engine) DefaultCountry() string {
+	return e.defaultCountry
+}
+
+// Countries returns the country codes we have rates for, sorted
+func (e *Engine) Countries() []string {
+	countries := make([]string, 0, len(e.rates))
+	for country := range e.rates {
+		countries = append(countries, country)
+	}
+	slices.Sort(countries)
+	return countries
+}
+
+// Calculate returns the tax over the lines for customers in the given country,
+// an empty country means our default country. Tax is rounded once per rate,
+// not per line, so many cheap lines don't add up rounding errors.
+func (e *Engine) Calculate(country string, lines []Line) (Result, error) {
+	if country == "" {
+		country = e.defaultCountry
+	}
+	rates, ok := e.rates[strings.ToUpper(country)]
+	if !ok {
+		return Result{}, fmt.Errorf("%w: %q", ErrUnknownCountry, country)
+	}
+
+	// add up everything that's taxed at the same rate
+	type group struct {
+		class  app.TaxClass
+		amount app.Money
+	}
+	groups := map[app.TaxRate]*group{}
+	var total app.Money
+	for _, line := range lines {
+		class := line.Class
+		if class == "" {
+			class = app.TaxClassStandard
+		}
+		rate, ok := rates[class]
+		if !ok {
+			return Result{}, fmt.Errorf("unknown tax class %q", line.Class)
+		}
+		if groups[rate] == nil {
+			groups[rate] = &group{class: class}
+		}
+		groups[rate].amount = groups[rate].amount.Add(line.Amount)
+		total = total.Add(line.Amount)
+	}
+
+	res := Result{Lines: make([]app.TaxLine, 0, len(groups))}
+	for rate, g := range groups {
+		tl := app.TaxLine{Class: g.class, Rate: rate}
+		if e.pricesIncludeTax {
+			tl.Amount = app.NewMoney(divRound(g.amount.Amount*int64(rate), 10000+int64(rate)), g.amount.Currency)
+			tl.Base = g.amount.Sub(tl.Amount)
+		} else {
+			tl.Base = g.amount
+			tl.Amount = app.NewMoney(divRound(g.amount.Amount*int64(rate), 10000), g.amount.Currency)
+		}
+		res.Lines = append(res.Lines, tl)
+		res.Net = res.Net.Add(tl.Base)
+		res.Tax = res.Tax.Add(tl.Amount)
+	}
+	slices.SortFunc(res.Lines, func(a, b app.TaxLine) int {
+		return int(b.Rate - a.Rate)
+	})
+	res.Net = app.NewMoney(res.Net.Amount, total.Currency)
+	res.Tax = app.NewMoney(res.Tax.Amount, total.Currency)
+	res.Gross = res.Net.Add(res.Tax)
+	return res, nil
+}
+
+// divRound divides and rounds half away from zero
+func divRound(a, b int64) int64 {
+	if (a < 0) != (b < 0) {
+		return (a - b/2) / b
+	}
+	return (a + b/2) / b
+}
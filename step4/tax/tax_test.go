@@ -0,0 +1,222 @@
+package tax_test
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	app "github.com/gerbenjacobs/go-webshop-course"
+	"github.com/gerbenjacobs/go-webshop-course/tax"
+)
+
+func newEngine(t *testing.T, pricesIncludeTax bool) *tax.Engine {
+	t.Helper()
+	e, err := tax.NewEngine(tax.Config{
+		PricesIncludeTax: pricesIncludeTax,
+		DefaultCountry:   "NL",
+		Countries: map[string]map[app.TaxClass]float64{
+			"NL": {app.TaxClassStandard: 21, app.TaxClassReduced: 9},
+			"fr": {app.TaxClassStandard: 20, app.TaxClassReduced: 5.5},
+			// everything that isn't zero rated is taxed the same
+			"XX": {app.TaxClassStandard: 10, app.TaxClassReduced: 10},
+		},
+	})
+	if err != nil {
+		t.Fatalf("NewEngine: %v", err)
+	}
+	return e
+}
+
+func TestCalculate(t *testing.T) {
+	tests := []struct {
+		name             string
+		pricesIncludeTax bool
+		country          string
+		lines            []tax.Line
+		want             []app.TaxLine
+		wantNet          app.Money
+		wantTax          app.Money
+	}{
+		{
+			name:    "net prices round half away from zero",
+			country: "NL",
+			lines:   []tax.Line{{Class: app.TaxClassStandard, Amount: app.EUR(50)}},
+			want: []app.TaxLine{
+				{Class: app.TaxClassStandard, Rate: 2100, Base: app.EUR(50), Amount: app.EUR(11)},
+			},
+			wantNet: app.EUR(50),
+			wantTax: app.EUR(11),
+		},
+		{
+			name:    "negative net amounts round half away from zero",
+			country: "NL",
+			lines:   []tax.Line{{Class: app.TaxClassStandard, Amount: app.EUR(-50)}},
+			want: []app.TaxLine{
+				{Class: app.TaxClassStandard, Rate: 2100, Base: app.EUR(-50), Amount: app.EUR(-11)},
+			},
+			wantNet: app.EUR(-50),
+			wantTax: app.EUR(-11),
+		},
+		{
+			name:    "tax is rounded per rate, not per line",
+			country: "NL",
+			lines: []tax.Line{
+				{Class: app.TaxClassStandard, Amount: app.EUR(3)},
+				{Class: app.TaxClassStandard, Amount: app.EUR(3)},
+				{Class: app.TaxClassStandard, Amount: app.EUR(3)},
+			},
+			want: []app.TaxLine{
+				{Class: app.TaxClassStandard, Rate: 2100, Base: app.EUR(9), Amount: app.EUR(2)},
+			},
+			wantNet: app.EUR(9),
+			wantTax: app.EUR(2),
+		},
+		{
+			name:    "lines are ordered from the highest rate down",
+			country: "NL",
+			lines: []tax.Line{
+				{Class: app.TaxClassZero, Amount: app.EUR(500)},
+				{Class: app.TaxClassReduced, Amount: app.EUR(1000)},
+				{Amount: app.EUR(2000)},
+			},
+			want: []app.TaxLine{
+				{Class: app.TaxClassStandard, Rate: 2100, Base: app.EUR(2000), Amount: app.EUR(420)},
+				{Class: app.TaxClassReduced, Rate: 900, Base: app.EUR(1000), Amount: app.EUR(90)},
+				{Class: app.TaxClassZero, Rate: 0, Base: app.EUR(500), Amount: app.EUR(0)},
+			},
+			wantNet: app.EUR(3500),
+			wantTax: app.EUR(510),
+		},
+		{
+			name:    "classes with the same rate share a line",
+			country: "XX",
+			lines: []tax.Line{
+				{Class: app.TaxClassReduced, Amount: app.EUR(1000)},
+				{Class: app.TaxClassStandard, Amount: app.EUR(1005)},
+			},
+			want: []app.TaxLine{
+				{Class: app.TaxClassReduced, Rate: 1000, Base: app.EUR(2005), Amount: app.EUR(201)},
+			},
+			wantNet: app.EUR(2005),
+			wantTax: app.EUR(201),
+		},
+		{
+			name:             "gross prices take the tax out",
+			pricesIncludeTax: true,
+			country:          "NL",
+			lines: []tax.Line{
+				{Class: app.TaxClassStandard, Amount: app.EUR(1299)},
+				{Class: app.TaxClassReduced, Amount: app.EUR(1000)},
+			},
+			want: []app.TaxLine{
+				{Class: app.TaxClassStandard, Rate: 2100, Base: app.EUR(1074), Amount: app.EUR(225)},
+				{Class: app.TaxClassReduced, Rate: 900, Base: app.EUR(917), Amount: app.EUR(83)},
+			},
+			wantNet: app.EUR(1991),
+			wantTax: app.EUR(308),
+		},
+		{
+			name:             "gross prices with a fractional rate",
+			pricesIncludeTax: true,
+			country:          "FR",
+			lines:            []tax.Line{{Class: app.TaxClassReduced, Amount: app.EUR(1055)}},
+			want: []app.TaxLine{
+				{Class: app.TaxClassReduced, Rate: 550, Base: app.EUR(1000), Amount: app.EUR(55)},
+			},
+			wantNet: app.EUR(1000),
+			wantTax: app.EUR(55),
+		},
+		{
+			name:    "an empty country is the default country",
+			country: "",
+			lines:   []tax.Line{{Class: app.TaxClassReduced, Amount: app.EUR(100)}},
+			want: []app.TaxLine{
+				{Class: app.TaxClassReduced, Rate: 900, Base: app.EUR(100), Amount: app.EUR(9)},
+			},
+			wantNet: app.EUR(100),
+			wantTax: app.EUR(9),
+		},
+		{
+			name:    "country codes ignore case",
+			country: "fr",
+			lines:   []tax.Line{{Class: app.TaxClassStandard, Amount: app.EUR(100)}},
+			want: []app.TaxLine{
+				{Class: app.TaxClassStandard, Rate: 2000, Base: app.EUR(100), Amount: app.EUR(20)},
+			},
+			wantNet: app.EUR(100),
+			wantTax: app.EUR(20),
+		},
+		{
+			name:    "nothing to tax",
+			country: "NL",
+			want:    []app.TaxLine{},
+			wantNet: app.Money{},
+			wantTax: app.Money{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res, err := newEngine(t, tt.pricesIncludeTax).Calculate(tt.country, tt.lines)
+			if err != nil {
+				t.Fatalf("Calculate: %v", err)
+			}
+			if !reflect.DeepEqual(res.Lines, tt.want) {
+				t.Errorf("Lines = %+v, want %+v", res.Lines, tt.want)
+			}
+			if res.Net != tt.wantNet || res.Tax != tt.wantTax {
+				t.Errorf("Net = %v and Tax = %v, want %v and %v", res.Net, res.Tax, tt.wantNet, tt.wantTax)
+			}
+			if res.Gross != res.Net.Add(res.Tax) {
+				t.Errorf("Gross = %v, want Net + Tax = %v", res.Gross, res.Net.Add(res.Tax))
+			}
+		})
+	}
+}
+
+func TestCalculateErrors(t *testing.T) {
+	e := newEngine(t, true)
+	if _, err := e.Calculate("US", nil); !errors.Is(err, tax.ErrUnknownCountry) {
+		t.Errorf("Calculate for US error = %v, want %v", err, tax.ErrUnknownCountry)
+	}
+	if _, err := e.Calculate("NL", []tax.Line{{Class: "luxury", Amount: app.EUR(100)}}); err == nil {
+		t.Error("Calculate with an unknown tax class didn't fail")
+	}
+}
+
+func TestNewEngine(t *testing.T) {
+	tests := []struct {
+		name      string
+		countries map[string]map[app.TaxClass]float64
+	}{
+		{"no standard rate", map[string]map[app.TaxClass]float64{"NL": {app.TaxClassReduced: 9}}},
+		{"no reduced rate", map[string]map[app.TaxClass]float64{"NL": {app.TaxClassStandard: 21}}},
+		{"unknown class", map[string]map[app.TaxClass]float64{"NL": {app.TaxClassStandard: 21, app.TaxClassReduced: 9, "luxury": 30}}},
+		{"rate of 100%", map[string]map[app.TaxClass]float64{"NL": {app.TaxClassStandard: 100, app.TaxClassReduced: 9}}},
+		{"negative rate", map[string]map[app.TaxClass]float64{"NL": {app.TaxClassStandard: 21, app.TaxClassReduced: -1}}},
+		{"default country without rates", map[string]map[app.TaxClass]float64{"BE": {app.TaxClassStandard: 21, app.TaxClassReduced: 6}}},
+	}
+	for _, tt := range tests {
+		_, err := tax.NewEngine(tax.Config{DefaultCountry: "NL", Countries: tt.countries})
+		if !errors.Is(err, tax.ErrInvalidConfig) {
+			t.Errorf("%s: NewEngine error = %v, want %v", tt.name, err, tax.ErrInvalidConfig)
+		}
+	}
+}
+
+func TestLoadConfig(t *testing.T) {
+	cfg, err := tax.LoadConfig("../tax.json")
+	if err != nil {
+		t.Fatalf("LoadConfig: %v", err)
+	}
+	e, err := tax.NewEngine(cfg)
+	if err != nil {
+		t.Fatalf("NewEngine: %v", err)
+	}
+	if !e.PricesIncludeTax() || e.DefaultCountry() != "NL" {
+		t.Errorf("engine has PricesIncludeTax %t and default country %s, want gross prices in NL", e.PricesIncludeTax(), e.DefaultCountry())
+	}
+	want := []string{"BE", "DE", "FR", "IE", "LU", "NL"}
+	if got := e.Countries(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Countries = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,14 @@
+package go_webshop_course
+
+import "errors"
+
+var ErrInvalidToken = errors.New("invalid token")
+
+// TokenPair is what we hand out to API clients, the access token is
+// short-lived and the refresh token can be exchanged for a new pair
+type TokenPair struct {
+	AccessToken  string `json:"access_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int    `json:"expires_in"`
+	RefreshToken string `json:"refresh_token"`
+}
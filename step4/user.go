@@ -0,0 +1,35 @@
+package go_webshop_course
+
+import (
+	"errors"
+	"time"
+)
+
+var (
+	ErrUserNotFound       = errors.New("user not found")
+	ErrUserExists         = errors.New("user already exists")
+	ErrInvalidCredentials = errors.New("invalid email or password")
+	ErrInvalidEmail       = errors.New("invalid email address")
+	ErrPasswordTooShort   = errors.New("password is too short")
+)
+
+// Role decides what a user is allowed to do, customers shop and admins run the shop
+type Role string
+
+const (
+	RoleCustomer Role = "customer"
+	RoleAdmin    Role = "admin"
+)
+
+type User struct {
+	ID           int       `json:"id"`
+	Name         string    `json:"name"`
+	Email        string    `json:"email"`
+	PasswordHash string    `json:"-"`
+	Role         Role      `json:"role"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+func (u User) IsAdmin() bool {
+	return u.Role == RoleAdmin
+}
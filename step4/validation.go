@@ -0,0 +1,43 @@
+package go_webshop_course
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+)
+
+var ErrInvalidInput = errors.New("invalid input")
+
+// FieldErrors tells what's wrong with the input, by field name.
+// Nested fields are named like "variants[1].sku".
+type FieldErrors map[string]string
+
+// Add records the problem with a field, the first problem of a field wins
+func (fe FieldErrors) Add(field, format string, args ...any) {
+	if _, ok := fe[field]; !ok {
+		fe[field] = fmt.Sprintf(format, args...)
+	}
+}
+
+// Err returns the field errors as an error, or nil when there are none
+func (fe FieldErrors) Err() error {
+	if len(fe) == 0 {
+		return nil
+	}
+	return fe
+}
+
+func (fe FieldErrors) Error() string {
+	problems := make([]string, 0, len(fe))
+	for _, field := range slices.Sorted(maps.Keys(fe)) {
+		problems = append(problems, field+": "+fe[field])
+	}
+	return ErrInvalidInput.Error() + ": " + strings.Join(problems, ", ")
+}
+
+// Is makes errors.Is(err, ErrInvalidInput) work for field errors
+func (fe FieldErrors) Is(target error) bool {
+	return target == ErrInvalidInput
+}